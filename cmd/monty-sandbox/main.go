@@ -0,0 +1,17 @@
+// Command monty-sandbox is the child process behind monty.Sandbox. It speaks
+// the sandbox protocol on stdin and stdout and exits when stdin closes.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+func main() {
+	if err := monty.ServeSandbox(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "monty-sandbox:", err)
+		os.Exit(1)
+	}
+}
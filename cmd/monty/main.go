@@ -0,0 +1,329 @@
+// Command monty compiles and runs a script outside any host service, printing
+// each progress event as a JSON line. Paused runs can be answered
+// interactively or dumped to a file and resumed later:
+//
+//	monty run -i x=41 -ext fetch script.py
+//	monty run -i x=41 -ext fetch -dump run.bin script.py
+//	monty resume -call 1 -result '{"ok":true}' run.bin
+//	monty repl -mocks mocks.json
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = runCmd(os.Args[2:])
+	case "resume":
+		err = resumeCmd(os.Args[2:])
+	case "repl":
+		err = replCmd(os.Args[2:])
+	case "version":
+		info := monty.Version()
+		fmt.Printf("wrapper %s, library %s, abi %d\n", info.Wrapper, info.Library, info.ABI)
+	default:
+		usage()
+	}
+	if err != nil {
+		msg := err.Error()
+		if !strings.HasPrefix(msg, "monty:") {
+			msg = "monty: " + msg
+		}
+		fmt.Fprintln(os.Stderr, msg)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: monty run [flags] script.py | monty resume [flags] snapshot | monty repl [-mocks file] | monty version")
+	os.Exit(2)
+}
+
+// listFlag collects a repeatable flag.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// driver continues a run past each pause, either from stdin or by dumping it.
+type driver struct {
+	dump   string
+	prompt *bufio.Reader
+	// out receives the events; drive defaults it to stdout.
+	out *json.Encoder
+}
+
+func (d *driver) register(fs *flag.FlagSet) {
+	fs.StringVar(&d.dump, "dump", "", "on the first pause, write the snapshot to `file` and exit")
+}
+
+func runCmd(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var inputs, exts listFlag
+	fs.Var(&inputs, "i", "input as `name=json`; repeatable")
+	fs.Var(&exts, "ext", "declare an external `function`; repeatable")
+	inputsFile := fs.String("inputs", "", "read inputs from a JSON object in `file` (- for stdin)")
+	var d driver
+	d.register(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("run needs exactly one script file")
+	}
+	script := fs.Arg(0)
+	code, err := os.ReadFile(script)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]json.RawMessage)
+	if *inputsFile != "" {
+		data, err := readInput(*inputsFile)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &values); err != nil {
+			return fmt.Errorf("inputs: %w", err)
+		}
+	}
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	for _, in := range inputs {
+		name, value, ok := strings.Cut(in, "=")
+		if !ok || !json.Valid([]byte(value)) {
+			return fmt.Errorf("input %q is not name=json", in)
+		}
+		if _, seen := values[name]; !seen {
+			names = append(names, name)
+		}
+		values[name] = json.RawMessage(value)
+	}
+	ordered := make([]any, len(names))
+	for i, name := range names {
+		ordered[i] = values[name]
+	}
+
+	m, err := monty.New(string(code), script, names, exts)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	progress, err := m.Start(ordered...)
+	if err != nil {
+		return err
+	}
+	if *inputsFile != "-" {
+		d.prompt = bufio.NewReader(os.Stdin)
+	}
+	return d.drive(progress)
+}
+
+func resumeCmd(args []string) error {
+	fs := flag.NewFlagSet("resume", flag.ExitOnError)
+	future := fs.Bool("future", false, "the snapshot is a FutureSnapshot; -result is a JSON list of {call_id, result, error}")
+	callID := fs.Uint("call", 0, "call `id` the snapshot is paused on")
+	result := fs.String("result", "null", "`json` value to resume with")
+	errMsg := fs.String("error", "", "raise `message` in the script instead of returning a result")
+	var d driver
+	d.register(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("resume needs exactly one snapshot file")
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	d.prompt = bufio.NewReader(os.Stdin)
+
+	var progress monty.Progress
+	if *future {
+		fut, err := monty.FutureSnapshotFromBytes(data)
+		if err != nil {
+			return err
+		}
+		results, err := parseFutureResults(*result)
+		if err != nil {
+			return err
+		}
+		progress, err = fut.Resume(results)
+		if err != nil {
+			return err
+		}
+		return d.drive(progress)
+	}
+	snap, err := monty.SnapshotFromBytes(data)
+	if err != nil {
+		return err
+	}
+	if *errMsg != "" {
+		progress, err = snap.ResumeError(uint32(*callID), *errMsg)
+	} else if !json.Valid([]byte(*result)) {
+		return fmt.Errorf("result %q is not valid JSON", *result)
+	} else {
+		progress, err = snap.Resume(uint32(*callID), json.RawMessage(*result))
+	}
+	if err != nil {
+		return err
+	}
+	return d.drive(progress)
+}
+
+// drive prints events until the run completes, answering pauses from stdin
+// or dumping the first one.
+func (d *driver) drive(progress monty.Progress) error {
+	if d.out == nil {
+		d.out = json.NewEncoder(os.Stdout)
+	}
+	for {
+		d.print(progress)
+		switch progress.Kind {
+		case monty.Complete:
+			return nil
+		case monty.Timer:
+			time.Sleep(progress.Duration)
+			next, err := progress.Snapshot.Wake(progress.CallID)
+			if err != nil {
+				return err
+			}
+			progress = next
+			continue
+		}
+		if d.dump != "" {
+			return d.dumpProgress(progress)
+		}
+		next, err := d.answer(progress)
+		if err != nil {
+			return err
+		}
+		progress = next
+	}
+}
+
+func (d *driver) dumpProgress(p monty.Progress) error {
+	var data []byte
+	var err error
+	if p.FutureSnapshot != nil {
+		data, err = p.FutureSnapshot.Dump()
+	} else {
+		data, err = p.Snapshot.Dump()
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(d.dump, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "monty: paused on call %d; snapshot written to %s\n", p.CallID, d.dump)
+	return nil
+}
+
+// answer reads one JSON line from stdin: a value, {"error": "..."} to raise,
+// or for futures a list of {call_id, result, error}.
+func (d *driver) answer(p monty.Progress) (monty.Progress, error) {
+	if d.prompt == nil {
+		return monty.Progress{}, errors.New("run paused but stdin holds the inputs; use -dump")
+	}
+	fmt.Fprint(os.Stderr, "> ")
+	line, err := d.prompt.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return monty.Progress{}, fmt.Errorf("reading answer: %w", err)
+	}
+	line = strings.TrimSpace(line)
+	if p.Kind == monty.ResolveFutures {
+		results, err := parseFutureResults(line)
+		if err != nil {
+			return monty.Progress{}, err
+		}
+		return p.FutureSnapshot.Resume(results)
+	}
+	var raise struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal([]byte(line), &raise) == nil && raise.Error != "" {
+		return p.Snapshot.ResumeError(p.CallID, raise.Error)
+	}
+	if !json.Valid([]byte(line)) {
+		return monty.Progress{}, fmt.Errorf("answer %q is not valid JSON", line)
+	}
+	return p.Snapshot.Resume(p.CallID, json.RawMessage(line))
+}
+
+// parseFutureResults decodes a JSON list of {call_id, result, error}.
+func parseFutureResults(s string) ([]monty.FutureResult, error) {
+	var items []struct {
+		CallID uint32          `json:"call_id"`
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(s), &items); err != nil {
+		return nil, fmt.Errorf("future results: %w", err)
+	}
+	results := make([]monty.FutureResult, len(items))
+	for i, item := range items {
+		results[i] = monty.FutureResult{CallID: item.CallID, Err: item.Error}
+		if len(item.Result) > 0 {
+			results[i].Result = item.Result
+		}
+	}
+	return results, nil
+}
+
+func (d *driver) print(p monty.Progress) {
+	ev := map[string]any{"kind": p.Kind.String()}
+	switch p.Kind {
+	case monty.Complete:
+		ev["result"] = json.RawMessage(p.Result)
+	case monty.FunctionCall, monty.OsCall:
+		name := p.FunctionName
+		if p.Kind == monty.OsCall {
+			name = p.OsFunction
+		}
+		args := make([]json.RawMessage, len(p.Args))
+		for i, a := range p.Args {
+			args[i] = json.RawMessage(a)
+		}
+		kwargs := make(map[string]json.RawMessage, len(p.Kwargs))
+		for _, kv := range p.Kwargs {
+			var key string
+			if kv.Key.Unmarshal(&key) != nil {
+				key = string(kv.Key)
+			}
+			kwargs[key] = json.RawMessage(kv.Value)
+		}
+		ev["call_id"], ev["name"], ev["args"], ev["kwargs"] = p.CallID, name, args, kwargs
+	case monty.ResolveFutures:
+		ev["pending_call_ids"] = p.PendingIDs
+	case monty.Timer:
+		ev["call_id"], ev["duration"] = p.CallID, p.Duration.String()
+	}
+	d.out.Encode(ev)
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
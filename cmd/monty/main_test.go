@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+// callBridge is a sandbox bridge whose programs call add_one(5) and complete
+// with the answer.
+func callBridge() *monty.Sandbox {
+	return monty.NewSandboxBridge(func(data []byte) ([]byte, error) {
+		var req struct {
+			Op      string          `json:"op"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		json.Unmarshal(data, &req)
+		switch req.Op {
+		case "compile":
+			return []byte(`{"handle":1}`), nil
+		case "start":
+			return []byte(`{"progress":{"kind":1,"call_id":1,"function_name":"add_one","args":[5],"kwargs":[["step",1]],"snapshot":2}}`), nil
+		case "resume":
+			return json.Marshal(map[string]any{"progress": map[string]any{"kind": 0, "result": req.Payload}})
+		case "dump_snapshot":
+			return []byte(`{"data":"c3RhdGU="}`), nil
+		}
+		return []byte(`{}`), nil
+	})
+}
+
+func start(t *testing.T) monty.Progress {
+	t.Helper()
+	m, err := monty.New("add_one(5, step=1)", "main.py", nil, []string{"add_one"}, monty.WithSandbox(callBridge()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDriveAnswersFromStdin(t *testing.T) {
+	var out bytes.Buffer
+	d := driver{prompt: bufio.NewReader(strings.NewReader("6\n")), out: json.NewEncoder(&out)}
+	if err := d.drive(start(t)); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"args":[5],"call_id":1,"kind":"function_call","kwargs":{"step":1},"name":"add_one"}` + "\n" +
+		`{"kind":"complete","result":6}` + "\n"
+	if out.String() != want {
+		t.Fatalf("events:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestDriveDumpsFirstPause(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.bin")
+	d := driver{dump: path, out: json.NewEncoder(&bytes.Buffer{})}
+	if err := d.drive(start(t)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := monty.ReadDumpInfo(data); err != nil || info.Kind != monty.SnapshotHandle {
+		t.Fatalf("dump header = %+v, %v", info, err)
+	}
+}
+
+func TestDriveRejectsBadAnswers(t *testing.T) {
+	d := driver{out: json.NewEncoder(&bytes.Buffer{})}
+	if err := d.drive(start(t)); err == nil || !strings.Contains(err.Error(), "use -dump") {
+		t.Fatalf("a pause without stdin should ask for -dump, got %v", err)
+	}
+	d.prompt = bufio.NewReader(strings.NewReader("{oops\n"))
+	if err := d.drive(start(t)); err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Fatalf("expected invalid JSON to be rejected, got %v", err)
+	}
+}
+
+func TestParseFutureResults(t *testing.T) {
+	results, err := parseFutureResults(`[{"call_id":1,"result":2},{"call_id":3,"error":"ValueError: x"}]`)
+	if err != nil || len(results) != 2 || string(results[0].Result.(json.RawMessage)) != "2" || results[1].Err != "ValueError: x" {
+		t.Fatalf("parseFutureResults = %+v, %v", results, err)
+	}
+	if _, err := parseFutureResults(`{}`); err == nil {
+		t.Fatal("expected a non-list to be rejected")
+	}
+}
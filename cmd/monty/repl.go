@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+// mock is the canned answer for an external function in a -mocks file:
+//
+//	{"fetch": {"result": {"status": 200}}, "delete_all": {"error": "not allowed"}}
+type mock struct {
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+func replCmd(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	mocks := fs.String("mocks", "", "register mock external functions from a JSON `file`")
+	fs.Parse(args)
+
+	session := monty.NewSession()
+	if *mocks != "" {
+		data, err := os.ReadFile(*mocks)
+		if err != nil {
+			return err
+		}
+		var defs map[string]mock
+		if err := json.Unmarshal(data, &defs); err != nil {
+			return fmt.Errorf("mocks: %w", err)
+		}
+		for name, def := range defs {
+			session.Register(name, mockHandler(name, def))
+		}
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	ctx := context.Background()
+	for {
+		snippet, ok := readSnippet(in)
+		if !ok {
+			return in.Err()
+		}
+		switch strings.TrimSpace(snippet) {
+		case "":
+			continue
+		case ":reset":
+			session.Reset()
+			continue
+		case ":quit":
+			return nil
+		}
+		result, err := session.Eval(ctx, snippet)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		if s := string(result); s != "" && s != "null" {
+			fmt.Println(s)
+		}
+	}
+}
+
+func mockHandler(name string, def mock) monty.Handler {
+	return func(_ context.Context, call monty.CallInfo) (any, error) {
+		fmt.Fprintf(os.Stderr, "[mock %s(%s)]\n", name, joinArgs(call.Args))
+		if def.Error != "" {
+			return nil, errors.New(def.Error)
+		}
+		if len(def.Result) == 0 {
+			return nil, nil
+		}
+		return def.Result, nil
+	}
+}
+
+// readSnippet reads one line, or a block when the line opens one with a
+// trailing colon; the block ends at the first blank line.
+func readSnippet(in *bufio.Scanner) (string, bool) {
+	fmt.Fprint(os.Stderr, ">>> ")
+	if !in.Scan() {
+		return "", false
+	}
+	line := in.Text()
+	if !strings.HasSuffix(strings.TrimSpace(line), ":") {
+		return line, true
+	}
+	lines := []string{line}
+	for {
+		fmt.Fprint(os.Stderr, "... ")
+		if !in.Scan() || strings.TrimSpace(in.Text()) == "" {
+			return strings.Join(lines, "\n"), true
+		}
+		lines = append(lines, in.Text())
+	}
+}
+
+func joinArgs(args []monty.Object) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = string(a)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,257 @@
+// Command montygen generates typed Go bindings for a script's external
+// functions from a Python stub file declaring their signatures:
+//
+//	# externals.pyi
+//	def fetch(url: str, retries: int = 3) -> dict:
+//	    """Fetch a URL."""
+//
+// From a go:generate directive such as
+//
+//	//go:generate go run github.com/ricochet1k/monty-go/cmd/montygen -pkg bindings -o externals_gen.go externals.pyi
+//
+// it writes an Externals interface with one method per function, the list
+// of external function names to pass to monty.New, and a RegisterExternals
+// function that installs an implementation on a monty.Runner through
+// Runner.RegisterFunc, so arguments arrive converted and bad calls raise
+// TypeError in the script.
+//
+// Annotations map to Go types as str→string, int→int, float→float64,
+// bool→bool, list[T]→[]T, and T | None or Optional[T]→*T; results also map
+// dict[str, T]→map[string]T. Parameters with a default become optional and
+// arrive as pointers, nil when omitted. Other parameter types arrive as
+// monty.Object and other results are returned as any.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+func main() {
+	pkg := flag.String("pkg", os.Getenv("GOPACKAGE"), "package name of the generated file")
+	out := flag.String("o", "", "output file (default stdout)")
+	iface := flag.String("type", "Externals", "name of the generated interface")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: montygen [-pkg name] [-type Externals] [-o file.go] stubs.pyi")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	src, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	code, err := generate(*pkg, *iface, filepath.Base(flag.Arg(0)), monty.ParseStubs(string(src)))
+	if err != nil {
+		fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := os.WriteFile(*out, code, 0o644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "montygen:", err)
+	os.Exit(1)
+}
+
+// generate renders the bindings for funcs as formatted Go source.
+func generate(pkg, iface, source string, funcs []monty.FunctionDoc) ([]byte, error) {
+	if len(funcs) == 0 {
+		return nil, fmt.Errorf("%s declares no functions", source)
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by montygen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	b.WriteString("import (\n\t\"context\"\n\n\t\"github.com/ricochet1k/monty-go/pkg/monty\"\n)\n\n")
+
+	fmt.Fprintf(&b, "// %s answers the external functions declared in %s.\n", iface, source)
+	fmt.Fprintf(&b, "type %s interface {\n", iface)
+	seen := make(map[string]string)
+	for i, fn := range funcs {
+		method := exportName(fn.Name)
+		if prev, ok := seen[method]; ok {
+			return nil, fmt.Errorf("%s and %s both map to method %s", prev, fn.Name, method)
+		}
+		seen[method] = fn.Name
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "\t// %s implements %s.\n", method, fn.Signature())
+		if fn.Doc != "" {
+			b.WriteString("\t//\n")
+			for _, line := range strings.Split(fn.Doc, "\n") {
+				b.WriteString(strings.TrimRight("\t// "+line, " ") + "\n")
+			}
+		}
+		params := []string{"ctx context.Context"}
+		for _, p := range fn.Params {
+			if strings.HasPrefix(p.Name, "*") {
+				return nil, fmt.Errorf("%s: variadic parameter %s is not supported", fn.Name, p.Name)
+			}
+			params = append(params, goIdent(p.Name)+" "+paramType(p))
+		}
+		results := "error"
+		if ret := returnType(fn.Returns); ret != "" {
+			results = "(" + ret + ", error)"
+		}
+		fmt.Fprintf(&b, "\t%s(%s) %s\n", method, strings.Join(params, ", "), results)
+	}
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "// %sNames lists the external functions of %s, for monty.New.\n", iface, iface)
+	fmt.Fprintf(&b, "var %sNames = []string{", iface)
+	for i, fn := range funcs {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%q", fn.Name)
+	}
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "// Register%s installs the methods of impl as handlers on r.\n", iface)
+	fmt.Fprintf(&b, "func Register%s(r *monty.Runner, impl %s) error {\n", iface, iface)
+	for _, fn := range funcs {
+		fmt.Fprintf(&b, "\tif err := r.RegisterFunc(%q, impl.%s", fn.Name, exportName(fn.Name))
+		for _, p := range fn.Params {
+			if p.Default != "" {
+				fmt.Fprintf(&b, ", monty.Param{Name: %q, Optional: true}", p.Name)
+			} else {
+				fmt.Fprintf(&b, ", monty.Param{Name: %q}", p.Name)
+			}
+		}
+		b.WriteString("); err != nil {\n\t\treturn err\n\t}\n")
+	}
+	b.WriteString("\treturn nil\n}\n")
+	return format.Source(b.Bytes())
+}
+
+// paramType maps a parameter annotation to a Go type; optional parameters
+// become pointers so implementations can tell an omitted argument apart.
+func paramType(p monty.ParamDoc) string {
+	t := goType(p.Annotation, false)
+	if p.Default != "" && !strings.HasPrefix(t, "*") && !nillable(t) {
+		t = "*" + t
+	}
+	return t
+}
+
+func returnType(ann string) string {
+	switch strings.TrimSpace(ann) {
+	case "None":
+		return ""
+	case "":
+		return "any"
+	}
+	if t := goType(ann, true); t != "monty.Object" {
+		return t
+	}
+	return "any"
+}
+
+func nillable(t string) bool {
+	return strings.HasPrefix(t, "[]") || strings.HasPrefix(t, "map[") || t == "monty.Object" || t == "any"
+}
+
+// goType maps a Python annotation to the Go type a script's argument decodes
+// into, or, for results, the type a handler returns.
+func goType(ann string, result bool) string {
+	t, err := monty.ParseType(ann)
+	if err != nil {
+		return "monty.Object"
+	}
+	return goTypeOf(t, result)
+}
+
+func goTypeOf(t monty.TypeExpr, result bool) string {
+	if inner, ok := t.Optional(); ok {
+		g := goTypeOf(inner, result)
+		if nillable(g) {
+			return g
+		}
+		return "*" + g
+	}
+	if t.Args == nil {
+		switch t.Name {
+		case "str":
+			return "string"
+		case "int":
+			return "int"
+		case "float":
+			return "float64"
+		case "bool":
+			return "bool"
+		}
+		return "monty.Object"
+	}
+	switch {
+	case (t.Name == "list" || t.Name == "List" || t.Name == "Sequence") && len(t.Args) == 1 && !t.Ellipsis:
+		if elem := goTypeOf(t.Args[0], result); elem != "monty.Object" {
+			return "[]" + elem
+		}
+		return "[]monty.Object"
+	case (t.Name == "dict" || t.Name == "Dict" || t.Name == "Mapping") && len(t.Args) == 2 && t.Args[0].Name == "str" && t.Args[0].Args == nil && result:
+		// Dict arguments reach the host as tagged pair lists that only
+		// Objects hold, but maps encode as dicts on the way back.
+		if elem := goTypeOf(t.Args[1], true); elem != "monty.Object" {
+			return "map[string]" + elem
+		}
+		return "map[string]any"
+	}
+	return "monty.Object"
+}
+
+// exportName converts snake_case to an exported Go name.
+func exportName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
+
+// goIdent converts a parameter name to a Go identifier that does not collide
+// with the ctx parameter or a keyword.
+func goIdent(name string) string {
+	parts := strings.Split(strings.Trim(name, "_"), "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	id := strings.Join(parts, "")
+	switch id {
+	case "", "ctx", "break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough",
+		"for", "func", "go", "goto", "if", "import", "interface", "map", "package", "range", "return",
+		"select", "struct", "switch", "type", "var", "monty", "context":
+		return id + "_"
+	}
+	return id
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+func TestGenerate(t *testing.T) {
+	funcs := monty.ParseStubs(`
+def fetch(url: str, retries: int = 3, headers: dict | None = None) -> dict[str, int]:
+    """Fetch a URL."""
+
+def log(msg: str, *, level: str = "info") -> None: ...
+def scores(ids: list[int], type: Optional[float]) -> list[float]: ...
+`)
+	code, err := generate("bindings", "Externals", "ext.pyi", funcs)
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	for _, want := range []string{
+		"Fetch(ctx context.Context, url string, retries *int, headers monty.Object) (map[string]int, error)",
+		"Log(ctx context.Context, msg string, level *string) error",
+		"Scores(ctx context.Context, ids []int, type_ *float64) ([]float64, error)",
+		`var ExternalsNames = []string{"fetch", "log", "scores"}`,
+		`r.RegisterFunc("log", impl.Log, monty.Param{Name: "msg"}, monty.Param{Name: "level", Optional: true})`,
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("generated code lacks %q:\n%s", want, code)
+		}
+	}
+
+	if _, err := generate("bindings", "Externals", "bad.pyi", monty.ParseStubs("def f(*args): ...")); err == nil {
+		t.Error("variadic parameters should be rejected")
+	}
+}
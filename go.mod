@@ -1,3 +1,3 @@
 module github.com/ricochet1k/monty-go
 
-go 1.21
+go 1.23
@@ -7,8 +7,73 @@
 #include <stdint.h>
 #include <stdlib.h>
 
+#define MONTY_PROGRESS_COMPLETE 0
+
+#define MONTY_PROGRESS_FUNCTION_CALL 1
+
+#define MONTY_PROGRESS_OS_CALL 2
+
+#define MONTY_PROGRESS_RESOLVE_FUTURES 3
+
+/**
+ * Error kinds of MontyStatus: failures other than the resource limits below.
+ */
+#define MONTY_ERROR_OTHER 0
+
+/**
+ * The run exceeded its time limit.
+ */
+#define MONTY_ERROR_TIMEOUT 1
+
+/**
+ * The run exceeded its memory limit.
+ */
+#define MONTY_ERROR_MEMORY 2
+
+/**
+ * The run exceeded its recursion depth.
+ */
+#define MONTY_ERROR_RECURSION 3
+
+/**
+ * Bumped whenever a function signature or struct layout in the header changes.
+ */
+#define MONTY_FFI_ABI_VERSION 3
+
+/**
+ * Allocator statistics of the library since it was loaded. Counts are
+ * updated without synchronization between fields, so a snapshot taken while
+ * other threads allocate may be off by the allocations in flight.
+ */
+typedef struct MontyMemStats {
+  /**
+   * Bytes allocated in total, including bytes since freed.
+   */
+  uint64_t total_allocated;
+  /**
+   * Bytes currently allocated.
+   */
+  uint64_t live_bytes;
+  /**
+   * Highest value live_bytes has reached.
+   */
+  uint64_t peak_bytes;
+  /**
+   * Number of allocations made.
+   */
+  uint64_t allocations;
+  /**
+   * Number of allocations freed.
+   */
+  uint64_t frees;
+} MontyMemStats;
+
 typedef struct MontyStatus {
   int32_t ok;
+  /**
+   * One of the MONTY_ERROR_* constants when ok is 0.
+   */
+  int32_t error_kind;
   char *error;
 } MontyStatus;
 
@@ -38,6 +103,21 @@ typedef struct ProgressResult {
   struct FutureSnapshotHandle *future_snapshot;
 } ProgressResult;
 
+/**
+ * Returns the ABI version this library was built with.
+ */
+uint32_t monty_ffi_abi_version(void);
+
+/**
+ * Returns the library version as a static string the caller must not free.
+ */
+const char *monty_ffi_version(void);
+
+/**
+ * Returns the library's allocator statistics.
+ */
+struct MontyMemStats monty_mem_stats(void);
+
 struct MontyStatus monty_run_new(const char *code,
                                  const char *script_name,
                                  const char *const *input_names,
@@ -70,6 +150,8 @@ struct MontyStatus monty_snapshot_dump(struct SnapshotHandle *snapshot,
                                        uint8_t **out_bytes,
                                        size_t *out_len);
 
+struct MontyStatus monty_snapshot_size(struct SnapshotHandle *snapshot, size_t *out_len);
+
 struct MontyStatus monty_snapshot_load(const uint8_t *bytes,
                                        size_t len,
                                        struct SnapshotHandle **out);
@@ -78,6 +160,14 @@ struct MontyStatus monty_future_snapshot_dump(struct FutureSnapshotHandle *snaps
                                               uint8_t **out_bytes,
                                               size_t *out_len);
 
+struct MontyStatus monty_future_snapshot_size(struct FutureSnapshotHandle *snapshot,
+                                              size_t *out_len);
+
+struct MontyStatus monty_future_snapshot_dump_pending(struct FutureSnapshotHandle *snapshot,
+                                                      uint8_t **out_bytes,
+                                                      size_t *out_len,
+                                                      char **out_pending_json);
+
 struct MontyStatus monty_future_snapshot_load(const uint8_t *bytes,
                                               size_t len,
                                               struct FutureSnapshotHandle **out);
@@ -0,0 +1,94 @@
+//! Global allocator that counts what the library allocates, so hosts can tell
+//! native heap growth apart from their own.
+
+use std::alloc::{GlobalAlloc, Layout, System};
+use std::sync::atomic::{AtomicU64, Ordering::Relaxed};
+
+struct Counting;
+
+static TOTAL_ALLOCATED: AtomicU64 = AtomicU64::new(0);
+static LIVE_BYTES: AtomicU64 = AtomicU64::new(0);
+static PEAK_BYTES: AtomicU64 = AtomicU64::new(0);
+static ALLOCATIONS: AtomicU64 = AtomicU64::new(0);
+static FREES: AtomicU64 = AtomicU64::new(0);
+
+#[global_allocator]
+static ALLOCATOR: Counting = Counting;
+
+fn grow(size: usize) {
+    let size = size as u64;
+    TOTAL_ALLOCATED.fetch_add(size, Relaxed);
+    let live = LIVE_BYTES.fetch_add(size, Relaxed) + size;
+    PEAK_BYTES.fetch_max(live, Relaxed);
+}
+
+fn shrink(size: usize) {
+    LIVE_BYTES.fetch_sub(size as u64, Relaxed);
+}
+
+unsafe impl GlobalAlloc for Counting {
+    unsafe fn alloc(&self, layout: Layout) -> *mut u8 {
+        let p = System.alloc(layout);
+        if !p.is_null() {
+            ALLOCATIONS.fetch_add(1, Relaxed);
+            grow(layout.size());
+        }
+        p
+    }
+
+    unsafe fn alloc_zeroed(&self, layout: Layout) -> *mut u8 {
+        let p = System.alloc_zeroed(layout);
+        if !p.is_null() {
+            ALLOCATIONS.fetch_add(1, Relaxed);
+            grow(layout.size());
+        }
+        p
+    }
+
+    unsafe fn dealloc(&self, ptr: *mut u8, layout: Layout) {
+        System.dealloc(ptr, layout);
+        FREES.fetch_add(1, Relaxed);
+        shrink(layout.size());
+    }
+
+    unsafe fn realloc(&self, ptr: *mut u8, layout: Layout, new_size: usize) -> *mut u8 {
+        let p = System.realloc(ptr, layout, new_size);
+        if !p.is_null() {
+            if new_size > layout.size() {
+                grow(new_size - layout.size());
+            } else {
+                shrink(layout.size() - new_size);
+            }
+        }
+        p
+    }
+}
+
+/// Allocator statistics of the library since it was loaded. Counts are
+/// updated without synchronization between fields, so a snapshot taken while
+/// other threads allocate may be off by the allocations in flight.
+#[repr(C)]
+pub struct MontyMemStats {
+    /// Bytes allocated in total, including bytes since freed.
+    pub total_allocated: u64,
+    /// Bytes currently allocated.
+    pub live_bytes: u64,
+    /// Highest value live_bytes has reached.
+    pub peak_bytes: u64,
+    /// Number of allocations made.
+    pub allocations: u64,
+    /// Number of allocations freed.
+    pub frees: u64,
+}
+
+/// Returns the library's allocator statistics.
+#[no_mangle]
+pub extern "C" fn monty_mem_stats() -> MontyMemStats {
+    MontyMemStats {
+        total_allocated: TOTAL_ALLOCATED.load(Relaxed),
+        live_bytes: LIVE_BYTES.load(Relaxed),
+        peak_bytes: PEAK_BYTES.load(Relaxed),
+        allocations: ALLOCATIONS.load(Relaxed),
+        frees: FREES.load(Relaxed),
+    }
+}
@@ -4,13 +4,24 @@ use std::{
     ptr,
 };
 
-use monty::MontyException;
+use monty::{ExcType, MontyException};
 use thiserror::Error;
 
+/// Error kinds of MontyStatus: failures other than the resource limits below.
+pub const MONTY_ERROR_OTHER: i32 = 0;
+/// The run exceeded its time limit.
+pub const MONTY_ERROR_TIMEOUT: i32 = 1;
+/// The run exceeded its memory limit.
+pub const MONTY_ERROR_MEMORY: i32 = 2;
+/// The run exceeded its recursion depth.
+pub const MONTY_ERROR_RECURSION: i32 = 3;
+
 #[repr(C)]
 #[derive(Debug, Clone, Copy)]
 pub struct MontyStatus {
     pub ok: i32,
+    /// One of the MONTY_ERROR_* constants when ok is 0.
+    pub error_kind: i32,
     pub error: *mut c_char,
 }
 
@@ -18,6 +29,7 @@ impl MontyStatus {
     pub fn success() -> Self {
         Self {
             ok: 1,
+            error_kind: MONTY_ERROR_OTHER,
             error: ptr::null_mut(),
         }
     }
@@ -28,6 +40,7 @@ impl MontyStatus {
             .unwrap_or_else(|_| CString::new("monty-ffi error").unwrap());
         Self {
             ok: 0,
+            error_kind: err.kind(),
             error: c_string.into_raw(),
         }
     }
@@ -39,6 +52,9 @@ pub type FfiResult<T> = Result<T, FfiError>;
 pub enum FfiError {
     #[error("{0}")]
     Message(String),
+    /// An exception that ended the run, with its MONTY_ERROR_* kind.
+    #[error("{message}")]
+    Exception { kind: i32, message: String },
     #[error("null pointer for {0}")]
     NullPointer(&'static str),
     #[error("{field} is not valid UTF-8")]
@@ -47,9 +63,28 @@ pub enum FfiError {
     InteriorNul { field: &'static str },
 }
 
+impl FfiError {
+    /// Returns the MONTY_ERROR_* kind of the error.
+    pub fn kind(&self) -> i32 {
+        match self {
+            Self::Exception { kind, .. } => *kind,
+            _ => MONTY_ERROR_OTHER,
+        }
+    }
+}
+
 impl From<MontyException> for FfiError {
     fn from(exc: MontyException) -> Self {
-        Self::Message(exc.summary())
+        let kind = match exc.exc_type() {
+            ExcType::TimeoutError => MONTY_ERROR_TIMEOUT,
+            ExcType::MemoryError => MONTY_ERROR_MEMORY,
+            ExcType::RecursionError => MONTY_ERROR_RECURSION,
+            _ => MONTY_ERROR_OTHER,
+        };
+        Self::Exception {
+            kind,
+            message: exc.summary(),
+        }
     }
 }
 
@@ -13,6 +13,7 @@ const EXCEPTION_TAG: &str = "$exception";
 const REPR_TAG: &str = "$repr";
 const PATH_TAG: &str = "$path";
 const BIGINT_TAG: &str = "$bigint";
+const FLOAT_TAG: &str = "$float";
 const DATACLASS_TAG: &str = "$dataclass";
 const NAMED_TUPLE_TAG: &str = "$named_tuple";
 
@@ -128,6 +129,16 @@ fn object_map_to_object(mut map: Map<String, Value>) -> FfiResult<MontyObject> {
             _ => Err(FfiError::Message("$bigint must be a string".into())),
         };
     }
+    if let Some(token) = map.remove(FLOAT_TAG) {
+        return match token.as_str() {
+            Some("nan") => Ok(MontyObject::Float(f64::NAN)),
+            Some("inf") => Ok(MontyObject::Float(f64::INFINITY)),
+            Some("-inf") => Ok(MontyObject::Float(f64::NEG_INFINITY)),
+            _ => Err(FfiError::Message(
+                "$float must be \"nan\", \"inf\", or \"-inf\"".into(),
+            )),
+        };
+    }
     if let Some(path) = map.remove(PATH_TAG) {
         return match path {
             Value::String(p) => Ok(MontyObject::Path(p)),
@@ -282,7 +293,7 @@ fn object_to_value(obj: &MontyObject) -> FfiResult<Value> {
         MontyObject::None => Value::Null,
         MontyObject::Bool(b) => Value::Bool(*b),
         MontyObject::Int(i) => Value::Number((*i).into()),
-        MontyObject::Float(f) => json!(f),
+        MontyObject::Float(f) => encode_float(*f),
         MontyObject::String(s) => Value::String(s.clone()),
         MontyObject::Bytes(bytes) => {
             let mut outer = Map::new();
@@ -415,6 +426,24 @@ fn object_to_value(obj: &MontyObject) -> FfiResult<Value> {
     })
 }
 
+/// JSON has no NaN or infinities, so they travel as {"$float": "nan"},
+/// {"$float": "inf"}, and {"$float": "-inf"}.
+fn encode_float(f: f64) -> Value {
+    if f.is_finite() {
+        return json!(f);
+    }
+    let token = if f.is_nan() {
+        "nan"
+    } else if f > 0.0 {
+        "inf"
+    } else {
+        "-inf"
+    };
+    let mut outer = Map::new();
+    outer.insert(FLOAT_TAG.into(), Value::String(token.into()));
+    Value::Object(outer)
+}
+
 fn encode_collection(tag: &str, items: &[MontyObject]) -> FfiResult<Value> {
     let mut outer = Map::new();
     outer.insert(
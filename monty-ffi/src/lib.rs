@@ -1,3 +1,4 @@
+mod alloc;
 mod error;
 mod json;
 
@@ -15,8 +16,8 @@ use monty::{
     ExcType, ExternalResult, FutureSnapshot, MontyException, MontyRun, NoLimitTracker, PrintWriter,
     RunProgress, Snapshot,
 };
-use postcard::{from_bytes, to_allocvec};
-use serde::Deserialize;
+use postcard::{from_bytes, ser_flavors, serialize_with_flavor, to_allocvec};
+use serde::{Deserialize, Serialize};
 use serde_json::Value;
 
 #[repr(C)]
@@ -123,6 +124,21 @@ pub const MONTY_PROGRESS_FUNCTION_CALL: i32 = 1;
 pub const MONTY_PROGRESS_OS_CALL: i32 = 2;
 pub const MONTY_PROGRESS_RESOLVE_FUTURES: i32 = 3;
 
+/// Bumped whenever a function signature or struct layout in the header changes.
+pub const MONTY_FFI_ABI_VERSION: u32 = 3;
+
+/// Returns the ABI version this library was built with.
+#[no_mangle]
+pub extern "C" fn monty_ffi_abi_version() -> u32 {
+    MONTY_FFI_ABI_VERSION
+}
+
+/// Returns the library version as a static string the caller must not free.
+#[no_mangle]
+pub extern "C" fn monty_ffi_version() -> *const c_char {
+    concat!(env!("CARGO_PKG_VERSION"), "\0").as_ptr() as *const c_char
+}
+
 #[derive(Debug, Deserialize)]
 struct FutureResultJson {
     call_id: u32,
@@ -300,7 +316,7 @@ pub unsafe extern "C" fn monty_snapshot_resume(
             return Err(FfiError::NullPointer("snapshot"));
         }
         let resolution = if let Some(err) = unsafe { read_optional_str(error_message)? } {
-            ExternalResult::Error(MontyException::new(ExcType::RuntimeError, Some(err)))
+            ExternalResult::Error(host_exception(err))
         } else if let Some(json) = unsafe { read_optional_str(result_json)? } {
             ExternalResult::Return(decode_object(&json)?)
         } else {
@@ -371,6 +387,24 @@ pub unsafe extern "C" fn monty_snapshot_dump(
     }
 }
 
+/// Computes the length of the bytes `monty_snapshot_dump` would return,
+/// without allocating them.
+#[no_mangle]
+pub unsafe extern "C" fn monty_snapshot_size(
+    snapshot: *mut SnapshotHandle,
+    out_len: *mut usize,
+) -> MontyStatus {
+    fn inner(snapshot: *mut SnapshotHandle, out_len: *mut usize) -> FfiResult<()> {
+        let snapshot = unsafe { snapshot.as_ref().ok_or(FfiError::NullPointer("snapshot"))? };
+        write_size(snapshot.as_ref(), out_len)
+    }
+
+    match inner(snapshot, out_len) {
+        Ok(()) => MontyStatus::success(),
+        Err(err) => MontyStatus::from_error(err),
+    }
+}
+
 #[no_mangle]
 pub unsafe extern "C" fn monty_snapshot_load(
     bytes: *const u8,
@@ -420,6 +454,62 @@ pub unsafe extern "C" fn monty_future_snapshot_dump(
     }
 }
 
+/// Computes the length of the bytes `monty_future_snapshot_dump` would
+/// return, without allocating them.
+#[no_mangle]
+pub unsafe extern "C" fn monty_future_snapshot_size(
+    snapshot: *mut FutureSnapshotHandle,
+    out_len: *mut usize,
+) -> MontyStatus {
+    fn inner(snapshot: *mut FutureSnapshotHandle, out_len: *mut usize) -> FfiResult<()> {
+        let snapshot = unsafe { snapshot.as_ref().ok_or(FfiError::NullPointer("snapshot"))? };
+        write_size(snapshot.as_ref(), out_len)
+    }
+
+    match inner(snapshot, out_len) {
+        Ok(()) => MontyStatus::success(),
+        Err(err) => MontyStatus::from_error(err),
+    }
+}
+
+/// Dumps the future snapshot like `monty_future_snapshot_dump` and writes
+/// the JSON array of the call IDs it waits on to `out_pending_json`, so the
+/// host can dispatch the calls and persist the state from one read of it.
+#[no_mangle]
+pub unsafe extern "C" fn monty_future_snapshot_dump_pending(
+    snapshot: *mut FutureSnapshotHandle,
+    out_bytes: *mut *mut u8,
+    out_len: *mut usize,
+    out_pending_json: *mut *mut c_char,
+) -> MontyStatus {
+    fn inner(
+        snapshot: *mut FutureSnapshotHandle,
+        out_bytes: *mut *mut u8,
+        out_len: *mut usize,
+        out_pending_json: *mut *mut c_char,
+    ) -> FfiResult<()> {
+        let snapshot = unsafe { snapshot.as_ref().ok_or(FfiError::NullPointer("snapshot"))? };
+        if out_pending_json.is_null() {
+            return Err(FfiError::NullPointer("out_pending_json"));
+        }
+        let bytes = to_allocvec(snapshot.as_ref())?;
+        let pending = to_c_string(encode_u32_slice(snapshot.pending_ids())?, "pending_call_ids")?;
+        if let Err(err) = write_bytes(bytes, out_bytes, out_len) {
+            unsafe { monty_free_string(pending) };
+            return Err(err);
+        }
+        unsafe {
+            *out_pending_json = pending;
+        }
+        Ok(())
+    }
+
+    match inner(snapshot, out_bytes, out_len, out_pending_json) {
+        Ok(()) => MontyStatus::success(),
+        Err(err) => MontyStatus::from_error(err),
+    }
+}
+
 #[no_mangle]
 pub unsafe extern "C" fn monty_future_snapshot_load(
     bytes: *const u8,
@@ -472,6 +562,17 @@ pub unsafe extern "C" fn monty_free_bytes(ptr: *mut u8, len: usize) {
     }
 }
 
+fn write_size<T: Serialize>(value: &T, out_len: *mut usize) -> FfiResult<()> {
+    if out_len.is_null() {
+        return Err(FfiError::NullPointer("out_len"));
+    }
+    let size = serialize_with_flavor(value, ser_flavors::Size::default())?;
+    unsafe {
+        *out_len = size;
+    }
+    Ok(())
+}
+
 fn write_bytes(bytes: Vec<u8>, out_bytes: *mut *mut u8, out_len: *mut usize) -> FfiResult<()> {
     if out_bytes.is_null() {
         return Err(FfiError::NullPointer("out_bytes"));
@@ -510,6 +611,25 @@ unsafe fn read_string_array(
     Ok(values)
 }
 
+/// Converts a host error message into the exception raised in the script.
+/// Messages prefixed "TypeError: ", "ValueError: ", "KeyError: ", or
+/// "OverflowError: " raise that type so hosts can report bad arguments,
+/// missing keys, and out-of-range values at the call site; anything else is a
+/// RuntimeError.
+fn host_exception(message: String) -> MontyException {
+    for (prefix, exc_type) in [
+        ("TypeError: ", ExcType::TypeError),
+        ("ValueError: ", ExcType::ValueError),
+        ("KeyError: ", ExcType::KeyError),
+        ("OverflowError: ", ExcType::OverflowError),
+    ] {
+        if let Some(rest) = message.strip_prefix(prefix) {
+            return MontyException::new(exc_type, Some(rest.to_owned()));
+        }
+    }
+    MontyException::new(ExcType::RuntimeError, Some(message))
+}
+
 fn decode_future_results(json: &str) -> FfiResult<Vec<(u32, ExternalResult)>> {
     let raw: Vec<FutureResultJson> = serde_json::from_str(json)?;
     raw.into_iter()
@@ -517,7 +637,7 @@ fn decode_future_results(json: &str) -> FfiResult<Vec<(u32, ExternalResult)>> {
             if let Some(err) = entry.error.filter(|s| !s.is_empty()) {
                 return Ok((
                     entry.call_id,
-                    ExternalResult::Error(MontyException::new(ExcType::RuntimeError, Some(err))),
+                    ExternalResult::Error(host_exception(err)),
                 ));
             }
             if let Some(value) = entry.result {
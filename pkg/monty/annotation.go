@@ -0,0 +1,203 @@
+package monty
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TypeExpr is a Python type annotation, as read by ParseType. It records the
+// syntax only; what a name means is up to the caller.
+type TypeExpr struct {
+	// Name is the type's name without its module prefix, such as "int",
+	// "List", or "Optional". Unions written with | are named "|", with the
+	// alternatives in Args.
+	Name string
+	// Args holds the bracketed type arguments, nil when there are none.
+	// Ellipsis is set when the last argument was `...`, as in
+	// tuple[int, ...], and is not included in Args.
+	Args     []TypeExpr
+	Ellipsis bool
+	// Values holds the arguments of Literal[...]: strings, int64s, bools,
+	// and nils.
+	Values []any
+}
+
+// ParseType parses a Python type annotation such as `dict[str, list[int]]`,
+// `Optional[float]`, `int | None`, or `Literal["a", 1]`. A quoted forward
+// reference is unquoted first.
+func ParseType(annotation string) (TypeExpr, error) {
+	p := &typeParser{toks: tokenizeType(annotation)}
+	t, err := p.union()
+	if err == nil && p.pos < len(p.toks) {
+		err = fmt.Errorf("unexpected %q", p.toks[p.pos])
+	}
+	if err != nil {
+		return TypeExpr{}, newError(ErrInvalidInput, fmt.Sprintf("monty: annotation %q: %v", annotation, err))
+	}
+	return t, nil
+}
+
+// Optional returns T for Optional[T], T | None, and None | T.
+func (t TypeExpr) Optional() (TypeExpr, bool) {
+	switch {
+	case t.Name == "Optional" && len(t.Args) == 1:
+		return t.Args[0], true
+	case t.Name != "|" || len(t.Args) != 2:
+		return TypeExpr{}, false
+	case t.Args[1].Name == "None":
+		return t.Args[0], true
+	case t.Args[0].Name == "None":
+		return t.Args[1], true
+	}
+	return TypeExpr{}, false
+}
+
+type typeParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *typeParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *typeParser) expect(tok string) error {
+	if p.peek() != tok {
+		if p.pos >= len(p.toks) {
+			return fmt.Errorf("expected %q at end", tok)
+		}
+		return fmt.Errorf("expected %q, got %q", tok, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+// union parses A | B | ...
+func (p *typeParser) union() (TypeExpr, error) {
+	t, err := p.term()
+	if err != nil || p.peek() != "|" {
+		return t, err
+	}
+	u := TypeExpr{Name: "|", Args: []TypeExpr{t}}
+	for p.peek() == "|" {
+		p.pos++
+		t, err := p.term()
+		if err != nil {
+			return TypeExpr{}, err
+		}
+		u.Args = append(u.Args, t)
+	}
+	return u, nil
+}
+
+// term parses a name and its bracketed arguments, if any.
+func (p *typeParser) term() (TypeExpr, error) {
+	tok := p.peek()
+	switch {
+	case tok == "":
+		return TypeExpr{}, fmt.Errorf("unexpected end")
+	case strings.IndexByte("[],|'\"", tok[0]) >= 0 || tok == "...":
+		return TypeExpr{}, fmt.Errorf("unexpected %q", tok)
+	}
+	p.pos++
+	t := TypeExpr{Name: tok[strings.LastIndexByte(tok, '.')+1:]}
+	if p.peek() != "[" {
+		return t, nil
+	}
+	if t.Name == "Literal" {
+		values, err := p.literals()
+		t.Values = values
+		return t, err
+	}
+	p.pos++
+	t.Args = []TypeExpr{}
+	for {
+		if p.peek() == "..." {
+			p.pos++
+			t.Ellipsis = true
+			break
+		}
+		arg, err := p.union()
+		if err != nil {
+			return TypeExpr{}, err
+		}
+		t.Args = append(t.Args, arg)
+		if p.peek() != "," {
+			break
+		}
+		p.pos++
+	}
+	return t, p.expect("]")
+}
+
+// literals parses the values of Literal[...].
+func (p *typeParser) literals() ([]any, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	var values []any
+	for {
+		tok := p.peek()
+		p.pos++
+		var v any
+		switch {
+		case tok == "True" || tok == "False":
+			v = tok == "True"
+		case tok == "None":
+			v = nil
+		case len(tok) >= 2 && (tok[0] == '"' || tok[0] == '\'') && tok[len(tok)-1] == tok[0]:
+			v = tok[1 : len(tok)-1]
+		default:
+			n, err := strconv.ParseInt(tok, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unsupported literal %q", tok)
+			}
+			v = n
+		}
+		values = append(values, v)
+		if p.peek() != "," {
+			break
+		}
+		p.pos++
+	}
+	return values, p.expect("]")
+}
+
+// tokenizeType splits an annotation into names, literals, and punctuation.
+// Quoted forward references such as "int" are unquoted first.
+func tokenizeType(src string) []string {
+	src = strings.TrimSpace(src)
+	if len(src) >= 2 && (src[0] == '"' || src[0] == '\'') && src[len(src)-1] == src[0] {
+		src = src[1 : len(src)-1]
+	}
+	var toks []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.HasPrefix(src[i:], "..."):
+			toks = append(toks, "...")
+			i += 3
+		case c == '"' || c == '\'':
+			end := stringEnd(src, i)
+			toks = append(toks, src[i:end])
+			i = end
+		case strings.IndexByte("[],|", c) >= 0:
+			toks = append(toks, string(c))
+			i++
+		default:
+			j := i
+			for j < len(src) && strings.IndexByte(" \t[],|'\"", src[j]) < 0 {
+				j++
+			}
+			toks = append(toks, src[i:j])
+			i = j
+		}
+	}
+	return toks
+}
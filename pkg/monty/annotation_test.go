@@ -0,0 +1,34 @@
+package monty
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseType(t *testing.T) {
+	for ann, want := range map[string]TypeExpr{
+		"int":                    {Name: "int"},
+		"typing.Dict[str, bool]": {Name: "Dict", Args: []TypeExpr{{Name: "str"}, {Name: "bool"}}},
+		"tuple[int, ...]":        {Name: "tuple", Args: []TypeExpr{{Name: "int"}}, Ellipsis: true},
+		"int | None":             {Name: "|", Args: []TypeExpr{{Name: "int"}, {Name: "None"}}},
+		`Literal["a", 2, True]`:  {Name: "Literal", Values: []any{"a", int64(2), true}},
+		"'list[str]'":            {Name: "list", Args: []TypeExpr{{Name: "str"}}},
+	} {
+		got, err := ParseType(ann)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseType(%q) = %+v, %v, want %+v", ann, got, err, want)
+		}
+	}
+	for _, ann := range []string{"", "list[int", "int |", `Literal["a`, "dict[str, int]]"} {
+		if _, err := ParseType(ann); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("ParseType(%q) = %v, want ErrInvalidInput", ann, err)
+		}
+	}
+	for ann, want := range map[string]string{"Optional[int]": "int", "None | str": "str", "int | str": ""} {
+		typ, _ := ParseType(ann)
+		if inner, ok := typ.Optional(); inner.Name != want || ok != (want != "") {
+			t.Errorf("%q.Optional() = %+v, %v", ann, inner, ok)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package monty
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// WithAsyncCalls makes a Runner promote calls to the named external
+// functions to futures: the handler runs in the background while the script
+// carries on, and the script receives the result once it waits on it, so
+// independent calls overlap without the script managing them. When the run
+// pauses at ResolveFutures, the Runner resumes it with the results that have
+// arrived, waiting for the first if none has. Handlers of promoted functions
+// run concurrently with each other and with the run. Promoted calls in
+// flight when a run is suspended by Shutdown are lost, and a run waiting only
+// on futures the Runner did not promote fails with a *DeadlockError.
+func WithAsyncCalls(names ...string) Option {
+	return func(c *config) {
+		c.asyncCalls = make(map[string]bool, len(names))
+		for _, name := range names {
+			c.asyncCalls[name] = true
+		}
+	}
+}
+
+// DeadlockError reports a run waiting on futures that the Runner can never
+// resolve, because none of PendingIDs is a call it promoted whose handler is
+// still running or whose result it holds. Such futures come from calls the
+// Runner did not make, so it returns this error rather than wait forever.
+type DeadlockError struct {
+	PendingIDs []uint32
+}
+
+func (e *DeadlockError) Error() string {
+	return fmt.Sprintf("monty: run waits on futures %v that no handler is resolving", e.PendingIDs)
+}
+
+// Unwrap classifies deadlocks as ErrDeadlock.
+func (e *DeadlockError) Unwrap() error {
+	return ErrDeadlock
+}
+
+// asyncCalls tracks the promoted calls of one run.
+type asyncCalls struct {
+	results chan FutureResult
+	// done is closed when the run returns, releasing handlers whose results
+	// are no longer awaited.
+	done chan struct{}
+	// running holds the IDs of calls whose handler has not sent its result,
+	// and arrived the results the run has not waited on yet.
+	running map[uint32]bool
+	arrived []FutureResult
+}
+
+func newAsyncCalls() *asyncCalls {
+	return &asyncCalls{
+		results: make(chan FutureResult),
+		done:    make(chan struct{}),
+		running: make(map[uint32]bool),
+	}
+}
+
+// receive records a result sent by a handler.
+func (a *asyncCalls) receive(result FutureResult) {
+	delete(a.running, result.CallID)
+	a.arrived = append(a.arrived, result)
+}
+
+// awaited reports whether a handler is still running for one of ids.
+func (a *asyncCalls) awaited(ids []uint32) bool {
+	for _, id := range ids {
+		if a.running[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// promote starts the handler for the call p is paused at in the background
+// and resumes the run with a future for it.
+func (r *Runner) promote(ctx context.Context, p Progress, a *asyncCalls) (Progress, error) {
+	a.running[p.CallID] = true
+	go func() {
+		value, err := r.call(ctx, p)
+		result := FutureResult{CallID: p.CallID, Result: value}
+		if err != nil {
+			result = FutureResult{CallID: p.CallID, Err: errorMessage(err)}
+		} else if value == nil {
+			result.Result = none{}
+		}
+		select {
+		case a.results <- result:
+		case <-a.done:
+		}
+	}()
+	next, _, err := p.Snapshot.ResumeFuture(p.CallID)
+	return next, err
+}
+
+// resolve resumes a run waiting on futures with the results that have
+// arrived for them, waiting for one if none has. It fails with a
+// *DeadlockError if no result can arrive.
+func (r *Runner) resolve(ctx context.Context, p Progress, a *asyncCalls) (Progress, error) {
+	for {
+		var ready []FutureResult
+		kept := a.arrived[:0]
+		for _, result := range a.arrived {
+			if slices.Contains(p.PendingIDs, result.CallID) {
+				ready = append(ready, result)
+			} else {
+				kept = append(kept, result)
+			}
+		}
+		a.arrived = kept
+		if len(ready) > 0 {
+			return p.FutureSnapshot.Resume(ready)
+		}
+		if !a.awaited(p.PendingIDs) {
+			closeProgress(p)
+			return Progress{}, &DeadlockError{PendingIDs: p.PendingIDs}
+		}
+		select {
+		case result := <-a.results:
+			a.receive(result)
+		case <-ctx.Done():
+			if r.interrupted() {
+				return p, nil
+			}
+			closeProgress(p)
+			return Progress{}, contextError(ctx)
+		}
+	drain:
+		for len(a.running) > 0 {
+			select {
+			case result := <-a.results:
+				a.receive(result)
+			default:
+				break drain
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// asyncBridge makes two calls to fetch, then waits on both and returns the
+// results resolving them.
+func asyncBridge() *Sandbox {
+	var resolved []json.RawMessage
+	return scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(callProgress(1, "fetch", "[]", 2)),
+		"resume": func(req sandboxRequest) sandboxResponse {
+			if req.Payload != nil || req.ErrMsg != "" {
+				return sandboxResponse{Err: "RuntimeError: expected a future"}
+			}
+			if req.CallID == 1 {
+				return callProgress(2, "fetch", "[]", 2)
+			}
+			return sandboxResponse{Progress: &sandboxProgress{
+				Kind: ResolveFutures, PendingIDs: json.RawMessage("[1,2]"), FutureSnapshot: 3,
+			}}
+		},
+		"resume_futures": func(req sandboxRequest) sandboxResponse {
+			var results []json.RawMessage
+			json.Unmarshal(req.Payload, &results)
+			resolved = append(resolved, results...)
+			if len(resolved) < 2 {
+				pending := "[1]"
+				if Object(resolved[0]).Get("call_id").String() == "1" {
+					pending = "[2]"
+				}
+				return sandboxResponse{Progress: &sandboxProgress{
+					Kind: ResolveFutures, PendingIDs: json.RawMessage(pending), FutureSnapshot: 3,
+				}}
+			}
+			result, _ := json.Marshal(resolved)
+			return completeProgress(result)
+		},
+	})
+}
+
+func TestRunnerAsyncCalls(t *testing.T) {
+	m, err := New("a = fetch(); b = fetch()", "main.py", nil, []string{"fetch"}, WithSandbox(asyncBridge()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	r := NewRunner(m, WithAsyncCalls("fetch"))
+	// Each handler waits for the other to start, so the run only completes
+	// if they overlap.
+	var started sync.WaitGroup
+	started.Add(2)
+	r.Register("fetch", func(ctx context.Context, call CallInfo) (any, error) {
+		started.Done()
+		started.Wait()
+		return int(call.CallID) * 10, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := r.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []struct {
+		CallID uint32 `json:"call_id"`
+		Result int
+	}
+	if err := result.Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	sum := 0
+	for _, g := range got {
+		sum += g.Result
+	}
+	if sum != 30 {
+		t.Errorf("Run resolved futures with %s", result)
+	}
+}
+
+func TestRunnerAsyncCallsMissingHandler(t *testing.T) {
+	m, err := New("fetch()", "main.py", nil, []string{"fetch"}, WithSandbox(asyncBridge()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	result, err := NewRunner(m, WithAsyncCalls("fetch")).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(result) || len(result.Get("0.error")) == 0 {
+		t.Errorf("Run = %s, want the missing handler raised in the futures", result)
+	}
+}
+
+func TestRunnerAsyncCallsDeadlock(t *testing.T) {
+	sb := scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		// The run awaits a future made before it was handed to the Runner.
+		"start": reply(sandboxResponse{Progress: &sandboxProgress{
+			Kind: ResolveFutures, PendingIDs: json.RawMessage("[7]"), FutureSnapshot: 3,
+		}}),
+	})
+	m, err := New("await fetch()", "main.py", nil, []string{"fetch"}, WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	r := NewRunner(m, WithAsyncCalls("fetch"))
+	r.Register("fetch", func(ctx context.Context, call CallInfo) (any, error) { return 1, nil })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = r.Run(ctx)
+	var deadlock *DeadlockError
+	if !errors.As(err, &deadlock) || !errors.Is(err, ErrDeadlock) || len(deadlock.PendingIDs) != 1 || deadlock.PendingIDs[0] != 7 {
+		t.Fatalf("Run: err = %v, want a DeadlockError for call 7", err)
+	}
+}
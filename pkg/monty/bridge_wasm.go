@@ -0,0 +1,43 @@
+//go:build wasm && monty_wasm && !nomonty
+
+package monty
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// In wasm builds tagged monty_wasm the library lives outside the guest. Each
+// engine call is encoded as a sandbox request and handed to the host through
+// two imports from the monty_host module:
+//
+//	call(req, len) -> n   handles the request and returns the response length,
+//	                      or 0 when the host has no library
+//	read(buf)             copies the pending response into buf
+//
+// NewSandboxHost implements the request handling for Go hosts.
+var native engine = NewSandboxBridge(hostRoundTrip)
+
+// LoadLibrary is meaningless in the guest; the host owns the library.
+func LoadLibrary(path string) error {
+	return errors.New("monty: LoadLibrary is not supported in monty_wasm builds")
+}
+
+//go:wasmimport monty_host call
+func hostCall(req unsafe.Pointer, length uint32) uint32
+
+//go:wasmimport monty_host read
+func hostRead(buf unsafe.Pointer)
+
+func hostRoundTrip(req []byte) ([]byte, error) {
+	if len(req) == 0 {
+		return nil, errors.New("monty: empty sandbox request")
+	}
+	n := hostCall(unsafe.Pointer(&req[0]), uint32(len(req)))
+	if n == 0 {
+		return nil, ErrUnavailable
+	}
+	resp := make([]byte, n)
+	hostRead(unsafe.Pointer(&resp[0]))
+	return resp, nil
+}
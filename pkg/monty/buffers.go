@@ -0,0 +1,97 @@
+package monty
+
+import (
+	"context"
+	"fmt"
+)
+
+// bufferTypeID identifies the dataclass that carries buffer references.
+const bufferTypeID = 0x6d6f6e7462 // "montb"
+
+// Buffers keeps large binary payloads on the host side of the bridge. Add
+// registers a buffer and returns a small reference the host passes to the
+// script as an input or call result; the script hands the reference back to
+// external functions, which resolve it with Bytes, so the payload never
+// crosses the JSON bridge. A reference supports
+//
+//	buf.size   buf.slice(start, stop)   buf.tobytes()
+//
+// where the methods, answered by the handlers Register installs, copy only
+// the requested range into the script. There is no shared memory between
+// the host and the interpreter: a range the script reads crosses the bridge
+// as a bytes value like any other result, so scripts should read large
+// buffers a slice at a time, or leave them to external functions.
+type Buffers struct {
+	refs handles
+}
+
+// NewBuffers returns an empty buffer registry.
+func NewBuffers() *Buffers {
+	return &Buffers{refs: newHandles(bufferTypeID, "ValueError: buffer was released")}
+}
+
+// Add registers data and returns a reference to it. The data is shared, not
+// copied; the host must not modify it while the reference is in use.
+func (b *Buffers) Add(data []byte) Object {
+	return b.refs.add("Buffer", data, [2]any{"size", len(data)})
+}
+
+// Bytes resolves a reference returned by Add. ok is false when o is not a
+// reference to a buffer of b or the buffer was released.
+func (b *Buffers) Bytes(o Object) (data []byte, ok bool) {
+	v, ok := b.refs.get(o)
+	if !ok {
+		return nil, false
+	}
+	return v.([]byte), true
+}
+
+// Release forgets the buffer o refers to; later uses of the reference fail.
+func (b *Buffers) Release(o Object) {
+	b.refs.release(o)
+}
+
+// Len reports the number of registered buffers.
+func (b *Buffers) Len() int {
+	return b.refs.len()
+}
+
+// Register installs the buffer methods on r, in front of the handlers r
+// already has under their names.
+func (b *Buffers) Register(r *Runner) {
+	registerMethods(r, []string{"slice", "tobytes"}, b.Handler)
+}
+
+// Handler returns the handler for the buffer method name, passing calls that
+// are not buffer method calls to next, which may be nil.
+func (b *Buffers) Handler(name string, next Handler) Handler {
+	return b.refs.method(next, func(ctx context.Context, v any, self Object, args []Object) (any, error) {
+		data := v.([]byte)
+		switch name {
+		case "tobytes":
+			return map[string]any{"$bytes": bytesToInts(data)}, nil
+		case "slice":
+			start, stop := 0, len(data)
+			if len(args) > 0 && args[0].Unmarshal(&start) != nil {
+				return nil, TypeError("slice() start must be int")
+			}
+			if len(args) > 1 && args[1].Unmarshal(&stop) != nil {
+				return nil, TypeError("slice() stop must be int")
+			}
+			start, stop = clampIndex(start, len(data)), clampIndex(stop, len(data))
+			if stop < start {
+				stop = start
+			}
+			return map[string]any{"$bytes": bytesToInts(data[start:stop])}, nil
+		}
+		return nil, fmt.Errorf("unsupported buffer method %s", name)
+	})
+}
+
+// clampIndex resolves a Python slice index against length n.
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	return min(max(i, 0), n)
+}
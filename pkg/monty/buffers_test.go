@@ -0,0 +1,35 @@
+package monty
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestBuffersResolveAndSlice(t *testing.T) {
+	b := NewBuffers()
+	data := []byte("0123456789")
+	ref := b.Add(data)
+	if got, ok := b.Bytes(ref); !ok || &got[0] != &data[0] {
+		t.Fatalf("Bytes should return the registered slice without copying, ok=%v", ok)
+	}
+	slice := b.Handler("slice", nil)
+	v, err := slice(context.Background(), CallInfo{MethodCall: true, Args: []Object{ref, Object("2"), Object("-5")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"$bytes": []int{'2', '3', '4'}}; !reflect.DeepEqual(v, want) {
+		t.Fatalf("slice(2, -5) = %v, want %v", v, want)
+	}
+
+	b.Release(ref)
+	if _, ok := b.Bytes(ref); ok || b.Len() != 0 {
+		t.Fatal("released buffer should not resolve")
+	}
+	if _, err := slice(context.Background(), CallInfo{MethodCall: true, Args: []Object{ref}}); err == nil {
+		t.Fatal("slicing a released buffer should fail")
+	}
+	if _, ok := b.Bytes(NewStreams().Reader(nil)); ok {
+		t.Fatal("a stream handle is not a buffer reference")
+	}
+}
@@ -0,0 +1,83 @@
+package monty
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// BuiltinFunc replaces a Python builtin in the programs compiled with
+// WithBuiltin. It is called by the package while the run is paused, like a
+// virtualized OS call, so the host never sees the call; returning an error
+// raises it inside the script.
+type BuiltinFunc func(call CallInfo) (any, error)
+
+// WithBuiltin replaces the builtin name, such as print or input, with fn in
+// the programs compiled with this option, so platforms can enforce their
+// policy at the language level. The name is declared as an external function
+// of the program, which shadows the builtin, and its calls are answered by
+// fn; the original builtin is not reachable from fn. Pass the option to New,
+// and again to NewFromBytes for programs restored from a dump.
+func WithBuiltin(name string, fn BuiltinFunc) Option {
+	return func(c *config) {
+		c.overrideBuiltin(name, fn)
+	}
+}
+
+// WithoutBuiltins disables the named builtins, such as eval or exec, in the
+// programs compiled with this option: calling one raises NameError as if
+// the builtin did not exist. Like WithBuiltin, the names become external
+// functions of the program.
+func WithoutBuiltins(names ...string) Option {
+	return func(c *config) {
+		for _, name := range names {
+			c.overrideBuiltin(name, nil)
+		}
+	}
+}
+
+// overrideBuiltin sets the override of name, nil disabling it. The map is
+// replaced, since configs share it.
+func (c *config) overrideBuiltin(name string, fn BuiltinFunc) {
+	builtins := make(map[string]BuiltinFunc, len(c.builtins)+1)
+	for k, v := range c.builtins {
+		builtins[k] = v
+	}
+	builtins[name] = fn
+	c.builtins = builtins
+}
+
+// declareBuiltins returns extFuncs with the overridden builtins added.
+func (c *config) declareBuiltins(extFuncs []string) []string {
+	if len(c.builtins) == 0 {
+		return extFuncs
+	}
+	var names []string
+	for name := range c.builtins {
+		if !slices.Contains(extFuncs, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append(append([]string(nil), extFuncs...), names...)
+}
+
+// builtinCall answers calls to overridden builtins, reporting false for
+// other calls.
+func (r *run) builtinCall(p Progress) (osReply, bool) {
+	if p.Kind != FunctionCall || p.MethodCall {
+		return osReply{}, false
+	}
+	fn, ok := r.cfg.builtins[p.FunctionName]
+	if !ok {
+		return osReply{}, false
+	}
+	if fn == nil {
+		return osReply{errMsg: fmt.Sprintf("NameError: name '%s' is not defined", p.FunctionName)}, true
+	}
+	value, err := fn(callInfo(p))
+	if err != nil {
+		return osReply{errMsg: errorMessage(err)}, true
+	}
+	return osReply{value: value}, true
+}
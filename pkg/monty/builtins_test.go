@@ -0,0 +1,59 @@
+package monty
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinOverrides(t *testing.T) {
+	var declared, raised []string
+	call := func(id uint32, name string) sandboxResponse {
+		return callProgress(id, name, `["hi"]`, 2)
+	}
+	sb := scriptedBridge(bridgeScript{
+		"compile": func(req sandboxRequest) sandboxResponse {
+			declared = req.ExtFuncs
+			return sandboxResponse{Handle: 1}
+		},
+		"start": reply(call(1, "print")),
+		"resume": func(req sandboxRequest) sandboxResponse {
+			raised = append(raised, req.ErrMsg)
+			switch req.CallID {
+			case 1:
+				return call(2, "eval")
+			case 2:
+				return call(3, "input")
+			}
+			return completeProgress(json.RawMessage("null"))
+		},
+	})
+	var printed []string
+	m, err := New("print('hi'); eval('1'); input()", "main.py", nil, []string{"fetch"}, WithSandbox(sb),
+		WithBuiltin("print", func(call CallInfo) (any, error) {
+			printed = append(printed, string(call.Args[0]))
+			return nil, nil
+		}),
+		WithBuiltin("input", func(call CallInfo) (any, error) {
+			return nil, errors.New("RuntimeError: input is not available")
+		}),
+		WithoutBuiltins("eval"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if strings.Join(declared, ",") != "fetch,eval,input,print" {
+		t.Errorf("compiled with external functions %v", declared)
+	}
+	if _, err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(printed) != 1 || printed[0] != `"hi"` {
+		t.Errorf("print override saw %v", printed)
+	}
+	want := []string{"", "NameError: name 'eval' is not defined", "RuntimeError: input is not available"}
+	if strings.Join(raised, "|") != strings.Join(want, "|") {
+		t.Errorf("raised %q, want %q", raised, want)
+	}
+}
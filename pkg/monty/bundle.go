@@ -0,0 +1,162 @@
+package monty
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// BundleVersion is the version of the bundle format written by SaveBundle.
+const BundleVersion = 1
+
+// bundleMagic starts every bundle.
+var bundleMagic = []byte("MTYB")
+
+// Bundle is the deployable artifact of one script version: the compiled
+// program, its declared interface and documentation, host metadata, and
+// optionally a warm-start snapshot, saved to one file by SaveBundle.
+type Bundle struct {
+	// Program is the compiled program. It is required.
+	Program *Monty
+	// Warm, if set, is a WarmSnapshot of Program that runs start from.
+	Warm *WarmSnapshot
+	// Script, Inputs, and Functions declare the program's script name,
+	// input names, and external functions, as passed to New, and Doc its
+	// documented surface. SaveBundle fills them from Program's source when
+	// the program was compiled with New.
+	Script    string
+	Inputs    []string
+	Functions []string
+	Doc       ScriptDoc
+	// Metadata is free-form host data, such as a version or commit.
+	Metadata map[string]string
+}
+
+// bundleManifest is the JSON section of a bundle.
+type bundleManifest struct {
+	Script     string            `json:"script"`
+	Inputs     []string          `json:"inputs,omitempty"`
+	Functions  []string          `json:"functions,omitempty"`
+	Doc        ScriptDoc         `json:"doc"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	WarmCallID uint32            `json:"warm_call_id,omitempty"`
+}
+
+// SaveBundle writes b to w: a header, the JSON manifest, the program's dump
+// and the warm snapshot, each length-prefixed, and a SHA-256 checksum of
+// everything before it, so LoadBundle rejects truncated or altered files.
+func SaveBundle(w io.Writer, b Bundle) error {
+	if b.Program == nil {
+		return newError(ErrInvalidInput, "monty: bundle has no program")
+	}
+	if b.Warm != nil && b.Warm.program != b.Program.program {
+		return newError(ErrInvalidInput, "monty: warm snapshot is not of the bundled program")
+	}
+	program, err := b.Program.Dump()
+	if err != nil {
+		return err
+	}
+	manifest := bundleManifest{
+		Script: b.Script, Inputs: b.Inputs, Functions: b.Functions, Doc: b.Doc, Metadata: b.Metadata,
+	}
+	if src := b.Program.src; src != nil {
+		manifest.Script, manifest.Inputs, manifest.Functions = src.scriptName, src.inputNames, src.extFuncs
+		if manifest.Doc, err = b.Program.Docs(); err != nil {
+			return err
+		}
+	}
+	var warm []byte
+	if b.Warm != nil {
+		warm, manifest.WarmCallID = b.Warm.data, b.Warm.callID
+	}
+	meta, err := json.Marshal(manifest)
+	if err != nil {
+		return wrapError(ErrInvalidInput, err)
+	}
+
+	out := binary.AppendUvarint(append([]byte(nil), bundleMagic...), BundleVersion)
+	for _, section := range [][]byte{meta, program, warm} {
+		out = binary.AppendUvarint(out, uint64(len(section)))
+		out = append(out, section...)
+	}
+	sum := sha256.Sum256(out)
+	_, err = w.Write(append(out, sum[:]...))
+	return err
+}
+
+// LoadBundle reads a bundle written by SaveBundle and restores its program
+// with opts, as NewFromBytes does. Files that are not bundles, are corrupt,
+// or hold a newer format fail with ErrIncompatibleSnapshot. The program has
+// no source, so its declarations and docs are those of the returned Bundle.
+func LoadBundle(r io.Reader, opts ...Option) (*Bundle, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := parseBundle(data)
+	if err != nil {
+		return nil, err
+	}
+	var manifest bundleManifest
+	if err := json.Unmarshal(sections[0], &manifest); err != nil {
+		return nil, newError(ErrIncompatibleSnapshot, fmt.Sprintf("monty: bundle manifest: %v", err))
+	}
+	m, err := NewFromBytes(sections[1], opts...)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{
+		Program: m, Script: manifest.Script, Inputs: manifest.Inputs, Functions: manifest.Functions,
+		Doc: manifest.Doc, Metadata: manifest.Metadata,
+	}
+	if len(sections[2]) > 0 {
+		if _, _, err := undump(SnapshotHandle, sections[2]); err != nil {
+			m.Close()
+			return nil, err
+		}
+		b.Warm = &WarmSnapshot{data: sections[2], callID: manifest.WarmCallID, program: m.program}
+	}
+	return b, nil
+}
+
+// parseBundle checks the header and checksum of data and returns its
+// manifest, program, and warm snapshot sections.
+func parseBundle(data []byte) ([3][]byte, error) {
+	var sections [3][]byte
+	corrupt := newError(ErrIncompatibleSnapshot, "monty: corrupt bundle")
+	rest, ok := bytes.CutPrefix(data, bundleMagic)
+	if !ok {
+		return sections, newError(ErrIncompatibleSnapshot, "monty: not a bundle")
+	}
+	if len(rest) < sha256.Size {
+		return sections, corrupt
+	}
+	body := data[:len(data)-sha256.Size]
+	if sum := sha256.Sum256(body); !bytes.Equal(sum[:], data[len(body):]) {
+		return sections, newError(ErrIncompatibleSnapshot, "monty: bundle checksum mismatch")
+	}
+	rest = body[len(bundleMagic):]
+	version, n := binary.Uvarint(rest)
+	if n <= 0 || version == 0 {
+		return sections, corrupt
+	}
+	if version > BundleVersion {
+		return sections, newError(ErrIncompatibleSnapshot,
+			fmt.Sprintf("monty: bundle format version %d is newer than %d", version, BundleVersion))
+	}
+	rest = rest[n:]
+	for i := range sections {
+		size, n := binary.Uvarint(rest)
+		if n <= 0 || uint64(len(rest)-n) < size {
+			return sections, corrupt
+		}
+		sections[i], rest = rest[n:n+int(size)], rest[n+int(size):]
+	}
+	if len(rest) != 0 {
+		return sections, corrupt
+	}
+	return sections, nil
+}
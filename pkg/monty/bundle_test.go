@@ -0,0 +1,58 @@
+package monty
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	sb := versionedBridge()
+	code := "\"\"\"Doubles n.\"\"\"\nn: int\nf(n)\n"
+	m, err := New(code, "double.py", []string{"n"}, []string{"f"}, WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := p.Snapshot.Dump()
+	p.Snapshot.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	warm := &WarmSnapshot{data: state, callID: p.CallID, program: m.program}
+
+	var buf bytes.Buffer
+	if err := SaveBundle(&buf, Bundle{Program: m, Warm: warm, Metadata: map[string]string{"version": "v3"}}); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.Bytes()
+	b, err := LoadBundle(bytes.NewReader(saved), WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Program.Close()
+	if b.Script != "double.py" || len(b.Inputs) != 1 || b.Inputs[0] != "n" || len(b.Functions) != 1 ||
+		b.Doc.Doc != "Doubles n." || b.Doc.Inputs[0].Annotation != "int" || b.Metadata["version"] != "v3" {
+		t.Errorf("LoadBundle = %+v", b)
+	}
+	// The bridge does not answer the resume, but the warm snapshot must be
+	// accepted as one of the loaded program's.
+	if _, err := b.Program.StartFrom(b.Warm, 2); errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("StartFrom the bundled warm snapshot: %v", err)
+	}
+
+	if err := SaveBundle(&buf, Bundle{Program: b.Program, Warm: warm}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("bundling another program's warm snapshot: err = %v", err)
+	}
+	altered := append([]byte(nil), saved...)
+	altered[len(altered)/2] ^= 1
+	for _, data := range [][]byte{altered, saved[:len(saved)-1], []byte("x")} {
+		if _, err := LoadBundle(bytes.NewReader(data), WithSandbox(sb)); !errors.Is(err, ErrIncompatibleSnapshot) {
+			t.Errorf("loading a corrupt bundle: err = %v", err)
+		}
+	}
+}
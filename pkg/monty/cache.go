@@ -0,0 +1,191 @@
+package monty
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// CacheStats counts the lookups of a ProgramCache.
+type CacheStats struct {
+	// Hits were served from memory, StoreHits from the store, and Misses
+	// compiled.
+	Hits      uint64
+	StoreHits uint64
+	Misses    uint64
+	// Evictions counts programs dropped from memory to stay within the size.
+	Evictions uint64
+	// StoreErrors counts dumps that could not be read or written. Lookups
+	// that hit one compile instead of failing.
+	StoreErrors uint64
+	// Len is the number of programs in memory.
+	Len int
+}
+
+// ProgramCache keeps compiled programs by their source, so hosts running many
+// tenant scripts compile each once. Recently used programs are kept in
+// memory; with a Store, such as a DirStore, every program's dump is kept
+// there too, and a process that restarts loads programs from their dumps
+// instead of compiling them again. It is safe for concurrent use.
+type ProgramCache struct {
+	size    int
+	store   Store
+	opts    []Option
+	version string
+
+	mu    sync.Mutex
+	lru   *list.List // of *cacheEntry, most recently used first
+	byKey map[string]*list.Element
+	stats CacheStats
+}
+
+type cacheEntry struct {
+	key string
+	m   *Monty
+}
+
+// NewProgramCache returns a cache holding up to size programs in memory,
+// dumping them to store unless it is nil. opts are used to compile and load
+// every program.
+func NewProgramCache(size int, store Store, opts ...Option) *ProgramCache {
+	if size < 1 {
+		size = 1
+	}
+	library, abi, _ := newConfig(opts).eng.version()
+	return &ProgramCache{
+		size:    size,
+		store:   store,
+		opts:    opts,
+		version: library + "/" + strconv.FormatUint(uint64(abi), 10),
+		lru:     list.New(),
+		byKey:   make(map[string]*list.Element),
+	}
+}
+
+// Get returns the program for the arguments of New, compiling it only if
+// neither memory nor the store holds it. Programs are shared between callers
+// and must not be closed by them. Evicted programs are released by their
+// finalizers once no caller holds them, and the rest by Close. Compile
+// errors are returned as from New and are not cached.
+func (c *ProgramCache) Get(ctx context.Context, code, scriptName string, inputNames, extFuncs []string) (*Monty, error) {
+	key := cacheKey(c.version, code, scriptName, inputNames, extFuncs)
+	if m := c.lookup(key); m != nil {
+		return m, nil
+	}
+	src := &source{code: code, scriptName: scriptName, inputNames: append([]string(nil), inputNames...), extFuncs: append([]string(nil), extFuncs...)}
+	if c.store != nil {
+		data, err := c.store.Get(ctx, key)
+		if err == nil {
+			var m *Monty
+			if m, err = NewFromBytes(data, c.opts...); err == nil {
+				m.src, m.program = src, programHash([]byte(code))
+				return c.add(key, m, &c.stats.StoreHits), nil
+			}
+		}
+		if !errors.Is(err, ErrNotStored) {
+			c.count(&c.stats.StoreErrors)
+		}
+	}
+	m, err := New(code, scriptName, inputNames, extFuncs, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	if c.store != nil {
+		data, err := m.Dump()
+		if err == nil {
+			err = c.store.Put(ctx, key, data)
+		}
+		if err != nil {
+			c.count(&c.stats.StoreErrors)
+		}
+	}
+	return c.add(key, m, &c.stats.Misses), nil
+}
+
+// Stats returns the cache's counters.
+func (c *ProgramCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Len = c.lru.Len()
+	return s
+}
+
+// Close closes the programs in memory and empties the cache. Dumps in the
+// store are kept.
+func (c *ProgramCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		errs = append(errs, e.Value.(*cacheEntry).m.Close())
+	}
+	c.lru.Init()
+	c.byKey = make(map[string]*list.Element)
+	return errors.Join(errs...)
+}
+
+func (c *ProgramCache) lookup(key string) *Monty {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.byKey[key]
+	if !ok {
+		return nil
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry).m
+}
+
+// add puts m in memory and counts the lookup in counter, unless another
+// caller added the program first, in which case m is closed and theirs
+// returned.
+func (c *ProgramCache) add(key string, m *Monty, counter *uint64) *Monty {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*counter++
+	if e, ok := c.byKey[key]; ok {
+		m.Close()
+		c.lru.MoveToFront(e)
+		return e.Value.(*cacheEntry).m
+	}
+	c.byKey[key] = c.lru.PushFront(&cacheEntry{key: key, m: m})
+	for c.lru.Len() > c.size {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.byKey, e.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+	return m
+}
+
+func (c *ProgramCache) count(counter *uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*counter++
+}
+
+// cacheKey hashes a program's source together with the library version, so
+// dumps made by another version are never loaded.
+func cacheKey(version, code, scriptName string, inputNames, extFuncs []string) string {
+	h := sha256.New()
+	field := func(s string) {
+		h.Write(binary.AppendUvarint(nil, uint64(len(s))))
+		h.Write([]byte(s))
+	}
+	field(version)
+	field(scriptName)
+	field(code)
+	for _, names := range [][]string{inputNames, extFuncs} {
+		field(strconv.Itoa(len(names)))
+		for _, name := range names {
+			field(name)
+		}
+	}
+	return "program-" + hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,133 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// compileBridge counts compiles and program loads.
+func compileBridge(compiles, loads *int) *Sandbox {
+	return scriptedBridge(bridgeScript{
+		"compile": func(req sandboxRequest) sandboxResponse {
+			*compiles++
+			if req.Code == "bad(" {
+				return sandboxResponse{Err: "SyntaxError: bad"}
+			}
+			return sandboxResponse{Handle: 1}
+		},
+		"dump_run": reply(sandboxResponse{Data: []byte("program")}),
+		"load_run": func(sandboxRequest) sandboxResponse {
+			*loads++
+			return sandboxResponse{Handle: 2}
+		},
+	})
+}
+
+func TestProgramCacheMemory(t *testing.T) {
+	var compiles, loads int
+	c := NewProgramCache(2, nil, WithSandbox(compileBridge(&compiles, &loads)))
+	defer c.Close()
+	ctx := context.Background()
+	a, err := c.Get(ctx, "a", "a.py", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.Get(ctx, "a", "a.py", nil, nil); again != a {
+		t.Error("second Get did not return the cached program")
+	}
+	if other, _ := c.Get(ctx, "a", "a.py", []string{"x"}, nil); other == a {
+		t.Error("programs with other inputs share an entry")
+	}
+	c.Get(ctx, "b", "b.py", nil, nil)
+	if _, err := c.Get(ctx, "bad(", "bad.py", nil, nil); err == nil {
+		t.Error("compile error not returned")
+	}
+	want := CacheStats{Hits: 1, Misses: 3, Evictions: 1, Len: 2}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+	if compiles != 4 {
+		t.Errorf("compiles = %d, want 4", compiles)
+	}
+}
+
+func TestProgramCacheStore(t *testing.T) {
+	var compiles, loads int
+	dir := t.TempDir()
+	store, err := NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	first := NewProgramCache(8, store, WithSandbox(compileBridge(&compiles, &loads)))
+	if _, err := first.Get(ctx, "x = 1", "main.py", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+
+	// A restarted process loads the dump instead of compiling.
+	second := NewProgramCache(8, store, WithSandbox(compileBridge(&compiles, &loads)))
+	defer second.Close()
+	m, err := second.Get(ctx, "x = 1", "main.py", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compiles != 1 || loads != 1 {
+		t.Errorf("compiles = %d, loads = %d; want 1 and 1", compiles, loads)
+	}
+	if got := second.Stats(); got.StoreHits != 1 || got.Misses != 0 {
+		t.Errorf("Stats = %+v", got)
+	}
+	if m.src == nil || m.src.code != "x = 1" {
+		t.Error("program loaded from the store lost its source")
+	}
+
+	// A corrupt dump is recompiled.
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("store holds %d files, want 1", len(entries))
+	}
+	os.WriteFile(filepath.Join(dir, entries[0].Name()), nil, 0o644)
+	third := NewProgramCache(8, store, WithSandbox(compileBridge(&compiles, &loads)))
+	defer third.Close()
+	if _, err := third.Get(ctx, "x = 1", "main.py", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := third.Stats(); got.StoreErrors != 1 || got.Misses != 1 || compiles != 2 {
+		t.Errorf("Stats = %+v, compiles = %d", got, compiles)
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDirStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, key := range []string{"run/1", "..", ".", ""} {
+		if err := s.Put(ctx, key, []byte(key+"!")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"run/1", "..", ".", ""} {
+		if data, err := s.Get(ctx, key); err != nil || string(data) != key+"!" {
+			t.Errorf("Get(%q) = %q, %v", key, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "!")); err == nil {
+		t.Error("key escaped the store directory")
+	}
+	if err := s.Delete(ctx, "run/1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "run/1"); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Get after Delete: err = %v", err)
+	}
+	if err := s.Delete(ctx, "run/1"); err != nil {
+		t.Errorf("Delete of a missing key: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package monty
+
+import (
+	"sync"
+	"time"
+)
+
+// CallState is the state of a call recorded by a CallTracker.
+type CallState int
+
+const (
+	// CallPending calls have not been answered, or were deferred with
+	// ResumeFuture and await a future result.
+	CallPending CallState = iota
+	// CallResumed calls were answered with a value.
+	CallResumed
+	// CallErrored calls were answered by raising an exception.
+	CallErrored
+	// CallCancelled calls were left unanswered when their run ended or its
+	// paused state was closed without being dumped.
+	CallCancelled
+)
+
+var callStateNames = map[CallState]string{
+	CallPending:   "pending",
+	CallResumed:   "resumed",
+	CallErrored:   "errored",
+	CallCancelled: "cancelled",
+}
+
+func (s CallState) String() string {
+	if name, ok := callStateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// CallRecord is the lifecycle of one call of a run. Issued is when the run
+// paused at the call and Finished when it left CallPending, both read from
+// the run's clock, so deterministic runs record virtual time.
+type CallRecord struct {
+	CallID uint32
+	Kind   ProgressKind
+	// Name is the external or OS function called; it is empty for timers.
+	Name  string
+	State CallState
+	// Future is set once the call was deferred with ResumeFuture.
+	Future bool
+	// Error is the message raised for an errored call, or the error that
+	// ended the run of a cancelled one.
+	Error string
+	// Meta is the metadata of the Annotated value the call was resumed
+	// with, scrubbed by the configured Redactor.
+	Meta     map[string]any
+	Issued   time.Time
+	Finished time.Time
+}
+
+// Duration returns how long the call was pending, or zero while it is.
+func (c CallRecord) Duration() time.Duration {
+	if c.State == CallPending {
+		return 0
+	}
+	return c.Finished.Sub(c.Issued)
+}
+
+// CallTracker records every call the runs configured with WithCallTracker
+// issue to the host, by run ID, so a stuck workflow can be inspected for the
+// calls it still waits on. Calls the package answers itself, such as
+// virtualized OS calls, are not recorded. Records are kept until Forget; a
+// run detached and attached again in the same process keeps its records,
+// since it keeps its RunID. It is safe for concurrent use.
+type CallTracker struct {
+	mu   sync.Mutex
+	runs map[string]*trackedCalls
+}
+
+type trackedCalls struct {
+	calls []CallRecord
+	// index maps a call ID to its latest record.
+	index map[uint32]int
+}
+
+// NewCallTracker returns an empty tracker.
+func NewCallTracker() *CallTracker {
+	return &CallTracker{runs: make(map[string]*trackedCalls)}
+}
+
+// WithCallTracker records the calls of every run in t.
+func WithCallTracker(t *CallTracker) Option {
+	return func(c *config) {
+		c.calls = t
+	}
+}
+
+// Calls returns the calls of the run with runID in the order they were
+// issued, or nil if it has none.
+func (t *CallTracker) Calls(runID string) []CallRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tc := t.runs[runID]; tc != nil {
+		return append([]CallRecord(nil), tc.calls...)
+	}
+	return nil
+}
+
+// Pending returns the calls of the run with runID still in CallPending.
+func (t *CallTracker) Pending(runID string) []CallRecord {
+	var pending []CallRecord
+	for _, c := range t.Calls(runID) {
+		if c.State == CallPending {
+			pending = append(pending, c)
+		}
+	}
+	return pending
+}
+
+// Runs returns the IDs of the runs with recorded calls.
+func (t *CallTracker) Runs() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make([]string, 0, len(t.runs))
+	for id := range t.runs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Forget drops the records of the run with runID.
+func (t *CallTracker) Forget(runID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.runs, runID)
+}
+
+// issue records a call the run paused at.
+func (t *CallTracker) issue(runID string, c CallRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tc := t.runs[runID]
+	if tc == nil {
+		tc = &trackedCalls{index: make(map[uint32]int)}
+		t.runs[runID] = tc
+	}
+	tc.index[c.CallID] = len(tc.calls)
+	tc.calls = append(tc.calls, c)
+}
+
+// update applies fn to the pending record of call id, if any.
+func (t *CallTracker) update(runID string, id uint32, fn func(*CallRecord)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tc := t.runs[runID]; tc != nil {
+		if i, ok := tc.index[id]; ok && tc.calls[i].State == CallPending {
+			fn(&tc.calls[i])
+		}
+	}
+}
+
+// cancel moves every pending call of the run to CallCancelled.
+func (t *CallTracker) cancel(runID string, at time.Time, msg string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tc := t.runs[runID]; tc != nil {
+		for i := range tc.calls {
+			if c := &tc.calls[i]; c.State == CallPending {
+				c.State, c.Finished, c.Error = CallCancelled, at, msg
+			}
+		}
+	}
+}
+
+// trackProgress records the calls issued by a step, and cancels the pending
+// ones once the run ends.
+func (r *run) trackProgress(p Progress, err error) {
+	t := r.cfg.calls
+	if t == nil {
+		return
+	}
+	switch {
+	case err != nil:
+		t.cancel(r.id, r.now(), err.Error())
+	case p.Kind == Complete:
+		t.cancel(r.id, r.now(), "")
+	case p.Kind == FunctionCall || p.Kind == OsCall || p.Kind == Timer:
+		name := p.FunctionName
+		if p.Kind == OsCall {
+			name = p.OsFunction
+		}
+		t.issue(r.id, CallRecord{CallID: p.CallID, Kind: p.Kind, Name: name, Issued: r.now()})
+	}
+}
+
+// trackResume records the answer to the call name.
+func (r *run) trackResume(callID uint32, name string, result any, errMsg string) {
+	if r.cfg.calls == nil {
+		return
+	}
+	now := r.now()
+	_, meta := splitMeta(result)
+	if meta != nil && r.cfg.redactor != nil {
+		meta = nil
+		if plain, err := r.redactValue(name, traceMeta(result)).Plain(); err == nil {
+			jsonUnmarshal(plain, &meta)
+		}
+	}
+	r.cfg.calls.update(r.id, callID, func(c *CallRecord) {
+		switch {
+		case errMsg != "":
+			c.State, c.Error, c.Finished = CallErrored, errMsg, now
+		case result == nil:
+			c.Future = true
+		default:
+			c.State, c.Finished, c.Meta = CallResumed, now, meta
+		}
+	})
+}
+
+// trackFutures records the results of pending futures.
+func (r *run) trackFutures(results []FutureResult) {
+	for _, res := range results {
+		if res.Err != "" || res.Result != nil {
+			r.trackResume(res.CallID, "", res.Result, res.Err)
+		}
+	}
+}
+
+// trackClose cancels the pending calls of a run whose paused state is closed
+// without having been dumped.
+func (r *run) trackClose(dumped bool) {
+	if r.cfg.calls != nil && !dumped {
+		r.cfg.calls.cancel(r.id, r.now(), "")
+	}
+}
@@ -0,0 +1,89 @@
+package monty
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCallTracker(t *testing.T) {
+	tracker := NewCallTracker()
+	m, err := New("double(21)", "main.py", nil, []string{"double"},
+		WithSandbox(callBridge("double", 1)), WithCallTracker(tracker), WithDeterministic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	start := func() Progress {
+		p, err := m.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	errored := start()
+	pending := tracker.Pending(errored.RunID)
+	if len(pending) != 1 || pending[0].CallID != 1 || pending[0].Name != "double" || pending[0].Kind != FunctionCall {
+		t.Fatalf("Pending = %+v", pending)
+	}
+	if _, err := errored.Snapshot.ResumeError(1, "boom"); err != nil {
+		t.Fatal(err)
+	}
+	closed, dumped := start(), start()
+	closed.Snapshot.Close()
+	if _, err := dumped.Snapshot.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	dumped.Snapshot.Close()
+
+	for _, tc := range []struct {
+		p     Progress
+		state CallState
+	}{
+		{errored, CallErrored},
+		{closed, CallCancelled},
+		{dumped, CallPending},
+	} {
+		calls := tracker.Calls(tc.p.RunID)
+		if len(calls) != 1 || calls[0].State != tc.state || !calls[0].Issued.Equal(time.Unix(0, 0)) {
+			t.Errorf("Calls = %+v, want one %v call", calls, tc.state)
+		}
+	}
+	if calls := tracker.Calls(errored.RunID); calls[0].Error != "boom" {
+		t.Errorf("errored call = %+v", calls[0])
+	}
+	if runs := tracker.Runs(); len(runs) != 3 {
+		t.Errorf("Runs = %v", runs)
+	}
+	tracker.Forget(dumped.RunID)
+	if calls := tracker.Calls(dumped.RunID); calls != nil {
+		t.Errorf("Calls after Forget = %+v", calls)
+	}
+}
+
+func TestCallTrackerFutures(t *testing.T) {
+	tracker := NewCallTracker()
+	m, err := New("a = fetch(); b = fetch()", "main.py", nil, []string{"fetch"},
+		WithSandbox(asyncBridge()), WithCallTracker(tracker), WithRunID("r1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	r := NewRunner(m, WithAsyncCalls("fetch"))
+	r.Register("fetch", func(ctx context.Context, call CallInfo) (any, error) {
+		return int(call.CallID), nil
+	})
+	if _, err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	calls := tracker.Calls("r1")
+	if len(calls) != 2 {
+		t.Fatalf("Calls = %+v", calls)
+	}
+	for i, c := range calls {
+		if c.CallID != uint32(i+1) || !c.Future || c.State != CallResumed || c.Duration() < 0 {
+			t.Errorf("call %d = %+v", i, c)
+		}
+	}
+}
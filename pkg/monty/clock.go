@@ -0,0 +1,61 @@
+package monty
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a virtual clock that tests move by hand. Runs started with
+// WithClock read the time from it, and their sleeps advance it and return
+// at once instead of surfacing as Timer events, so tests of retries and
+// schedules run instantly. A Clock is safe for concurrent use and may be
+// shared by many runs.
+type Clock struct {
+	mu    sync.Mutex
+	start time.Time
+	now   time.Time
+}
+
+// NewClock returns a Clock set to start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{start: start, now: start}
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d. Negative durations are ignored, so
+// the clock never runs backwards.
+func (c *Clock) Advance(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// elapsed returns the time the clock has advanced since it was created,
+// which monotonic clock reads report.
+func (c *Clock) elapsed() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now.Sub(c.start)
+}
+
+// WithClock answers the clock reads of runs from c: time.time and
+// time.time_ns return c.Now(), time.monotonic and time.perf_counter the
+// time c has advanced, and time.localtime and the other calls WithTimezone
+// covers the time in UTC unless another zone is chosen. time.sleep and
+// asyncio.sleep advance c by their duration and return at once. Progress
+// CreatedAt and call records use c as well. It takes precedence over the
+// virtual clock of WithDeterministic.
+func WithClock(c *Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
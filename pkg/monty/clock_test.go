@@ -0,0 +1,77 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// retryBridge scripts a run that sleeps an hour, reads time.time, and
+// completes with what it read.
+func retryBridge() *Sandbox {
+	osCall := func(id uint32, fn, args string) sandboxResponse {
+		return sandboxResponse{Progress: &sandboxProgress{
+			Kind: OsCall, CallID: id, OsFunction: fn,
+			Args: json.RawMessage(args), Kwargs: json.RawMessage("[]"), Snapshot: uint64(id + 1),
+		}}
+	}
+	return scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(osCall(1, OsSleep, "[3600]")),
+		"resume": func(req sandboxRequest) sandboxResponse {
+			if req.CallID == 1 {
+				return osCall(2, OsTime, "[]")
+			}
+			return echoResume(req)
+		},
+	})
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewClock(start)
+	r := newConfig([]Option{WithClock(clock), WithDeterministic()}).newRun()
+
+	now := Progress{Kind: OsCall, OsFunction: OsTime}
+	if reply, ok := r.osCall(now); !ok || reply.value != float64(start.Unix()) {
+		t.Fatalf("time.time = %+v, want the clock's start", reply)
+	}
+	clock.Advance(90 * time.Second)
+	clock.Advance(-time.Hour)
+	if reply, _ := r.osCall(Progress{Kind: OsCall, OsFunction: OsMonotonic}); reply.value != 90.0 {
+		t.Fatalf("time.monotonic = %v after Advance", reply.value)
+	}
+	sleep := Progress{Kind: OsCall, OsFunction: OsAsyncSleep, Args: []Object{Object("30")}}
+	if _, ok := r.answer(&sleep); !ok || sleep.Kind != OsCall {
+		t.Fatal("sleeps should be answered without a Timer event")
+	}
+	if got := clock.Now().Sub(start); got != 2*time.Minute {
+		t.Fatalf("clock advanced %v, want 2m", got)
+	}
+	if r.now() != clock.Now() {
+		t.Fatalf("run time %v, want the clock's", r.now())
+	}
+}
+
+func TestRunnerSleepsOnClock(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewClock(start)
+	m, err := New("retry()", "main.py", nil, nil, WithSandbox(retryBridge()), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	began := time.Now()
+	result, err := NewRunner(m).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var woke float64
+	if err := result.Unmarshal(&woke); err != nil || woke != float64(start.Add(time.Hour).Unix()) {
+		t.Fatalf("script read %v after sleeping, want an hour past the start", result)
+	}
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Fatalf("run took %v, want the sleep skipped", elapsed)
+	}
+}
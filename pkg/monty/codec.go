@@ -0,0 +1,65 @@
+package monty
+
+import (
+	"encoding/json"
+	"sync/atomic"
+)
+
+// JSONCodec encodes and decodes the JSON exchanged with the interpreter. Its
+// methods must behave like json.Marshal and json.Unmarshal, including support
+// for json.RawMessage and the json.Marshaler and json.Unmarshaler interfaces;
+// drop-in replacements such as sonic and go-json qualify.
+type JSONCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (stdCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// StdJSON is the encoding/json codec used by default.
+var StdJSON JSONCodec = stdCodec{}
+
+type numberCodec struct{ stdCodec }
+
+func (numberCodec) Unmarshal(data []byte, v any) error { return unmarshalNumbers(data, v) }
+
+// NumberJSON is StdJSON decoding numbers into interface values as
+// json.Number, as Object.UnmarshalStrict does. Install it with SetJSONCodec
+// so that Object.Unmarshal, handler arguments decoded into any, and the
+// values of Tables never round large integers through float64.
+var NumberJSON JSONCodec = numberCodec{}
+
+type codecBox struct{ JSONCodec }
+
+var activeCodec atomic.Value // codecBox
+
+// SetJSONCodec replaces the codec used to encode inputs, call results, and
+// future results, to decode call arguments and Objects, and to speak the
+// sandbox protocol. A nil codec restores StdJSON. It is meant to be called
+// once during program initialization; runs already in flight may observe
+// either codec.
+func SetJSONCodec(c JSONCodec) {
+	if c == nil {
+		c = StdJSON
+	}
+	activeCodec.Store(codecBox{c})
+}
+
+// CurrentJSONCodec returns the codec set by SetJSONCodec.
+func CurrentJSONCodec() JSONCodec {
+	if box, ok := activeCodec.Load().(codecBox); ok {
+		return box.JSONCodec
+	}
+	return StdJSON
+}
+
+func jsonMarshal(v any) ([]byte, error) {
+	return CurrentJSONCodec().Marshal(v)
+}
+
+func jsonUnmarshal(data []byte, v any) error {
+	return CurrentJSONCodec().Unmarshal(data, v)
+}
@@ -0,0 +1,65 @@
+package monty
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+)
+
+type countingCodec struct {
+	JSONCodec
+	marshals, unmarshals atomic.Int64
+}
+
+func (c *countingCodec) Marshal(v any) ([]byte, error) {
+	c.marshals.Add(1)
+	return c.JSONCodec.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v any) error {
+	c.unmarshals.Add(1)
+	return c.JSONCodec.Unmarshal(data, v)
+}
+
+func TestSetJSONCodec(t *testing.T) {
+	c := &countingCodec{JSONCodec: StdJSON}
+	SetJSONCodec(c)
+	t.Cleanup(func() { SetJSONCodec(nil) })
+
+	if CurrentJSONCodec() != c {
+		t.Fatal("CurrentJSONCodec should return the installed codec")
+	}
+	data, err := marshalInputs([]any{Object(`{"a":[1,2]}`), "x"})
+	if err != nil || string(data) != `[{"a":[1,2]},"x"]` {
+		t.Fatalf("marshalInputs = %s, %v", data, err)
+	}
+	var v map[string]int
+	if err := Object(`{"b":3}`).Unmarshal(&v); err != nil || v["b"] != 3 {
+		t.Fatalf("Unmarshal = %v, %v", v, err)
+	}
+	if c.marshals.Load() == 0 || c.unmarshals.Load() < 2 {
+		t.Fatalf("codec not used: %d marshals, %d unmarshals", c.marshals.Load(), c.unmarshals.Load())
+	}
+
+	SetJSONCodec(nil)
+	if CurrentJSONCodec() != StdJSON {
+		t.Fatal("SetJSONCodec(nil) should restore StdJSON")
+	}
+}
+
+func TestNumberJSON(t *testing.T) {
+	SetJSONCodec(NumberJSON)
+	t.Cleanup(func() { SetJSONCodec(nil) })
+
+	var v []any
+	if err := Object(`[18446744073709551615, 2]`).Unmarshal(&v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if v[0] != json.Number("18446744073709551615") || v[1] != json.Number("2") {
+		t.Fatalf("Unmarshal = %#v", v)
+	}
+	data, err := marshalInputs([]any{v})
+	if err != nil || string(data) != `[[18446744073709551615,2]]` {
+		t.Fatalf("marshalInputs = %s, %v", data, err)
+	}
+}
@@ -0,0 +1,194 @@
+package monty
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Comparison reports how two programs handled the same traced run.
+type Comparison struct {
+	Run uint64
+	// CallsA and CallsB are the calls each program made, as progress events.
+	CallsA, CallsB []TraceEvent
+	// ResultA and ResultB are the final results; ErrA and ErrB the errors of
+	// programs that failed.
+	ResultA, ResultB Object
+	ErrA, ErrB       string
+	// Divergences describes each difference, empty when the programs agree.
+	Divergences []string
+}
+
+// Diverged reports whether the programs behaved differently.
+func (c Comparison) Diverged() bool {
+	return len(c.Divergences) > 0
+}
+
+// CompareRuns is a safe-deploy check for script changes: it runs the inputs
+// of each traced run through program a, typically the deployed version, and
+// program b, the candidate, and reports where they disagree on the calls they
+// make or on their final results.
+//
+// Both programs are answered from the trace rather than live handlers: the
+// nth call a program makes to a function receives the answer recorded for the
+// nth call to that function, including futures. A call with no recorded
+// answer raises a RuntimeError in the script and is reported. Sleeps are
+// woken immediately.
+func CompareRuns(a, b *Monty, runs []TraceRun) ([]Comparison, error) {
+	out := make([]Comparison, 0, len(runs))
+	for _, tr := range runs {
+		if len(tr.Events) == 0 || tr.Events[0].Type != "start" {
+			return nil, newError(ErrInvalidInput, fmt.Sprintf("monty: trace run %d has no start event", tr.ID))
+		}
+		var inputs []json.RawMessage
+		if err := json.Unmarshal(tr.Events[0].Inputs, &inputs); err != nil && len(tr.Events[0].Inputs) > 0 {
+			return nil, newError(ErrInvalidInput, fmt.Sprintf("monty: trace run %d inputs: %v", tr.ID, err))
+		}
+		args := make([]any, len(inputs))
+		for i, in := range inputs {
+			args[i] = in
+		}
+		book := newAnswerBook(tr)
+		c := Comparison{Run: tr.ID}
+		c.CallsA, c.ResultA, c.ErrA = book.drive(a, args)
+		c.CallsB, c.ResultB, c.ErrB = book.drive(b, args)
+		c.Divergences = c.diverge()
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (c Comparison) diverge() []string {
+	var out []string
+	n := min(len(c.CallsA), len(c.CallsB))
+	for i := 0; i < n; i++ {
+		if x, y := describeCall(c.CallsA[i]), describeCall(c.CallsB[i]); x != y {
+			out = append(out, fmt.Sprintf("call %d: a made %s, b made %s", i+1, x, y))
+		}
+	}
+	if len(c.CallsA) != len(c.CallsB) {
+		out = append(out, fmt.Sprintf("call count: a made %d, b made %d", len(c.CallsA), len(c.CallsB)))
+	}
+	switch {
+	case c.ErrA != c.ErrB:
+		out = append(out, fmt.Sprintf("error: a %s, b %s", outcome(c.ErrA), outcome(c.ErrB)))
+	case c.ErrA == "":
+		for _, d := range Diff(c.ResultA, c.ResultB) {
+			out = append(out, "result "+d.String())
+		}
+	}
+	return out
+}
+
+func outcome(err string) string {
+	if err == "" {
+		return "completed"
+	}
+	return fmt.Sprintf("failed with %q", err)
+}
+
+// describeCall renders a call event as name(args, key=value).
+func describeCall(e TraceEvent) string {
+	parts := make([]string, 0, len(e.Args)+len(e.Kwargs)/2)
+	for _, arg := range e.Args {
+		parts = append(parts, string(arg))
+	}
+	for i := 0; i+1 < len(e.Kwargs); i += 2 {
+		parts = append(parts, string(e.Kwargs[i])+"="+string(e.Kwargs[i+1]))
+	}
+	return fmt.Sprintf("%s %s(%s)", e.Kind, e.Function, strings.Join(parts, ", "))
+}
+
+// callKey identifies the nth call to a function within a run.
+type callKey struct {
+	function string
+	n        int
+}
+
+// answerBook holds the answers a trace recorded, by call.
+type answerBook struct {
+	answers map[callKey]TraceEvent
+	futures map[callKey]TraceFuture
+	steps   int
+}
+
+func newAnswerBook(tr TraceRun) *answerBook {
+	b := &answerBook{answers: make(map[callKey]TraceEvent), futures: make(map[callKey]TraceFuture), steps: 2*len(tr.Events) + 100}
+	counts := make(map[string]int)
+	keys := make(map[uint32]callKey)
+	var pending callKey
+	for _, e := range tr.Events {
+		switch {
+		case e.Type == "progress" && e.Function != "":
+			pending = callKey{e.Function, counts[e.Function]}
+			counts[e.Function]++
+			keys[e.CallID] = pending
+		case e.Type == "resume" && pending.function != "":
+			b.answers[pending] = e
+			pending = callKey{}
+		case e.Type == "resume_futures":
+			for _, r := range e.Results {
+				if k, ok := keys[r.CallID]; ok {
+					b.futures[k] = r
+				}
+			}
+		}
+	}
+	return b
+}
+
+// drive runs m on inputs, answering from the book, and returns the calls it
+// made and how it ended.
+func (b *answerBook) drive(m *Monty, inputs []any) ([]TraceEvent, Object, string) {
+	var calls []TraceEvent
+	counts := make(map[string]int)
+	keys := make(map[uint32]callKey)
+	p, err := m.Start(inputs...)
+	for step := 0; err == nil; step++ {
+		if step > b.steps {
+			closeProgress(p)
+			return calls, nil, "monty: run made more steps than its trace"
+		}
+		switch p.Kind {
+		case Complete:
+			result, err := completeResult(p)
+			if err != nil {
+				return calls, nil, err.Error()
+			}
+			return calls, result, ""
+		case FunctionCall, OsCall:
+			e := progressEvent(p)
+			calls = append(calls, e)
+			key := callKey{e.Function, counts[e.Function]}
+			counts[e.Function]++
+			keys[p.CallID] = key
+			answer, ok := b.answers[key]
+			switch {
+			case !ok:
+				p, err = p.Snapshot.ResumeError(p.CallID, fmt.Sprintf("RuntimeError: no recorded answer for call %d to %s", key.n+1, key.function))
+			case answer.Error != "":
+				p, err = p.Snapshot.ResumeError(p.CallID, answer.Error)
+			case answer.Future:
+				p, _, err = p.Snapshot.ResumeFuture(p.CallID)
+			default:
+				p, err = p.Snapshot.Resume(p.CallID, answer.Result)
+			}
+		case Timer:
+			p, err = p.Snapshot.Wake(p.CallID)
+		case ResolveFutures:
+			results := make([]FutureResult, len(p.PendingIDs))
+			for i, id := range p.PendingIDs {
+				key := keys[id]
+				results[i] = FutureResult{CallID: id, Err: fmt.Sprintf("RuntimeError: no recorded answer for call %d to %s", key.n+1, key.function)}
+				if r, ok := b.futures[key]; ok {
+					results[i] = FutureResult{CallID: id, Result: r.Result, Err: r.Error}
+				}
+			}
+			p, err = p.FutureSnapshot.Resume(results)
+		default:
+			closeProgress(p)
+			return calls, nil, fmt.Sprintf("monty: unexpected progress kind %v", p.Kind)
+		}
+	}
+	return calls, nil, err.Error()
+}
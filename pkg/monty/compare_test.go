@@ -0,0 +1,56 @@
+package monty
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompareRuns(t *testing.T) {
+	var buf bytes.Buffer
+	old, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(callBridge("double", 1)), WithTrace(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	p, err := old.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Snapshot.Resume(p.CallID, 42); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(callBridge("double", 1)))
+	defer a.Close()
+	same, _ := New("x = 1\ndouble(21)", "main.py", nil, []string{"double"}, WithSandbox(callBridge("double", 3)))
+	defer same.Close()
+	changed, _ := New("triple(21)", "main.py", nil, []string{"triple"}, WithSandbox(callBridge("triple", 1)))
+	defer changed.Close()
+
+	got, err := CompareRuns(a, same, runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Diverged() || string(got[0].ResultA) != "42" || string(got[0].ResultB) != "42" {
+		t.Fatalf("expected matching runs answered from the trace, got %+v", got)
+	}
+
+	got, err = CompareRuns(a, changed, runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got[0].Diverged() || !strings.HasPrefix(got[0].Divergences[0], "call 1: a made function_call double(21), b made function_call triple(21)") {
+		t.Fatalf("expected the changed call reported, got %q", got[0].Divergences)
+	}
+}
+
+func TestCompareRunsRequiresStart(t *testing.T) {
+	if _, err := CompareRuns(nil, nil, []TraceRun{{ID: 1}}); err == nil {
+		t.Fatal("expected an error for a trace without a start event")
+	}
+}
@@ -0,0 +1,145 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ContextVarFunction is the external function through which scripts reach
+// the variables declared with WithContextVars. List it among the program's
+// external functions.
+const ContextVarFunction = "ContextVar"
+
+// contextVarTypeID identifies the dataclass that carries context variables.
+const contextVarTypeID = 0x6d6f6e7463 // "montc"
+
+// ContextVar maps the value stored in a context.Context under Key to the
+// Python context variable Name.
+type ContextVar struct {
+	Name string
+	Key  any
+}
+
+// WithContextVars makes selected context.Context values, such as request
+// and tenant IDs, visible to scripts as context variables, so user code and
+// host telemetry share correlation IDs. The values are read from the context
+// passed to StartContext, Runner.Run, or Drive, and must encode to JSON.
+// Scripts use them as they would contextvars.ContextVar:
+//
+//	request_id = ContextVar("request_id")
+//	log(request_id.get(), request_id.get("fallback"))
+//	request_id.set("req-7")
+//
+// get raises KeyError, a LookupError, for a variable the context does not
+// hold when no default is given. set changes the variable for the rest of
+// the run. Every progress event of the run reports the current values in
+// Progress.Context. The calls are answered by the package and never reach
+// the host; values set by a script are lost when a snapshot is restored from
+// bytes.
+func WithContextVars(vars ...ContextVar) Option {
+	return func(c *config) {
+		c.contextVars = append(append([]ContextVar(nil), c.contextVars...), vars...)
+	}
+}
+
+// StartContext is Start with the context that WithContextVars reads.
+func (m *Monty) StartContext(ctx context.Context, inputs ...any) (Progress, error) {
+	if m == nil {
+		return Progress{}, newError(ErrClosed, "monty: nil handle")
+	}
+	return m.start(ctx, m.cfg, inputs)
+}
+
+// bindContext records the declared context variables ctx holds.
+func (r *run) bindContext(ctx context.Context) error {
+	for _, v := range r.cfg.contextVars {
+		value := ctx.Value(v.Key)
+		if value == nil {
+			continue
+		}
+		data, err := marshalValue(value)
+		if err != nil {
+			return fmt.Errorf("monty: context variable %s: %w", v.Name, err)
+		}
+		if r.context == nil {
+			r.context = make(map[string]Object)
+		}
+		r.context[v.Name] = data
+	}
+	return nil
+}
+
+// contextCall answers ContextVar(name) and the get and set methods of the
+// variables it returns, reporting false for other calls.
+func (r *run) contextCall(p Progress) (osReply, bool) {
+	if r.cfg.contextVars == nil || p.Kind != FunctionCall {
+		return osReply{}, false
+	}
+	if !p.MethodCall {
+		if p.FunctionName != ContextVarFunction {
+			return osReply{}, false
+		}
+		var name string
+		if len(p.Args) != 1 || len(p.Kwargs) != 0 || p.Args[0].Unmarshal(&name) != nil {
+			return osReply{errMsg: "TypeError: ContextVar() takes a name"}, true
+		}
+		return osReply{value: json.RawMessage(handleObject("ContextVar", contextVarTypeID, 0, [2]any{"name", name}))}, true
+	}
+	if len(p.Args) == 0 {
+		return osReply{}, false
+	}
+	name, ok := contextVarName(p.Args[0])
+	if !ok {
+		return osReply{}, false
+	}
+	args := p.Args[1:]
+	switch p.FunctionName {
+	case "get":
+		if len(args) > 1 || len(p.Kwargs) != 0 {
+			return osReply{errMsg: "TypeError: get() takes at most one default"}, true
+		}
+		if value, ok := r.context[name]; ok {
+			return osReply{value: json.RawMessage(value)}, true
+		}
+		if len(args) == 1 {
+			return osReply{value: json.RawMessage(args[0])}, true
+		}
+		return osReply{errMsg: fmt.Sprintf("KeyError: context variable %s is not set", name)}, true
+	case "set":
+		if len(args) != 1 || len(p.Kwargs) != 0 {
+			return osReply{errMsg: "TypeError: set() takes one value"}, true
+		}
+		// Progress events share the map, so replace rather than mutate it.
+		values := make(map[string]Object, len(r.context)+1)
+		for k, v := range r.context {
+			values[k] = v
+		}
+		values[name] = args[0]
+		r.context = values
+		return osReply{value: none{}}, true
+	}
+	return osReply{errMsg: fmt.Sprintf("AttributeError: ContextVar has no method %s", p.FunctionName)}, true
+}
+
+// contextVarName returns the name of a variable created by ContextVar.
+func contextVarName(o Object) (string, bool) {
+	if _, ok := handleRef(o, contextVarTypeID); !ok {
+		return "", false
+	}
+	var self struct {
+		Dataclass struct {
+			Attrs [][]json.RawMessage `json:"attrs"`
+		} `json:"$dataclass"`
+	}
+	if o.Unmarshal(&self) != nil {
+		return "", false
+	}
+	for _, kv := range self.Dataclass.Attrs {
+		var key, name string
+		if len(kv) == 2 && json.Unmarshal(kv[0], &key) == nil && key == "name" && json.Unmarshal(kv[1], &name) == nil {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,94 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+type ctxKey string
+
+// contextBridge scripts
+//
+//	v = ContextVar("request_id")
+//	report(v.get("none"))
+//	v.set("inner")
+//	v.get()
+//
+// feeding each resume payload into the next call.
+func contextBridge() *Sandbox {
+	var handle json.RawMessage
+	call := func(id uint32, name string, method bool, args ...json.RawMessage) sandboxResponse {
+		a, _ := json.Marshal(args)
+		return sandboxResponse{Progress: &sandboxProgress{
+			Kind: FunctionCall, CallID: id, FunctionName: name, MethodCall: method,
+			Args: a, Kwargs: json.RawMessage("[]"), Snapshot: uint64(id) + 10,
+		}}
+	}
+	return scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(call(1, ContextVarFunction, false, json.RawMessage(`"request_id"`))),
+		"resume": func(req sandboxRequest) sandboxResponse {
+			if req.ErrMsg != "" {
+				return sandboxResponse{Err: req.ErrMsg}
+			}
+			switch req.CallID {
+			case 1:
+				handle = req.Payload
+				return call(2, "get", true, handle, json.RawMessage(`"none"`))
+			case 2:
+				return call(3, "report", false, req.Payload)
+			case 3:
+				return call(4, "set", true, handle, json.RawMessage(`"inner"`))
+			case 4:
+				return call(5, "get", true, handle)
+			}
+			return echoResume(req)
+		},
+	})
+}
+
+func TestContextVars(t *testing.T) {
+	m, err := New("...", "main.py", nil, []string{ContextVarFunction, "report"},
+		WithSandbox(contextBridge()), WithContextVars(ContextVar{Name: "request_id", Key: ctxKey("rid")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	ctx := context.WithValue(context.Background(), ctxKey("rid"), "req-42")
+	p, err := m.StartContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.FunctionName != "report" || string(p.Args[0]) != `"req-42"` {
+		t.Fatalf("expected report(\"req-42\"), got %s%s", p.FunctionName, p.Args)
+	}
+	if string(p.Context["request_id"]) != `"req-42"` {
+		t.Fatalf("expected the context echoed on the event, got %v", p.Context)
+	}
+	done, err := p.Snapshot.Resume(p.CallID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(done.Result) != `"inner"` || string(done.Context["request_id"]) != `"inner"` {
+		t.Fatalf("expected the set value, got %s with %v", done.Result, done.Context)
+	}
+}
+
+func TestContextVarsUnset(t *testing.T) {
+	m, err := New("...", "main.py", nil, []string{ContextVarFunction, "report"},
+		WithSandbox(contextBridge()), WithContextVars(ContextVar{Name: "request_id", Key: ctxKey("rid")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeProgress(p)
+	if string(p.Args[0]) != `"none"` || p.Context != nil {
+		t.Fatalf("expected the default without a context, got %s and %v", p.Args, p.Context)
+	}
+}
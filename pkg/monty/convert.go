@@ -0,0 +1,172 @@
+package monty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Converter encodes host values that the JSON codec does not map onto
+// Python values by itself, returning the JSON the script receives. ok is
+// false for values the converter does not handle.
+type Converter func(v any) (data []byte, ok bool, err error)
+
+var converters struct {
+	sync.RWMutex
+	list []Converter
+}
+
+// RegisterConverter adds c to the converters consulted, in registration
+// order, for each Start input, call result, and future result before it is
+// encoded. Converters apply to those values themselves, not to values nested
+// inside them. Register converters during program initialization.
+func RegisterConverter(c Converter) {
+	converters.Lock()
+	converters.list = append(converters.list, c)
+	converters.Unlock()
+}
+
+// convertValue applies the first registered converter that handles v.
+func convertValue(v any) ([]byte, bool, error) {
+	converters.RLock()
+	defer converters.RUnlock()
+	for _, c := range converters.list {
+		if data, ok, err := c(v); ok || err != nil {
+			return data, ok, err
+		}
+	}
+	return nil, false, nil
+}
+
+// Plain re-encodes o as ordinary JSON, the way a host JSON library expects
+// it: dicts, dataclasses, and named tuples become objects; tuples and sets
+// become arrays; bytes become base64 strings; big ints become numbers; NaN
+// and infinities become null, as JSON.stringify renders them; and paths and
+// reprs become strings. Dict keys that are not strings are
+// rendered as JSON text, as json.dumps does for numbers, booleans, and None;
+// other keys are an error.
+func (o Object) Plain() (Object, error) {
+	if len(o) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(o))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	plain, err := plainValue(v, false)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(plain)
+}
+
+// plainValue flattens the tagged values in v. keepFloats leaves $float tags
+// for Float to decode instead of rendering them as null.
+func plainValue(v any, keepFloats bool) (any, error) {
+	switch tag, inner := treeTag(v); tag {
+	case "$dict":
+		out := make(map[string]any)
+		list, _ := inner.([]any)
+		for _, p := range list {
+			kv, ok := p.([]any)
+			if !ok || len(kv) != 2 {
+				return nil, fmt.Errorf("monty: invalid $dict entry")
+			}
+			key, err := plainKey(kv[0])
+			if err != nil {
+				return nil, err
+			}
+			if out[key], err = plainValue(kv[1], keepFloats); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case "$tuple", "$set", "$frozenset":
+		return plainValue(inner, keepFloats)
+	case "$bytes":
+		list, _ := inner.([]any)
+		data := make([]byte, len(list))
+		for i, b := range list {
+			n, _ := b.(json.Number)
+			i64, _ := n.Int64()
+			data[i] = byte(i64)
+		}
+		return data, nil
+	case "$bigint":
+		s, _ := inner.(string)
+		return json.Number(s), nil
+	case "$float":
+		if keepFloats {
+			return v, nil
+		}
+		return nil, nil
+	case "$path", "$repr", "$exception":
+		return inner, nil
+	case "$dataclass":
+		m, _ := inner.(map[string]any)
+		return plainValue(map[string]any{"$dict": m["attrs"]}, keepFloats)
+	case "$named_tuple":
+		m, _ := inner.(map[string]any)
+		names, _ := m["field_names"].([]any)
+		values, _ := m["values"].([]any)
+		out := make(map[string]any, len(names))
+		for i, name := range names {
+			key, _ := name.(string)
+			if i < len(values) {
+				value, err := plainValue(values[i], keepFloats)
+				if err != nil {
+					return nil, err
+				}
+				out[key] = value
+			}
+		}
+		return out, nil
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			value, err := plainValue(item, keepFloats)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = value
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			value, err := plainValue(item, keepFloats)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = value
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+func plainKey(k any) (string, error) {
+	switch k := k.(type) {
+	case string:
+		return k, nil
+	case json.Number:
+		return string(k), nil
+	case bool:
+		if k {
+			return "true", nil
+		}
+		return "false", nil
+	case nil:
+		return "null", nil
+	}
+	if tag, inner := treeTag(k); tag == "$bigint" {
+		s, _ := inner.(string)
+		return s, nil
+	}
+	return "", fmt.Errorf("monty: dict key %s is not representable in JSON", canonicalTree(k))
+}
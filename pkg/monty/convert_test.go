@@ -0,0 +1,51 @@
+package monty
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestObjectPlain(t *testing.T) {
+	o := Object(`{"$dict": [
+		["t", {"$tuple": [1, {"$set": ["a"]}]}],
+		[2, {"$bytes": [104, 105]}],
+		[true, {"$bigint": "123456789012345678901234567890"}],
+		["p", {"$path": "/tmp/x"}],
+		["dc", {"$dataclass": {"name": "P", "type_id": 1, "field_names": ["x"], "attrs": [["x", 1.5]], "frozen": false}}],
+		["nt", {"$named_tuple": {"type": "N", "field_names": ["a", "b"], "values": [null, []]}}]
+	]}`)
+	got, err := o.Plain()
+	if err != nil {
+		t.Fatalf("Plain failed: %v", err)
+	}
+	want := `{"2":"aGk=","dc":{"x":1.5},"nt":{"a":null,"b":[]},"p":"/tmp/x","t":[1,["a"]],"true":123456789012345678901234567890}`
+	if string(got) != want {
+		t.Fatalf("Plain = %s, want %s", got, want)
+	}
+	if _, err := Object(`{"$dict": [[{"$tuple": [1]}, 2]]}`).Plain(); err == nil {
+		t.Fatal("tuple keys should not convert")
+	}
+}
+
+type celsius float64
+
+func TestRegisterConverter(t *testing.T) {
+	RegisterConverter(func(v any) ([]byte, bool, error) {
+		c, ok := v.(celsius)
+		if !ok {
+			return nil, false, nil
+		}
+		if c < -273.15 {
+			return nil, true, errors.New("below absolute zero")
+		}
+		return []byte(fmt.Sprintf(`{"$tuple": [%v, "C"]}`, float64(c))), true, nil
+	})
+	data, err := marshalInputs([]any{celsius(21.5), 3})
+	if err != nil || string(data) != `[{"$tuple": [21.5, "C"]},3]` {
+		t.Fatalf("marshalInputs = %s, %v", data, err)
+	}
+	if _, err := marshalValue(celsius(-300)); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("converter error should be ErrInvalidInput, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownProgram is returned by a Coordinator for progress of a program
+// it does not hold, so the event can be handed to a worker that does.
+var ErrUnknownProgram = errors.New("monty: program not registered")
+
+// Reply answers a progress event handed to Coordinator.Resume: Result or Err
+// for a function or OS call, and Futures for ResolveFutures. Timer events
+// are woken whatever the reply.
+type Reply struct {
+	Result  any
+	Err     string
+	Futures []FutureResult
+}
+
+// Coordinator lets stateless workers continue runs started by any other
+// worker. Every worker registers the programs it serves; runs pause as
+// detached progress events, whose state is kept in a shared Store under
+// their Token, and any worker holding the program, found by
+// Progress.Program, loads the state by token and resumes it. Workers build
+// programs the same way, with New from the same source or NewFromBytes from
+// the same dump, so that their hashes match. It is safe for concurrent use.
+type Coordinator struct {
+	store Store
+
+	mu       sync.Mutex
+	programs map[string]*Monty
+}
+
+// NewCoordinator returns a coordinator keeping paused runs in store.
+func NewCoordinator(store Store) *Coordinator {
+	return &Coordinator{store: store, programs: make(map[string]*Monty)}
+}
+
+// Register lets the coordinator resume runs of m, and returns its hash.
+// Runs are resumed with the options m was created with.
+func (c *Coordinator) Register(m *Monty) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.programs[m.program] = m
+	return m.program
+}
+
+// Program returns the registered program with hash, or nil.
+func (c *Coordinator) Program(hash string) *Monty {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.programs[hash]
+}
+
+// Start begins a run of the registered program with hash and returns its
+// first event, detached unless the run completed.
+func (c *Coordinator) Start(ctx context.Context, hash string, inputs ...any) (Progress, error) {
+	m := c.Program(hash)
+	if m == nil {
+		return Progress{}, unknownProgram(hash)
+	}
+	p, err := m.StartContext(ctx, inputs...)
+	if err != nil {
+		return Progress{}, err
+	}
+	return p, c.detach(ctx, &p)
+}
+
+// Resume loads the state of the detached event p, answers it with reply,
+// and returns the run's next event, detached unless the run completed.
+// Events of programs this coordinator does not hold fail with
+// ErrUnknownProgram before their state is touched.
+func (c *Coordinator) Resume(ctx context.Context, p Progress, reply Reply) (Progress, error) {
+	if p.Kind == Complete {
+		return Progress{}, newError(ErrInvalidInput, "monty: run already complete")
+	}
+	m := c.Program(p.Program)
+	if m == nil {
+		return Progress{}, unknownProgram(p.Program)
+	}
+	var r *run
+	err := p.attach(ctx, c.store, func() *run {
+		r = m.cfg.newRun()
+		r.eng, r.program = m.eng, m.program
+		if m.src != nil {
+			r.script = m.src.scriptName
+		}
+		return r
+	})
+	if err != nil {
+		return Progress{}, err
+	}
+	if err := r.bindContext(ctx); err != nil {
+		closeProgress(p)
+		return Progress{}, err
+	}
+	var next Progress
+	switch {
+	case p.Kind == ResolveFutures:
+		next, err = p.FutureSnapshot.Resume(reply.Futures)
+	case p.Kind == Timer:
+		next, err = p.Snapshot.Wake(p.CallID)
+	case reply.Err != "":
+		next, err = p.Snapshot.ResumeError(p.CallID, reply.Err)
+	case reply.Result == nil:
+		next, err = p.Snapshot.Resume(p.CallID, none{})
+	default:
+		next, err = p.Snapshot.Resume(p.CallID, reply.Result)
+	}
+	if err != nil {
+		return Progress{}, err
+	}
+	return next, c.detach(ctx, &next)
+}
+
+// detach moves a paused p into the store, closing it if that fails.
+func (c *Coordinator) detach(ctx context.Context, p *Progress) error {
+	if err := p.Detach(ctx, c.store); err != nil {
+		closeProgress(*p)
+		return err
+	}
+	return nil
+}
+
+func unknownProgram(hash string) error {
+	return fmt.Errorf("%w: %q", ErrUnknownProgram, hash)
+}
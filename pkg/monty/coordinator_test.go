@@ -0,0 +1,79 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCoordinatorResumeOnAnotherWorker(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	worker := func() (*Coordinator, string) {
+		m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(callBridge("double", 1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { m.Close() })
+		c := NewCoordinator(store)
+		return c, c.Register(m)
+	}
+	a, hash := worker()
+	b, _ := worker()
+
+	p, err := a.Start(ctx, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Snapshot != nil || p.Token == "" || p.Program != hash {
+		t.Fatalf("Start returned %+v, want a detached event of %s", p, hash)
+	}
+	msg, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Progress
+	if err := json.Unmarshal(msg, &got); err != nil {
+		t.Fatal(err)
+	}
+	done, err := b.Resume(ctx, got, Reply{Result: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.Kind != Complete || string(done.Result) != "42" || done.RunID != p.RunID {
+		t.Errorf("Resume = %+v", done)
+	}
+	if _, err := b.Resume(ctx, got, Reply{Result: 42}); !errors.Is(err, ErrNotStored) {
+		t.Errorf("resuming twice: err = %v, want ErrNotStored", err)
+	}
+	if _, err := b.Resume(ctx, done, Reply{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("resuming a complete run: err = %v", err)
+	}
+}
+
+func TestCoordinatorUnknownProgram(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(callBridge("double", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	a := NewCoordinator(store)
+	p, err := a.Start(ctx, a.Register(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := NewCoordinator(store)
+	if _, err := other.Start(ctx, p.Program); !errors.Is(err, ErrUnknownProgram) {
+		t.Errorf("Start: err = %v, want ErrUnknownProgram", err)
+	}
+	if _, err := other.Resume(ctx, p, Reply{Result: 1}); !errors.Is(err, ErrUnknownProgram) {
+		t.Errorf("Resume: err = %v, want ErrUnknownProgram", err)
+	}
+	// The state is untouched, so a worker holding the program still can.
+	if _, err := a.Resume(ctx, p, Reply{Err: "ValueError: no"}); err != nil {
+		t.Errorf("Resume on the right worker: %v", err)
+	}
+}
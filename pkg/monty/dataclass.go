@@ -0,0 +1,49 @@
+package monty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Decode decodes o into target with Python containers flattened first, so
+// structured results fill Go structs by field name without dict plumbing.
+// Dataclass instances, TypedDicts and other dicts with string keys, and
+// named tuples decode into structs by field name, with the usual
+// encoding/json matching: json tags first, then case-insensitive names.
+// Tuples and sets decode into slices, bytes into []byte, and big ints into
+// any integer type wide enough. NaN and infinities decode only into Float.
+// Use Unmarshal instead to keep tags for Tuple and Set targets.
+func (o Object) Decode(target any) error {
+	if len(o) == 0 {
+		return fmt.Errorf("monty: empty object payload")
+	}
+	dec := json.NewDecoder(bytes.NewReader(o))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	plain, err := plainValue(v, true)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(plain)
+	if err != nil {
+		return err
+	}
+	return jsonUnmarshal(data, target)
+}
+
+// ClassName returns the class of a dataclass instance or named tuple, and ""
+// for other values, so hosts can pick a Go type before decoding a result.
+func (o Object) ClassName() string {
+	var name string
+	switch tag, inner := taggedValue(bytes.TrimSpace(o)); tag {
+	case "$dataclass":
+		name, _ = jsonString(memberValue(inner, "name"))
+	case "$named_tuple":
+		name, _ = jsonString(memberValue(inner, "type"))
+	}
+	return name
+}
@@ -0,0 +1,59 @@
+package monty
+
+import (
+	"math"
+	"testing"
+)
+
+type order struct {
+	ID    uint64
+	Items []lineItem `json:"items"`
+	Tags  []string
+	Total Float
+	Raw   []byte
+}
+
+type lineItem struct {
+	SKU string
+	Qty int
+}
+
+func TestObjectDecode(t *testing.T) {
+	o := Object(`{"$dataclass": {"name": "Order", "type_id": 1, "field_names": ["id", "items", "tags", "total", "raw"], "attrs": [
+		["id", {"$bigint": "18446744073709551615"}],
+		["items", [
+			{"$dict": [["sku", "a"], ["qty", 2]]},
+			{"$named_tuple": {"type": "Item", "field_names": ["sku", "qty"], "values": ["b", 1]}}
+		]],
+		["tags", {"$set": ["x"]}],
+		["total", {"$float": "inf"}],
+		["raw", {"$bytes": [104, 105]}]
+	], "frozen": true}}`)
+	if got := o.ClassName(); got != "Order" {
+		t.Fatalf("ClassName = %q", got)
+	}
+	var got order
+	if err := o.Decode(&got); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got.ID != math.MaxUint64 || len(got.Items) != 2 || got.Items[0] != (lineItem{"a", 2}) || got.Items[1] != (lineItem{"b", 1}) {
+		t.Fatalf("Decode = %+v", got)
+	}
+	if len(got.Tags) != 1 || !math.IsInf(float64(got.Total), 1) || string(got.Raw) != "hi" {
+		t.Fatalf("Decode = %+v", got)
+	}
+
+	var f struct{ F float64 }
+	if err := Object(`{"$dict": [["f", {"$float": "nan"}]]}`).Decode(&f); err == nil {
+		t.Fatal("NaN should not decode into a float64")
+	}
+	if err := Object(``).Decode(&f); err == nil {
+		t.Fatal("an empty Object should not decode")
+	}
+	if got := Object(`{"$named_tuple": {"type": "Item", "field_names": [], "values": []}}`).ClassName(); got != "Item" {
+		t.Fatalf("named tuple ClassName = %q", got)
+	}
+	if got := Object(`{"$dict": []}`).ClassName(); got != "" {
+		t.Fatalf("dict ClassName = %q", got)
+	}
+}
@@ -0,0 +1,107 @@
+package monty
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// OS clock functions answered from a virtual clock under WithDeterministic.
+const (
+	OsTime        = "time.time"
+	OsTimeNs      = "time.time_ns"
+	OsMonotonic   = "time.monotonic"
+	OsPerfCounter = "time.perf_counter"
+)
+
+// WithDeterministic makes a run depend only on its inputs and the results
+// the host supplies, as event-sourced replays of durable workflows require:
+//
+//   - random calls are answered from a generator seeded with 0, unless
+//     WithRandomSeed or WithRandomSource chose another;
+//   - clock reads return a virtual time that starts at the Unix epoch and
+//     advances only by the time the script sleeps;
+//   - time.localtime, time.strftime, and the other time zone and locale
+//     calls use UTC and LocaleC unless WithTimezone or WithLocale chose
+//     others;
+//   - environment reads see only the variables granted to the run, and
+//     values the host passes in encode with sorted map keys.
+//
+// Any other OS call, including HTTP requests, ends the run with a
+// *NondeterminismError instead of reaching the host. External function
+// calls are unaffected: their results are the host's to record and replay.
+// The virtual clock belongs to the run and is recorded in its dumps, so a
+// snapshot restored from bytes carries on from the time it was dumped at.
+// Iteration order is the interpreter's: dicts keep insertion order, while
+// the order of sets follows their hashing, which this option does not
+// control, so scripts replayed across library versions should sort sets
+// before depending on their order.
+func WithDeterministic() Option {
+	return func(c *config) {
+		c.deterministic = true
+		if c.newRand == nil {
+			c.newRand = func() *rand.Rand { return rand.New(rand.NewSource(0)) }
+		}
+	}
+}
+
+// NondeterminismError reports an OS call refused by WithDeterministic.
+type NondeterminismError struct {
+	Function string
+}
+
+func (e *NondeterminismError) Error() string {
+	return fmt.Sprintf("monty: %s is nondeterministic and cannot run in deterministic mode", e.Function)
+}
+
+// Unwrap classifies refused calls as ErrNondeterministic.
+func (e *NondeterminismError) Unwrap() error {
+	return ErrNondeterministic
+}
+
+// clockCall answers a clock read from the Clock given with WithClock, the
+// run's virtual clock, or the host's clock under a profile other than
+// ProfilePure.
+func (r *run) clockCall(p Progress) osReply {
+	if err := unmarshalArgs(p); err != nil {
+		return osReply{errMsg: err.Error()}
+	}
+	if c := r.cfg.clock; c != nil {
+		switch p.OsFunction {
+		case OsTime:
+			return osReply{value: float64(c.Now().UnixNano()) / 1e9}
+		case OsTimeNs:
+			return osReply{value: c.Now().UnixNano()}
+		}
+		return osReply{value: c.elapsed().Seconds()}
+	}
+	if r.cfg.hostClock() {
+		switch p.OsFunction {
+		case OsTime:
+			return osReply{value: float64(time.Now().UnixNano()) / 1e9}
+		case OsTimeNs:
+			return osReply{value: time.Now().UnixNano()}
+		}
+		return osReply{value: time.Since(started).Seconds()}
+	}
+	if p.OsFunction == OsTimeNs {
+		return osReply{value: r.clock.Nanoseconds()}
+	}
+	return osReply{value: r.clock.Seconds()}
+}
+
+// nondeterministic reports an event the host would have to answer that a
+// deterministic run must not depend on.
+func (r *run) nondeterministic(p Progress) error {
+	if r.cfg.deterministic && p.Kind == OsCall {
+		return &NondeterminismError{Function: p.OsFunction}
+	}
+	return nil
+}
+
+// advance moves the virtual clock past a sleep.
+func (r *run) advance(d time.Duration) {
+	if r.cfg.deterministic {
+		r.clock += d
+	}
+}
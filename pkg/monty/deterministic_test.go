@@ -0,0 +1,79 @@
+package monty
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeterministicClockAndRandom(t *testing.T) {
+	cfg := newConfig([]Option{WithDeterministic()})
+	first, second := cfg.newRun(), cfg.newRun()
+	call := Progress{Kind: OsCall, OsFunction: OsRandom}
+	if a, b := first.randomCall(call), second.randomCall(call); a.value != b.value {
+		t.Fatalf("deterministic runs drew %v and %v", a.value, b.value)
+	}
+
+	r := cfg.newRun()
+	now := Progress{Kind: OsCall, OsFunction: OsTime}
+	if reply, ok := r.osCall(now); !ok || reply.value != 0.0 {
+		t.Fatalf("virtual clock should start at the epoch, got %+v", reply)
+	}
+	sleep := Progress{Kind: OsCall, OsFunction: OsSleep, Args: []Object{Object("1.5")}}
+	if _, ok := r.answer(&sleep); ok || sleep.Kind != Timer {
+		t.Fatalf("sleeps should still surface as Timer events")
+	}
+	if reply, _ := r.osCall(now); reply.value != 1.5 {
+		t.Fatalf("virtual clock should advance by the sleep, got %v", reply.value)
+	}
+	if reply, _ := r.osCall(Progress{Kind: OsCall, OsFunction: OsTimeNs}); reply.value != int64(1500*time.Millisecond) {
+		t.Fatalf("unexpected time_ns %v", reply.value)
+	}
+
+	if _, ok := newConfig(nil).newRun().osCall(now); ok {
+		t.Fatalf("clock reads should reach the host outside deterministic mode")
+	}
+}
+
+func TestDeterministicRefusesHostOsCalls(t *testing.T) {
+	r := newConfig([]Option{WithDeterministic()}).newRun()
+	for _, fn := range []string{"Path.read_text", OsHTTPRequest} {
+		_, err := r.intercept(Progress{Kind: OsCall, OsFunction: fn, Snapshot: &Snapshot{}})
+		var ne *NondeterminismError
+		if !errors.As(err, &ne) || ne.Function != fn || !errors.Is(err, ErrNondeterministic) {
+			t.Fatalf("%s should be refused, got %v", fn, err)
+		}
+	}
+	p, err := r.intercept(Progress{Kind: FunctionCall, FunctionName: "fetch", Snapshot: &Snapshot{}})
+	if err != nil || p.Kind != FunctionCall {
+		t.Fatalf("external calls should reach the host, got %v", err)
+	}
+}
+
+func TestDeterministicClockSurvivesDumps(t *testing.T) {
+	sb := versionedBridge()
+	m, err := New("f()", "main.py", nil, []string{"f"}, WithSandbox(sb), WithDeterministic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Snapshot.run.clock = 90 * time.Second
+	data, err := p.Snapshot.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := ReadDumpInfo(data); err != nil || info.Clock != 90*time.Second {
+		t.Fatalf("ReadDumpInfo = %+v, %v", info, err)
+	}
+	restored, err := SnapshotFromBytes(data, WithSandbox(sb), WithDeterministic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply, _ := restored.run.osCall(Progress{Kind: OsCall, OsFunction: OsTime}); reply.value != 90.0 {
+		t.Fatalf("restored clock reads %v, want 90", reply.value)
+	}
+}
@@ -0,0 +1,356 @@
+// Package dispatch fans the pending external calls of a run out to a message
+// queue and resumes the run as results come back. Each run is identified by
+// a resume token; its state lives in a monty.Store between messages, so the
+// process that consumes a result need not be the one that started the run.
+//
+// Queue clients (NATS, Kafka, SQS, ...) plug in through Publisher on the
+// sending side and by passing received results to Dispatcher.Complete.
+package dispatch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+	"github.com/ricochet1k/monty-go/pkg/montyflow"
+)
+
+// Call is published once for each external call a run waits on.
+type Call struct {
+	Token    string                     `json:"token"`
+	CallID   uint32                     `json:"call_id"`
+	Function string                     `json:"function"`
+	OS       bool                       `json:"os,omitempty"`
+	Args     []json.RawMessage          `json:"args,omitempty"`
+	Kwargs   map[string]json.RawMessage `json:"kwargs,omitempty"`
+}
+
+// Result answers a published Call.
+type Result struct {
+	Token  string          `json:"token"`
+	CallID uint32          `json:"call_id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// ErrNotWaiting is returned, wrapped, by Complete for a result the run is not
+// waiting on, such as a second result for the same call.
+var ErrNotWaiting = errors.New("call not pending")
+
+// Publisher sends calls to workers.
+type Publisher interface {
+	Publish(ctx context.Context, call Call) error
+}
+
+// PublisherFunc adapts a function to Publisher.
+type PublisherFunc func(ctx context.Context, call Call) error
+
+func (f PublisherFunc) Publish(ctx context.Context, call Call) error {
+	return f(ctx, call)
+}
+
+// Dispatcher starts runs and resumes them from results.
+type Dispatcher struct {
+	pub   Publisher
+	store monty.Store
+	opts  []monty.Option
+
+	// OnComplete, if set, receives each run's final result.
+	OnComplete func(ctx context.Context, token string, result monty.Object)
+	// OnError, if set, receives errors from wake-ups fired by the default scheduler.
+	OnError func(token string, err error)
+	// Scheduler wakes runs that sleep. If nil, a TimerWheel with one-second
+	// ticks is started on first use; Close stops it.
+	Scheduler Scheduler
+
+	mu    sync.Mutex
+	locks map[string]*tokenLock
+	wheel *TimerWheel
+}
+
+// tokenLock serializes work on one token; refs counts goroutines using it.
+type tokenLock struct {
+	sync.Mutex
+	refs int
+}
+
+// record is the stored state of a run.
+type record struct {
+	State montyflow.State `json:"state"`
+	// Published lists call IDs already sent to the queue.
+	Published []uint32 `json:"published,omitempty"`
+	// WakeAt is when a sleeping run is due.
+	WakeAt time.Time `json:"wake_at,omitempty"`
+}
+
+// New returns a Dispatcher that publishes to pub and keeps run state in store.
+// opts configure every program it starts and resumes.
+func New(pub Publisher, store monty.Store, opts ...monty.Option) *Dispatcher {
+	return &Dispatcher{pub: pub, store: store, opts: opts, locks: make(map[string]*tokenLock)}
+}
+
+// Start runs a program until it waits on external calls, publishes them, and
+// returns the run's resume token. External calls are answered with futures so
+// that calls a script awaits together are published together.
+func (d *Dispatcher) Start(ctx context.Context, in montyflow.StartInput) (string, error) {
+	in.Async = true
+	st, err := montyflow.Start(ctx, in, d.opts...)
+	if err != nil {
+		return "", err
+	}
+	token := newToken()
+	unlock := d.lock(token)
+	defer unlock()
+	return token, d.save(ctx, token, record{State: st})
+}
+
+// Complete applies one result to its run, publishing any calls the run makes
+// next. Results for the same token are applied one at a time within this
+// process; consumers in different processes must partition by token. A
+// result for a call the run is not waiting on, or one that was never
+// published, is an error and leaves the run as it was.
+func (d *Dispatcher) Complete(ctx context.Context, res Result) error {
+	unlock := d.lock(res.Token)
+	defer unlock()
+	data, err := d.store.Get(ctx, res.Token)
+	if err != nil {
+		return err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+	if rec.State.WakeAfter > 0 {
+		return fmt.Errorf("dispatch: run %s is sleeping, not waiting on call %d: %w", res.Token, res.CallID, ErrNotWaiting)
+	}
+	if !rec.waitsOn(res.CallID) {
+		return fmt.Errorf("dispatch: run %s, call %d: %w", res.Token, res.CallID, ErrNotWaiting)
+	}
+	st, err := montyflow.Resume(ctx, montyflow.ResumeInput{
+		State:       rec.State,
+		Completions: []montyflow.Completion{{CallID: res.CallID, Result: res.Result, Error: res.Error}},
+	}, d.opts...)
+	if err != nil {
+		return err
+	}
+	published := rec.Published[:0]
+	for _, id := range rec.Published {
+		if id != res.CallID {
+			published = append(published, id)
+		}
+	}
+	return d.save(ctx, res.Token, record{State: st, Published: published})
+}
+
+// Republish publishes the calls of a run that a failed Publish left unsent.
+// Calls already published are not sent again.
+func (d *Dispatcher) Republish(ctx context.Context, token string) error {
+	unlock := d.lock(token)
+	defer unlock()
+	data, err := d.store.Get(ctx, token)
+	if err != nil {
+		return err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+	if rec.State.WakeAfter > 0 {
+		// A sleeping run has no calls, and saving it would push its wake-up back.
+		return nil
+	}
+	return d.save(ctx, token, rec)
+}
+
+// Wake resumes a sleeping run once it is due. Waking a run early, or one that
+// is not sleeping, is an error.
+func (d *Dispatcher) Wake(ctx context.Context, token string) error {
+	unlock := d.lock(token)
+	defer unlock()
+	data, err := d.store.Get(ctx, token)
+	if err != nil {
+		return err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+	if rec.State.WakeAfter == 0 {
+		return fmt.Errorf("dispatch: run %s is not sleeping", token)
+	}
+	if time.Now().Before(rec.WakeAt) {
+		return &notDueError{token: token, at: rec.WakeAt}
+	}
+	st, err := montyflow.Resume(ctx, montyflow.ResumeInput{State: rec.State}, d.opts...)
+	if err != nil {
+		return err
+	}
+	return d.save(ctx, token, record{State: st, Published: rec.Published})
+}
+
+// notDueError is returned by Wake for a run woken early.
+type notDueError struct {
+	token string
+	at    time.Time
+}
+
+func (e *notDueError) Error() string {
+	return fmt.Sprintf("dispatch: run %s is not due until %s", e.token, e.at.Format(time.RFC3339Nano))
+}
+
+// Close stops the default scheduler, if it was started.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.wheel != nil {
+		d.wheel.Stop()
+		d.wheel = nil
+	}
+}
+
+func (d *Dispatcher) scheduler() Scheduler {
+	if d.Scheduler != nil {
+		return d.Scheduler
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.wheel == nil {
+		var wheel *TimerWheel
+		wheel = NewTimerWheel(time.Second, 3600, func(token string) {
+			err := d.Wake(context.Background(), token)
+			if early := (*notDueError)(nil); errors.As(err, &early) {
+				// The wheel may fire up to a tick early; try again when due.
+				err = wheel.Schedule(context.Background(), early.at, token)
+			}
+			if err != nil && d.OnError != nil {
+				d.OnError(token, err)
+			}
+		})
+		d.wheel = wheel
+	}
+	return d.wheel
+}
+
+// Consume applies results from ch until it is closed or ctx is done. Errors
+// are passed to onErr, which may be nil.
+func (d *Dispatcher) Consume(ctx context.Context, ch <-chan Result, onErr func(Result, error)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case res, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := d.Complete(ctx, res); err != nil && onErr != nil {
+				onErr(res, err)
+			}
+		}
+	}
+}
+
+// save stores the run, then publishes calls not yet published or schedules
+// its wake-up; a completed run is deleted instead.
+func (d *Dispatcher) save(ctx context.Context, token string, rec record) error {
+	if rec.State.Done {
+		if err := d.store.Delete(ctx, token); err != nil {
+			return err
+		}
+		if d.OnComplete != nil {
+			d.OnComplete(ctx, token, monty.Object(rec.State.Result))
+		}
+		return nil
+	}
+	if rec.State.WakeAfter > 0 {
+		rec.WakeAt = time.Now().Add(rec.State.WakeAfter)
+	}
+	sent := make(map[uint32]bool, len(rec.Published))
+	for _, id := range rec.Published {
+		sent[id] = true
+	}
+	var fresh []montyflow.Call
+	for _, c := range rec.State.Calls {
+		if !sent[c.CallID] {
+			fresh = append(fresh, c)
+			rec.Published = append(rec.Published, c.CallID)
+		}
+	}
+	// Store before publishing so a fast result always finds the run.
+	if err := d.put(ctx, token, rec); err != nil {
+		return err
+	}
+	if !rec.WakeAt.IsZero() {
+		if err := d.scheduler().Schedule(ctx, rec.WakeAt, token); err != nil {
+			return fmt.Errorf("dispatch: schedule wake-up: %w", err)
+		}
+	}
+	for i, c := range fresh {
+		call := Call{Token: token, CallID: c.CallID, Function: c.Function, OS: c.OS, Args: c.Args, Kwargs: c.Kwargs}
+		if err := d.pub.Publish(ctx, call); err != nil {
+			err = fmt.Errorf("dispatch: publish call %d: %w", c.CallID, err)
+			// Unlist the calls not sent, so results for them are refused and
+			// Republish or the run's next save sends them.
+			rec.Published = rec.Published[:len(rec.Published)-len(fresh)+i]
+			return errors.Join(err, d.put(ctx, token, rec))
+		}
+	}
+	return nil
+}
+
+// put stores rec under token.
+func (d *Dispatcher) put(ctx context.Context, token string, rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return d.store.Put(ctx, token, data)
+}
+
+// waitsOn reports whether the run waits on the published call id.
+func (rec *record) waitsOn(id uint32) bool {
+	if !slices.Contains(rec.Published, id) {
+		return false
+	}
+	for _, c := range rec.State.Calls {
+		if c.CallID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// lock serializes work on one token and returns its unlock function.
+func (d *Dispatcher) lock(token string) func() {
+	d.mu.Lock()
+	l, ok := d.locks[token]
+	if !ok {
+		l = &tokenLock{}
+		d.locks[token] = l
+	}
+	l.refs++
+	d.mu.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		d.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(d.locks, token)
+		}
+		d.mu.Unlock()
+	}
+}
+
+func newToken() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
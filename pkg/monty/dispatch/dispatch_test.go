@@ -0,0 +1,118 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+	"github.com/ricochet1k/monty-go/pkg/montyflow"
+)
+
+func TestSavePublishesNewCallsOnce(t *testing.T) {
+	var published []Call
+	store := monty.NewMemoryStore()
+	d := New(PublisherFunc(func(_ context.Context, c Call) error {
+		published = append(published, c)
+		return nil
+	}), store)
+	ctx := context.Background()
+
+	st := montyflow.State{Snapshot: []byte{1}, Future: true, Calls: []montyflow.Call{{CallID: 1, Function: "a"}, {CallID: 2, Function: "b"}}}
+	if err := d.save(ctx, "tok", record{State: st}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if err := d.save(ctx, "tok", record{State: st, Published: []uint32{1, 2}}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if len(published) != 2 || published[0].Token != "tok" || published[1].Function != "b" {
+		t.Fatalf("unexpected publications: %+v", published)
+	}
+
+	data, err := store.Get(ctx, "tok")
+	if err != nil {
+		t.Fatalf("run not stored: %v", err)
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil || len(rec.Published) != 2 {
+		t.Fatalf("unexpected record %s (%v)", data, err)
+	}
+}
+
+func TestSaveFinishesCompletedRun(t *testing.T) {
+	store := monty.NewMemoryStore()
+	d := New(PublisherFunc(func(context.Context, Call) error { return nil }), store)
+	var got string
+	d.OnComplete = func(_ context.Context, token string, result monty.Object) {
+		got = token + "=" + string(result)
+	}
+	ctx := context.Background()
+	store.Put(ctx, "tok", []byte("{}"))
+	if err := d.save(ctx, "tok", record{State: montyflow.State{Done: true, Result: json.RawMessage("7")}}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if got != "tok=7" {
+		t.Fatalf("unexpected completion %q", got)
+	}
+	if _, err := store.Get(ctx, "tok"); !errors.Is(err, monty.ErrNotStored) {
+		t.Fatalf("expected run to be deleted, got %v", err)
+	}
+	if err := d.Complete(ctx, Result{Token: "tok"}); !errors.Is(err, monty.ErrNotStored) {
+		t.Fatalf("expected ErrNotStored for finished run, got %v", err)
+	}
+}
+
+func TestSaveUnlistsCallsNotPublished(t *testing.T) {
+	var published []uint32
+	fail := true
+	store := monty.NewMemoryStore()
+	d := New(PublisherFunc(func(_ context.Context, c Call) error {
+		if c.CallID == 2 && fail {
+			return errors.New("queue down")
+		}
+		published = append(published, c.CallID)
+		return nil
+	}), store)
+	ctx := context.Background()
+
+	st := montyflow.State{Snapshot: []byte{1}, Future: true, Calls: []montyflow.Call{{CallID: 1, Function: "a"}, {CallID: 2, Function: "b"}, {CallID: 3, Function: "c"}}}
+	if err := d.save(ctx, "tok", record{State: st}); err == nil {
+		t.Fatal("expected the publish error")
+	}
+	data, _ := store.Get(ctx, "tok")
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil || len(rec.Published) != 1 || rec.Published[0] != 1 {
+		t.Fatalf("unexpected record %s (%v)", data, err)
+	}
+	if err := d.Complete(ctx, Result{Token: "tok", CallID: 2, Result: json.RawMessage("1")}); err == nil {
+		t.Fatal("expected a result for an unpublished call to be refused")
+	}
+
+	fail = false
+	if err := d.Republish(ctx, "tok"); err != nil {
+		t.Fatalf("Republish failed: %v", err)
+	}
+	if len(published) != 3 || published[1] != 2 || published[2] != 3 {
+		t.Fatalf("unexpected publications: %v", published)
+	}
+}
+
+func TestCompleteRefusesUnknownCalls(t *testing.T) {
+	store := monty.NewMemoryStore()
+	d := New(PublisherFunc(func(context.Context, Call) error { return nil }), store)
+	ctx := context.Background()
+	st := montyflow.State{Snapshot: []byte{1}, Future: true, Calls: []montyflow.Call{{CallID: 1, Function: "a"}}}
+	if err := d.save(ctx, "tok", record{State: st}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	before, _ := store.Get(ctx, "tok")
+	for _, id := range []uint32{0, 7} {
+		if err := d.Complete(ctx, Result{Token: "tok", CallID: id}); err == nil {
+			t.Errorf("Complete(call %d) succeeded", id)
+		}
+	}
+	if after, _ := store.Get(ctx, "tok"); string(after) != string(before) {
+		t.Fatalf("run changed from %s to %s", before, after)
+	}
+}
@@ -0,0 +1,101 @@
+package dispatch
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Scheduler wakes sleeping runs. A durable implementation (a cron table, a
+// delayed queue message) persists the wake-up so it survives restarts, and
+// calls Dispatcher.Wake with the token once at has passed.
+type Scheduler interface {
+	Schedule(ctx context.Context, at time.Time, token string) error
+}
+
+// TimerWheel is an in-process Scheduler. It keeps wake-ups in memory, so they
+// are lost when the process exits; the runs themselves stay in the store and
+// can be woken by calling Dispatcher.Wake directly.
+type TimerWheel struct {
+	tick time.Duration
+	fire func(token string)
+
+	mu    sync.Mutex
+	slots [][]wheelEntry
+	pos   int
+	next  time.Time // when the slot after pos fires
+	stop  chan struct{}
+	once  sync.Once
+}
+
+type wheelEntry struct {
+	token  string
+	rounds int
+}
+
+// NewTimerWheel starts a wheel of slots buckets advancing every tick, which
+// calls fire for each due token. Wake-ups are accurate to one tick.
+func NewTimerWheel(tick time.Duration, slots int, fire func(token string)) *TimerWheel {
+	if slots < 1 {
+		slots = 1
+	}
+	w := &TimerWheel{tick: tick, fire: fire, slots: make([][]wheelEntry, slots), next: time.Now().Add(tick), stop: make(chan struct{})}
+	go w.loop()
+	return w
+}
+
+// Schedule arranges for token to fire at or shortly after at.
+func (w *TimerWheel) Schedule(_ context.Context, at time.Time, token string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Count from the next tick, which is less than a whole tick away.
+	ticks := 1
+	if wait := at.Sub(w.next); wait > 0 {
+		ticks += int((wait + w.tick - 1) / w.tick)
+	}
+	n := len(w.slots)
+	slot := (w.pos + ticks) % n
+	w.slots[slot] = append(w.slots[slot], wheelEntry{token: token, rounds: (ticks - 1) / n})
+	return nil
+}
+
+// Stop halts the wheel; pending wake-ups are dropped.
+func (w *TimerWheel) Stop() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+func (w *TimerWheel) loop() {
+	t := time.NewTicker(w.tick)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case now := <-t.C:
+			for _, token := range w.advance(now) {
+				w.fire(token)
+			}
+		}
+	}
+}
+
+// advance moves to the next slot at the tick now and returns the tokens now
+// due.
+func (w *TimerWheel) advance(now time.Time) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pos = (w.pos + 1) % len(w.slots)
+	w.next = now.Add(w.tick)
+	var due []string
+	kept := w.slots[w.pos][:0]
+	for _, e := range w.slots[w.pos] {
+		if e.rounds == 0 {
+			due = append(due, e.token)
+			continue
+		}
+		e.rounds--
+		kept = append(kept, e)
+	}
+	w.slots[w.pos] = kept
+	return due
+}
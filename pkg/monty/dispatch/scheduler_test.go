@@ -0,0 +1,56 @@
+package dispatch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTimerWheelFiresInOrder(t *testing.T) {
+	fired := make(chan string, 2)
+	w := NewTimerWheel(5*time.Millisecond, 4, func(token string) { fired <- token })
+	defer w.Stop()
+	now := time.Now()
+	// The later wake-up wraps the wheel more than once.
+	w.Schedule(context.Background(), now.Add(60*time.Millisecond), "late")
+	w.Schedule(context.Background(), now.Add(10*time.Millisecond), "early")
+	for _, want := range []string{"early", "late"} {
+		select {
+		case got := <-fired:
+			if got != want {
+				t.Fatalf("expected %s, got %s", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+}
+
+func TestTimerWheelNeverFiresEarly(t *testing.T) {
+	type firing struct {
+		token string
+		at    time.Time
+	}
+	fired := make(chan firing, 8)
+	w := NewTimerWheel(20*time.Millisecond, 8, func(token string) { fired <- firing{token, time.Now()} })
+	defer w.Stop()
+	// Schedule part way between ticks, when the next tick is closer than one
+	// tick away.
+	time.Sleep(15 * time.Millisecond)
+	due := map[string]time.Time{}
+	for _, d := range []time.Duration{time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond} {
+		token := d.String()
+		due[token] = time.Now().Add(d)
+		w.Schedule(context.Background(), due[token], token)
+	}
+	for range due {
+		select {
+		case f := <-fired:
+			if f.at.Before(due[f.token]) {
+				t.Errorf("%s fired %v early", f.token, due[f.token].Sub(f.at))
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for wake-ups")
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package dispatch
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+// Webhooks resolves calls through signed callback URLs, for external work
+// that reports back over HTTP long after the call was made. Each URL
+// identifies one call of one run and carries an HMAC signature, so callbacks
+// cannot be forged or redirected to another call. Each URL resolves its call
+// once: replays, concurrent ones included, are refused.
+type Webhooks struct {
+	d    *Dispatcher
+	base string
+	key  []byte
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu sync.Mutex
+	// inflight holds the callbacks being applied; once applied, the run no
+	// longer waits on the call and Complete refuses it.
+	inflight map[callback]bool
+}
+
+// callback identifies the call a callback URL resolves.
+type callback struct {
+	token  string
+	callID uint32
+}
+
+// NewWebhooks returns Webhooks that resume runs through d. Callback URLs point
+// at base, which must route to Handler, are signed with key, and expire after
+// ttl; a zero ttl never expires.
+func NewWebhooks(d *Dispatcher, base string, key []byte, ttl time.Duration) *Webhooks {
+	return &Webhooks{d: d, base: base, key: append([]byte(nil), key...), ttl: ttl, now: time.Now, inflight: make(map[callback]bool)}
+}
+
+// URL mints the callback URL for call.
+func (w *Webhooks) URL(call Call) string {
+	var exp int64
+	if w.ttl > 0 {
+		exp = w.now().Add(w.ttl).Unix()
+	}
+	q := url.Values{}
+	q.Set("token", call.Token)
+	q.Set("call", strconv.FormatUint(uint64(call.CallID), 10))
+	q.Set("exp", strconv.FormatInt(exp, 10))
+	q.Set("sig", w.sign(call.Token, call.CallID, exp))
+	return w.base + "?" + q.Encode()
+}
+
+// Publisher returns a Publisher that hands each call and its callback URL to
+// send, which starts the external work.
+func (w *Webhooks) Publisher(send func(ctx context.Context, call Call, callbackURL string) error) Publisher {
+	return PublisherFunc(func(ctx context.Context, call Call) error {
+		return send(ctx, call, w.URL(call))
+	})
+}
+
+// Handler accepts callbacks. The POST body is {"result": <json>} or
+// {"error": "message"}; the run resumes before the response is written, with
+// 204 No Content. Forged or expired URLs get 403 Forbidden, malformed ones and
+// bodies 400 Bad Request, and callbacks for calls already resolved, or runs
+// no longer stored, 409 Conflict. Other failures, such as the store being
+// unavailable, get 500 and may be retried.
+func (w *Webhooks) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		token, callID, err := w.verify(r.URL.Query())
+		if err != nil {
+			code := http.StatusForbidden
+			if errors.Is(err, errMalformedCallback) {
+				code = http.StatusBadRequest
+			}
+			http.Error(rw, err.Error(), code)
+			return
+		}
+		var body struct {
+			Result json.RawMessage `json:"result"`
+			Error  string          `json:"error"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxCallbackBytes)).Decode(&body); err != nil {
+			code := http.StatusBadRequest
+			if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+				code = http.StatusRequestEntityTooLarge
+			}
+			http.Error(rw, err.Error(), code)
+			return
+		}
+		cb := callback{token, callID}
+		if !w.claim(cb) {
+			http.Error(rw, "dispatch: callback already in progress", http.StatusConflict)
+			return
+		}
+		defer w.release(cb)
+		res := Result{Token: token, CallID: callID, Result: body.Result, Error: body.Error}
+		if err := w.d.Complete(r.Context(), res); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, ErrNotWaiting) || errors.Is(err, monty.ErrNotStored) {
+				code = http.StatusConflict
+			}
+			http.Error(rw, err.Error(), code)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// maxCallbackBytes bounds callback bodies.
+const maxCallbackBytes = 64 << 20
+
+// errMalformedCallback is wrapped by verify for URLs it cannot parse.
+var errMalformedCallback = errors.New("malformed callback URL")
+
+func (w *Webhooks) verify(q url.Values) (string, uint32, error) {
+	token := q.Get("token")
+	callID, err := strconv.ParseUint(q.Get("call"), 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("dispatch: invalid callback call id: %w", errMalformedCallback)
+	}
+	exp, err := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("dispatch: invalid callback expiry: %w", errMalformedCallback)
+	}
+	want := w.sign(token, uint32(callID), exp)
+	if !hmac.Equal([]byte(want), []byte(q.Get("sig"))) {
+		return "", 0, errors.New("dispatch: bad callback signature")
+	}
+	if exp != 0 && w.now().Unix() > exp {
+		return "", 0, errors.New("dispatch: callback URL expired")
+	}
+	return token, uint32(callID), nil
+}
+
+// claim marks cb in progress, reporting false if it already was.
+func (w *Webhooks) claim(cb callback) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.inflight[cb] {
+		return false
+	}
+	w.inflight[cb] = true
+	return true
+}
+
+func (w *Webhooks) release(cb callback) {
+	w.mu.Lock()
+	delete(w.inflight, cb)
+	w.mu.Unlock()
+}
+
+func (w *Webhooks) sign(token string, callID uint32, exp int64) string {
+	mac := hmac.New(sha256.New, w.key)
+	fmt.Fprintf(mac, "%s\n%d\n%d", token, callID, exp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
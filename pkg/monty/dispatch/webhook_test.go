@@ -0,0 +1,90 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+	"github.com/ricochet1k/monty-go/pkg/montyflow"
+)
+
+func TestWebhookSignatures(t *testing.T) {
+	d := New(PublisherFunc(func(context.Context, Call) error { return nil }), monty.NewMemoryStore())
+	w := NewWebhooks(d, "https://host/cb", []byte("secret"), time.Minute)
+	now := time.Unix(1000, 0)
+	w.now = func() time.Time { return now }
+
+	callback := w.URL(Call{Token: "tok", CallID: 3})
+	post := func(target string) int {
+		rec := httptest.NewRecorder()
+		w.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"result":1}`)))
+		return rec.Code
+	}
+
+	// A valid signature reaches the dispatcher, which has no such run.
+	if code := post(callback); code != http.StatusConflict {
+		t.Fatalf("valid callback: expected %d, got %d", http.StatusConflict, code)
+	}
+	if code := post(strings.Replace(callback, "call=3", "call=4", 1)); code != http.StatusForbidden {
+		t.Fatalf("tampered callback: expected %d, got %d", http.StatusForbidden, code)
+	}
+	now = now.Add(2 * time.Minute)
+	if code := post(callback); code != http.StatusForbidden {
+		t.Fatalf("expired callback: expected %d, got %d", http.StatusForbidden, code)
+	}
+}
+
+// failingStore is a Store that is down.
+type failingStore struct{}
+
+func (failingStore) Put(context.Context, string, []byte) error { return errors.New("store down") }
+func (failingStore) Get(context.Context, string) ([]byte, error) {
+	return nil, errors.New("store down")
+}
+func (failingStore) Delete(context.Context, string) error { return errors.New("store down") }
+
+func TestWebhookStatusCodes(t *testing.T) {
+	ctx := context.Background()
+	store := monty.NewMemoryStore()
+	d := New(PublisherFunc(func(context.Context, Call) error { return nil }), store)
+	w := NewWebhooks(d, "https://host/cb", []byte("secret"), 0)
+	post := func(w *Webhooks, target string) int {
+		rec := httptest.NewRecorder()
+		w.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"result":1}`)))
+		return rec.Code
+	}
+
+	// The run waits on call 1 only, as it does once call 2 is resolved.
+	st := montyflow.State{Snapshot: []byte{1}, Future: true, Calls: []montyflow.Call{{CallID: 1, Function: "a"}}}
+	if err := d.save(ctx, "tok", record{State: st}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if code := post(w, w.URL(Call{Token: "tok", CallID: 2})); code != http.StatusConflict {
+		t.Errorf("replayed callback: expected %d, got %d", http.StatusConflict, code)
+	}
+	if code := post(w, "https://host/cb?token=tok&call=x"); code != http.StatusBadRequest {
+		t.Errorf("malformed callback: expected %d, got %d", http.StatusBadRequest, code)
+	}
+
+	down := NewWebhooks(New(nil, failingStore{}), "https://host/cb", []byte("secret"), 0)
+	if code := post(down, down.URL(Call{Token: "tok", CallID: 1})); code != http.StatusInternalServerError {
+		t.Errorf("store down: expected %d, got %d", http.StatusInternalServerError, code)
+	}
+
+	cb := callback{"tok", 1}
+	if !w.claim(cb) || w.claim(cb) {
+		t.Fatal("a callback in progress was claimed twice")
+	}
+	if code := post(w, w.URL(Call{Token: "tok", CallID: 1})); code != http.StatusConflict {
+		t.Errorf("concurrent callback: expected %d, got %d", http.StatusConflict, code)
+	}
+	w.release(cb)
+	if !w.claim(cb) {
+		t.Fatal("a released callback could not be claimed")
+	}
+}
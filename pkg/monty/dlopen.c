@@ -0,0 +1,240 @@
+//go:build cgo && !nomonty && (monty_dlopen || monty_embed)
+
+// Trampolines that resolve libmonty_ffi at runtime instead of link time. Each
+// exported monty_* symbol forwards to the function found with dlsym.
+
+#include <dlfcn.h>
+#include <pthread.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include "monty_ffi.h"
+
+#if defined(__APPLE__)
+#define MONTY_DEFAULT_LIBRARY "libmonty_ffi.dylib"
+#else
+#define MONTY_DEFAULT_LIBRARY "libmonty_ffi.so"
+#endif
+
+#define MONTY_SYMBOLS(X)                  \
+  X(monty_ffi_abi_version)                \
+  X(monty_ffi_version)                    \
+  X(monty_mem_stats)                      \
+  X(monty_run_new)                        \
+  X(monty_run_dump)                       \
+  X(monty_run_load)                       \
+  X(monty_run_free)                       \
+  X(monty_run_start)                      \
+  X(monty_progress_result_free_strings)   \
+  X(monty_snapshot_resume)                \
+  X(monty_future_snapshot_resume)         \
+  X(monty_snapshot_dump)                  \
+  X(monty_snapshot_size)                  \
+  X(monty_snapshot_load)                  \
+  X(monty_future_snapshot_dump)           \
+  X(monty_future_snapshot_size)           \
+  X(monty_future_snapshot_dump_pending)   \
+  X(monty_future_snapshot_load)           \
+  X(monty_snapshot_free)                  \
+  X(monty_future_snapshot_free)           \
+  X(monty_free_bytes)                     \
+  X(monty_free_string)
+
+#define MONTY_DECLARE(name) static __typeof__(&name) p_##name;
+MONTY_SYMBOLS(MONTY_DECLARE)
+
+static pthread_mutex_t monty_lock = PTHREAD_MUTEX_INITIALIZER;
+static void *monty_lib;
+// Holds the last load failure. Statuses returned while the library is missing
+// point here, so monty_free_string must never forward it to the library.
+static char monty_error[512] = "libmonty_ffi not loaded";
+// Set when an embedded library failed to extract, so the default search path
+// is not tried and the original failure is reported.
+static int monty_disabled;
+
+static int monty_load_locked(const char *path) {
+  void *lib = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+  if (lib == NULL) {
+    snprintf(monty_error, sizeof(monty_error), "dlopen %s: %s", path, dlerror());
+    return 0;
+  }
+  // Check the ABI before resolving the rest, so a library from another
+  // version is reported as such rather than as a missing symbol.
+  __typeof__(&monty_ffi_abi_version) abi = (__typeof__(&monty_ffi_abi_version))dlsym(lib, "monty_ffi_abi_version");
+  if (abi != NULL && abi() != MONTY_FFI_ABI_VERSION) {
+    snprintf(monty_error, sizeof(monty_error),
+             "%s has ABI %u but include/monty_ffi.h declares ABI %u; rebuild the library and header together", path,
+             abi(), MONTY_FFI_ABI_VERSION);
+    dlclose(lib);
+    return 0;
+  }
+#define MONTY_RESOLVE(name)                                                        \
+  p_##name = (__typeof__(&name))dlsym(lib, #name);                                 \
+  if (p_##name == NULL) {                                                          \
+    snprintf(monty_error, sizeof(monty_error), "%s: missing symbol %s", path, #name); \
+    dlclose(lib);                                                                  \
+    return 0;                                                                      \
+  }
+  MONTY_SYMBOLS(MONTY_RESOLVE)
+#undef MONTY_RESOLVE
+  if (monty_lib != NULL) {
+    dlclose(monty_lib);
+  }
+  monty_lib = lib;
+  monty_disabled = 0;
+  return 1;
+}
+
+int monty_dlopen_load(const char *path) {
+  pthread_mutex_lock(&monty_lock);
+  int ok = monty_load_locked(path);
+  pthread_mutex_unlock(&monty_lock);
+  return ok;
+}
+
+const char *monty_dlopen_error(void) { return monty_error; }
+
+void monty_dlopen_disable(const char *reason) {
+  pthread_mutex_lock(&monty_lock);
+  snprintf(monty_error, sizeof(monty_error), "%s", reason);
+  monty_disabled = 1;
+  pthread_mutex_unlock(&monty_lock);
+}
+
+static int monty_ensure(void) {
+  int ok = 1;
+  pthread_mutex_lock(&monty_lock);
+  if (monty_lib == NULL && monty_disabled) {
+    ok = 0;
+  } else if (monty_lib == NULL) {
+    const char *path = getenv("MONTY_FFI_LIBRARY");
+    ok = monty_load_locked(path != NULL && path[0] != '\0' ? path : MONTY_DEFAULT_LIBRARY);
+  }
+  pthread_mutex_unlock(&monty_lock);
+  return ok;
+}
+
+static struct MontyStatus monty_unavailable(void) {
+  struct MontyStatus status = {.ok = 0, .error_kind = MONTY_ERROR_OTHER, .error = monty_error};
+  return status;
+}
+
+// Both report zero or empty when the library cannot be loaded, letting the
+// caller fall through to a call that returns the load error.
+uint32_t monty_ffi_abi_version(void) {
+  return monty_ensure() ? p_monty_ffi_abi_version() : 0;
+}
+
+const char *monty_ffi_version(void) {
+  return monty_ensure() ? p_monty_ffi_version() : "";
+}
+
+struct MontyMemStats monty_mem_stats(void) {
+  struct MontyMemStats stats = {0};
+  return monty_ensure() ? p_monty_mem_stats() : stats;
+}
+
+struct MontyStatus monty_run_new(const char *code, const char *script_name,
+                                 const char *const *input_names,
+                                 const char *const *ext_funcs,
+                                 struct MontyRunHandle **out) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_run_new(code, script_name, input_names, ext_funcs, out);
+}
+
+struct MontyStatus monty_run_dump(struct MontyRunHandle *run, uint8_t **out_bytes, size_t *out_len) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_run_dump(run, out_bytes, out_len);
+}
+
+struct MontyStatus monty_run_load(const uint8_t *bytes, size_t len, struct MontyRunHandle **out) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_run_load(bytes, len, out);
+}
+
+void monty_run_free(struct MontyRunHandle *run) {
+  if (monty_ensure()) p_monty_run_free(run);
+}
+
+struct MontyStatus monty_run_start(struct MontyRunHandle *run, const char *inputs_json,
+                                   struct ProgressResult *out) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_run_start(run, inputs_json, out);
+}
+
+void monty_progress_result_free_strings(struct ProgressResult *result) {
+  if (monty_ensure()) p_monty_progress_result_free_strings(result);
+}
+
+struct MontyStatus monty_snapshot_resume(struct SnapshotHandle *snapshot, uint32_t call_id,
+                                         const char *result_json, const char *error_message,
+                                         struct ProgressResult *out) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_snapshot_resume(snapshot, call_id, result_json, error_message, out);
+}
+
+struct MontyStatus monty_future_snapshot_resume(struct FutureSnapshotHandle *snapshot,
+                                                const char *results_json,
+                                                struct ProgressResult *out) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_future_snapshot_resume(snapshot, results_json, out);
+}
+
+struct MontyStatus monty_snapshot_dump(struct SnapshotHandle *snapshot, uint8_t **out_bytes,
+                                       size_t *out_len) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_snapshot_dump(snapshot, out_bytes, out_len);
+}
+
+struct MontyStatus monty_snapshot_size(struct SnapshotHandle *snapshot, size_t *out_len) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_snapshot_size(snapshot, out_len);
+}
+
+struct MontyStatus monty_snapshot_load(const uint8_t *bytes, size_t len,
+                                       struct SnapshotHandle **out) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_snapshot_load(bytes, len, out);
+}
+
+struct MontyStatus monty_future_snapshot_dump(struct FutureSnapshotHandle *snapshot,
+                                              uint8_t **out_bytes, size_t *out_len) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_future_snapshot_dump(snapshot, out_bytes, out_len);
+}
+
+struct MontyStatus monty_future_snapshot_size(struct FutureSnapshotHandle *snapshot,
+                                              size_t *out_len) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_future_snapshot_size(snapshot, out_len);
+}
+
+struct MontyStatus monty_future_snapshot_dump_pending(struct FutureSnapshotHandle *snapshot,
+                                                      uint8_t **out_bytes, size_t *out_len,
+                                                      char **out_pending_json) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_future_snapshot_dump_pending(snapshot, out_bytes, out_len, out_pending_json);
+}
+
+struct MontyStatus monty_future_snapshot_load(const uint8_t *bytes, size_t len,
+                                              struct FutureSnapshotHandle **out) {
+  if (!monty_ensure()) return monty_unavailable();
+  return p_monty_future_snapshot_load(bytes, len, out);
+}
+
+void monty_snapshot_free(struct SnapshotHandle *snapshot) {
+  if (monty_ensure()) p_monty_snapshot_free(snapshot);
+}
+
+void monty_future_snapshot_free(struct FutureSnapshotHandle *snapshot) {
+  if (monty_ensure()) p_monty_future_snapshot_free(snapshot);
+}
+
+void monty_free_bytes(uint8_t *ptr, size_t len) {
+  if (monty_ensure()) p_monty_free_bytes(ptr, len);
+}
+
+void monty_free_string(char *s) {
+  if (s == monty_error) return;
+  if (monty_ensure()) p_monty_free_string(s);
+}
@@ -0,0 +1,97 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDeferCall, returned by Callbacks.OnFunctionCall, answers the call with an
+// external future instead of a value; OnFutures resolves it later.
+var ErrDeferCall = errors.New("monty: defer call as a future")
+
+// Callbacks answers the events of a run driven by Monty.Drive. A nil
+// callback raises an error in the script for calls it would answer.
+type Callbacks struct {
+	// OnFunctionCall answers an external function call. Returning an error
+	// raises it inside the script; returning ErrDeferCall makes the call a
+	// pending future.
+	OnFunctionCall Handler
+	// OnOsCall answers an OS call the run does not virtualize.
+	OnOsCall Handler
+	// OnFutures resolves the futures the script awaits, given their call IDs.
+	// It may resolve a subset; it is called again for the rest.
+	OnFutures func(ctx context.Context, pending []uint32) ([]FutureResult, error)
+	// OnOutput receives the run's final result before Drive returns it.
+	OnOutput func(result Object)
+}
+
+// Drive runs the program with inputs to completion, invoking the callback
+// for each event, and returns the final result. Sleeps wait in real time.
+// It is a lighter alternative to a Runner for one-off runs; cancelling ctx
+// abandons the run.
+func (m *Monty) Drive(ctx context.Context, inputs []any, cb Callbacks) (Object, error) {
+	if ctx.Err() != nil {
+		return nil, contextError(ctx)
+	}
+	for p, err := range m.events(ctx, inputs) {
+		if err != nil {
+			return nil, err
+		}
+		switch p.Kind {
+		case Complete:
+			result, err := completeResult(p)
+			if err == nil && cb.OnOutput != nil {
+				cb.OnOutput(result)
+			}
+			return result, err
+		case FunctionCall:
+			answerCall(ctx, p, cb.OnFunctionCall)
+		case OsCall:
+			answerCall(ctx, p, cb.OnOsCall)
+		case ResolveFutures:
+			if cb.OnFutures == nil {
+				return nil, fmt.Errorf("monty: %d futures pending without an OnFutures callback", len(p.PendingIDs))
+			}
+			results, err := cb.OnFutures(ctx, p.PendingIDs)
+			if err != nil {
+				return nil, err
+			}
+			p.SetFutureResults(results)
+		case Timer:
+			timer := time.NewTimer(p.Duration)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, contextError(ctx)
+			case <-timer.C:
+			}
+		default:
+			return nil, fmt.Errorf("monty: cannot drive progress kind %v", p.Kind)
+		}
+		if ctx.Err() != nil {
+			return nil, contextError(ctx)
+		}
+	}
+	return nil, errors.New("monty: run ended without completing")
+}
+
+func answerCall(ctx context.Context, p Progress, h Handler) {
+	call := callInfo(p)
+	if h == nil {
+		p.SetError(fmt.Sprintf("no handler registered for %s", call.Name))
+		return
+	}
+	value, err := h(ctx, call)
+	switch {
+	case errors.Is(err, ErrDeferCall):
+		p.SetFuture()
+	case err != nil:
+		p.SetError(errorMessage(err))
+	case value == nil:
+		p.SetResult(none{})
+	default:
+		p.SetResult(value)
+	}
+}
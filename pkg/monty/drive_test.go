@@ -0,0 +1,64 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDriveCallbacks(t *testing.T) {
+	var ops []string
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(eventsBridge(&ops)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	var output Object
+	result, err := m.Drive(context.Background(), nil, Callbacks{
+		OnFunctionCall: func(ctx context.Context, call CallInfo) (any, error) {
+			var n int
+			if err := call.Args[0].Unmarshal(&n); err != nil {
+				return nil, err
+			}
+			return n * 2, nil
+		},
+		OnOutput: func(result Object) { output = result },
+	})
+	if err != nil {
+		t.Fatalf("Drive failed: %v", err)
+	}
+	if string(result) != "42" || string(output) != "42" {
+		t.Fatalf("unexpected result %s, output %s", result, output)
+	}
+}
+
+func TestDriveMissingCallbackAndCancel(t *testing.T) {
+	var ops []string
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(eventsBridge(&ops)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// eventsBridge completes with the resume payload, which is empty when
+	// the call raised.
+	result, err := m.Drive(context.Background(), nil, Callbacks{})
+	if err != nil {
+		t.Fatalf("Drive failed: %v", err)
+	}
+	if len(result) != 0 {
+		t.Fatalf("a call without a callback should raise in the script, got result %s", result)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = m.Drive(ctx, nil, Callbacks{
+		OnFunctionCall: func(context.Context, CallInfo) (any, error) {
+			cancel()
+			return 1, nil
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled Drive should fail with context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package monty
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// DumpVersion is the version of the dump format written by Dump. It changes
+// whenever the library's serialized state or the header does. A release
+// guarantees to load the formats from MinDumpVersion to DumpVersion.
+// Version 2 added the virtual clock of the run to the header.
+const DumpVersion = 2
+
+// MinDumpVersion is the oldest dump format this package loads. Version 0
+// stands for the dumps written before the format was versioned, which have
+// no header.
+const MinDumpVersion = 0
+
+// dumpMagic starts the header of versioned dumps.
+var dumpMagic = []byte("MTYD")
+
+var dumpKinds = map[HandleKind]byte{MontyHandle: 'R', SnapshotHandle: 'S', FutureSnapshotHandle: 'F'}
+
+// DumpInfo describes dumped bytes, as read by ReadDumpInfo.
+type DumpInfo struct {
+	// Version is the format version, 0 for dumps without a header.
+	Version int
+	// Kind is what was dumped, and Library the libmonty_ffi version that
+	// wrote it. Both are empty for version 0.
+	Kind    HandleKind
+	Library string
+	// Clock is how far the virtual clock of a WithDeterministic run had
+	// advanced when it was dumped, restored with the run. Formats before
+	// version 2 do not record it.
+	Clock time.Duration
+}
+
+// Compatible reports whether this package loads dumps of format version,
+// so operators can check persisted snapshots before rolling out a release.
+func Compatible(version int) bool {
+	return version >= MinDumpVersion && version <= DumpVersion
+}
+
+// ReadDumpInfo reads the header of bytes returned by Dump, without loading
+// them. Dumps of a newer format, and headers it cannot parse, are reported
+// as ErrIncompatibleSnapshot; for the former the version is returned too.
+func ReadDumpInfo(data []byte) (DumpInfo, error) {
+	info, _, err := parseDump(data)
+	return info, err
+}
+
+// parseDump splits dumped bytes into their header and the library's state.
+func parseDump(data []byte) (DumpInfo, []byte, error) {
+	rest, ok := bytes.CutPrefix(data, dumpMagic)
+	if !ok {
+		return DumpInfo{}, data, nil
+	}
+	version, n := binary.Uvarint(rest)
+	if n <= 0 || version == 0 || len(rest) < n+1 {
+		return DumpInfo{}, nil, newError(ErrIncompatibleSnapshot, "monty: corrupt dump header")
+	}
+	if version > DumpVersion {
+		return DumpInfo{Version: int(min(version, 1<<31))}, nil, newError(ErrIncompatibleSnapshot,
+			fmt.Sprintf("monty: dump format version %d is newer than %d", version, DumpVersion))
+	}
+	info := DumpInfo{Version: int(version)}
+	for kind, b := range dumpKinds {
+		if rest[n] == b {
+			info.Kind = kind
+		}
+	}
+	rest = rest[n+1:]
+	size, n := binary.Uvarint(rest)
+	if info.Kind == "" || n <= 0 || uint64(len(rest)-n) < size {
+		return DumpInfo{}, nil, newError(ErrIncompatibleSnapshot, "monty: corrupt dump header")
+	}
+	info.Library = string(rest[n : n+int(size)])
+	rest = rest[n+int(size):]
+	if version >= 2 {
+		clock, n := binary.Uvarint(rest)
+		if n <= 0 || clock > math.MaxInt64 {
+			return DumpInfo{}, nil, newError(ErrIncompatibleSnapshot, "monty: corrupt dump header")
+		}
+		info.Clock, rest = time.Duration(clock), rest[n:]
+	}
+	return info, rest, nil
+}
+
+// dump prefixes the library's state of a handle of kind with the header,
+// recording the virtual clock of its run.
+func dump(eng engine, kind HandleKind, clock time.Duration, state []byte, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	return append(dumpHeader(eng, kind, clock), state...), nil
+}
+
+// dumpHeader returns the header dump puts before the library's state.
+func dumpHeader(eng engine, kind HandleKind, clock time.Duration) []byte {
+	library, _, _ := eng.version()
+	out := append([]byte(nil), dumpMagic...)
+	out = binary.AppendUvarint(out, DumpVersion)
+	out = append(out, dumpKinds[kind])
+	out = binary.AppendUvarint(out, uint64(len(library)))
+	out = append(out, library...)
+	return binary.AppendUvarint(out, uint64(max(clock, 0)))
+}
+
+// undump returns the header and the library's state of data, which must
+// hold a handle of kind in a compatible format.
+func undump(kind HandleKind, data []byte) (DumpInfo, []byte, error) {
+	info, state, err := parseDump(data)
+	if err != nil {
+		return DumpInfo{}, nil, err
+	}
+	if info.Kind != "" && info.Kind != kind {
+		return DumpInfo{}, nil, newError(ErrInvalidInput, fmt.Sprintf("monty: dump holds a %s, not a %s", info.Kind, kind))
+	}
+	return info, state, nil
+}
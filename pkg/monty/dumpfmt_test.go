@@ -0,0 +1,111 @@
+package monty
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func versionedBridge() *Sandbox {
+	load := func(req sandboxRequest) sandboxResponse {
+		if string(req.Data) != "state" {
+			return sandboxResponse{Err: "bad state"}
+		}
+		return sandboxResponse{Handle: 1}
+	}
+	return scriptedBridge(bridgeScript{
+		"version":       reply(sandboxResponse{Version: "9.9.9", ABI: ABIVersion}),
+		"compile":       reply(sandboxResponse{Handle: 1}),
+		"load_run":      load,
+		"load_snapshot": load,
+		"dump_run":      reply(sandboxResponse{Data: []byte("state")}),
+		"dump_snapshot": reply(sandboxResponse{Data: []byte("state")}),
+		"snapshot_size": reply(sandboxResponse{Size: int64(len("state"))}),
+		"start":         reply(callProgress(1, "f", "[]", 2)),
+	})
+}
+
+func TestDumpHeader(t *testing.T) {
+	sb := versionedBridge()
+	m, err := New("x", "main.py", nil, nil, WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	data, err := m.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ReadDumpInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (DumpInfo{Version: DumpVersion, Kind: MontyHandle, Library: "9.9.9"}); info != want {
+		t.Errorf("ReadDumpInfo = %+v, want %+v", info, want)
+	}
+	restored, err := NewFromBytes(data, WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored.Close()
+
+	if _, err := SnapshotFromBytes(data, WithSandbox(sb)); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("loading a program dump as a snapshot: err = %v", err)
+	}
+	// Dumps from before versioning load as they are.
+	if info, _ := ReadDumpInfo([]byte("state")); info.Version != 0 || !Compatible(info.Version) {
+		t.Errorf("unversioned dump: %+v", info)
+	}
+	legacy, err := NewFromBytes([]byte("state"), WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+}
+
+func TestDumpVersionCompatibility(t *testing.T) {
+	if !Compatible(DumpVersion) || !Compatible(MinDumpVersion) || Compatible(DumpVersion+1) || Compatible(-1) {
+		t.Error("Compatible does not match the supported range")
+	}
+	newer := binary.AppendUvarint(append([]byte(nil), dumpMagic...), DumpVersion+1)
+	newer = append(newer, 'R', 0)
+	info, err := ReadDumpInfo(newer)
+	if !errors.Is(err, ErrIncompatibleSnapshot) || info.Version != DumpVersion+1 {
+		t.Errorf("newer dump: %+v, %v", info, err)
+	}
+	if _, err := NewFromBytes(newer, WithSandbox(versionedBridge())); !errors.Is(err, ErrIncompatibleSnapshot) {
+		t.Errorf("NewFromBytes of a newer dump: err = %v", err)
+	}
+	for _, corrupt := range []string{"MTYD", "MTYD\x01", "MTYD\x01X\x00", "MTYD\x01R\x05ab"} {
+		if _, err := ReadDumpInfo([]byte(corrupt)); !errors.Is(err, ErrIncompatibleSnapshot) {
+			t.Errorf("ReadDumpInfo(%q): err = %v", corrupt, err)
+		}
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	m, err := New("f()", "main.py", nil, []string{"f"}, WithSandbox(versionedBridge()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := p.Snapshot.EstimateSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.Snapshot.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(data)) {
+		t.Errorf("EstimateSize = %d, Dump returned %d bytes", size, len(data))
+	}
+	p.Snapshot.Close()
+	if _, err := p.Snapshot.EstimateSize(); !errors.Is(err, ErrClosed) {
+		t.Errorf("EstimateSize after Close: err = %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+//go:build cgo && !nomonty && monty_embed
+
+package monty
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// The lib directory is populated by `make embed` with the shared library for
+// each platform the binary should support. Without it only lib/README.md is
+// embedded, and loading fails with a message saying so.
+//
+//go:embed lib
+var embeddedLibs embed.FS
+
+func init() {
+	path, err := extractEmbeddedLibrary()
+	if err == nil {
+		err = LoadLibrary(path)
+	}
+	if err != nil {
+		disableLibrary(fmt.Sprintf("embedded libmonty_ffi: %v", err))
+	}
+}
+
+// extractEmbeddedLibrary writes the library for this platform into the user
+// cache directory, keyed by content hash so upgrades never reuse a stale copy.
+// Without a cache directory it goes into a new private temporary directory,
+// never a predictable path another user could plant a library at. The file is
+// checked against the embedded hash before it is loaded.
+func extractEmbeddedLibrary() (string, error) {
+	name := "libmonty_ffi.so"
+	if runtime.GOOS == "darwin" {
+		name = "libmonty_ffi.dylib"
+	}
+	data, err := embeddedLibs.ReadFile("lib/" + runtime.GOOS + "-" + runtime.GOARCH + "/" + name)
+	if err != nil {
+		return "", fmt.Errorf("no library embedded for %s/%s; run `make embed` before building with -tags monty_embed", runtime.GOOS, runtime.GOARCH)
+	}
+	sum := sha256.Sum256(data)
+	var dir string
+	if base, err := os.UserCacheDir(); err == nil {
+		dir = filepath.Join(base, "monty-go", hex.EncodeToString(sum[:8]))
+		if err := checkLibrary(filepath.Join(dir, name), sum); err == nil {
+			return filepath.Join(dir, name), nil
+		}
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return "", err
+		}
+	} else if dir, err = os.MkdirTemp("", "monty-go-"); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	tmp, err := os.CreateTemp(dir, name+".*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, checkLibrary(path, sum)
+}
+
+// checkLibrary verifies that the file at path has the SHA-256 sum.
+func checkLibrary(path string, sum [sha256.Size]byte) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if sha256.Sum256(data) != sum {
+		return fmt.Errorf("%s does not match the embedded library", path)
+	}
+	return nil
+}
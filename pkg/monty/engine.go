@@ -0,0 +1,158 @@
+package monty
+
+import "errors"
+
+// ErrUnavailable is returned by every operation in builds without the native
+// library, i.e. builds tagged nomonty or built with cgo disabled.
+var ErrUnavailable = errors.New("monty: native library unavailable in this build")
+
+// engine is the boundary to libmonty_ffi. Handles are opaque outside the
+// engine that produced them, and resuming a snapshot consumes its handle.
+// Freeing a handle releases it even when an error is reported.
+type engine interface {
+	// version reports the library version and ABI.
+	version() (string, uint32, error)
+	// memStats reports the library's allocator statistics.
+	memStats() (MemStats, error)
+	compile(code, scriptName string, inputNames, extFuncs []string) (any, error)
+	loadRun(data []byte) (any, error)
+	dumpRun(h any) ([]byte, error)
+	freeRun(h any) error
+	start(h any, inputs []byte, r *run) (rawProgress, error)
+
+	loadSnapshot(data []byte) (any, error)
+	dumpSnapshot(h any) ([]byte, error)
+	// snapshotSize reports the length dumpSnapshot would return.
+	snapshotSize(h any) (int64, error)
+	freeSnapshot(h any) error
+	// resume continues a snapshot; a nil result with no errMsg leaves the call pending.
+	resume(h any, callID uint32, result []byte, errMsg string, r *run) (rawProgress, error)
+
+	loadFutureSnapshot(data []byte) (any, error)
+	dumpFutureSnapshot(h any) ([]byte, error)
+	futureSnapshotSize(h any) (int64, error)
+	// dumpFutureSnapshotPending dumps a future snapshot together with the
+	// JSON array of the call IDs it waits on, read at once.
+	dumpFutureSnapshotPending(h any) ([]byte, []byte, error)
+	freeFutureSnapshot(h any) error
+	resumeFutures(h any, results []byte, r *run) (rawProgress, error)
+}
+
+// deferredFreer is implemented by engines whose frees may block, such as on
+// a sandbox child busy with another call. Finalizers queue their frees there
+// instead of blocking the finalizer goroutine.
+type deferredFreer interface {
+	freeRunLater(h any)
+	freeSnapshotLater(h any)
+	freeFutureSnapshotLater(h any)
+}
+
+// rawProgress is a progress result copied out of the engine but not yet
+// decoded. Nil JSON fields were absent in the native result.
+type rawProgress struct {
+	kind           ProgressKind
+	callID         uint32
+	methodCall     bool
+	result         []byte
+	resultStream   *ResultStream
+	functionName   string
+	osFunction     string
+	args           []byte
+	kwargs         []byte
+	pendingIDs     []byte
+	snapshot       any
+	futureSnapshot any
+}
+
+// release frees snapshot handles and results a progress result will not
+// hand out.
+func (p *rawProgress) release(eng engine) {
+	if p.resultStream != nil {
+		p.resultStream.Close()
+		p.resultStream = nil
+	}
+	if p.snapshot != nil {
+		eng.freeSnapshot(p.snapshot)
+		p.snapshot = nil
+	}
+	if p.futureSnapshot != nil {
+		eng.freeFutureSnapshot(p.futureSnapshot)
+		p.futureSnapshot = nil
+	}
+}
+
+// stubEngine stands in for libmonty_ffi in builds without it. Every call
+// fails with ErrUnavailable; the pure-Go parts of the package still work.
+type stubEngine struct{}
+
+func (stubEngine) version() (string, uint32, error) {
+	return "", 0, ErrUnavailable
+}
+
+func (stubEngine) memStats() (MemStats, error) {
+	return MemStats{}, ErrUnavailable
+}
+
+func (stubEngine) compile(string, string, []string, []string) (any, error) {
+	return nil, ErrUnavailable
+}
+
+func (stubEngine) loadRun([]byte) (any, error) {
+	return nil, ErrUnavailable
+}
+
+func (stubEngine) dumpRun(any) ([]byte, error) {
+	return nil, ErrUnavailable
+}
+
+func (stubEngine) freeRun(any) error {
+	return nil
+}
+
+func (stubEngine) loadSnapshot([]byte) (any, error) {
+	return nil, ErrUnavailable
+}
+
+func (stubEngine) dumpSnapshot(any) ([]byte, error) {
+	return nil, ErrUnavailable
+}
+
+func (stubEngine) snapshotSize(any) (int64, error) {
+	return 0, ErrUnavailable
+}
+
+func (stubEngine) freeSnapshot(any) error {
+	return nil
+}
+
+func (stubEngine) loadFutureSnapshot([]byte) (any, error) {
+	return nil, ErrUnavailable
+}
+
+func (stubEngine) dumpFutureSnapshot(any) ([]byte, error) {
+	return nil, ErrUnavailable
+}
+
+func (stubEngine) futureSnapshotSize(any) (int64, error) {
+	return 0, ErrUnavailable
+}
+
+func (stubEngine) dumpFutureSnapshotPending(any) ([]byte, []byte, error) {
+	return nil, nil, ErrUnavailable
+}
+
+func (stubEngine) freeFutureSnapshot(any) error {
+	return nil
+}
+
+func (stubEngine) start(any, []byte, *run) (rawProgress, error) {
+	return rawProgress{}, ErrUnavailable
+}
+
+func (stubEngine) resume(any, uint32, []byte, string, *run) (rawProgress, error) {
+	return rawProgress{}, ErrUnavailable
+}
+
+func (stubEngine) resumeFutures(any, []byte, *run) (rawProgress, error) {
+	return rawProgress{}, ErrUnavailable
+}
@@ -0,0 +1,346 @@
+package monty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Difference is one place where two Objects disagree. Path uses the syntax
+// of Object.Get; A or B is nil when the value is missing on that side.
+type Difference struct {
+	Path string
+	A, B Object
+}
+
+func (d Difference) String() string {
+	path := d.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Sprintf("%s: %s != %s", path, side(d.A), side(d.B))
+}
+
+func side(o Object) string {
+	if o == nil {
+		return "(missing)"
+	}
+	return string(o)
+}
+
+// Equal reports whether a and b encode equal Python values: formatting and
+// key order are ignored, numbers compare by value (1 == 1.0), and dicts and
+// sets compare without regard to order.
+func Equal(a, b Object) bool {
+	return len(Diff(a, b)) == 0
+}
+
+// Diff lists the differences between a and b, comparing as Equal does. Lists
+// and tuples are compared position by position; a set that differs is
+// reported as a whole. Keys are visited in sorted order, so the result is
+// deterministic. An Object that is not valid JSON differs from everything at
+// the root.
+func Diff(a, b Object) []Difference {
+	x, errA := decodeTree(a)
+	y, errB := decodeTree(b)
+	if errA != nil || errB != nil {
+		if errA != nil && errB != nil && bytes.Equal(a, b) {
+			return nil
+		}
+		return []Difference{{A: a, B: b}}
+	}
+	var out []Difference
+	diffTree("", x, y, &out)
+	return out
+}
+
+func decodeTree(o Object) (any, error) {
+	if len(o) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(o))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func diffTree(path string, x, y any, out *[]Difference) {
+	report := func() {
+		*out = append(*out, Difference{Path: path, A: encodeTree(x), B: encodeTree(y)})
+	}
+	tx, ix := treeTag(x)
+	ty, iy := treeTag(y)
+	if tx != ty {
+		report()
+		return
+	}
+	switch tx {
+	case "$dict":
+		diffPairs(path, pairMap(ix), pairMap(iy), out)
+		return
+	case "$set", "$frozenset":
+		if canonicalSet(ix) != canonicalSet(iy) {
+			report()
+		}
+		return
+	case "$tuple":
+		diffTree(path, ix, iy, out)
+		return
+	}
+
+	switch x := x.(type) {
+	case map[string]any:
+		y, ok := y.(map[string]any)
+		if !ok {
+			report()
+			return
+		}
+		keys := make([]string, 0, len(x)+len(y))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		for k := range y {
+			if _, ok := x[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diffMember(keyPath(path, k), x, y, k, out)
+		}
+	case []any:
+		y, ok := y.([]any)
+		if !ok {
+			report()
+			return
+		}
+		for i := 0; i < max(len(x), len(y)); i++ {
+			p := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= len(x):
+				*out = append(*out, Difference{Path: p, B: encodeTree(y[i])})
+			case i >= len(y):
+				*out = append(*out, Difference{Path: p, A: encodeTree(x[i])})
+			default:
+				diffTree(p, x[i], y[i], out)
+			}
+		}
+	case json.Number:
+		y, ok := y.(json.Number)
+		if !ok || !numbersEqual(x, y) {
+			report()
+		}
+	default:
+		if x != y {
+			report()
+		}
+	}
+}
+
+func diffMember(path string, x, y map[string]any, k string, out *[]Difference) {
+	vx, okx := x[k]
+	vy, oky := y[k]
+	switch {
+	case !okx:
+		*out = append(*out, Difference{Path: path, B: encodeTree(vy)})
+	case !oky:
+		*out = append(*out, Difference{Path: path, A: encodeTree(vx)})
+	default:
+		diffTree(path, vx, vy, out)
+	}
+}
+
+// dictEntry is one $dict pair, indexed by the canonical form of its key.
+type dictEntry struct {
+	key   any
+	value any
+}
+
+func pairMap(pairs any) map[string]dictEntry {
+	m := make(map[string]dictEntry)
+	list, _ := pairs.([]any)
+	for _, p := range list {
+		if kv, ok := p.([]any); ok && len(kv) == 2 {
+			m[canonicalTree(kv[0])] = dictEntry{kv[0], kv[1]}
+		}
+	}
+	return m
+}
+
+func diffPairs(path string, x, y map[string]dictEntry, out *[]Difference) {
+	keys := make([]string, 0, len(x)+len(y))
+	for k := range x {
+		keys = append(keys, k)
+	}
+	for k := range y {
+		if _, ok := x[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ex, okx := x[k]
+		ey, oky := y[k]
+		key := ex.key
+		if !okx {
+			key = ey.key
+		}
+		p := pairPath(path, key)
+		switch {
+		case !okx:
+			*out = append(*out, Difference{Path: p, B: encodeTree(ey.value)})
+		case !oky:
+			*out = append(*out, Difference{Path: p, A: encodeTree(ex.value)})
+		default:
+			diffTree(p, ex.value, ey.value, out)
+		}
+	}
+}
+
+// treeTag returns the tag and payload of a tagged value such as
+// {"$tuple": [...]}.
+func treeTag(v any) (string, any) {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) != 1 {
+		return "", nil
+	}
+	for k, inner := range m {
+		if strings.HasPrefix(k, "$") {
+			return k, inner
+		}
+	}
+	return "", nil
+}
+
+func numbersEqual(a, b json.Number) bool {
+	if a == b {
+		return true
+	}
+	x, okx := new(big.Rat).SetString(string(a))
+	y, oky := new(big.Rat).SetString(string(b))
+	return okx && oky && x.Cmp(y) == 0
+}
+
+// canonicalTree renders v so that values Equal considers equal render the
+// same.
+func canonicalTree(v any) string {
+	var b strings.Builder
+	writeCanonical(&b, v)
+	return b.String()
+}
+
+func writeCanonical(b *strings.Builder, v any) {
+	switch tag, inner := treeTag(v); tag {
+	case "$dict":
+		m := pairMap(inner)
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteString(`{"$dict":[`)
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString("[" + k + ",")
+			writeCanonical(b, m[k].value)
+			b.WriteByte(']')
+		}
+		b.WriteString("]}")
+		return
+	case "$set", "$frozenset":
+		fmt.Fprintf(b, `{%q:%s}`, tag, canonicalSet(inner))
+		return
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			key, _ := json.Marshal(k)
+			b.Write(key)
+			b.WriteByte(':')
+			writeCanonical(b, v[k])
+		}
+		b.WriteByte('}')
+	case []any:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeCanonical(b, item)
+		}
+		b.WriteByte(']')
+	case json.Number:
+		if r, ok := new(big.Rat).SetString(string(v)); ok {
+			b.WriteString(r.RatString())
+		} else {
+			b.WriteString(string(v))
+		}
+	default:
+		data, _ := json.Marshal(v)
+		b.Write(data)
+	}
+}
+
+func canonicalSet(items any) string {
+	list, _ := items.([]any)
+	elems := make([]string, len(list))
+	for i, item := range list {
+		elems[i] = canonicalTree(item)
+	}
+	sort.Strings(elems)
+	return "[" + strings.Join(elems, ",") + "]"
+}
+
+func encodeTree(v any) Object {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// keyPath appends a key to a Get path, escaping path syntax.
+func keyPath(path, key string) string {
+	var b strings.Builder
+	b.WriteString(path)
+	if path != "" {
+		b.WriteByte('.')
+	}
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case '.', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(key[i])
+	}
+	return b.String()
+}
+
+// pairPath appends a dict key to a Get path: strings as keys, ints as
+// positions, anything else in its JSON form.
+func pairPath(path string, key any) string {
+	switch k := key.(type) {
+	case string:
+		return keyPath(path, k)
+	case json.Number:
+		if n, err := strconv.Atoi(string(k)); err == nil {
+			return path + "[" + strconv.Itoa(n) + "]"
+		}
+	}
+	return keyPath(path, canonicalTree(key))
+}
@@ -0,0 +1,49 @@
+package monty
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{`{"a": 1, "b": [1, 2]}`, `{"b":[1,2],"a":1.0}`, true},
+		{`{"$dict": [["x", 1], [2, "y"]]}`, `{"$dict": [[2, "y"], ["x", 1]]}`, true},
+		{`{"$set": [3, 1, 2]}`, `{"$set": [1, 2, 3]}`, true},
+		{`{"$tuple": [1, 2]}`, `[1, 2]`, false},
+		{`{"$bigint": "123456789012345678901234567890"}`, `{"$bigint": "123456789012345678901234567890"}`, true},
+		{`100000000000000000001`, `100000000000000000000`, false},
+		{`"a"`, `"b"`, false},
+		{`null`, ``, true},
+	} {
+		if got := Equal(Object(tc.a), Object(tc.b)); got != tc.want {
+			t.Errorf("Equal(%s, %s) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := Object(`{"$dict": [["user", {"$dict": [["name", "ada"], ["tags", ["x", "y"]]]}], ["a.b", 1], [3, true]]}`)
+	b := Object(`{"$dict": [["user", {"$dict": [["name", "bob"], ["tags", ["x"]]]}], ["a.b", 1], [3, false], ["new", null]]}`)
+	got := Diff(a, b)
+	want := []Difference{
+		{Path: "new", B: Object(`null`)},
+		{Path: "user.name", A: Object(`"ada"`), B: Object(`"bob"`)},
+		{Path: "user.tags[1]", A: Object(`"y"`)},
+		{Path: "[3]", A: Object(`true`), B: Object(`false`)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff = %v, want %v", got, want)
+	}
+	for _, d := range got {
+		if d.A != nil && !Equal(a.Get(d.Path), d.A) {
+			t.Errorf("path %q does not resolve to %s in a", d.Path, d.A)
+		}
+	}
+	if s := got[0].String(); s != "new: (missing) != null" {
+		t.Errorf("String = %q", s)
+	}
+}
@@ -0,0 +1,217 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Sentinel errors classify failures for errors.Is. Errors returned by this
+// package wrap at most one of them; the message text is not part of the API.
+var (
+	// ErrClosed is returned when using a handle after Close, or a snapshot
+	// after it was resumed.
+	ErrClosed = errors.New("monty: handle closed")
+	// ErrTimeout is returned when a run's context deadline passes or the
+	// interpreter's time limit is exhausted.
+	ErrTimeout = errors.New("monty: execution timed out")
+	// ErrStepLimit is returned when a run exceeds a bound on the work it may do.
+	ErrStepLimit = errors.New("monty: step limit exceeded")
+	// ErrMemoryLimit is returned when a run exceeds a memory bound, including
+	// the transfer bounds of Limits.
+	ErrMemoryLimit = errors.New("monty: memory limit exceeded")
+	// ErrInvalidInput is returned for arguments the bridge cannot accept, such
+	// as values that do not encode to JSON.
+	ErrInvalidInput = errors.New("monty: invalid input")
+	// ErrIncompatibleSnapshot is returned when dumped bytes cannot be loaded,
+	// because they are corrupt or come from an incompatible library version.
+	ErrIncompatibleSnapshot = errors.New("monty: incompatible snapshot")
+	// ErrQuotaExceeded is returned when a start is refused because a tenant
+	// exhausted a Quotas budget.
+	ErrQuotaExceeded = errors.New("monty: quota exceeded")
+	// ErrNondeterministic is returned when a run under WithDeterministic
+	// makes an OS call whose result would not replay.
+	ErrNondeterministic = errors.New("monty: nondeterministic call")
+	// ErrConcurrentUse is returned when a handle is used by two goroutines at
+	// once in a way the native library does not allow.
+	ErrConcurrentUse = errors.New("monty: concurrent use of handle")
+	// ErrDeadlock is returned when a Runner's run waits on futures that
+	// nothing will resolve.
+	ErrDeadlock = errors.New("monty: futures deadlocked")
+)
+
+// Error is a classified error. Its message is the original one, and it
+// unwraps to Kind and to the underlying error, if any.
+type Error struct {
+	Kind error
+	Msg  string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+func newError(kind error, msg string) error {
+	return &Error{Kind: kind, Msg: msg}
+}
+
+// wrapError classifies err as kind, keeping its message.
+func wrapError(kind error, err error) error {
+	if err == nil || errors.Is(err, kind) {
+		return err
+	}
+	return &Error{Kind: kind, Msg: err.Error(), Err: err}
+}
+
+// Error kinds the native library reports with a failure, mirroring the
+// MONTY_ERROR_* constants of monty_ffi.h. The library derives them from the
+// type of the exception that ended the run, not from its message.
+const (
+	nativeOther = iota
+	nativeTimeout
+	nativeMemory
+	nativeRecursion
+)
+
+// nativeKinds maps the kinds of resource exhaustion to sentinels.
+var nativeKinds = map[int]error{
+	nativeTimeout:   ErrTimeout,
+	nativeMemory:    ErrMemoryLimit,
+	nativeRecursion: ErrStepLimit,
+}
+
+// nativeError converts an error reported by the native library with its
+// MONTY_ERROR_* kind.
+func nativeError(kind int, msg string) error {
+	if sentinel, ok := nativeKinds[kind]; ok {
+		return newError(sentinel, msg)
+	}
+	return errors.New(msg)
+}
+
+// nativeKind returns the MONTY_ERROR_* kind of err, for passing it on.
+func nativeKind(err error) int {
+	for kind, sentinel := range nativeKinds {
+		if errors.Is(err, sentinel) {
+			return kind
+		}
+	}
+	return nativeOther
+}
+
+// loadError classifies a failure to load dumped bytes. Failures of the
+// library itself are InternalErrors, as compileError reports them.
+func loadError(err error) error {
+	var ie *InternalError
+	if err == nil || errors.Is(err, ErrClosed) || errors.As(err, &ie) {
+		return err
+	}
+	if errors.Is(err, ErrUnavailable) || errors.Is(err, ErrSandboxExited) {
+		return &InternalError{Op: "load", Err: err}
+	}
+	return wrapError(ErrIncompatibleSnapshot, err)
+}
+
+// contextError reports a done context, classifying deadlines as ErrTimeout.
+func contextError(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
+
+// CompileError reports a problem with the script itself, such as a syntax
+// error, found while compiling it in New or linking it in NewLinked. It is
+// meant for script authors.
+type CompileError struct {
+	Script string
+	// Type is the exception type, e.g. "SyntaxError".
+	Type    string
+	Message string
+	// Line and Column locate the problem, 1-based; zero when unknown.
+	Line, Column int
+
+	msg string
+}
+
+func (e *CompileError) Error() string {
+	return e.msg
+}
+
+// InternalError reports a failure of the library or the bridge rather than
+// of the script, e.g. a missing native library or an ABI mismatch. It is
+// meant for operators.
+type InternalError struct {
+	// Op names the failed operation, e.g. "compile" or "start".
+	Op  string
+	Err error
+}
+
+func (e *InternalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InternalError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	exceptionPattern = regexp.MustCompile(`(?s)^([A-Z][A-Za-z0-9_]*(?:Error|Exception))(?::\s*(.*))?$`)
+	linePattern      = regexp.MustCompile(`\bline (\d+)`)
+	columnPattern    = regexp.MustCompile(`\b(?:column|col) (\d+)`)
+)
+
+// parseException splits a native "Type: message" exception summary.
+func parseException(msg string) (typ, text string, ok bool) {
+	m := exceptionPattern.FindStringSubmatch(msg)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// compileError classifies an error from compiling script.
+func compileError(script string, err error) error {
+	var ie *InternalError
+	if err == nil || errors.As(err, &ie) {
+		return err
+	}
+	typ, text, ok := parseException(err.Error())
+	if !ok {
+		return &InternalError{Op: "compile", Err: err}
+	}
+	ce := &CompileError{Script: script, Type: typ, Message: text, msg: err.Error()}
+	if m := linePattern.FindStringSubmatch(text); m != nil {
+		ce.Line, _ = strconv.Atoi(m[1])
+	}
+	if m := columnPattern.FindStringSubmatch(text); m != nil {
+		ce.Column, _ = strconv.Atoi(m[1])
+	}
+	return ce
+}
+
+// runError classifies an error from the engine while running: exceptions
+// raised by the script and watchdog interruptions pass through, anything
+// else is an InternalError.
+func runError(op string, err error) error {
+	var ie *InternalError
+	var we *WatchdogError
+	if err == nil || errors.As(err, &ie) || errors.As(err, &we) {
+		return err
+	}
+	if _, _, ok := parseException(err.Error()); ok {
+		return err
+	}
+	return &InternalError{Op: op, Err: err}
+}
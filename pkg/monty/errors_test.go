@@ -0,0 +1,124 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	if _, err := (&Snapshot{}).Resume(1, 2); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Resume on closed snapshot: expected ErrClosed, got %v", err)
+	}
+	if _, err := (&FutureSnapshot{}).Dump(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Dump on closed future snapshot: expected ErrClosed, got %v", err)
+	}
+	if _, err := SnapshotFromBytes(nil); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("SnapshotFromBytes(nil): expected ErrInvalidInput, got %v", err)
+	}
+	if _, err := marshalValue(make(chan int)); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("marshalValue(chan): expected ErrInvalidInput, got %v", err)
+	}
+	var limit error = &LimitError{Limit: "result", Max: 1, Size: 2}
+	if !errors.Is(limit, ErrMemoryLimit) {
+		t.Fatalf("LimitError should match ErrMemoryLimit")
+	}
+}
+
+func TestNativeErrorClassification(t *testing.T) {
+	for kind, want := range map[int]error{
+		nativeTimeout:   ErrTimeout,
+		nativeMemory:    ErrMemoryLimit,
+		nativeRecursion: ErrStepLimit,
+	} {
+		err := nativeError(kind, "limit exceeded")
+		if !errors.Is(err, want) || err.Error() != "limit exceeded" || nativeKind(err) != kind {
+			t.Errorf("nativeError(%d) = %v, want %v with the original message", kind, err, want)
+		}
+	}
+	// Only the kind classifies: a message naming a limit is not enough.
+	for _, msg := range []string{"NameError: x", "TimeoutError: raised by the script", "MemoryError"} {
+		if err := nativeError(nativeOther, msg); errors.Is(err, ErrTimeout) || errors.Is(err, ErrStepLimit) || errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("unexpected classification of %v", err)
+		}
+	}
+}
+
+func TestLoadErrorIsIncompatibleSnapshot(t *testing.T) {
+	sb := scriptedBridge(bridgeScript{anyOp: reply(sandboxResponse{Err: "Deserialize Bad Encoding"})})
+	_, err := SnapshotFromBytes([]byte{1, 2, 3}, WithSandbox(sb))
+	if !errors.Is(err, ErrIncompatibleSnapshot) {
+		t.Fatalf("expected ErrIncompatibleSnapshot, got %v", err)
+	}
+}
+
+func TestContextErrorTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	err := contextError(ctx)
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected ErrTimeout wrapping DeadlineExceeded, got %v", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := contextError(ctx); errors.Is(err, ErrTimeout) {
+		t.Fatalf("cancellation is not a timeout: %v", err)
+	}
+}
+
+func TestCompileErrorDiagnostics(t *testing.T) {
+	err := compileError("main.py", errors.New("SyntaxError: invalid syntax at line 3, column 7"))
+	var ce *CompileError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected *CompileError, got %T", err)
+	}
+	if ce.Script != "main.py" || ce.Type != "SyntaxError" || ce.Line != 3 || ce.Column != 7 {
+		t.Fatalf("unexpected diagnostics: %+v", ce)
+	}
+	if ce.Error() != "SyntaxError: invalid syntax at line 3, column 7" {
+		t.Fatalf("message changed: %q", ce.Error())
+	}
+
+	err = compileError("main.py", ErrUnavailable)
+	var ie *InternalError
+	if !errors.As(err, &ie) || ie.Op != "compile" || !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected *InternalError wrapping ErrUnavailable, got %#v", err)
+	}
+	if err := runError("start", errors.New("ZeroDivisionError: division by zero")); errors.As(err, &ie) {
+		t.Fatalf("script exceptions are not internal errors")
+	}
+}
+
+func TestSandboxPassesErrorKinds(t *testing.T) {
+	m, err := New("f()", "main.py", nil, nil, WithSandbox(failingBridge(nativeRecursion, "RecursionError: maximum depth reached")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.Start(); !errors.Is(err, ErrStepLimit) {
+		t.Fatalf("Start = %v, want ErrStepLimit", err)
+	}
+}
+
+func TestResumeErrorsAreClassified(t *testing.T) {
+	sb := scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(callProgress(1, "f", "[]", 2)),
+		"resume":  reply(sandboxResponse{Err: "snapshot state corrupted"}),
+	})
+	m, err := New("f()", "main.py", nil, []string{"f"}, WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ie *InternalError
+	if _, err := p.Snapshot.Resume(p.CallID, 1); !errors.As(err, &ie) || ie.Op != "resume" {
+		t.Fatalf("expected an InternalError from resume, got %#v", err)
+	}
+}
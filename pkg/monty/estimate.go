@@ -0,0 +1,160 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EstimateConfig bounds the dry run made by Monty.Estimate. Zero fields take
+// their defaults.
+type EstimateConfig struct {
+	// MaxCalls caps the external and OS calls answered, 1000 by default.
+	MaxCalls int
+	// MaxTime caps the time spent in the interpreter, one second by default.
+	// It is checked between events, so a single long step can overrun it;
+	// combine it with WithWatchdog to interrupt one.
+	MaxTime time.Duration
+	// Answer stubs the external and OS calls the script makes. A non-nil
+	// error is raised in the script. Nil answers every call with None.
+	Answer func(call Progress) (any, error)
+}
+
+// UsageEstimate is the resource use observed in a dry run. The library does
+// not count instructions, so CPU and Events stand in for them.
+type UsageEstimate struct {
+	// Events counts the steps of the run: its start and each resume.
+	Events int
+	// CPU is the wall time spent in the interpreter.
+	CPU time.Duration
+	// Allocated is the bytes the native library allocated during the run,
+	// and PeakLive the largest growth of its live heap seen at an event.
+	// Both are zero when NativeMemStats is unavailable, and count other
+	// runs in the same process too.
+	Allocated uint64
+	PeakLive  uint64
+	// Calls and OsCalls count the external and OS calls made, by name,
+	// including the one that hit MaxCalls.
+	Calls   map[string]int
+	OsCalls map[string]int
+	// Complete reports whether the run finished, normally or with an
+	// exception, within the caps.
+	Complete bool
+	// Stopped says why the run did not complete: "call limit" or "time
+	// limit".
+	Stopped string
+	// Exception is the uncaught exception that ended the run, if any.
+	Exception string
+}
+
+// Estimate executes m as a dry run to estimate what a real run would cost:
+// the time and native memory it takes and the external and OS calls it
+// makes, with every call stubbed and the run stopped at the caps of cfg.
+// Platforms use it to pre-screen submitted automations. The returned error
+// reports failures of the bridge or a done ctx; script exceptions and caps
+// are reported in the estimate.
+func (m *Monty) Estimate(ctx context.Context, cfg EstimateConfig, inputs ...any) (UsageEstimate, error) {
+	if cfg.MaxCalls <= 0 {
+		cfg.MaxCalls = 1000
+	}
+	if cfg.MaxTime <= 0 {
+		cfg.MaxTime = time.Second
+	}
+	if m == nil {
+		return UsageEstimate{}, newError(ErrClosed, "monty: nil handle")
+	}
+	est := UsageEstimate{Calls: make(map[string]int), OsCalls: make(map[string]int)}
+	eng := m.eng
+	base, memErr := eng.memStats()
+	sample := func() {
+		if memErr != nil {
+			return
+		}
+		if s, err := eng.memStats(); err == nil {
+			est.Allocated = s.TotalAlloc - base.TotalAlloc
+			if s.Live > base.Live && s.Live-base.Live > est.PeakLive {
+				est.PeakLive = s.Live - base.Live
+			}
+		}
+	}
+	step := func(fn func() (Progress, error)) (Progress, error) {
+		began := time.Now()
+		p, err := fn()
+		est.CPU += time.Since(began)
+		est.Events++
+		sample()
+		return p, err
+	}
+
+	p, err := step(func() (Progress, error) { return m.start(ctx, m.cfg, inputs) })
+	calls := 0
+	for err == nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			closeProgress(p)
+			return est, ctxErr
+		}
+		if p.Kind == Complete {
+			est.Complete = true
+			if p.Exception != nil {
+				est.Exception = p.Exception.Error()
+			}
+			return est, nil
+		}
+		if est.CPU > cfg.MaxTime {
+			closeProgress(p)
+			est.Stopped = "time limit"
+			return est, nil
+		}
+		switch p.Kind {
+		case FunctionCall, OsCall:
+			if p.Kind == OsCall {
+				est.OsCalls[p.OsFunction]++
+			} else {
+				est.Calls[p.FunctionName]++
+			}
+			if calls++; calls > cfg.MaxCalls {
+				closeProgress(p)
+				est.Stopped = "call limit"
+				return est, nil
+			}
+			var value any
+			var callErr error
+			if cfg.Answer != nil {
+				value, callErr = cfg.Answer(p)
+			}
+			snap, id := p.Snapshot, p.CallID
+			switch {
+			case callErr != nil:
+				p, err = step(func() (Progress, error) { return snap.ResumeError(id, errorMessage(callErr)) })
+			case value == nil:
+				p, err = step(func() (Progress, error) { return snap.Resume(id, none{}) })
+			default:
+				p, err = step(func() (Progress, error) { return snap.Resume(id, value) })
+			}
+		case Timer:
+			snap, id := p.Snapshot, p.CallID
+			p, err = step(func() (Progress, error) { return snap.Wake(id) })
+		case ResolveFutures:
+			results := make([]FutureResult, len(p.PendingIDs))
+			for i, id := range p.PendingIDs {
+				results[i] = FutureResult{CallID: id, Result: none{}}
+			}
+			fs := p.FutureSnapshot
+			p, err = step(func() (Progress, error) { return fs.Resume(results) })
+		default:
+			closeProgress(p)
+			return est, fmt.Errorf("monty: unexpected progress kind %d", p.Kind)
+		}
+	}
+	if exc, ok := exceptionOf(err); ok {
+		est.Complete = true
+		est.Exception = exc.Error()
+		return est, nil
+	}
+	if errors.Is(err, ErrTimeout) {
+		est.Stopped = "time limit"
+		return est, nil
+	}
+	return est, err
+}
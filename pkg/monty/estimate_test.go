@@ -0,0 +1,65 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// loopBridge scripts a run that calls fetch n times, then completes.
+func loopBridge(n uint32) *Sandbox {
+	call := func(id uint32) sandboxResponse {
+		if id > n {
+			return completeProgress(json.RawMessage("1"))
+		}
+		return callProgress(id, "fetch", "[]", uint64(id+1))
+	}
+	return scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(call(1)),
+		"resume": func(req sandboxRequest) sandboxResponse {
+			if req.ErrMsg != "" {
+				return sandboxResponse{Err: req.ErrMsg}
+			}
+			return call(req.CallID + 1)
+		},
+	})
+}
+
+func TestEstimate(t *testing.T) {
+	m, err := New("fetch()", "main.py", nil, []string{"fetch"}, WithSandbox(loopBridge(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	var answered int
+	est, err := m.Estimate(context.Background(), EstimateConfig{Answer: func(p Progress) (any, error) {
+		answered++
+		return nil, nil
+	}})
+	if err != nil {
+		t.Fatalf("Estimate failed: %v", err)
+	}
+	if !est.Complete || est.Stopped != "" || est.Events != 4 || est.Calls["fetch"] != 3 || answered != 3 {
+		t.Fatalf("Estimate = %+v, %d answered", est, answered)
+	}
+
+	est, err = m.Estimate(context.Background(), EstimateConfig{MaxCalls: 2})
+	if err != nil || est.Complete || est.Stopped != "call limit" || est.Calls["fetch"] != 3 {
+		t.Fatalf("capped Estimate = %+v, %v", est, err)
+	}
+
+	est, err = m.Estimate(context.Background(), EstimateConfig{Answer: func(Progress) (any, error) {
+		return nil, errors.New("ValueError: no network")
+	}})
+	if err != nil || !est.Complete || est.Exception != "ValueError: no network" {
+		t.Fatalf("raising Estimate = %+v, %v", est, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := m.Estimate(ctx, EstimateConfig{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+)
+
+// answer is how a caller of Monty.Events replies to a yielded Progress.
+type answer struct {
+	set     bool
+	result  any
+	errMsg  string
+	future  bool
+	resolve bool
+	results []FutureResult
+}
+
+// errNotEvents is returned by the setters of a Progress not yielded by Events.
+var errNotEvents = errors.New("monty: progress was not yielded by Events")
+
+func (p Progress) reply(a answer) error {
+	if p.answer == nil {
+		return errNotEvents
+	}
+	a.set = true
+	*p.answer = a
+	return nil
+}
+
+// SetResult answers a FunctionCall or OsCall yielded by Events with a value;
+// nil answers it with None.
+func (p Progress) SetResult(result any) error {
+	return p.reply(answer{result: result})
+}
+
+// SetError answers a FunctionCall or OsCall yielded by Events by raising an
+// exception with message in the script.
+func (p Progress) SetError(message string) error {
+	if message == "" {
+		return newError(ErrInvalidInput, "monty: empty error message")
+	}
+	return p.reply(answer{errMsg: message})
+}
+
+// SetFuture answers a FunctionCall yielded by Events with an external future,
+// to be resolved by SetFutureResults on a later ResolveFutures event.
+func (p Progress) SetFuture() error {
+	return p.reply(answer{future: true})
+}
+
+// SetFutureResults answers a ResolveFutures event yielded by Events.
+func (p Progress) SetFutureResults(results []FutureResult) error {
+	return p.reply(answer{resolve: true, results: results})
+}
+
+// Events starts a run and yields each progress event, driving the run with a
+// for-range loop instead of threading snapshots by hand:
+//
+//	for p, err := range m.Events(inputs...) {
+//		if err != nil {
+//			return err
+//		}
+//		switch p.Kind {
+//		case monty.FunctionCall:
+//			p.SetResult(call(p))
+//		case monty.Complete:
+//			return use(p.Result)
+//		}
+//	}
+//
+// The loop body answers each FunctionCall, OsCall, and ResolveFutures event
+// with one of the setters before the next iteration; Timer events are woken
+// immediately unless answered. An event left unanswered ends the sequence
+// with an error. Complete is the last event, and a failure is yielded as the
+// error of a final pair. Breaking out of the loop releases the paused run.
+func (m *Monty) Events(inputs ...any) iter.Seq2[Progress, error] {
+	return m.events(context.Background(), inputs)
+}
+
+func (m *Monty) events(ctx context.Context, inputs []any) iter.Seq2[Progress, error] {
+	return func(yield func(Progress, error) bool) {
+		p, err := m.StartContext(ctx, inputs...)
+		for {
+			if err != nil {
+				yield(Progress{}, err)
+				return
+			}
+			a := new(answer)
+			p.answer = a
+			if !yield(p, nil) {
+				p.Snapshot.Close()
+				p.FutureSnapshot.Close()
+				return
+			}
+			if p.Kind == Complete {
+				return
+			}
+			if p.Kind == Timer && !a.set {
+				a.set, a.result = true, none{}
+			}
+			if !a.set || a.resolve != (p.Kind == ResolveFutures) {
+				p.Snapshot.Close()
+				p.FutureSnapshot.Close()
+				yield(Progress{}, newError(ErrInvalidInput, fmt.Sprintf("monty: %v event for call %d was not answered with a matching setter", p.Kind, p.CallID)))
+				return
+			}
+			switch {
+			case a.resolve:
+				p, err = p.FutureSnapshot.Resume(a.results)
+			case a.future:
+				p, _, err = p.Snapshot.ResumeFuture(p.CallID)
+			case a.errMsg != "":
+				p, err = p.Snapshot.ResumeError(p.CallID, a.errMsg)
+			default:
+				p, err = p.Snapshot.Resume(p.CallID, a.result)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package monty
+
+import (
+	"errors"
+	"testing"
+)
+
+// eventsBridge scripts a run that calls double(21) and completes with the
+// value it is resumed with, recording the operations it receives.
+func eventsBridge(ops *[]string) *Sandbox {
+	record := func(answer func(sandboxRequest) sandboxResponse) func(sandboxRequest) sandboxResponse {
+		return func(req sandboxRequest) sandboxResponse {
+			*ops = append(*ops, req.Op)
+			return answer(req)
+		}
+	}
+	return scriptedBridge(bridgeScript{
+		"compile": record(reply(sandboxResponse{Handle: 1})),
+		"start":   record(reply(callProgress(1, "double", "[21]", 2))),
+		"resume":  record(echoResume),
+		anyOp:     record(reply(sandboxResponse{})),
+	})
+}
+
+func TestEventsDrivesRun(t *testing.T) {
+	var ops []string
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(eventsBridge(&ops)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	var kinds []ProgressKind
+	var result Object
+	for p, err := range m.Events() {
+		if err != nil {
+			t.Fatalf("Events failed: %v", err)
+		}
+		kinds = append(kinds, p.Kind)
+		switch p.Kind {
+		case FunctionCall:
+			var n int
+			p.Args[0].Unmarshal(&n)
+			p.SetResult(n * 2)
+		case Complete:
+			result = p.Result
+		}
+	}
+	if len(kinds) != 2 || kinds[0] != FunctionCall || kinds[1] != Complete || string(result) != "42" {
+		t.Fatalf("unexpected events %v with result %s", kinds, result)
+	}
+	if err := (Progress{}).SetResult(1); !errors.Is(err, errNotEvents) {
+		t.Fatalf("setters outside Events should fail, got %v", err)
+	}
+}
+
+func TestEventsNilResultIsNone(t *testing.T) {
+	var ops []string
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(eventsBridge(&ops)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	var result Object
+	for p, err := range m.Events() {
+		if err != nil {
+			t.Fatalf("Events failed: %v", err)
+		}
+		if p.Kind == FunctionCall {
+			p.SetResult(nil)
+		} else {
+			result = p.Result
+		}
+	}
+	if string(result) != "null" {
+		t.Fatalf("SetResult(nil) resumed with %q, want None", result)
+	}
+}
+
+func TestEventsUnansweredAndBreak(t *testing.T) {
+	var ops []string
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(eventsBridge(&ops)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	var last error
+	for _, err := range m.Events() {
+		last = err
+	}
+	if !errors.Is(last, ErrInvalidInput) {
+		t.Fatalf("an unanswered call should end with ErrInvalidInput, got %v", last)
+	}
+
+	ops = nil
+	for range m.Events() {
+		break
+	}
+	if len(ops) != 2 || ops[1] != "free_snapshot" {
+		t.Fatalf("breaking should free the paused snapshot, got ops %v", ops)
+	}
+}
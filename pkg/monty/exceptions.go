@@ -0,0 +1,89 @@
+package monty
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// WithExceptionResults reports an exception the script does not catch as a
+// Complete progress instead of an error: Progress.Exception describes it and
+// Progress.Result holds it as an {"$exception": ...} object, so platforms
+// can show script authors rich failure detail and store it like any other
+// result. Resource exhaustion (ErrTimeout, ErrMemoryLimit, ErrStepLimit),
+// watchdog interruptions, and failures of the bridge remain errors.
+func WithExceptionResults() Option {
+	return func(c *config) {
+		c.exceptionResults = true
+	}
+}
+
+// Exception is a Python exception raised by a script. The library reports
+// only the "Type: message" summary of an exception, so that is all it holds.
+type Exception struct {
+	// Type is the exception class, e.g. "ValueError".
+	Type string
+	// Message is str(exc).
+	Message string
+}
+
+func (e *Exception) Error() string {
+	if e.Message == "" {
+		return e.Type
+	}
+	return e.Type + ": " + e.Message
+}
+
+// Object encodes e as the interpreter encodes exception values.
+func (e *Exception) Object() Object {
+	inner := map[string]string{"type": e.Type}
+	if e.Message != "" {
+		inner["message"] = e.Message
+	}
+	data, _ := json.Marshal(map[string]any{"$exception": inner})
+	return data
+}
+
+// Exception decodes an exception returned as a value, reporting false when o
+// is not one.
+func (o Object) Exception() (*Exception, bool) {
+	var tagged struct {
+		Exception *struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"$exception"`
+	}
+	if o.Kind() != ExceptionKind || json.Unmarshal(o, &tagged) != nil || tagged.Exception == nil {
+		return nil, false
+	}
+	return &Exception{Type: tagged.Exception.Type, Message: tagged.Exception.Message}, true
+}
+
+// exceptionOf parses the exception behind a script error.
+func exceptionOf(err error) (*Exception, bool) {
+	var ie *InternalError
+	var we *WatchdogError
+	var e *Error
+	if err == nil || errors.As(err, &ie) || errors.As(err, &we) || errors.As(err, &e) {
+		return nil, false
+	}
+	typ, text, ok := parseException(err.Error())
+	if !ok {
+		return nil, false
+	}
+	return &Exception{Type: typ, Message: text}, true
+}
+
+// raised turns an uncaught script exception into a Complete progress when
+// the run asked for WithExceptionResults.
+func (r *run) raised(p Progress, err error) (Progress, error) {
+	if !r.cfg.exceptionResults {
+		return p, err
+	}
+	exc, ok := exceptionOf(err)
+	if !ok {
+		return p, err
+	}
+	p = Progress{Kind: Complete, Result: exc.Object(), Exception: exc}
+	r.annotate(&p)
+	return p, nil
+}
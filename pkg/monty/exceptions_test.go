@@ -0,0 +1,65 @@
+package monty
+
+import (
+	"errors"
+	"testing"
+)
+
+// raisingBridge scripts a run whose start fails with msg.
+func raisingBridge(msg string) *Sandbox {
+	return failingBridge(nativeOther, msg)
+}
+
+// failingBridge scripts a run whose start fails with msg and the native error
+// kind.
+func failingBridge(kind int, msg string) *Sandbox {
+	return scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		anyOp:     reply(sandboxResponse{Err: msg, ErrKind: kind}),
+	})
+}
+
+func TestWithExceptionResults(t *testing.T) {
+	m, err := New("check(x)", "main.py", nil, nil, WithSandbox(raisingBridge("ValueError: bad input")), WithExceptionResults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	exc := p.Exception
+	if p.Kind != Complete || exc == nil || exc.Type != "ValueError" || exc.Message != "bad input" {
+		t.Fatalf("Start = %+v", p)
+	}
+	if got, ok := p.Result.Exception(); !ok || got.Error() != "ValueError: bad input" || p.Result.Kind() != ExceptionKind {
+		t.Fatalf("Result = %s", p.Result)
+	}
+
+	plain, err := New("1", "main.py", nil, nil, WithSandbox(raisingBridge("KeyError: 'k'")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if _, err := plain.Start(); err == nil || err.Error() != "KeyError: 'k'" {
+		t.Fatalf("without the option the exception should be an error, got %v", err)
+	}
+}
+
+func TestExceptionResultsKeepResourceErrors(t *testing.T) {
+	m, err := New("1", "main.py", nil, nil, WithSandbox(failingBridge(nativeTimeout, "TimeoutError: time limit exceeded")), WithExceptionResults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.Start(); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if _, ok := exceptionOf(&InternalError{Op: "start", Err: errors.New("ValueError: x")}); ok {
+		t.Fatal("internal errors are not script exceptions")
+	}
+	if _, ok := Object(`{"$dict": []}`).Exception(); ok {
+		t.Fatal("a dict is not an exception")
+	}
+}
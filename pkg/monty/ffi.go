@@ -0,0 +1,380 @@
+//go:build cgo && !nomonty
+
+package monty
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/../../include
+#include <stdlib.h>
+#include <string.h>
+#include "monty_ffi.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+)
+
+var native engine = cgoEngine{}
+
+// cgoEngine calls libmonty_ffi in process.
+type cgoEngine struct{}
+
+// abiChecked is set once the loaded library's ABI has been verified.
+var abiChecked atomic.Bool
+
+// checkABI verifies that the header, the library, and this package agree on
+// the FFI ABI before any handle is created.
+func checkABI() error {
+	if abiChecked.Load() {
+		return nil
+	}
+	header := uint32(C.MONTY_FFI_ABI_VERSION)
+	if header != ABIVersion {
+		return fmt.Errorf("monty: include/monty_ffi.h declares ABI %d but this package expects ABI %d", header, ABIVersion)
+	}
+	lib := uint32(C.monty_ffi_abi_version())
+	if lib == 0 {
+		// The library failed to load; the call itself reports why.
+		return nil
+	}
+	if lib != header {
+		return fmt.Errorf("monty: libmonty_ffi %s has ABI %d but include/monty_ffi.h declares ABI %d; rebuild the library and header together",
+			C.GoString(C.monty_ffi_version()), lib, header)
+	}
+	abiChecked.Store(true)
+	return nil
+}
+
+// statusLayout reports the offsets of the MontyStatus fields and its size as
+// the header declares them, for a test pinning the layout to the library's.
+func statusLayout() (ok, errorKind, message, size uintptr) {
+	var s C.struct_MontyStatus
+	return unsafe.Offsetof(s.ok), unsafe.Offsetof(s.error_kind), unsafe.Offsetof(s.error), unsafe.Sizeof(s)
+}
+
+func (cgoEngine) version() (string, uint32, error) {
+	return C.GoString(C.monty_ffi_version()), uint32(C.monty_ffi_abi_version()), nil
+}
+
+func (cgoEngine) memStats() (MemStats, error) {
+	if C.monty_ffi_abi_version() == 0 {
+		return MemStats{}, ErrUnavailable
+	}
+	stats := C.monty_mem_stats()
+	return MemStats{
+		TotalAlloc: uint64(stats.total_allocated),
+		Live:       uint64(stats.live_bytes),
+		Peak:       uint64(stats.peak_bytes),
+		Mallocs:    uint64(stats.allocations),
+		Frees:      uint64(stats.frees),
+	}, nil
+}
+
+func (cgoEngine) compile(code, scriptName string, inputNames, extFuncs []string) (any, error) {
+	if err := checkABI(); err != nil {
+		return nil, err
+	}
+	cCode, freeCode := cString(code)
+	defer freeCode()
+	cScript, freeScript := cString(scriptName)
+	defer freeScript()
+	inputs, freeInputs := cStringArray(inputNames)
+	defer freeInputs()
+	exts, freeExts := cStringArray(extFuncs)
+	defer freeExts()
+
+	var out *C.MontyRunHandle
+	status := C.monty_run_new(cCode, cScript, (**C.char)(inputs), (**C.char)(exts), &out)
+	if err := statusError(status); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (cgoEngine) loadRun(data []byte) (any, error) {
+	if err := checkABI(); err != nil {
+		return nil, err
+	}
+	var out *C.MontyRunHandle
+	status := C.monty_run_load((*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &out)
+	if err := statusError(status); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (cgoEngine) dumpRun(h any) ([]byte, error) {
+	var buf *C.uint8_t
+	var length C.size_t
+	status := C.monty_run_dump(h.(*C.MontyRunHandle), &buf, &length)
+	if err := statusError(status); err != nil {
+		return nil, err
+	}
+	return copyBytes(buf, length), nil
+}
+
+func (cgoEngine) freeRun(h any) error {
+	C.monty_run_free(h.(*C.MontyRunHandle))
+	return nil
+}
+
+func (cgoEngine) start(h any, inputs []byte, r *run) (rawProgress, error) {
+	payload, freePayload := cBytes(inputs)
+	defer freePayload()
+
+	var raw C.ProgressResult
+	status := C.monty_run_start(h.(*C.MontyRunHandle), payload, &raw)
+	defer C.monty_progress_result_free_strings(&raw)
+	if err := statusError(status); err != nil {
+		return rawProgress{}, err
+	}
+	return copyProgress(&raw, r)
+}
+
+func (cgoEngine) loadSnapshot(data []byte) (any, error) {
+	if err := checkABI(); err != nil {
+		return nil, err
+	}
+	var out *C.SnapshotHandle
+	status := C.monty_snapshot_load((*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &out)
+	if err := statusError(status); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (cgoEngine) dumpSnapshot(h any) ([]byte, error) {
+	var buf *C.uint8_t
+	var length C.size_t
+	status := C.monty_snapshot_dump(h.(*C.SnapshotHandle), &buf, &length)
+	if err := statusError(status); err != nil {
+		return nil, err
+	}
+	return copyBytes(buf, length), nil
+}
+
+func (cgoEngine) snapshotSize(h any) (int64, error) {
+	var length C.size_t
+	status := C.monty_snapshot_size(h.(*C.SnapshotHandle), &length)
+	if err := statusError(status); err != nil {
+		return 0, err
+	}
+	return int64(length), nil
+}
+
+func (cgoEngine) freeSnapshot(h any) error {
+	C.monty_snapshot_free(h.(*C.SnapshotHandle))
+	return nil
+}
+
+func (cgoEngine) resume(h any, callID uint32, result []byte, errMsg string, r *run) (rawProgress, error) {
+	var resultC *C.char
+	if result != nil {
+		var freeResult func()
+		resultC, freeResult = cBytes(result)
+		defer freeResult()
+	}
+	var errC *C.char
+	if errMsg != "" {
+		var freeErr func()
+		errC, freeErr = cString(errMsg)
+		defer freeErr()
+	}
+
+	var raw C.ProgressResult
+	status := C.monty_snapshot_resume(h.(*C.SnapshotHandle), C.uint32_t(callID), resultC, errC, &raw)
+	defer C.monty_progress_result_free_strings(&raw)
+	if err := statusError(status); err != nil {
+		return rawProgress{}, err
+	}
+	return copyProgress(&raw, r)
+}
+
+func (cgoEngine) loadFutureSnapshot(data []byte) (any, error) {
+	if err := checkABI(); err != nil {
+		return nil, err
+	}
+	var out *C.FutureSnapshotHandle
+	status := C.monty_future_snapshot_load((*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &out)
+	if err := statusError(status); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (cgoEngine) dumpFutureSnapshot(h any) ([]byte, error) {
+	var buf *C.uint8_t
+	var length C.size_t
+	status := C.monty_future_snapshot_dump(h.(*C.FutureSnapshotHandle), &buf, &length)
+	if err := statusError(status); err != nil {
+		return nil, err
+	}
+	return copyBytes(buf, length), nil
+}
+
+func (cgoEngine) futureSnapshotSize(h any) (int64, error) {
+	var length C.size_t
+	status := C.monty_future_snapshot_size(h.(*C.FutureSnapshotHandle), &length)
+	if err := statusError(status); err != nil {
+		return 0, err
+	}
+	return int64(length), nil
+}
+
+func (cgoEngine) dumpFutureSnapshotPending(h any) ([]byte, []byte, error) {
+	var buf *C.uint8_t
+	var length C.size_t
+	var pending *C.char
+	status := C.monty_future_snapshot_dump_pending(h.(*C.FutureSnapshotHandle), &buf, &length, &pending)
+	if err := statusError(status); err != nil {
+		return nil, nil, err
+	}
+	ids := []byte(C.GoString(pending))
+	C.monty_free_string(pending)
+	return copyBytes(buf, length), ids, nil
+}
+
+func (cgoEngine) freeFutureSnapshot(h any) error {
+	C.monty_future_snapshot_free(h.(*C.FutureSnapshotHandle))
+	return nil
+}
+
+func (cgoEngine) resumeFutures(h any, results []byte, r *run) (rawProgress, error) {
+	payload, freePayload := cBytes(results)
+	defer freePayload()
+
+	var raw C.ProgressResult
+	status := C.monty_future_snapshot_resume(h.(*C.FutureSnapshotHandle), payload, &raw)
+	defer C.monty_progress_result_free_strings(&raw)
+	if err := statusError(status); err != nil {
+		return rawProgress{}, err
+	}
+	return copyProgress(&raw, r)
+}
+
+// copyProgress moves a native progress result into Go memory, checking the
+// run's transfer limits before anything is copied.
+func copyProgress(raw *C.ProgressResult, r *run) (rawProgress, error) {
+	if err := r.receive(cLen(raw.args_json)+cLen(raw.kwargs_json), cLen(raw.result_json)); err != nil {
+		freeRawHandles(raw)
+		return rawProgress{}, err
+	}
+	out := rawProgress{
+		kind:       ProgressKind(raw.kind),
+		callID:     uint32(raw.call_id),
+		methodCall: raw.method_call != 0,
+		args:       goBytes(raw.args_json),
+		kwargs:     goBytes(raw.kwargs_json),
+		pendingIDs: goBytes(raw.pending_call_ids_json),
+	}
+	if n := cLen(raw.result_json); r.cfg.streamResults > 0 && n > r.cfg.streamResults {
+		// Hand the library's string to the stream instead of copying it.
+		s := raw.result_json
+		raw.result_json = nil
+		out.resultStream = newResultStream(unsafe.Slice((*byte)(unsafe.Pointer(s)), n), func() {
+			C.monty_free_string(s)
+		})
+	} else {
+		out.result = goBytes(raw.result_json)
+	}
+	if raw.function_name != nil {
+		out.functionName = C.GoString(raw.function_name)
+	}
+	if raw.os_function != nil {
+		out.osFunction = C.GoString(raw.os_function)
+	}
+	if raw.snapshot != nil {
+		out.snapshot = raw.snapshot
+		raw.snapshot = nil
+	}
+	if raw.future_snapshot != nil {
+		out.futureSnapshot = raw.future_snapshot
+		raw.future_snapshot = nil
+	}
+	return out, nil
+}
+
+// freeRawHandles releases snapshot handles a progress result will not hand out.
+func freeRawHandles(raw *C.ProgressResult) {
+	if raw.snapshot != nil {
+		C.monty_snapshot_free(raw.snapshot)
+		raw.snapshot = nil
+	}
+	if raw.future_snapshot != nil {
+		C.monty_future_snapshot_free(raw.future_snapshot)
+		raw.future_snapshot = nil
+	}
+}
+
+func copyBytes(buf *C.uint8_t, length C.size_t) []byte {
+	if buf == nil || length == 0 {
+		return nil
+	}
+	goBuf := C.GoBytes(unsafe.Pointer(buf), C.int(length))
+	C.monty_free_bytes(buf, length)
+	return goBuf
+}
+
+// goBytes copies a C string, returning nil for NULL.
+func goBytes(s *C.char) []byte {
+	if s == nil {
+		return nil
+	}
+	return C.GoBytes(unsafe.Pointer(s), C.int(C.strlen(s)))
+}
+
+// cLen returns the length of a C string without copying it into Go memory.
+func cLen(s *C.char) int64 {
+	if s == nil {
+		return 0
+	}
+	return int64(C.strlen(s))
+}
+
+func cString(value string) (*C.char, func()) {
+	cstr := C.CString(value)
+	return cstr, func() {
+		C.free(unsafe.Pointer(cstr))
+	}
+}
+
+func cBytes(data []byte) (*C.char, func()) {
+	if len(data) == 0 {
+		cstr := C.CString("")
+		return cstr, func() { C.free(unsafe.Pointer(cstr)) }
+	}
+	cstr := C.CString(string(data))
+	return cstr, func() { C.free(unsafe.Pointer(cstr)) }
+}
+
+func cStringArray(values []string) (**C.char, func()) {
+	if len(values) == 0 {
+		return nil, func() {}
+	}
+	items := make([]*C.char, len(values)+1)
+	for i, v := range values {
+		items[i] = C.CString(v)
+	}
+	items[len(values)] = nil
+	return (**C.char)(unsafe.Pointer(&items[0])), func() {
+		for _, ptr := range items[:len(values)] {
+			C.free(unsafe.Pointer(ptr))
+		}
+	}
+}
+
+func statusError(status C.MontyStatus) error {
+	if status.ok != 0 {
+		return nil
+	}
+	var message string
+	if status.error != nil {
+		message = C.GoString(status.error)
+		C.monty_free_string(status.error)
+	} else {
+		message = "monty: unknown error"
+	}
+	return nativeError(int(status.error_kind), message)
+}
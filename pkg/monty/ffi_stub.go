@@ -0,0 +1,10 @@
+//go:build nomonty || (!cgo && !(wasm && monty_wasm))
+
+package monty
+
+var native engine = stubEngine{}
+
+// LoadLibrary always fails in builds without the native library.
+func LoadLibrary(path string) error {
+	return ErrUnavailable
+}
@@ -0,0 +1,33 @@
+//go:build nomonty || (!cgo && !(wasm && monty_wasm))
+
+package monty
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStubReturnsErrUnavailable(t *testing.T) {
+	if _, err := New("1", "test.py", nil, nil); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("New: expected ErrUnavailable, got %v", err)
+	}
+	if _, err := SnapshotFromBytes([]byte{1}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("SnapshotFromBytes: expected ErrUnavailable, got %v", err)
+	}
+	if err := LoadLibrary("libmonty_ffi.so"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("LoadLibrary: expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestStubVersion(t *testing.T) {
+	info := Version()
+	if info.Wrapper != WrapperVersion || info.Library != "" || info.ABI != 0 {
+		t.Fatalf("unexpected version info: %+v", info)
+	}
+}
+
+func TestStubNativeMemStats(t *testing.T) {
+	if _, err := NativeMemStats(); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("NativeMemStats: expected ErrUnavailable, got %v", err)
+	}
+}
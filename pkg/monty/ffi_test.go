@@ -0,0 +1,19 @@
+//go:build cgo && !nomonty
+
+package monty
+
+import (
+	"testing"
+	"unsafe"
+)
+
+// TestStatusLayout pins MontyStatus to the #[repr(C)] struct in
+// monty-ffi/src/error.rs. Changing it needs an ABI bump.
+func TestStatusLayout(t *testing.T) {
+	ok, errorKind, message, size := statusLayout()
+	ptr := unsafe.Sizeof(uintptr(0))
+	if ok != 0 || errorKind != 4 || message != 8 || size != 8+ptr {
+		t.Fatalf("MontyStatus layout is ok@%d error_kind@%d error@%d size %d, want ok@0 error_kind@4 error@8 size %d",
+			ok, errorKind, message, size, 8+ptr)
+	}
+}
@@ -0,0 +1,166 @@
+package monty
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Float is a float64 that survives the bridge when it is NaN or infinite.
+// JSON has no such numbers, so the bridge carries them as {"$float": "nan"},
+// {"$float": "inf"}, and {"$float": "-inf"}. Inputs holding non-finite
+// float64 or float32 values, directly or in slices, arrays, maps, and
+// interfaces, are tagged automatically; struct fields need type Float.
+// Results decode with Object.Float or into Float, since float64 rejects the
+// tag.
+type Float float64
+
+// MarshalJSON encodes f as a JSON number, or tagged when it is not finite.
+func (f Float) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	switch {
+	case math.IsNaN(v):
+		return []byte(`{"$float":"nan"}`), nil
+	case math.IsInf(v, 1):
+		return []byte(`{"$float":"inf"}`), nil
+	case math.IsInf(v, -1):
+		return []byte(`{"$float":"-inf"}`), nil
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON accepts a JSON number or a $float tag.
+func (f *Float) UnmarshalJSON(data []byte) error {
+	v, err := decodeFloat(data)
+	if err != nil {
+		return err
+	}
+	*f = Float(v)
+	return nil
+}
+
+// Float decodes o as a float: a JSON number, a big int, or a $float tag.
+func (o Object) Float() (float64, error) {
+	if len(o) == 0 {
+		return 0, fmt.Errorf("monty: empty object payload")
+	}
+	return decodeFloat(o)
+}
+
+func decodeFloat(data []byte) (float64, error) {
+	var tagged struct {
+		Float  *string `json:"$float"`
+		BigInt *string `json:"$bigint"`
+	}
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, &tagged); err != nil {
+			return 0, err
+		}
+		switch {
+		case tagged.Float != nil:
+			switch *tagged.Float {
+			case "nan":
+				return math.NaN(), nil
+			case "inf":
+				return math.Inf(1), nil
+			case "-inf":
+				return math.Inf(-1), nil
+			}
+			return 0, fmt.Errorf("monty: invalid $float %q", *tagged.Float)
+		case tagged.BigInt != nil:
+			return strconv.ParseFloat(*tagged.BigInt, 64)
+		}
+		return 0, fmt.Errorf("monty: cannot decode %s as a float", data)
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return 0, fmt.Errorf("monty: cannot decode %s as a float: %w", data, err)
+	}
+	return v, nil
+}
+
+// tagFloats returns v with its non-finite floats replaced by Float, so that
+// it encodes, or v itself when it has none. Structs are left alone; their
+// fields need the Float type.
+func tagFloats(v any) any {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if !hasNonFinite(rv, 0) {
+		return v
+	}
+	return tagValue(rv).Interface()
+}
+
+// maxFloatDepth bounds the search for non-finite floats in nested values.
+const maxFloatDepth = 64
+
+func hasNonFinite(v reflect.Value, depth int) bool {
+	if depth > maxFloatDepth {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		return math.IsNaN(f) || math.IsInf(f, 0)
+	case reflect.Interface, reflect.Pointer:
+		return !v.IsNil() && hasNonFinite(v.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+		for i := 0; i < v.Len(); i++ {
+			if hasNonFinite(v.Index(i), depth+1) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if hasNonFinite(iter.Value(), depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tagValue copies v with floats converted to Float, slices and arrays to
+// []any, and maps to maps of any with the same keys.
+func tagValue(v reflect.Value) reflect.Value {
+	if _, ok := v.Interface().(json.Marshaler); ok && v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+		return v
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return reflect.ValueOf(Float(v.Float()))
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		return tagValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && (v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8) {
+			return v
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = tagValue(v.Index(i)).Interface()
+		}
+		return reflect.ValueOf(out)
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(reflect.MapOf(v.Type().Key(), reflect.TypeOf((*any)(nil)).Elem()), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), tagValue(iter.Value()))
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,67 @@
+package monty
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestMarshalNonFiniteFloats(t *testing.T) {
+	cases := []struct {
+		in   any
+		want string
+	}{
+		{1.5, `1.5`},
+		{math.NaN(), `{"$float":"nan"}`},
+		{float32(math.Inf(-1)), `{"$float":"-inf"}`},
+		{[]float64{1, math.Inf(1)}, `[1,{"$float":"inf"}]`},
+		{map[string]any{"x": []any{math.NaN()}, "y": "s"}, `{"x":[{"$float":"nan"}],"y":"s"}`},
+		{struct{ F Float }{Float(math.Inf(1))}, `{"F":{"$float":"inf"}}`},
+	}
+	for _, tc := range cases {
+		got, err := marshalValue(tc.in)
+		if err != nil {
+			t.Fatalf("marshalValue(%v) failed: %v", tc.in, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("marshalValue(%v) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+	if _, err := marshalValue(struct{ F float64 }{math.NaN()}); err == nil {
+		t.Fatal("a float64 struct field holding NaN should not encode")
+	}
+}
+
+func TestObjectFloat(t *testing.T) {
+	for in, want := range map[string]float64{
+		`2.5`:                  2.5,
+		`{"$float":"inf"}`:     math.Inf(1),
+		`{"$float":"-inf"}`:    math.Inf(-1),
+		`{"$bigint":"123456"}`: 123456,
+	} {
+		got, err := Object(in).Float()
+		if err != nil || got != want {
+			t.Errorf("Float(%s) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if got, err := Object(`{"$float":"nan"}`).Float(); err != nil || !math.IsNaN(got) {
+		t.Fatalf("Float(nan) = %v, %v", got, err)
+	}
+	for _, in := range []string{`"x"`, `{"$float":"big"}`, `{"$tuple":[1]}`, ``} {
+		if _, err := Object(in).Float(); err == nil {
+			t.Errorf("Float(%s) should fail", in)
+		}
+	}
+
+	var fs []Float
+	if err := json.Unmarshal([]byte(`[1, {"$float":"-inf"}]`), &fs); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if fs[0] != 1 || !math.IsInf(float64(fs[1]), -1) {
+		t.Fatalf("Unmarshal = %v", fs)
+	}
+	plain, err := Object(`{"$tuple":[{"$float":"nan"},1]}`).Plain()
+	if err != nil || string(plain) != `[null,1]` {
+		t.Fatalf("Plain = %s, %v", plain, err)
+	}
+}
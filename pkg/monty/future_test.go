@@ -0,0 +1,117 @@
+package monty
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFutureSnapshotResumeOne(t *testing.T) {
+	var sent []json.RawMessage
+	sb := scriptedBridge(bridgeScript{"resume_futures": func(req sandboxRequest) sandboxResponse {
+		sent = append(sent, req.Payload)
+		return sandboxResponse{Progress: &sandboxProgress{
+			Kind:           ResolveFutures,
+			PendingIDs:     json.RawMessage("[2]"),
+			FutureSnapshot: 7,
+		}}
+	}})
+	fs := newFutureSnapshot(sandboxHandle{id: 1}, []uint32{1, 2}, newConfig([]Option{WithSandbox(sb)}).newRun())
+
+	if _, err := fs.ResumeOne(FutureResult{CallID: 3, Result: 1}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected ErrInvalidInput for a call that is not pending, got %v", err)
+	}
+	p, err := fs.ResumeOne(FutureResult{CallID: 1, Result: "a"})
+	if err != nil {
+		t.Fatalf("ResumeOne failed: %v", err)
+	}
+	defer p.FutureSnapshot.Close()
+	if !fs.IsClosed() {
+		t.Fatalf("ResumeOne should consume the snapshot")
+	}
+	if p.Kind != ResolveFutures || len(p.PendingIDs) != 1 || p.PendingIDs[0] != 2 {
+		t.Fatalf("unexpected progress: %+v", p)
+	}
+	if len(sent) != 1 || string(sent[0]) != `[{"call_id":1,"result":"a"}]` {
+		t.Fatalf("unexpected payloads: %s", sent)
+	}
+}
+
+func TestFutureSnapshotDumpPending(t *testing.T) {
+	dumped, unblock := make(chan struct{}), make(chan struct{})
+	sb := scriptedBridge(bridgeScript{
+		"load_future_snapshot": reply(sandboxResponse{Handle: 1}),
+		"dump_future_snapshot_pending": func(sandboxRequest) sandboxResponse {
+			dumped <- struct{}{}
+			<-unblock
+			return sandboxResponse{Data: []byte("state"), PendingIDs: json.RawMessage("[4,5]")}
+		},
+	})
+	fs := newFutureSnapshot(sandboxHandle{id: 1}, []uint32{4, 5}, newConfig([]Option{WithSandbox(sb)}).newRun())
+	defer fs.Close()
+
+	type dump struct {
+		data []byte
+		ids  []uint32
+		err  error
+	}
+	done := make(chan dump)
+	go func() {
+		data, ids, err := fs.DumpPending()
+		done <- dump{data, ids, err}
+	}()
+	<-dumped
+	// Other goroutines read the pending calls to dispatch them meanwhile,
+	// but cannot resume the snapshot from under the dump.
+	if ids := fs.PendingCallIDs(); len(ids) != 2 {
+		t.Errorf("PendingCallIDs = %v", ids)
+	}
+	if _, err := fs.Resume(nil); !errors.Is(err, ErrConcurrentUse) {
+		t.Errorf("Resume during a dump: err = %v, want ErrConcurrentUse", err)
+	}
+	close(unblock)
+	d := <-done
+	if d.err != nil || len(d.ids) != 2 || d.ids[0] != 4 || d.ids[1] != 5 {
+		t.Fatalf("DumpPending = %v, %v", d.ids, d.err)
+	}
+	restored, err := FutureSnapshotFromBytes(d.data, WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored.Close()
+}
+
+func TestResumeFuturePendingCall(t *testing.T) {
+	sb := scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start": reply(sandboxResponse{Progress: &sandboxProgress{
+			Kind: FunctionCall, CallID: 3, FunctionName: "fetch",
+			Args: json.RawMessage(`["u"]`), Kwargs: json.RawMessage(`[["timeout",5]]`), Snapshot: 2,
+		}}),
+		"resume": reply(sandboxResponse{Progress: &sandboxProgress{
+			Kind: ResolveFutures, PendingIDs: json.RawMessage("[3]"), FutureSnapshot: 4,
+		}}),
+	})
+	m, err := New("await fetch('u', timeout=5)", "main.py", nil, []string{"fetch"}, WithSandbox(sb), WithDeterministic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, call, err := p.Snapshot.ResumeFuture(p.CallID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer next.FutureSnapshot.Close()
+	if call.CallID != 3 || call.FunctionName != "fetch" || len(call.Args) != 1 || string(call.Args[0]) != `"u"` ||
+		len(call.Kwargs) != 1 || string(call.Kwargs[0].Value) != "5" || !call.CreatedAt.Equal(time.Unix(0, 0)) {
+		t.Errorf("PendingCall = %+v", call)
+	}
+	if next.Kind != ResolveFutures || next.PendingIDs[0] != call.CallID {
+		t.Errorf("ResumeFuture = %+v", next)
+	}
+}
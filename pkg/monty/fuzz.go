@@ -0,0 +1,68 @@
+package monty
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// fuzzSeeds are JSON documents covering the shapes the bridge must survive.
+var fuzzSeeds = []string{
+	`[]`, `[null]`, `[0]`, `[-1]`, `[1.5e308]`, `[18446744073709551616]`, `[-9223372036854775809]`,
+	`[""]`, `["\u0000"]`, `["\ud800"]`, `["é漢🙂"]`, `[true, false]`,
+	`[[1, [2, [3, [4]]]]]`, `[{"a": {"b": {"c": []}}}]`, `[{"": null}]`, `[{"1": 1, "01": 2}]`,
+	`[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]`,
+}
+
+// fuzzExprs are evaluated by the interpreter so the corpus also holds values
+// in exactly the encoding the library produces.
+var fuzzExprs = []string{
+	`(1, "a", None)`, `{1, 2, 3}`, `frozenset([1])`, `{"k": [1.5, -0.0]}`, `2 ** 100`,
+	`b"bytes"`, `[[]] * 3`, `{(1, 2): "tuple key"}`, `"x" * 64`, `float("inf")`,
+}
+
+// FuzzCorpus returns seed inputs for fuzz targets built on FuzzInputs and the
+// Object decoders, each a JSON array of Start inputs. When the native library
+// is available the seeds include values produced by the interpreter itself.
+func FuzzCorpus(opts ...Option) [][]byte {
+	corpus := make([][]byte, 0, len(fuzzSeeds)+len(fuzzExprs))
+	for _, s := range fuzzSeeds {
+		corpus = append(corpus, []byte(s))
+	}
+	for _, expr := range fuzzExprs {
+		m, err := New(expr, "corpus.py", nil, nil, opts...)
+		if errors.Is(err, ErrUnavailable) {
+			break
+		}
+		if err != nil {
+			continue
+		}
+		result, err := m.Run()
+		m.Close()
+		if err == nil && len(result) > 0 {
+			corpus = append(corpus, append(append([]byte("["), result...), ']'))
+		}
+	}
+	return corpus
+}
+
+// FuzzInputs starts m with the inputs encoded in data, a JSON array, and
+// returns any error instead of panicking. Rejected data is expected; fuzz
+// targets should only fail on panics. Compile m with WithSandbox so that a
+// crash in native code surfaces as ErrSandboxExited rather than killing the
+// fuzzer process.
+func FuzzInputs(m *Monty, data []byte) error {
+	var inputs []json.RawMessage
+	if err := json.Unmarshal(data, &inputs); err != nil {
+		return err
+	}
+	args := make([]any, len(inputs))
+	for i, in := range inputs {
+		args[i] = in
+	}
+	progress, err := m.Start(args...)
+	if err != nil {
+		return err
+	}
+	closeProgress(progress)
+	return nil
+}
@@ -0,0 +1,68 @@
+package monty
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func FuzzObjectDecoding(f *testing.F) {
+	for _, seed := range FuzzCorpus() {
+		f.Add(seed)
+	}
+	f.Add([]byte(`[["k", 1], ["v"]]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s := string(data)
+		if objs, err := decodeObjectArrayString(s); err == nil {
+			for _, obj := range objs {
+				if _, err := objectToInterface(obj); err != nil {
+					t.Fatalf("element of a decoded array failed to decode: %v", err)
+				}
+			}
+		}
+		decodeKwargsString(s)
+		decodeUint32ArrayString(s)
+	})
+}
+
+func FuzzMarshalInputs(f *testing.F) {
+	for _, seed := range FuzzCorpus() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var values []any
+		if json.Unmarshal(data, &values) != nil {
+			return
+		}
+		inputs := make([]any, len(values))
+		for i, v := range values {
+			raw, _ := json.Marshal(v)
+			inputs[i] = Object(raw)
+		}
+		payload, err := marshalInputs(inputs)
+		if err != nil {
+			t.Fatalf("marshalInputs rejected decoded JSON: %v", err)
+		}
+		var back []any
+		if err := json.Unmarshal(payload, &back); err != nil || len(back) != len(values) {
+			t.Fatalf("marshalInputs produced %s (%v)", payload, err)
+		}
+	})
+}
+
+func FuzzInputsNative(f *testing.F) {
+	m, err := New("x", "fuzz.py", []string{"x"}, nil)
+	if errors.Is(err, ErrUnavailable) {
+		f.Skip("native library unavailable")
+	}
+	if err != nil {
+		f.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+	for _, seed := range FuzzCorpus() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		FuzzInputs(m, data)
+	})
+}
@@ -0,0 +1,215 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrCanceled is returned by runs whose Group was canceled.
+var ErrCanceled = errors.New("monty: run group canceled")
+
+// maxDrainSteps bounds how long a canceled run may keep running while it
+// handles the cancellation.
+const maxDrainSteps = 100
+
+// Group ties runs together so the host can cancel them at once, e.g. when a
+// tenant is suspended or the request that started them is aborted. Runs
+// join a group by being started with WithGroup.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	mu   sync.Mutex
+	err  error
+	runs map[*run]struct{}
+}
+
+// NewGroup returns a group that is canceled along with parent.
+func NewGroup(parent context.Context) *Group {
+	ctx, cancel := context.WithCancelCause(parent)
+	g := &Group{ctx: ctx, cancel: cancel, runs: make(map[*run]struct{})}
+	context.AfterFunc(ctx, func() { g.Cancel(context.Cause(ctx)) })
+	return g
+}
+
+// WithGroup adds the runs started with the option to g.
+func WithGroup(g *Group) Option {
+	return func(c *config) {
+		c.group = g
+	}
+}
+
+// Cancel cancels every run in g, now and later, for cause. Runs paused at
+// an event are resumed with the cancellation at once: the calls they wait
+// on, pending futures included, raise a RuntimeError naming the cause, so
+// their finally blocks and except handlers run, and any calls they make
+// while unwinding raise it too. Their snapshots are consumed, and resuming
+// them fails with ErrCanceled. Runs in the middle of a step are canceled
+// when it returns, and Starts under g fail with ErrCanceled. The context of
+// Runner handlers for runs in g is canceled too. Canceling twice is a no-op.
+func (g *Group) Cancel(cause error) {
+	g.mu.Lock()
+	if g.err != nil {
+		g.mu.Unlock()
+		return
+	}
+	g.err = canceled(cause)
+	var paused []*run
+	for r := range g.runs {
+		if r.paused.Snapshot != nil || r.paused.FutureSnapshot != nil {
+			paused = append(paused, r)
+			delete(g.runs, r)
+		}
+	}
+	g.mu.Unlock()
+	g.cancel(g.err)
+	for _, r := range paused {
+		r.drain(r.paused)
+	}
+}
+
+// Err returns nil until g is canceled, and then an error wrapping
+// ErrCanceled and the cause.
+func (g *Group) Err() error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.errLocked()
+}
+
+// errLocked returns the group's error, counting a done parent context the
+// group has not yet been canceled for.
+func (g *Group) errLocked() error {
+	if g.err == nil && g.ctx.Err() != nil {
+		return canceled(context.Cause(g.ctx))
+	}
+	return g.err
+}
+
+func canceled(cause error) error {
+	if cause == nil {
+		return ErrCanceled
+	}
+	return fmt.Errorf("%w: %w", ErrCanceled, cause)
+}
+
+// Context returns a context canceled with g, for work done on behalf of its
+// runs.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Len returns the number of runs in g that have started and not finished.
+func (g *Group) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.runs)
+}
+
+// enterGroup admits a step of r unless its group is canceled.
+func (r *run) enterGroup() error {
+	g := r.cfg.group
+	if g == nil || r.draining {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.errLocked(); err != nil {
+		return err
+	}
+	g.runs[r] = struct{}{}
+	r.paused = Progress{}
+	return nil
+}
+
+// leaveGroup records where a step of r paused, or removes the run from its
+// group once it finishes. A run whose group was canceled during the step is
+// drained instead, unless it completed.
+func (r *run) leaveGroup(p Progress, err error) (Progress, error) {
+	g := r.cfg.group
+	if g == nil || r.draining {
+		return p, err
+	}
+	g.mu.Lock()
+	cancelErr := g.errLocked()
+	if cancelErr == nil && err == nil && p.Kind != Complete {
+		r.paused = p
+		g.mu.Unlock()
+		return p, nil
+	}
+	delete(g.runs, r)
+	g.mu.Unlock()
+	if cancelErr == nil || err != nil || p.Kind == Complete {
+		return p, err
+	}
+	r.drain(p)
+	return Progress{}, cancelErr
+}
+
+// forgetPaused removes r from its group when the host closes the snapshot
+// it paused at.
+func (r *run) forgetPaused(s *Snapshot, fs *FutureSnapshot) {
+	g := r.cfg.group
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s != nil && r.paused.Snapshot == s || fs != nil && r.paused.FutureSnapshot == fs {
+		delete(g.runs, r)
+		r.paused = Progress{}
+	}
+}
+
+// closedError reports resuming s after it was closed or consumed, by the
+// host or by the cancellation of its group.
+func (s *Snapshot) closedError() error {
+	if s != nil && s.run != nil {
+		if err := s.run.cfg.group.Err(); err != nil {
+			return err
+		}
+	}
+	return newError(ErrClosed, "monty: snapshot closed")
+}
+
+func (fs *FutureSnapshot) closedError() error {
+	if fs != nil && fs.run != nil {
+		if err := fs.run.cfg.group.Err(); err != nil {
+			return err
+		}
+	}
+	return newError(ErrClosed, "monty: future snapshot closed")
+}
+
+// drain raises the group's cancellation in the run paused at p until it
+// finishes, closing it if it does not within maxDrainSteps events.
+func (r *run) drain(p Progress) {
+	r.draining = true
+	msg := "run group canceled"
+	if err := r.cfg.group.Err(); err != nil {
+		msg = err.Error()
+	}
+	for i := 0; i < maxDrainSteps; i++ {
+		var err error
+		switch p.Kind {
+		case FunctionCall, OsCall, Timer:
+			p, err = p.Snapshot.ResumeError(p.CallID, msg)
+		case ResolveFutures:
+			results := make([]FutureResult, len(p.PendingIDs))
+			for i, id := range p.PendingIDs {
+				results[i] = FutureResult{CallID: id, Err: msg}
+			}
+			p, err = p.FutureSnapshot.Resume(results)
+		default:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+	closeProgress(p)
+}
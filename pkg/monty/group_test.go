@@ -0,0 +1,169 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// cancelBridge pauses at an external call, or at futures when futures is
+// set, and completes with the error each was answered with, as a script
+// catching it would.
+func cancelBridge(futures bool, answers *[]string) *Sandbox {
+	start := callProgress(1, "fetch", "[]", 2)
+	if futures {
+		start = sandboxResponse{Progress: &sandboxProgress{
+			Kind: ResolveFutures, PendingIDs: json.RawMessage("[3,4]"), FutureSnapshot: 2,
+		}}
+	}
+	return scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(start),
+		"resume": func(req sandboxRequest) sandboxResponse {
+			*answers = append(*answers, req.ErrMsg)
+			result, _ := json.Marshal(req.ErrMsg)
+			return completeProgress(result)
+		},
+		"resume_futures": func(req sandboxRequest) sandboxResponse {
+			var results []struct {
+				CallID uint32 `json:"call_id"`
+				Error  string `json:"error"`
+			}
+			json.Unmarshal(req.Payload, &results)
+			for _, r := range results {
+				*answers = append(*answers, r.Error)
+			}
+			return completeProgress(json.RawMessage("null"))
+		},
+	})
+}
+
+func TestGroupCancelPausedRuns(t *testing.T) {
+	var answers []string
+	g := NewGroup(context.Background())
+	m, err := New("fetch()", "main.py", nil, []string{"fetch"}, WithSandbox(cancelBridge(false, &answers)), WithGroup(g))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	first, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if g.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", g.Len())
+	}
+
+	g.Cancel(errors.New("tenant suspended"))
+	if len(answers) != 2 {
+		t.Fatalf("answers = %q, want both runs resumed", answers)
+	}
+	for _, a := range answers {
+		if !strings.Contains(a, "tenant suspended") {
+			t.Errorf("answer = %q, want the cause", a)
+		}
+	}
+	if g.Len() != 0 {
+		t.Errorf("Len = %d after Cancel", g.Len())
+	}
+	if !first.Snapshot.IsClosed() {
+		t.Error("snapshot not consumed")
+	}
+	if _, err := first.Snapshot.Resume(1, "late"); !errors.Is(err, ErrCanceled) {
+		t.Errorf("Resume err = %v, want ErrCanceled", err)
+	}
+	if _, err := m.Start(); !errors.Is(err, ErrCanceled) {
+		t.Errorf("Start err = %v, want ErrCanceled", err)
+	}
+	if err := g.Err(); !errors.Is(err, ErrCanceled) || !strings.Contains(err.Error(), "tenant suspended") {
+		t.Errorf("Err = %v", err)
+	}
+}
+
+func TestGroupCancelFutures(t *testing.T) {
+	var answers []string
+	g := NewGroup(context.Background())
+	m, err := New("x", "main.py", nil, nil, WithSandbox(cancelBridge(true, &answers)), WithGroup(g))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	g.Cancel(nil)
+	if len(answers) != 2 || answers[0] != ErrCanceled.Error() {
+		t.Errorf("answers = %q, want both futures canceled", answers)
+	}
+}
+
+func TestGroupParentContext(t *testing.T) {
+	var answers []string
+	ctx, cancel := context.WithCancel(context.Background())
+	g := NewGroup(ctx)
+	m, err := New("fetch()", "main.py", nil, []string{"fetch"}, WithSandbox(cancelBridge(false, &answers)), WithGroup(g))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	<-g.Context().Done()
+	if err := g.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Err = %v, want context.Canceled", err)
+	}
+}
+
+func TestGroupForgetsFinishedRuns(t *testing.T) {
+	var answers []string
+	g := NewGroup(context.Background())
+	m, err := New("fetch()", "main.py", nil, []string{"fetch"}, WithSandbox(cancelBridge(false, &answers)), WithGroup(g))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err = p.Snapshot.Resume(1, "ok"); err != nil || p.Kind != Complete {
+		t.Fatalf("Resume = %v, %v", p.Kind, err)
+	}
+	p, err = m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Snapshot.Close()
+	if g.Len() != 0 {
+		t.Errorf("Len = %d, want 0", g.Len())
+	}
+}
+
+func TestGroupCancelsRunner(t *testing.T) {
+	var answers []string
+	g := NewGroup(context.Background())
+	m, err := New("fetch()", "main.py", nil, []string{"fetch"}, WithSandbox(cancelBridge(false, &answers)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	r := NewRunner(m, WithGroup(g))
+	r.Register("fetch", func(ctx context.Context, call CallInfo) (any, error) {
+		g.Cancel(errors.New("request aborted"))
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if _, err := r.Run(context.Background()); !errors.Is(err, ErrCanceled) {
+		t.Errorf("Run err = %v, want ErrCanceled", err)
+	}
+	if len(answers) != 1 || !strings.Contains(answers[0], "request aborted") {
+		t.Errorf("answers = %q", answers)
+	}
+}
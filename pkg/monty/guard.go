@@ -0,0 +1,97 @@
+package monty
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ConcurrentUseError reports a handle used by two goroutines at once, such
+// as a snapshot resumed twice in parallel or a program closed while a run
+// starts. The second use is refused before it reaches the native library.
+type ConcurrentUseError struct {
+	Kind HandleKind
+	// Op is the refused operation, such as "resume" or "close".
+	Op string
+	// Stack and OtherStack are the goroutine stacks of the refused use and
+	// of the use in progress, recorded while TrackConcurrentUse is enabled.
+	Stack      string
+	OtherStack string
+}
+
+func (e *ConcurrentUseError) Error() string {
+	return fmt.Sprintf("monty: %s of %s while another goroutine is using it", e.Op, e.Kind)
+}
+
+// Unwrap classifies the error as ErrConcurrentUse.
+func (e *ConcurrentUseError) Unwrap() error {
+	return ErrConcurrentUse
+}
+
+// TrackConcurrentUse turns recording of goroutine stacks for
+// ConcurrentUseError on or off. Recording costs a stack capture per call on
+// a handle, so enable it in tests and debugging sessions.
+func TrackConcurrentUse(enable bool) {
+	trackUse.Store(enable)
+}
+
+var trackUse atomic.Bool
+
+// useGuard detects concurrent use of a handle. Its state counts shared users,
+// or is -1 while one user holds it exclusively.
+type useGuard struct {
+	state atomic.Int64
+
+	mu    sync.Mutex
+	stack string
+}
+
+// acquire admits op on a handle of kind, shared with other shared users or
+// exclusively, and returns a *ConcurrentUseError if it conflicts with a use
+// in progress. Callers release the guard once the native call returns.
+func (g *useGuard) acquire(kind HandleKind, op string, shared bool) error {
+	var stack string
+	if trackUse.Load() {
+		stack = currentStack()
+	}
+	for {
+		n := g.state.Load()
+		if n < 0 || n > 0 && !shared {
+			return g.conflict(kind, op, stack)
+		}
+		next := int64(-1)
+		if shared {
+			next = n + 1
+		}
+		if g.state.CompareAndSwap(n, next) {
+			break
+		}
+	}
+	if stack != "" {
+		g.mu.Lock()
+		g.stack = stack
+		g.mu.Unlock()
+	}
+	return nil
+}
+
+// release ends a use admitted by acquire.
+func (g *useGuard) release(shared bool) {
+	if shared {
+		g.state.Add(-1)
+	} else {
+		g.state.Store(0)
+	}
+}
+
+func (g *useGuard) conflict(kind HandleKind, op, stack string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return &ConcurrentUseError{Kind: kind, Op: op, Stack: stack, OtherStack: g.stack}
+}
+
+func currentStack() string {
+	buf := make([]byte, 8<<10)
+	return string(buf[:runtime.Stack(buf, false)])
+}
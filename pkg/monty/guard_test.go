@@ -0,0 +1,82 @@
+package monty
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConcurrentUse(t *testing.T) {
+	TrackConcurrentUse(true)
+	defer TrackConcurrentUse(false)
+
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	sb := scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(callProgress(1, "f", "[]", 2)),
+		"resume": func(sandboxRequest) sandboxResponse {
+			entered <- struct{}{}
+			<-unblock
+			return completeProgress(json.RawMessage("1"))
+		},
+	})
+	m, err := New("f()", "main.py", nil, []string{"f"}, WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := p.Snapshot.Resume(p.CallID, 1)
+		done <- err
+	}()
+	<-entered
+	for _, use := range []func() error{
+		func() error { _, err := p.Snapshot.Resume(p.CallID, 2); return err },
+		func() error { _, err := p.Snapshot.Dump(); return err },
+		p.Snapshot.Close,
+	} {
+		err := use()
+		var useErr *ConcurrentUseError
+		if !errors.As(err, &useErr) || !errors.Is(err, ErrConcurrentUse) {
+			t.Fatalf("err = %v, want a ConcurrentUseError", err)
+		}
+		if useErr.Kind != SnapshotHandle || !strings.Contains(useErr.Stack, "TestConcurrentUse") || !strings.Contains(useErr.OtherStack, "TestConcurrentUse") {
+			t.Errorf("ConcurrentUseError = %+v, want both stacks", useErr)
+		}
+	}
+	close(unblock)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Snapshot.Resume(p.CallID, 1); !errors.Is(err, ErrClosed) {
+		t.Errorf("resume after the first one finished: err = %v, want ErrClosed", err)
+	}
+}
+
+func TestConcurrentUseSharedStart(t *testing.T) {
+	var g useGuard
+	if err := g.acquire(MontyHandle, "start", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.acquire(MontyHandle, "start", true); err != nil {
+		t.Fatalf("second start refused: %v", err)
+	}
+	if err := g.acquire(MontyHandle, "close", false); !errors.Is(err, ErrConcurrentUse) {
+		t.Fatalf("close during starts: err = %v", err)
+	}
+	g.release(true)
+	g.release(true)
+	if err := g.acquire(MontyHandle, "close", false); err != nil {
+		t.Fatalf("close after starts: %v", err)
+	}
+	if err := g.acquire(MontyHandle, "start", true); !errors.Is(err, ErrConcurrentUse) {
+		t.Fatalf("start during close: err = %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// handles lends host values to scripts by reference, as frozen dataclasses
+// of one type, and answers the method calls scripts make on them. Streams,
+// Buffers, and Tables each keep their values in one.
+type handles struct {
+	typeID uint64
+	// gone is raised for method calls on a reference whose value was
+	// released.
+	gone string
+
+	mu     sync.Mutex
+	next   int64
+	values map[int64]any
+}
+
+func newHandles(typeID uint64, gone string) handles {
+	return handles{typeID: typeID, gone: gone, values: make(map[int64]any)}
+}
+
+// add registers v and returns a reference to it named name, carrying attrs
+// after its id.
+func (h *handles) add(name string, v any, attrs ...[2]any) Object {
+	h.mu.Lock()
+	h.next++
+	id := h.next
+	h.values[id] = v
+	h.mu.Unlock()
+	return handleObject(name, h.typeID, id, attrs...)
+}
+
+// get resolves a reference returned by add. ok is false when o is not such a
+// reference or its value was released.
+func (h *handles) get(o Object) (v any, ok bool) {
+	id, ok := handleRef(o, h.typeID)
+	if !ok {
+		return nil, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v, ok = h.values[id]
+	return v, ok
+}
+
+// release forgets the value o refers to and returns it.
+func (h *handles) release(o Object) (v any, ok bool) {
+	id, ok := handleRef(o, h.typeID)
+	if !ok {
+		return nil, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v, ok = h.values[id]
+	delete(h.values, id)
+	return v, ok
+}
+
+func (h *handles) len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.values)
+}
+
+// method returns a handler that passes method calls on references of h to
+// fn, with the value, the reference, and the arguments after it. Calls on
+// released references raise h.gone. Other calls, including plain calls to
+// functions named like the method, go to next, which may be nil.
+func (h *handles) method(next Handler, fn func(ctx context.Context, v any, self Object, args []Object) (any, error)) Handler {
+	return func(ctx context.Context, call CallInfo) (any, error) {
+		ok := call.MethodCall && len(call.Args) > 0
+		if ok {
+			_, ok = handleRef(call.Args[0], h.typeID)
+		}
+		if !ok {
+			if next == nil {
+				return nil, fmt.Errorf("no handler registered for %s", call.Name)
+			}
+			return next(ctx, call)
+		}
+		v, ok := h.get(call.Args[0])
+		if !ok {
+			return nil, errors.New(h.gone)
+		}
+		return fn(ctx, v, call.Args[0], call.Args[1:])
+	}
+}
+
+// registerMethods installs handler(name, previous) on r for each method name,
+// where previous is the handler r had under that name.
+func registerMethods(r *Runner, names []string, handler func(name string, next Handler) Handler) {
+	for _, name := range names {
+		r.Register(name, handler(name, r.handlers[name]))
+	}
+}
+
+// handleObject builds the frozen dataclass through which a host-side value is
+// referenced from a script: an id attribute followed by attrs.
+func handleObject(name string, typeID uint64, id int64, attrs ...[2]any) Object {
+	fields := []string{"id"}
+	for _, kv := range attrs {
+		fields = append(fields, kv[0].(string))
+	}
+	data, _ := json.Marshal(map[string]any{"$dataclass": map[string]any{
+		"name":        name,
+		"type_id":     typeID,
+		"field_names": fields,
+		"attrs":       append([][2]any{{"id", id}}, attrs...),
+		"frozen":      true,
+	}})
+	return data
+}
+
+// handleRef returns the id of a handleObject of the given type.
+func handleRef(o Object, typeID uint64) (id int64, ok bool) {
+	var self struct {
+		Dataclass *struct {
+			TypeID uint64              `json:"type_id"`
+			Attrs  [][]json.RawMessage `json:"attrs"`
+		} `json:"$dataclass"`
+	}
+	if o.Unmarshal(&self) != nil || self.Dataclass == nil || self.Dataclass.TypeID != typeID {
+		return 0, false
+	}
+	for _, kv := range self.Dataclass.Attrs {
+		var key string
+		if len(kv) == 2 && json.Unmarshal(kv[0], &key) == nil && key == "id" {
+			return id, json.Unmarshal(kv[1], &id) == nil
+		}
+	}
+	return 0, false
+}
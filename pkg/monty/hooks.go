@@ -0,0 +1,145 @@
+package monty
+
+import (
+	"sync"
+	"time"
+)
+
+// Hook observes the life of programs and runs. Hooks are called
+// synchronously, in registration order, on the goroutine that compiled the
+// program or stepped the run, so they must be quick and must not use the
+// handle whose event they observe.
+type Hook func(e ExecEvent)
+
+// ExecEvent describes the moment a Hook is called for.
+type ExecEvent struct {
+	// Script is the script name of the program; programs restored from
+	// bytes have none.
+	Script string
+	// RunID and Labels identify the run. Compile events have neither.
+	RunID  string
+	Labels map[string]string
+	// Kind is the progress kind of a pause or completion, and
+	// ResolveFutures for the resumption of futures.
+	Kind ProgressKind
+	// CallID and Name identify the call a run paused at or was resumed
+	// from, Name being the external or OS function called.
+	CallID uint32
+	Name   string
+	// Err is the failure of a compile or run.
+	Err error
+	// Time is when the event happened, by the run's clock.
+	Time time.Time
+	// Duration is how long a compile took.
+	Duration time.Duration
+}
+
+// hookPoint indexes the points hooks attach to.
+type hookPoint int
+
+const (
+	hookCompile hookPoint = iota
+	hookStart
+	hookPause
+	hookResume
+	hookComplete
+	hookError
+	hookPoints
+)
+
+var hooks struct {
+	sync.RWMutex
+	list [hookPoints][]*Hook
+}
+
+// OnCompile registers h to be called after New compiles a program, with Err
+// set when compilation failed. It returns a function that unregisters h.
+func OnCompile(h Hook) (remove func()) { return addHook(hookCompile, h) }
+
+// OnStart registers h to be called when a run starts, before its first
+// step.
+func OnStart(h Hook) (remove func()) { return addHook(hookStart, h) }
+
+// OnPause registers h to be called when a run pauses at an event the host
+// handles: a call, a sleep, or futures to resolve. Calls the package answers
+// itself, such as virtualized OS calls, do not pause the run.
+func OnPause(h Hook) (remove func()) { return addHook(hookPause, h) }
+
+// OnResume registers h to be called when the host resumes a paused run.
+func OnResume(h Hook) (remove func()) { return addHook(hookResume, h) }
+
+// OnComplete registers h to be called when a run completes.
+func OnComplete(h Hook) (remove func()) { return addHook(hookComplete, h) }
+
+// OnError registers h to be called when a run fails, including uncaught
+// exceptions of the script. Compile errors are reported to OnCompile.
+func OnError(h Hook) (remove func()) { return addHook(hookError, h) }
+
+func addHook(point hookPoint, h Hook) func() {
+	entry := &h
+	hooks.Lock()
+	hooks.list[point] = append(hooks.list[point], entry)
+	hooks.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			hooks.Lock()
+			defer hooks.Unlock()
+			list := hooks.list[point]
+			for i, e := range list {
+				if e == entry {
+					// Copy, since fire may be ranging over the old slice.
+					hooks.list[point] = append(append([]*Hook(nil), list[:i]...), list[i+1:]...)
+					return
+				}
+			}
+		})
+	}
+}
+
+// fire calls the hooks registered at point with the event built by event,
+// which is only called when there are any.
+func fire(point hookPoint, event func() ExecEvent) {
+	hooks.RLock()
+	list := hooks.list[point]
+	hooks.RUnlock()
+	if len(list) == 0 {
+		return
+	}
+	e := event()
+	for _, h := range list {
+		(*h)(e)
+	}
+}
+
+// event returns the ExecEvent of the run at the current time.
+func (r *run) event() ExecEvent {
+	return ExecEvent{Script: r.script, RunID: r.id, Labels: r.cfg.labels, Time: r.now()}
+}
+
+// fireProgress reports the outcome of a step the host will see.
+func (r *run) fireProgress(p Progress, err error) {
+	switch {
+	case err != nil:
+		fire(hookError, func() ExecEvent {
+			e := r.event()
+			e.Err = err
+			return e
+		})
+	case p.Kind == Complete:
+		fire(hookComplete, func() ExecEvent {
+			e := r.event()
+			e.Kind = Complete
+			return e
+		})
+	default:
+		fire(hookPause, func() ExecEvent {
+			e := r.event()
+			e.Kind, e.CallID, e.Name = p.Kind, p.CallID, p.FunctionName
+			if p.Kind == OsCall || p.Kind == Timer {
+				e.Name = p.OsFunction
+			}
+			return e
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package monty
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	record := func(name string) Hook {
+		return func(e ExecEvent) {
+			if e.Script != "hooks.py" {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			s := name
+			if e.Name != "" {
+				s += " " + e.Name
+			}
+			if e.Err != nil {
+				s += " error"
+			}
+			seen = append(seen, s)
+		}
+	}
+	removes := []func(){
+		OnCompile(record("compile")), OnStart(record("start")), OnPause(record("pause")),
+		OnResume(record("resume")), OnComplete(record("complete")), OnError(record("error")),
+	}
+	defer func() {
+		for _, remove := range removes {
+			remove()
+		}
+	}()
+
+	m, err := New("double(21)", "hooks.py", nil, []string{"double"}, WithSandbox(callBridge("double", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Snapshot.Resume(p.CallID, 42); err != nil {
+		t.Fatal(err)
+	}
+	failing, err := New("fail()", "hooks.py", nil, nil, WithSandbox(raisingBridge("ValueError: bad")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer failing.Close()
+	if _, err := failing.Start(); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+
+	rejecting := scriptedBridge(bridgeScript{anyOp: reply(sandboxResponse{Err: "SyntaxError: invalid syntax at line 1"})})
+	if _, err := New("bad(", "hooks.py", nil, nil, WithSandbox(rejecting)); !errors.As(err, new(*CompileError)) {
+		t.Fatalf("New = %v, want a CompileError", err)
+	}
+
+	want := "compile, start, pause double, resume double, complete, compile, start, error error, compile error"
+	if got := strings.Join(seen, ", "); got != want {
+		t.Errorf("hooks saw %s, want %s", got, want)
+	}
+
+	seen = nil
+	removes[0]()
+	removes[0]()
+	var runID string
+	remove := OnStart(func(e ExecEvent) { runID = e.RunID })
+	defer remove()
+	p, err = m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Snapshot.Close()
+	if runID == "" || runID != p.RunID || fmt.Sprint(seen) != "[start pause double]" {
+		t.Errorf("run %q (want %q) saw %v after removing OnCompile", runID, p.RunID, seen)
+	}
+}
@@ -0,0 +1,226 @@
+package monty
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// OsHTTPRequest is the OS function name for outbound network requests.
+const OsHTTPRequest = "http.request"
+
+// DefaultMaxResponseBytes caps response bodies when HTTPPolicy leaves it unset.
+const DefaultMaxResponseBytes = 10 << 20
+
+// HTTPPolicy restricts which URLs scripts may reach and how large responses may be.
+//
+// Allow and Deny entries are URLs such as "https://api.example.com/v1". A
+// request matches an entry when its scheme, host, and port are the entry's
+// and its path, unescaped and cleaned of "." and ".." segments and repeated
+// slashes, is the entry's path or lies below it. Deny entries match paths
+// regardless of case. URLs with user information are never allowed, and
+// redirects are checked like the requests themselves.
+type HTTPPolicy struct {
+	// Allow lists URLs scripts may request. An empty list allows nothing.
+	Allow []string
+	// Deny lists URLs that are rejected even when allowed.
+	Deny []string
+	// Timeout bounds each request. Zero leaves the client's timeout in charge.
+	Timeout time.Duration
+	// MaxResponseBytes caps the response body; zero means DefaultMaxResponseBytes.
+	MaxResponseBytes int64
+}
+
+// WithHTTPClient routes network OS calls through client, subject to policy.
+// Without it every network call raises inside the script.
+func WithHTTPClient(client *http.Client, policy HTTPPolicy) Option {
+	return func(c *config) {
+		if client == nil {
+			client = http.DefaultClient
+		}
+		checked := *client
+		checked.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if !policy.allowsURL(req.URL) {
+				return fmt.Errorf("redirect to %s is not allowed", req.URL)
+			}
+			if client.CheckRedirect != nil {
+				return client.CheckRedirect(req, via)
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		}
+		c.http = &httpTransport{client: &checked, policy: policy}
+	}
+}
+
+type httpTransport struct {
+	client *http.Client
+	policy HTTPPolicy
+}
+
+func (p HTTPPolicy) allows(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && p.allowsURL(u)
+}
+
+func (p HTTPPolicy) allowsURL(u *url.URL) bool {
+	if u.User != nil || u.Host == "" || u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	for _, rule := range p.Deny {
+		if matchesURL(rule, u, true) {
+			return false
+		}
+	}
+	for _, rule := range p.Allow {
+		if matchesURL(rule, u, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesURL reports whether u is the URL rule or lies below it.
+func matchesURL(rule string, u *url.URL, foldCase bool) bool {
+	r, err := url.Parse(rule)
+	if err != nil || r.Host == "" || !strings.EqualFold(r.Scheme, u.Scheme) ||
+		!strings.EqualFold(r.Hostname(), u.Hostname()) || urlPort(r) != urlPort(u) {
+		return false
+	}
+	prefix, target := path.Clean("/"+r.Path), path.Clean("/"+u.Path)
+	if foldCase {
+		prefix, target = strings.ToLower(prefix), strings.ToLower(target)
+	}
+	return prefix == "/" || target == prefix || strings.HasPrefix(target, prefix+"/")
+}
+
+// urlPort returns the port of u, defaulting by scheme.
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if strings.EqualFold(u.Scheme, "http") {
+		return "80"
+	}
+	return "443"
+}
+
+// httpCall answers http.request(method, url, headers=None, body=None).
+func (r *run) httpCall(p Progress) osReply {
+	t := r.cfg.http
+	if t == nil {
+		return osReply{errMsg: "network access is disabled"}
+	}
+	var method, rawURL string
+	if len(p.Args) < 2 {
+		return osReply{errMsg: "http.request() requires method and url"}
+	}
+	if err := p.Args[0].Unmarshal(&method); err != nil {
+		return osReply{errMsg: fmt.Sprintf("http.request() method: %v", err)}
+	}
+	if err := p.Args[1].Unmarshal(&rawURL); err != nil {
+		return osReply{errMsg: fmt.Sprintf("http.request() url: %v", err)}
+	}
+	if !t.policy.allows(rawURL) {
+		return osReply{errMsg: fmt.Sprintf("network access to %s is not allowed", rawURL)}
+	}
+
+	var headers map[string]string
+	var body string
+	for _, kv := range p.Kwargs {
+		var name string
+		if err := kv.Key.Unmarshal(&name); err != nil {
+			return osReply{errMsg: fmt.Sprintf("http.request() keyword: %v", err)}
+		}
+		switch name {
+		case "headers":
+			h, err := decodeStringDict(kv.Value)
+			if err != nil {
+				return osReply{errMsg: fmt.Sprintf("http.request() headers: %v", err)}
+			}
+			headers = h
+		case "body":
+			if err := kv.Value.Unmarshal(&body); err != nil {
+				return osReply{errMsg: fmt.Sprintf("http.request() body: %v", err)}
+			}
+		default:
+			return osReply{errMsg: fmt.Sprintf("http.request() got an unexpected keyword argument '%s'", name)}
+		}
+	}
+
+	ctx := r.ctx
+	if t.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.policy.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), rawURL, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return osReply{errMsg: err.Error()}
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return osReply{errMsg: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	limit := t.policy.MaxResponseBytes
+	if limit <= 0 {
+		limit = DefaultMaxResponseBytes
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return osReply{errMsg: err.Error()}
+	}
+	if int64(len(data)) > limit {
+		return osReply{errMsg: fmt.Sprintf("response from %s exceeds %d bytes", rawURL, limit)}
+	}
+	respHeaders := make(map[string]string, len(resp.Header))
+	for k := range resp.Header {
+		respHeaders[k] = resp.Header.Get(k)
+	}
+	return osReply{value: map[string]any{
+		"status":  resp.StatusCode,
+		"headers": respHeaders,
+		"body":    map[string]any{"$bytes": bytesToInts(data)},
+	}}
+}
+
+// decodeStringDict decodes a Python dict of str to str.
+func decodeStringDict(obj Object) (map[string]string, error) {
+	var tagged struct {
+		Dict [][2]string `json:"$dict"`
+	}
+	if err := obj.Unmarshal(&tagged); err == nil && tagged.Dict != nil {
+		out := make(map[string]string, len(tagged.Dict))
+		for _, pair := range tagged.Dict {
+			out[pair[0]] = pair[1]
+		}
+		return out, nil
+	}
+	var plain map[string]string
+	if err := obj.Unmarshal(&plain); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+func bytesToInts(data []byte) []int {
+	out := make([]int, len(data))
+	for i, b := range data {
+		out[i] = int(b)
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package monty
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPCallPolicy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 32)))
+	}))
+	defer srv.Close()
+
+	call := func(r *run, url string) osReply {
+		return r.httpCall(Progress{OsFunction: OsHTTPRequest, Args: []Object{Object(`"GET"`), Object(`"` + url + `"`)}})
+	}
+
+	if reply := call(newConfig(nil).newRun(), srv.URL); reply.errMsg == "" {
+		t.Fatalf("expected network access to be disabled by default")
+	}
+
+	r := newConfig([]Option{WithHTTPClient(srv.Client(), HTTPPolicy{
+		Allow: []string{srv.URL + "/api"},
+		Deny:  []string{srv.URL + "/api/admin"},
+	})}).newRun()
+	if reply := call(r, srv.URL+"/api/items"); reply.errMsg != "" {
+		t.Fatalf("allowed request failed: %s", reply.errMsg)
+	} else if status := reply.value.(map[string]any)["status"]; status != 200 {
+		t.Fatalf("unexpected status %v", status)
+	}
+	if reply := call(r, srv.URL+"/api/admin"); reply.errMsg == "" {
+		t.Fatalf("denied prefix was reachable")
+	}
+	if reply := call(r, srv.URL+"/other"); reply.errMsg == "" {
+		t.Fatalf("unlisted URL was reachable")
+	}
+
+	small := newConfig([]Option{WithHTTPClient(srv.Client(), HTTPPolicy{Allow: []string{srv.URL}, MaxResponseBytes: 8})}).newRun()
+	if reply := call(small, srv.URL); !strings.Contains(reply.errMsg, "exceeds") {
+		t.Fatalf("expected size limit error, got %q", reply.errMsg)
+	}
+}
+
+func TestHTTPPolicyMatchesParsedURLs(t *testing.T) {
+	p := HTTPPolicy{
+		Allow: []string{"https://api.example.com/v1"},
+		Deny:  []string{"https://api.example.com/v1/admin"},
+	}
+	for url, allowed := range map[string]bool{
+		"https://api.example.com/v1":                  true,
+		"https://API.example.com/v1/items?q=1":        true,
+		"https://api.example.com:443/v1/items":        true,
+		"https://api.example.com/v10":                 false,
+		"https://api.example.com.evil.com/v1":         false,
+		"https://api.example.com@evil.com/v1":         false,
+		"https://user@api.example.com/v1":             false,
+		"http://api.example.com/v1":                   false,
+		"https://api.example.com:8443/v1":             false,
+		"https://api.example.com/v1/admin":            false,
+		"https://api.example.com/v1//admin":           false,
+		"https://api.example.com/v1/./admin/users":    false,
+		"https://api.example.com/v1/x/../admin":       false,
+		"https://api.example.com/v1/%61dmin":          false,
+		"https://api.example.com/v1/ADMIN":            false,
+		"https://api.example.com/v1/../internal":      false,
+		"ftp://api.example.com/v1":                    false,
+		"https://api.example.com/v1/items/%2e%2e/../": false,
+	} {
+		if got := p.allows(url); got != allowed {
+			t.Errorf("allows(%s) = %v, want %v", url, got, allowed)
+		}
+	}
+}
+
+func TestHTTPPolicyChecksRedirects(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/hop":
+			http.Redirect(w, req, "/api/done", http.StatusFound)
+		case "/api/escape":
+			http.Redirect(w, req, "/private", http.StatusFound)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+	r := newConfig([]Option{WithHTTPClient(srv.Client(), HTTPPolicy{Allow: []string{srv.URL + "/api"}})}).newRun()
+	call := func(path string) osReply {
+		return r.httpCall(Progress{OsFunction: OsHTTPRequest, Args: []Object{Object(`"GET"`), Object(`"` + srv.URL + path + `"`)}})
+	}
+	if reply := call("/api/hop"); reply.errMsg != "" {
+		t.Fatalf("redirect within the policy failed: %s", reply.errMsg)
+	}
+	if reply := call("/api/escape"); !strings.Contains(reply.errMsg, "not allowed") {
+		t.Fatalf("redirect out of the policy = %+v", reply)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.ctx = ctx
+	if reply := call("/api/done"); !strings.Contains(reply.errMsg, "context canceled") {
+		t.Fatalf("request of a canceled run = %+v", reply)
+	}
+}
@@ -0,0 +1,25 @@
+package monty
+
+import "unique"
+
+// intern returns the canonical copy of s. Script and function names repeat
+// across the programs and runs of a process, so interning them keeps one
+// copy of each alive however many programs and progress events hold it.
+func intern(s string) string {
+	if s == "" {
+		return s
+	}
+	return unique.Make(s).Value()
+}
+
+// internAll returns a copy of names with each name interned.
+func internAll(names []string) []string {
+	if names == nil {
+		return nil
+	}
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = intern(name)
+	}
+	return out
+}
@@ -0,0 +1,29 @@
+package monty
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestInternNames(t *testing.T) {
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(callBridge("double", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	var names []string
+	for i := 0; i < 2; i++ {
+		p, err := m.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, p.FunctionName)
+		p.Snapshot.Close()
+	}
+	names = append(names, m.src.extFuncs[0])
+	for _, name := range names[1:] {
+		if name != "double" || unsafe.StringData(name) != unsafe.StringData(names[0]) {
+			t.Errorf("names %q do not share one copy", names)
+		}
+	}
+}
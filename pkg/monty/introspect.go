@@ -0,0 +1,450 @@
+package monty
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrNoSource is returned by introspection methods of programs restored with
+// NewFromBytes, which do not carry their source.
+var ErrNoSource = errors.New("monty: program source unavailable")
+
+// ScriptDoc describes the documented surface of a script.
+type ScriptDoc struct {
+	// Doc is the module docstring.
+	Doc string
+	// Inputs lists the program's inputs in declaration order, with the
+	// annotation of a top-level `name: type` statement when the script has one.
+	Inputs []InputDoc
+	// Functions lists the top-level functions the script defines.
+	Functions []FunctionDoc
+}
+
+// InputDoc describes one program input.
+type InputDoc struct {
+	Name       string
+	Annotation string
+}
+
+// FunctionDoc describes a function definition.
+type FunctionDoc struct {
+	Name   string
+	Params []ParamDoc
+	// Returns is the return annotation, if any.
+	Returns string
+	Doc     string
+	Async   bool
+	// Decorators holds the source of each decorator, without the @.
+	Decorators []string
+	// Line is the 1-based line of the def statement.
+	Line int
+}
+
+// ClassDoc describes a class definition.
+type ClassDoc struct {
+	Name string
+	// Bases holds the source of each base class and keyword, e.g. "metaclass=M".
+	Bases      []string
+	Doc        string
+	Decorators []string
+	// Methods lists the functions defined directly in the class body.
+	Methods []FunctionDoc
+	Line    int
+}
+
+// Signature renders f as a def header without the keyword, e.g.
+// "greet(who: str, times: int = 1) -> str".
+func (f FunctionDoc) Signature() string {
+	var b strings.Builder
+	b.WriteString(f.Name)
+	b.WriteByte('(')
+	for i, p := range f.Params {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(p.Name)
+		if p.Annotation != "" {
+			b.WriteString(": " + p.Annotation)
+		}
+		if p.Default != "" {
+			if p.Annotation != "" {
+				b.WriteString(" = " + p.Default)
+			} else {
+				b.WriteString("=" + p.Default)
+			}
+		}
+	}
+	b.WriteByte(')')
+	if f.Returns != "" {
+		b.WriteString(" -> " + f.Returns)
+	}
+	return b.String()
+}
+
+// ParamDoc describes one parameter. Annotation and Default hold source text.
+// Name keeps a leading * or ** for variadic parameters.
+type ParamDoc struct {
+	Name       string
+	Annotation string
+	Default    string
+}
+
+// Docs returns the annotations and docstrings of the script's inputs and
+// functions, for generating forms and documentation. Expressions are
+// reported as source text; nothing is evaluated.
+func (m *Monty) Docs() (ScriptDoc, error) {
+	if m.IsClosed() {
+		return ScriptDoc{}, newError(ErrClosed, "monty: nil handle")
+	}
+	if m.src == nil {
+		return ScriptDoc{}, ErrNoSource
+	}
+	lines := logicalLines(m.src.code)
+	doc := ScriptDoc{}
+	if len(lines) > 0 && lines[0].indent == 0 {
+		if s, ok := stringLiteral(lines[0].text); ok {
+			doc.Doc = cleanDoc(s)
+		}
+	}
+	doc.Functions, _ = definitions(lines)
+	annotations := make(map[string]string)
+	for _, l := range lines {
+		if l.indent != 0 {
+			continue
+		}
+		if name, ann, ok := parseAnnotation(l.text); ok {
+			if _, seen := annotations[name]; !seen {
+				annotations[name] = ann
+			}
+		}
+	}
+	for _, name := range m.src.inputNames {
+		doc.Inputs = append(doc.Inputs, InputDoc{Name: name, Annotation: annotations[name]})
+	}
+	return doc, nil
+}
+
+// Functions lists the functions the script defines at top level, so hosts can
+// check that required hook functions exist before accepting a script.
+func (m *Monty) Functions() ([]FunctionDoc, error) {
+	if m.IsClosed() {
+		return nil, newError(ErrClosed, "monty: nil handle")
+	}
+	if m.src == nil {
+		return nil, ErrNoSource
+	}
+	funcs, _ := definitions(logicalLines(m.src.code))
+	return funcs, nil
+}
+
+// Classes lists the classes the script defines at top level.
+func (m *Monty) Classes() ([]ClassDoc, error) {
+	if m.IsClosed() {
+		return nil, newError(ErrClosed, "monty: nil handle")
+	}
+	if m.src == nil {
+		return nil, ErrNoSource
+	}
+	_, classes := definitions(logicalLines(m.src.code))
+	return classes, nil
+}
+
+// ParseStubs lists the functions declared at top level in Python stub
+// source, such as a .pyi file of `def fetch(url: str) -> dict: ...` lines
+// describing a host's external functions. The source is not compiled.
+func ParseStubs(src string) []FunctionDoc {
+	funcs, _ := definitions(logicalLines(src))
+	return funcs
+}
+
+// definitions collects the top-level functions and classes in lines.
+func definitions(lines []logicalLine) ([]FunctionDoc, []ClassDoc) {
+	var (
+		funcs   []FunctionDoc
+		classes []ClassDoc
+	)
+	for i, l := range lines {
+		if l.indent != 0 {
+			continue
+		}
+		if fn, ok := parseDef(lines, i); ok {
+			funcs = append(funcs, fn)
+		} else if c, ok := parseClass(lines, i); ok {
+			classes = append(classes, c)
+		}
+	}
+	return funcs, classes
+}
+
+// parseClass parses lines[i] if it is a class statement, with its docstring
+// and methods.
+func parseClass(lines []logicalLine, i int) (ClassDoc, bool) {
+	text, ok := cutKeyword(lines[i].text, "class")
+	if !ok {
+		return ClassDoc{}, false
+	}
+	colon := indexTopLevel(text, ":")
+	if colon < 0 {
+		return ClassDoc{}, false
+	}
+	c := ClassDoc{Line: lines[i].line, Decorators: decorators(lines, i)}
+	head := strings.TrimSpace(text[:colon])
+	if open := strings.IndexByte(head, '('); open >= 0 {
+		end := matchingParen(head, open)
+		if end < 0 {
+			return ClassDoc{}, false
+		}
+		c.Bases = splitTopLevel(head[open+1 : end])
+		head = head[:open]
+	}
+	c.Name = strings.TrimSpace(head)
+	if !isIdentifier(c.Name) {
+		return ClassDoc{}, false
+	}
+	c.Doc = bodyDoc(lines, i, text[colon+1:])
+	indent := lines[i].indent
+	if i+1 >= len(lines) || lines[i+1].indent <= indent {
+		return c, true
+	}
+	body := lines[i+1].indent
+	for j := i + 1; j < len(lines) && lines[j].indent > indent; j++ {
+		if lines[j].indent != body {
+			continue
+		}
+		if fn, ok := parseDef(lines, j); ok {
+			c.Methods = append(c.Methods, fn)
+		}
+	}
+	return c, true
+}
+
+// decorators returns the decorators directly above lines[i].
+func decorators(lines []logicalLine, i int) []string {
+	var decs []string
+	for j := i - 1; j >= 0 && lines[j].indent == lines[i].indent && strings.HasPrefix(lines[j].text, "@"); j-- {
+		decs = append([]string{strings.TrimSpace(lines[j].text[1:])}, decs...)
+	}
+	return decs
+}
+
+// bodyDoc returns the docstring opening the block headed by lines[i], whose
+// header ends with rest after the colon.
+func bodyDoc(lines []logicalLine, i int, rest string) string {
+	first := strings.TrimSpace(rest)
+	if first == "" {
+		if i+1 >= len(lines) || lines[i+1].indent <= lines[i].indent {
+			return ""
+		}
+		first = lines[i+1].text
+	}
+	if s, ok := stringLiteral(first); ok {
+		return cleanDoc(s)
+	}
+	return ""
+}
+
+// parseDef parses lines[i] if it is a def statement, with the docstring that
+// opens its body.
+func parseDef(lines []logicalLine, i int) (FunctionDoc, bool) {
+	text := lines[i].text
+	fn := FunctionDoc{Line: lines[i].line, Decorators: decorators(lines, i)}
+	if rest, ok := cutKeyword(text, "async"); ok {
+		text, fn.Async = rest, true
+	}
+	text, ok := cutKeyword(text, "def")
+	if !ok {
+		return FunctionDoc{}, false
+	}
+	open := strings.IndexByte(text, '(')
+	if open < 0 {
+		return FunctionDoc{}, false
+	}
+	fn.Name = strings.TrimSpace(text[:open])
+	end := matchingParen(text, open)
+	if fn.Name == "" || end < 0 {
+		return FunctionDoc{}, false
+	}
+	fn.Params = parseParams(text[open+1 : end])
+	header := text[end+1:]
+	colon := indexTopLevel(header, ":")
+	if colon < 0 {
+		return FunctionDoc{}, false
+	}
+	if ret, ok := strings.CutPrefix(strings.TrimSpace(header[:colon]), "->"); ok {
+		fn.Returns = strings.TrimSpace(ret)
+	}
+	fn.Doc = bodyDoc(lines, i, header[colon+1:])
+	return fn, true
+}
+
+// parseParams splits a parameter list, skipping the / and * markers.
+func parseParams(list string) []ParamDoc {
+	var params []ParamDoc
+	for _, part := range splitTopLevel(list) {
+		if part == "/" || part == "*" {
+			continue
+		}
+		var p ParamDoc
+		if eq := indexTopLevel(part, "="); eq >= 0 {
+			p.Default = strings.TrimSpace(part[eq+1:])
+			part = strings.TrimSpace(part[:eq])
+		}
+		if colon := indexTopLevel(part, ":"); colon >= 0 {
+			p.Annotation = strings.TrimSpace(part[colon+1:])
+			part = strings.TrimSpace(part[:colon])
+		}
+		p.Name = part
+		params = append(params, p)
+	}
+	return params
+}
+
+// parseAnnotation parses an annotated assignment `name: type [= value]`.
+func parseAnnotation(text string) (name, ann string, ok bool) {
+	colon := indexTopLevel(text, ":")
+	if colon <= 0 {
+		return "", "", false
+	}
+	name = strings.TrimSpace(text[:colon])
+	if !isIdentifier(name) {
+		return "", "", false
+	}
+	ann = text[colon+1:]
+	if eq := indexTopLevel(ann, "="); eq >= 0 {
+		ann = ann[:eq]
+	}
+	return name, strings.TrimSpace(ann), true
+}
+
+// cutKeyword removes a leading keyword followed by whitespace.
+func cutKeyword(text, kw string) (string, bool) {
+	rest, ok := strings.CutPrefix(text, kw)
+	if !ok || rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+		return text, false
+	}
+	return strings.TrimLeft(rest, " \t"), true
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7f || i > 0 && r >= '0' && r <= '9' {
+			continue
+		}
+		return false
+	}
+	switch s {
+	case "if", "elif", "else", "while", "for", "try", "except", "finally", "with", "class", "def", "lambda", "match", "case":
+		return false
+	}
+	return true
+}
+
+// References reports which declared capabilities a program's code refers to.
+type References struct {
+	// Functions lists the declared external functions the code refers to.
+	Functions []string
+	// OsFunctions lists OS functions the code may call, named as they are in
+	// OsCall progress events and Policy, e.g. "time.sleep" or "Path.read_text".
+	OsFunctions []string
+	// Inputs lists the inputs the code reads; UnusedInputs the others.
+	Inputs       []string
+	UnusedInputs []string
+}
+
+// osModules are the modules whose functions reach the host as OS calls.
+var osModules = map[string]bool{"os": true, "time": true, "random": true, "asyncio": true, "pathlib": true}
+
+// pathMethods are the pathlib.Path methods that reach the host as OS calls.
+var pathMethods = map[string]bool{
+	"exists": true, "is_file": true, "is_dir": true, "is_symlink": true, "stat": true,
+	"read_text": true, "read_bytes": true, "write_text": true, "write_bytes": true,
+	"iterdir": true, "mkdir": true, "rmdir": true, "unlink": true, "rename": true, "resolve": true, "absolute": true,
+}
+
+// References statically determines which declared external functions and
+// inputs, and which OS functions, the code refers to, so hosts can provision
+// only what a script needs and warn about unused inputs. The analysis looks
+// at names, not control flow: a referenced function may never be called,
+// and OS access through dynamic means such as getattr is not seen.
+func (m *Monty) References() (References, error) {
+	if m.IsClosed() {
+		return References{}, newError(ErrClosed, "monty: nil handle")
+	}
+	if m.src == nil {
+		return References{}, ErrNoSource
+	}
+	lines := logicalLines(m.src.code)
+	bound := imports(lines)
+	var body []string
+	for _, l := range lines {
+		if _, ok := cutKeyword(l.text, "import"); ok {
+			continue
+		}
+		if _, ok := cutKeyword(l.text, "from"); ok {
+			continue
+		}
+		body = append(body, l.text)
+	}
+
+	names := make(map[string]bool)
+	osFuncs := make(map[string]bool)
+	usesPath := false
+	var methods []string
+	for _, ref := range nameRefs(strings.Join(body, "\n")) {
+		if ref.attr {
+			if ref.call {
+				methods = append(methods, ref.parts[len(ref.parts)-1])
+			}
+			continue
+		}
+		names[ref.parts[0]] = true
+		qualified, ok := bound[ref.parts[0]]
+		if !ok {
+			continue
+		}
+		parts := append(strings.Split(qualified, "."), ref.parts[1:]...)
+		if !osModules[parts[0]] {
+			continue
+		}
+		if parts[0] == "pathlib" {
+			usesPath = true
+			if len(parts) > 2 && ref.call {
+				methods = append(methods, parts[len(parts)-1])
+			}
+			continue
+		}
+		if len(parts) >= 2 {
+			osFuncs[parts[0]+"."+parts[1]] = true
+		}
+	}
+	if usesPath {
+		for _, name := range methods {
+			if pathMethods[name] {
+				osFuncs["Path."+name] = true
+			}
+		}
+	}
+
+	var refs References
+	for _, name := range m.src.extFuncs {
+		if names[name] {
+			refs.Functions = append(refs.Functions, name)
+		}
+	}
+	for _, name := range m.src.inputNames {
+		if names[name] {
+			refs.Inputs = append(refs.Inputs, name)
+		} else {
+			refs.UnusedInputs = append(refs.UnusedInputs, name)
+		}
+	}
+	for name := range osFuncs {
+		refs.OsFunctions = append(refs.OsFunctions, name)
+	}
+	sort.Strings(refs.OsFunctions)
+	return refs, nil
+}
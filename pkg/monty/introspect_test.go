@@ -0,0 +1,167 @@
+package monty
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const docsScript = `"""Send a greeting.
+
+    Used by the onboarding flow.
+"""
+name: str
+count: int = 1  # how many times
+
+def greet(who: str, times: int = 1, *rest, sep: str = ", ", **kw) -> list[str]:
+    """Greet someone.
+
+    Returns one line per greeting.
+    """
+    return [f"hi {who}"] * times
+
+async def fetch(url: "str"): 'Fetch a page.'
+
+def helper(x): return x  # no docstring
+
+if name:
+    inner: bool = True
+greet(name, count)
+`
+
+func TestDocs(t *testing.T) {
+	m, err := New(docsScript, "docs.py", []string{"name", "count", "extra"}, nil, WithSandbox(handleBridge()))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+	doc, err := m.Docs()
+	if err != nil {
+		t.Fatalf("Docs failed: %v", err)
+	}
+	if doc.Doc != "Send a greeting.\n\nUsed by the onboarding flow." {
+		t.Errorf("unexpected module doc %q", doc.Doc)
+	}
+	wantInputs := []InputDoc{{"name", "str"}, {"count", "int"}, {"extra", ""}}
+	if !reflect.DeepEqual(doc.Inputs, wantInputs) {
+		t.Errorf("unexpected inputs %+v", doc.Inputs)
+	}
+	want := []FunctionDoc{
+		{
+			Name: "greet",
+			Params: []ParamDoc{
+				{Name: "who", Annotation: "str"},
+				{Name: "times", Annotation: "int", Default: "1"},
+				{Name: "*rest"},
+				{Name: "sep", Annotation: "str", Default: `", "`},
+				{Name: "**kw"},
+			},
+			Returns: "list[str]",
+			Doc:     "Greet someone.\n\nReturns one line per greeting.",
+			Line:    8,
+		},
+		{Name: "fetch", Params: []ParamDoc{{Name: "url", Annotation: `"str"`}}, Doc: "Fetch a page.", Async: true, Line: 15},
+		{Name: "helper", Params: []ParamDoc{{Name: "x"}}, Line: 17},
+	}
+	if !reflect.DeepEqual(doc.Functions, want) {
+		t.Errorf("unexpected functions:\n got %+v\nwant %+v", doc.Functions, want)
+	}
+}
+
+func TestDocsWithoutSource(t *testing.T) {
+	m, err := NewFromBytes([]byte{1}, WithSandbox(handleBridge()))
+	if err != nil {
+		t.Fatalf("NewFromBytes failed: %v", err)
+	}
+	defer m.Close()
+	if _, err := m.Docs(); !errors.Is(err, ErrNoSource) {
+		t.Fatalf("expected ErrNoSource, got %v", err)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	code := `import time as t
+from os import getenv, environ
+from pathlib import Path
+import random
+
+# fetch(unused) in a comment
+label = "store(x) in a string"
+key = getenv("KEY") or environ["FALLBACK"]
+t.sleep(1)
+text = Path(base).read_text()
+n = random.randint(1, 6)
+result = fetch(url=f"{base}/items", retries=retries)
+`
+	m, err := New(code, "refs.py", []string{"base", "retries", "unused"}, []string{"fetch", "store"}, WithSandbox(handleBridge()))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+	refs, err := m.References()
+	if err != nil {
+		t.Fatalf("References failed: %v", err)
+	}
+	want := References{
+		Functions:    []string{"fetch"},
+		OsFunctions:  []string{"Path.read_text", "os.environ", "os.getenv", "random.randint", "time.sleep"},
+		Inputs:       []string{"base", "retries"},
+		UnusedInputs: []string{"unused"},
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Fatalf("unexpected references:\n got %+v\nwant %+v", refs, want)
+	}
+}
+
+func TestFunctionsAndClasses(t *testing.T) {
+	code := `@dataclass(frozen=True)
+class Order(Base, metaclass=Meta):
+    """An order."""
+    id: int
+
+    def total(self, tax: float = 0.1) -> float:
+        def inner(): pass
+        return 0
+
+    @staticmethod
+    async def load(id): ...
+
+class Empty: pass
+
+@hook
+def on_event(event: dict, *, dry_run=False) -> None:
+    pass
+`
+	m, err := New(code, "defs.py", nil, nil, WithSandbox(handleBridge()))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+	funcs, err := m.Functions()
+	if err != nil {
+		t.Fatalf("Functions failed: %v", err)
+	}
+	if len(funcs) != 1 || funcs[0].Signature() != "on_event(event: dict, dry_run=False) -> None" || !reflect.DeepEqual(funcs[0].Decorators, []string{"hook"}) {
+		t.Fatalf("unexpected functions: %+v", funcs)
+	}
+	classes, err := m.Classes()
+	if err != nil {
+		t.Fatalf("Classes failed: %v", err)
+	}
+	if len(classes) != 2 {
+		t.Fatalf("expected 2 classes, got %+v", classes)
+	}
+	order := classes[0]
+	if order.Name != "Order" || order.Doc != "An order." || order.Line != 2 ||
+		!reflect.DeepEqual(order.Bases, []string{"Base", "metaclass=Meta"}) ||
+		!reflect.DeepEqual(order.Decorators, []string{"dataclass(frozen=True)"}) {
+		t.Fatalf("unexpected class: %+v", order)
+	}
+	if len(order.Methods) != 2 || order.Methods[0].Signature() != "total(self, tax: float = 0.1) -> float" ||
+		order.Methods[1].Name != "load" || !order.Methods[1].Async || !reflect.DeepEqual(order.Methods[1].Decorators, []string{"staticmethod"}) {
+		t.Fatalf("unexpected methods: %+v", order.Methods)
+	}
+	if classes[1].Name != "Empty" || classes[1].Bases != nil || classes[1].Methods != nil {
+		t.Fatalf("unexpected class: %+v", classes[1])
+	}
+}
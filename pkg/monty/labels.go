@@ -0,0 +1,71 @@
+package monty
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sort"
+)
+
+// WithLabels attaches labels, such as a tenant or workflow name, to every
+// run, next to the RunID each run is given. Both are reported in
+// Progress.RunID and Progress.Labels, in the start event of traces, in
+// Runner logs, in profiler labels under WithProfileLabels, and in the
+// SuspendedError of runs persisted by Shutdown, so every subsystem can
+// correlate the same run. Labels set by later options are merged in.
+func WithLabels(labels map[string]string) Option {
+	return func(c *config) {
+		merged := make(map[string]string, len(c.labels)+len(labels))
+		for k, v := range c.labels {
+			merged[k] = v
+		}
+		for k, v := range labels {
+			merged[k] = v
+		}
+		c.labels = merged
+	}
+}
+
+// WithRunID sets the RunID of the runs started or restored with the option,
+// in place of a generated one. Pass it to SnapshotFromBytes or
+// FutureSnapshotFromBytes to keep the ID of a run resumed from a dump.
+func WithRunID(id string) Option {
+	return func(c *config) {
+		c.runID = id
+	}
+}
+
+// newRunID returns a random run ID.
+func newRunID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// progressAttrs returns the run ID and labels of p for log records.
+func progressAttrs(p Progress) []slog.Attr {
+	attrs := []slog.Attr{slog.String("run_id", p.RunID)}
+	if len(p.Labels) > 0 {
+		group := make([]any, 0, len(p.Labels))
+		for _, k := range sortedKeys(p.Labels) {
+			group = append(group, slog.String(k, p.Labels[k]))
+		}
+		attrs = append(attrs, slog.Group("labels", group...))
+	}
+	return attrs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// annotate sets the run's state on p as the host will see it.
+func (r *run) annotate(p *Progress) {
+	p.RunID, p.Labels, p.Program = r.id, r.cfg.labels, r.program
+	p.Context, p.Warnings = r.context, r.takeWarnings()
+}
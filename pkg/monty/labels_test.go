@@ -0,0 +1,55 @@
+package monty
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithLabels(t *testing.T) {
+	var trace, logs bytes.Buffer
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(callBridge("double", 1)),
+		WithLabels(map[string]string{"tenant": "acme"}), WithLabels(map[string]string{"flow": "billing"}), WithTrace(&trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.RunID) != 16 || p.Labels["tenant"] != "acme" || p.Labels["flow"] != "billing" {
+		t.Fatalf("Start = %+v", p)
+	}
+	done, err := p.Snapshot.Resume(p.CallID, 42)
+	if err != nil || done.RunID != p.RunID {
+		t.Fatalf("resumed run ID = %q, want %q (%v)", done.RunID, p.RunID, err)
+	}
+	runs, err := ReadTrace(&trace)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("ReadTrace = %v, %v", runs, err)
+	}
+	if start := runs[0].Events[0]; start.RunID != p.RunID || start.Labels["tenant"] != "acme" {
+		t.Fatalf("start event = %+v", start)
+	}
+
+	second, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Snapshot.Close()
+	if second.RunID == p.RunID {
+		t.Fatal("each run should get its own ID")
+	}
+
+	r := NewRunner(m, WithLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))), WithRunID("run-7"))
+	r.Register("double", func(_ context.Context, call CallInfo) (any, error) { return 42, nil })
+	if _, err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if line := logs.String(); !strings.Contains(line, "run_id=run-7") || !strings.Contains(line, "labels.tenant=acme") {
+		t.Fatalf("log = %s", line)
+	}
+}
@@ -0,0 +1,123 @@
+package monty
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// WithoutFinalizer stops the package from attaching finalizers to the handles
+// it creates, so a handle that is never closed stays allocated and shows up in
+// LiveHandles instead of being freed whenever the garbage collector notices.
+func WithoutFinalizer() Option {
+	return func(c *config) {
+		c.noFinalizer = true
+	}
+}
+
+// HandleKind names a type of native handle.
+type HandleKind string
+
+const (
+	MontyHandle          HandleKind = "Monty"
+	SnapshotHandle       HandleKind = "Snapshot"
+	FutureSnapshotHandle HandleKind = "FutureSnapshot"
+)
+
+// HandleStats counts native handles.
+type HandleStats struct {
+	// Live counts handles created and not yet released, by kind.
+	Live map[HandleKind]int
+	// Finalized counts handles released by a finalizer rather than by Close
+	// or resuming; each one is a leak in the calling code.
+	Finalized map[HandleKind]int
+}
+
+// Leak is a live handle recorded while TrackLeaks was enabled.
+type Leak struct {
+	Kind HandleKind
+	// Stack is the goroutine stack that created the handle.
+	Stack string
+}
+
+// TrackLeaks turns recording of creation stacks on or off. Recording costs a
+// stack capture per handle, so enable it in tests and debugging sessions.
+func TrackLeaks(enable bool) {
+	leakRegistry.tracking.Store(enable)
+}
+
+// LiveHandles reports the handles currently held by this process.
+func LiveHandles() HandleStats {
+	r := &leakRegistry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := HandleStats{Live: make(map[HandleKind]int), Finalized: make(map[HandleKind]int)}
+	for k, n := range r.live {
+		stats.Live[k] = n
+	}
+	for k, n := range r.finalized {
+		stats.Finalized[k] = n
+	}
+	return stats
+}
+
+// Leaks returns the live handles created while TrackLeaks was enabled,
+// oldest first.
+func Leaks() []Leak {
+	r := &leakRegistry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]uint64, 0, len(r.stacks))
+	for id := range r.stacks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	leaks := make([]Leak, len(ids))
+	for i, id := range ids {
+		leaks[i] = r.stacks[id]
+	}
+	return leaks
+}
+
+var leakRegistry = registry{
+	live:      make(map[HandleKind]int),
+	finalized: make(map[HandleKind]int),
+	stacks:    make(map[uint64]Leak),
+}
+
+type registry struct {
+	tracking atomic.Bool
+
+	mu        sync.Mutex
+	next      uint64
+	live      map[HandleKind]int
+	finalized map[HandleKind]int
+	stacks    map[uint64]Leak
+}
+
+// track records a new handle and returns its registry id.
+func (r *registry) track(kind HandleKind) uint64 {
+	var stack string
+	if r.tracking.Load() {
+		stack = currentStack()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	r.live[kind]++
+	if stack != "" {
+		r.stacks[r.next] = Leak{Kind: kind, Stack: stack}
+	}
+	return r.next
+}
+
+// untrack records that a handle was released.
+func (r *registry) untrack(kind HandleKind, id uint64, finalized bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.live[kind]--
+	if finalized {
+		r.finalized[kind]++
+	}
+	delete(r.stacks, id)
+}
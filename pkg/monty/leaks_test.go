@@ -0,0 +1,97 @@
+package monty
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// handleBridge is a sandbox bridge that hands out handles and frees them.
+func handleBridge() *Sandbox {
+	var next uint64
+	return scriptedBridge(bridgeScript{anyOp: func(sandboxRequest) sandboxResponse {
+		next++
+		return sandboxResponse{Handle: next}
+	}})
+}
+
+func TestLeakRegistry(t *testing.T) {
+	TrackLeaks(true)
+	defer TrackLeaks(false)
+	before := LiveHandles().Live[MontyHandle]
+
+	m, err := New("x", "leak.py", nil, nil, WithSandbox(handleBridge()), WithoutFinalizer())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if got := LiveHandles().Live[MontyHandle]; got != before+1 {
+		t.Fatalf("expected %d live Monty handles, got %d", before+1, got)
+	}
+	var found bool
+	for _, leak := range Leaks() {
+		if leak.Kind == MontyHandle && strings.Contains(leak.Stack, "TestLeakRegistry") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("creation stack not recorded: %+v", Leaks())
+	}
+	m.Close()
+	if got := LiveHandles().Live[MontyHandle]; got != before {
+		t.Fatalf("expected %d live Monty handles after Close, got %d", before, got)
+	}
+	for _, leak := range Leaks() {
+		if strings.Contains(leak.Stack, "TestLeakRegistry") {
+			t.Fatalf("closed handle still reported as leaked")
+		}
+	}
+}
+
+func TestFinalizedHandlesAreCounted(t *testing.T) {
+	before := LiveHandles().Finalized[MontyHandle]
+	func() {
+		if _, err := New("x", "leak.py", nil, nil, WithSandbox(handleBridge())); err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for LiveHandles().Finalized[MontyHandle] == before {
+		if time.Now().After(deadline) {
+			t.Fatalf("leaked handle was never finalized")
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCloseIsIdempotentAndReportsErrors(t *testing.T) {
+	m, err := New("x", "close.py", nil, nil, WithSandbox(handleBridge()))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if m.IsClosed() {
+		t.Fatalf("new handle reported closed")
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !m.IsClosed() || m.Close() != nil {
+		t.Fatalf("second Close should be a no-op on a closed handle")
+	}
+
+	failing := scriptedBridge(bridgeScript{
+		"free_run": reply(sandboxResponse{Err: "free failed"}),
+		anyOp:      reply(sandboxResponse{Handle: 1}),
+	})
+	m, err = New("x", "close.py", nil, nil, WithSandbox(failing))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := m.Close(); err == nil || err.Error() != "free failed" {
+		t.Fatalf("expected the free error, got %v", err)
+	}
+	if !m.IsClosed() {
+		t.Fatalf("handle should be released even when freeing fails")
+	}
+}
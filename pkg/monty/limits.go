@@ -0,0 +1,225 @@
+package monty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Limits caps how much data crosses the JSON bridge during a run, so a script
+// cannot exhaust host memory by producing huge values, and how much code a
+// program may have. Zero disables a limit.
+type Limits struct {
+	// MaxArgBytes caps the serialized args and kwargs of a single call.
+	MaxArgBytes int64
+	// MaxResultBytes caps the final result and every value resumed into the script.
+	MaxResultBytes int64
+	// MaxTotalBytes caps everything transferred in either direction over one run.
+	MaxTotalBytes int64
+	// MaxDepth caps how deeply lists, dicts, and other containers nest in
+	// the args, kwargs, and results a script hands the host, so crafted
+	// values cannot exhaust the stack of the code that decodes them. Each
+	// container is one level, as in Python, whatever its JSON encoding.
+	MaxDepth int
+	// MaxSnapshotBytes caps the dumped size of a paused run, so a script
+	// that accumulates state cannot overwhelm the store it is persisted to.
+	// Runs that pause with larger state, by Snapshot.EstimateSize, fail, and
+	// Dump refuses to return it.
+	MaxSnapshotBytes int64
+	// MaxSourceBytes caps the length of the code New compiles, checked
+	// before the code reaches the library.
+	MaxSourceBytes int64
+}
+
+// LimitError reports a value that exceeded one of the configured Limits.
+type LimitError struct {
+	Limit string
+	Max   int64
+	Size  int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("monty: %s of %d bytes exceeds limit of %d", e.Limit, e.Size, e.Max)
+}
+
+// Unwrap classifies limit violations as ErrMemoryLimit.
+func (e *LimitError) Unwrap() error {
+	return ErrMemoryLimit
+}
+
+// DepthError reports a value nested deeper than Limits.MaxDepth.
+type DepthError struct {
+	// Value is "call arguments" or "result".
+	Value string
+	Max   int
+}
+
+func (e *DepthError) Error() string {
+	return fmt.Sprintf("monty: %s nested deeper than %d levels", e.Value, e.Max)
+}
+
+// Unwrap classifies depth violations as ErrMemoryLimit.
+func (e *DepthError) Unwrap() error {
+	return ErrMemoryLimit
+}
+
+// WithLimits bounds the data transferred through the JSON bridge.
+func WithLimits(l Limits) Option {
+	return func(c *config) {
+		c.limits = l
+	}
+}
+
+// sendInput accounts for inputs passed to Start.
+func (r *run) sendInput(n int64) error {
+	return r.transfer(n)
+}
+
+// sendValue accounts for a value resumed into the script.
+func (r *run) sendValue(n int64) error {
+	if max := r.cfg.limits.MaxResultBytes; max > 0 && n > max {
+		return &LimitError{Limit: "resumed value", Max: max, Size: n}
+	}
+	return r.transfer(n)
+}
+
+// receive accounts for the args and result of a progress event.
+func (r *run) receive(args, result int64) error {
+	if max := r.cfg.limits.MaxArgBytes; max > 0 && args > max {
+		return &LimitError{Limit: "call arguments", Max: max, Size: args}
+	}
+	if max := r.cfg.limits.MaxResultBytes; max > 0 && result > max {
+		return &LimitError{Limit: "result", Max: max, Size: result}
+	}
+	return r.transfer(args + result)
+}
+
+func (r *run) transfer(n int64) error {
+	r.transferred += n
+	r.cfg.quota.charge(Usage{Memory: n})
+	if max := r.cfg.limits.MaxTotalBytes; max > 0 && r.transferred > max {
+		return &LimitError{Limit: "total transfer", Max: max, Size: r.transferred}
+	}
+	return nil
+}
+
+// checkSnapshot reports a LimitError if a dumped snapshot of size bytes
+// exceeds the run's limit.
+func (r *run) checkSnapshot(size int64) error {
+	if max := r.cfg.limits.MaxSnapshotBytes; max > 0 && size > max {
+		return &LimitError{Limit: "snapshot", Max: max, Size: size}
+	}
+	return nil
+}
+
+// checkPause reports a LimitError if the run paused at p holds more state
+// than its limit allows, by its estimated size.
+func (r *run) checkPause(p Progress) error {
+	if r.cfg.limits.MaxSnapshotBytes <= 0 || p.Snapshot == nil && p.FutureSnapshot == nil {
+		return nil
+	}
+	size, err := estimateSize(p)
+	if err != nil {
+		return err
+	}
+	return r.checkSnapshot(size)
+}
+
+// checkDepth reports a DepthError if the JSON v, describing value, nests
+// Python containers deeper than the run allows. v holds one value inside
+// wrappers arrays that are not containers of the script's, such as the list
+// of positional arguments.
+func (r *run) checkDepth(value string, v []byte, wrappers int) error {
+	max := r.cfg.limits.MaxDepth
+	if max <= 0 {
+		return nil
+	}
+	// The tagged encoding spends one to four brackets on each container, so
+	// only values between those bounds need decoding.
+	brackets := bracketDepth(v)
+	if brackets <= max+wrappers {
+		return nil
+	}
+	if brackets > 4*(max+wrappers) {
+		return &DepthError{Value: value, Max: max}
+	}
+	dec := json.NewDecoder(bytes.NewReader(v))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil // reported where the value is decoded
+	}
+	if valueDepth(tree)-wrappers > max {
+		return &DepthError{Value: value, Max: max}
+	}
+	return nil
+}
+
+// bracketDepth returns how deeply arrays and objects nest in the JSON v.
+func bracketDepth(v []byte) int {
+	depth, deepest, inString := 0, 0, false
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '[' || c == '{':
+			depth++
+			deepest = max(deepest, depth)
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return deepest
+}
+
+// valueDepth returns how deeply containers nest in the tagged value v,
+// counting one level per list, dict, tuple, set, dataclass, or named tuple.
+// Other tagged values, such as bytes and big ints, are scalars.
+func valueDepth(v any) int {
+	var children []any
+	switch tag, inner := treeTag(v); tag {
+	case "":
+		switch v := v.(type) {
+		case []any:
+			children = v
+		case map[string]any:
+			for _, child := range v {
+				children = append(children, child)
+			}
+		default:
+			return 0
+		}
+	case "$dict":
+		pairs, _ := inner.([]any)
+		for _, p := range pairs {
+			kv, _ := p.([]any)
+			children = append(children, kv...)
+		}
+	case "$tuple", "$set", "$frozenset":
+		children, _ = inner.([]any)
+	case "$dataclass":
+		m, _ := inner.(map[string]any)
+		attrs, _ := m["attrs"].([]any)
+		for _, p := range attrs {
+			if kv, _ := p.([]any); len(kv) == 2 {
+				children = append(children, kv[1])
+			}
+		}
+	case "$named_tuple":
+		m, _ := inner.(map[string]any)
+		children, _ = m["values"].([]any)
+	default:
+		return 0
+	}
+	depth := 0
+	for _, child := range children {
+		depth = max(depth, valueDepth(child))
+	}
+	return depth + 1
+}
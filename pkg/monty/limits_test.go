@@ -0,0 +1,116 @@
+package monty
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimitsAccounting(t *testing.T) {
+	r := newConfig([]Option{WithLimits(Limits{MaxArgBytes: 10, MaxResultBytes: 20, MaxTotalBytes: 50})}).newRun()
+
+	var limitErr *LimitError
+	if err := r.receive(11, 0); !errors.As(err, &limitErr) || limitErr.Limit != "call arguments" {
+		t.Fatalf("expected argument limit error, got %v", err)
+	}
+	if err := r.sendValue(21); !errors.As(err, &limitErr) || limitErr.Size != 21 {
+		t.Fatalf("expected resumed value limit error, got %v", err)
+	}
+	if err := r.receive(10, 20); err != nil {
+		t.Fatalf("within limits: %v", err)
+	}
+	if err := r.sendInput(25); !errors.As(err, &limitErr) || limitErr.Limit != "total transfer" {
+		t.Fatalf("expected total transfer error, got %v", err)
+	}
+}
+
+func TestLimitsDepth(t *testing.T) {
+	r := newConfig([]Option{WithLimits(Limits{MaxDepth: 3})}).newRun()
+	for v, ok := range map[string]bool{
+		`[[[1]]]`:              true,
+		`[[[[1]]]]`:            false,
+		`{"a":{"b":[{}]}}`:     false,
+		`["[[[[", "\\"]`:       true,
+		`[[["\"[[[["]], [[]]]`: true,
+		// Tags do not count as levels of their own.
+		`{"$dict":[["a",1]]}`:                                         true,
+		`{"$dict":[["a",{"$dict":[["b",{"$tuple":[1]}]]}]]}`:          true,
+		`{"$dict":[["a",{"$dict":[["b",{"$tuple":[[1]]}]]}]]}`:        false,
+		`{"$tuple":[{"$set":[{"$bytes":[1,2]}]}, {"$frozenset":[]}]}`: true,
+		`[{"$dataclass":{"name":"P","attrs":[["x",[[1]]]]}}]`:         false,
+		`{"$named_tuple":{"field_names":["x"],"values":[[1]]}}`:       true,
+	} {
+		err := r.checkDepth("result", []byte(v), 0)
+		var depthErr *DepthError
+		if ok != (err == nil) || !ok && (!errors.As(err, &depthErr) || !errors.Is(err, ErrMemoryLimit)) {
+			t.Errorf("checkDepth(%s) = %v", v, err)
+		}
+	}
+
+	deep := json.RawMessage(strings.Repeat("[", 50) + strings.Repeat("]", 50))
+	sb := scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(callProgress(1, "f", "["+string(deep)+"]", 2)),
+	})
+	m, err := New("f()", "main.py", nil, []string{"f"}, WithSandbox(sb), WithLimits(Limits{MaxDepth: 32}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	var depthErr *DepthError
+	if _, err := m.Start(); !errors.As(err, &depthErr) || depthErr.Value != "call arguments" {
+		t.Errorf("Start = %v, want a DepthError for the arguments", err)
+	}
+}
+
+func TestLimitsSnapshotSize(t *testing.T) {
+	state := make([]byte, 100)
+	sb := scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(callProgress(1, "f", "[]", 2)),
+		"dump_snapshot": func(sandboxRequest) sandboxResponse {
+			return sandboxResponse{Data: state}
+		},
+		"snapshot_size": func(sandboxRequest) sandboxResponse {
+			return sandboxResponse{Size: int64(len(state))}
+		},
+	})
+	start := func(max int64) (Progress, error) {
+		m, err := New("f()", "main.py", nil, []string{"f"}, WithSandbox(sb), WithLimits(Limits{MaxSnapshotBytes: max}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { m.Close() })
+		return m.Start()
+	}
+
+	size := int64(len(dumpHeader(sb, SnapshotHandle, 0))) + 100
+	var limitErr *LimitError
+	if _, err := start(size - 1); !errors.As(err, &limitErr) || limitErr.Limit != "snapshot" || limitErr.Size != size {
+		t.Fatalf("pausing over the limit: err = %v", err)
+	}
+	p, err := start(size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeProgress(p)
+	state = make([]byte, 101)
+	if _, err := p.Snapshot.Dump(); !errors.As(err, &limitErr) || !errors.Is(err, ErrMemoryLimit) || limitErr.Size != size+1 {
+		t.Errorf("dumping over the limit: err = %v", err)
+	}
+}
+
+func TestLimitsSource(t *testing.T) {
+	limits := WithLimits(Limits{MaxSourceBytes: 8})
+	_, err := New("x = 1 + 2", "main.py", nil, nil, WithSandbox(callBridge("f", 1)), limits)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "source" || limitErr.Size != 9 || !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("New with oversized source: err = %v", err)
+	}
+	m, err := New("x = 1", "main.py", nil, nil, WithSandbox(callBridge("f", 1)), limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+}
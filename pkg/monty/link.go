@@ -0,0 +1,406 @@
+package monty
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Module is a named script of a linked program, imported by other modules
+// under Name.
+type Module struct {
+	Name string
+	Code string
+}
+
+// NewLinked compiles the module named entry, together with the modules it
+// imports directly or through other modules, into one program, so a
+// tenant's library scripts and entry scripts run together without host-side
+// stitching. Modules import each other with `import lib`, `import lib as l`,
+// `from lib import name`, or `from lib import *`; imports of other modules
+// are left to the interpreter.
+//
+// Each library runs once, in a function of its own, before the entry script,
+// so its top-level names do not clash with those of other modules; `global`
+// statements in its functions refer to its own top-level names. A library
+// exports the names bound by its top-level def, class, and assignment
+// statements, and `lib.name` must be written as such, since the module
+// itself is not a value: other uses of an import alias, such as a parameter
+// or variable named after it, fail with ErrInvalidInput. As with Python's from-imports, importers see the
+// values the exports have once the library has run. Like the package's
+// other source analysis, linking reads the text rather than the syntax tree.
+//
+// Compile errors are reported against the module and line they occur on.
+// Tracebacks at run time refer to the linked source, which the program's
+// introspection methods also read. Import cycles fail with ErrInvalidInput.
+func NewLinked(modules []Module, entry string, inputNames, extFuncs []string, opts ...Option) (*Monty, error) {
+	code, origins, err := link(modules, entry)
+	if err != nil {
+		return nil, err
+	}
+	m, err := New(code, entry, inputNames, extFuncs, opts...)
+	var ce *CompileError
+	if errors.As(err, &ce) && ce.Line > 0 && ce.Line <= len(origins) && origins[ce.Line-1].line > 0 {
+		o := origins[ce.Line-1]
+		if o.module != entry && ce.Column > len(linkIndent) {
+			ce.Column -= len(linkIndent)
+		}
+		at := fmt.Sprintf("line %d", o.line)
+		ce.Script, ce.Line = o.module, o.line
+		ce.Message = replaceFirst(linePattern, ce.Message, at)
+		ce.msg = replaceFirst(linePattern, ce.msg, at)
+	}
+	return m, err
+}
+
+// linkOrigin locates a line of linked source in its module; line is zero
+// for lines added by the linker.
+type linkOrigin struct {
+	module string
+	line   int
+}
+
+// linkIndent indents library bodies inside their loader functions.
+const linkIndent = "    "
+
+// linker holds the state of one link.
+type linker struct {
+	modules map[string]string
+	// exports lists the top-level names of each linked library.
+	exports map[string][]string
+	order   []string
+	visit   map[string]int // 1 while visiting, 2 once ordered
+}
+
+// link returns the source of the program running entry with the modules it
+// imports, and the origin of each of its lines.
+func link(modules []Module, entry string) (string, []linkOrigin, error) {
+	l := &linker{modules: make(map[string]string), exports: make(map[string][]string), visit: make(map[string]int)}
+	for _, mod := range modules {
+		if !isIdentifier(mod.Name) {
+			return "", nil, newError(ErrInvalidInput, fmt.Sprintf("monty: invalid module name %q", mod.Name))
+		}
+		if _, dup := l.modules[mod.Name]; dup {
+			return "", nil, newError(ErrInvalidInput, fmt.Sprintf("monty: duplicate module %s", mod.Name))
+		}
+		l.modules[mod.Name] = mod.Code
+	}
+	if _, ok := l.modules[entry]; !ok {
+		return "", nil, newError(ErrInvalidInput, fmt.Sprintf("monty: entry module %s not given", entry))
+	}
+	if err := l.sort(entry, nil); err != nil {
+		return "", nil, err
+	}
+
+	var out []string
+	var origins []linkOrigin
+	emit := func(module string, line int, text string) {
+		out = append(out, text)
+		origins = append(origins, linkOrigin{module, line})
+	}
+	for _, name := range l.order {
+		lines, err := l.rewrite(name, name != entry)
+		if err != nil {
+			return "", nil, err
+		}
+		if name == entry {
+			for i, text := range lines {
+				emit(name, i+1, text)
+			}
+			break
+		}
+		inString := continuationLines(l.modules[name])
+		emit(name, 0, fmt.Sprintf("def _link_%s():", name))
+		for i, text := range lines {
+			if text != "" && !inString[i+1] {
+				text = linkIndent + text
+			}
+			emit(name, i+1, text)
+		}
+		exports := l.exports[name]
+		mangled := make([]string, len(exports))
+		for i, export := range exports {
+			mangled[i] = linkedName(name, export)
+		}
+		if len(exports) == 0 {
+			emit(name, 0, linkIndent+"return ()")
+			emit(name, 0, fmt.Sprintf("_link_%s()", name))
+			continue
+		}
+		emit(name, 0, linkIndent+"return ("+strings.Join(exports, ", ")+",)")
+		emit(name, 0, strings.Join(mangled, ", ")+", = "+fmt.Sprintf("_link_%s()", name))
+	}
+	return strings.Join(out, "\n") + "\n", origins, nil
+}
+
+// sort orders name after the modules it imports, failing on cycles.
+func (l *linker) sort(name string, path []string) error {
+	path = append(path, name)
+	switch l.visit[name] {
+	case 1:
+		return linkError(path[len(path)-2], 0, "import cycle "+strings.Join(path, " -> "))
+	case 2:
+		return nil
+	}
+	l.visit[name] = 1
+	for _, qualified := range imports(logicalLines(l.modules[name])) {
+		dep, _, _ := strings.Cut(qualified, ".")
+		if _, ok := l.modules[dep]; ok {
+			if err := l.sort(dep, path); err != nil {
+				return err
+			}
+		}
+	}
+	l.visit[name] = 2
+	l.order = append(l.order, name)
+	l.exports[name] = topLevelNames(logicalLines(l.modules[name]))
+	return nil
+}
+
+// rewrite returns the physical lines of module name with its imports of
+// linked modules replaced by bindings of their mangled exports, and, for
+// libraries, global statements turned into nonlocal ones.
+func (l *linker) rewrite(name string, library bool) ([]string, error) {
+	code := l.modules[name]
+	lines := logicalLines(code)
+	aliases := make(map[string]string)
+	type replacement struct {
+		first, last int
+		text        string
+	}
+	var replaced []replacement
+	var globals []int
+	for i, ll := range lines {
+		last := strings.Count(code, "\n") + 1
+		if i+1 < len(lines) {
+			last = lines[i+1].line - 1
+		}
+		if text, ok := l.rewriteImport(ll.text, aliases); ok {
+			replaced = append(replaced, replacement{ll.line, last, text})
+		} else if _, ok := cutKeyword(ll.text, "global"); ok && ll.indent > 0 && library {
+			globals = append(globals, ll.line)
+		}
+	}
+
+	imported := make(map[int]int, len(replaced))
+	for _, r := range replaced {
+		imported[r.first] = r.last
+	}
+	if err := checkAliases(name, code, aliases, imported); err != nil {
+		return nil, err
+	}
+	code = l.rewriteAttrs(code, aliases)
+	physical := strings.Split(strings.TrimSuffix(code, "\n"), "\n")
+	for _, r := range replaced {
+		indent := physical[r.first-1][:len(physical[r.first-1])-len(strings.TrimLeft(physical[r.first-1], " \t"))]
+		physical[r.first-1] = indent + r.text
+		for j := r.first; j < r.last && j < len(physical); j++ {
+			physical[j] = ""
+		}
+	}
+	for _, line := range globals {
+		text := physical[line-1]
+		trimmed := strings.TrimLeft(text, " \t")
+		physical[line-1] = text[:len(text)-len(trimmed)] + "nonlocal" + strings.TrimPrefix(trimmed, "global")
+	}
+	return physical, nil
+}
+
+// checkAliases rejects uses of the names modules are imported under other
+// than `alias.name`, outside the import statements spanning the lines of
+// imported. Since the linker rewrites `alias.name` wherever it appears, a
+// parameter, variable, or comprehension target named like an alias would
+// silently refer to the module's export instead of its own value.
+func checkAliases(module, code string, aliases map[string]string, imported map[int]int) error {
+	if len(aliases) == 0 {
+		return nil
+	}
+	s := codeOnly(code)
+	for _, loc := range identPattern.FindAllStringIndex(s, -1) {
+		alias := s[loc[0]:loc[1]]
+		if _, ok := aliases[alias]; !ok || prevNonSpace(s, loc[0]) == '.' {
+			continue
+		}
+		if next := strings.TrimLeft(s[loc[1]:], " \t"); strings.HasPrefix(next, ".") {
+			continue
+		}
+		line := strings.Count(s[:loc[0]], "\n") + 1
+		if inImport(imported, line) {
+			continue
+		}
+		return linkError(module, line, fmt.Sprintf(
+			"%s used at line %d other than as %s.name, which linking does not support", alias, line, alias))
+	}
+	return nil
+}
+
+// linkError reports a problem with the source of module found while
+// linking, as the CompileError New would return for it.
+func linkError(module string, line int, msg string) error {
+	return &CompileError{Script: module, Type: "ImportError", Message: msg, Line: line, msg: "ImportError: " + msg}
+}
+
+// inImport reports whether line belongs to one of the import statements
+// spanning the lines of imported, keyed by first line.
+func inImport(imported map[int]int, line int) bool {
+	for first, last := range imported {
+		if line >= first && line <= last {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteImport returns the statement replacing an import of linked
+// modules, recording `import lib` aliases, and false for other statements.
+func (l *linker) rewriteImport(text string, aliases map[string]string) (string, bool) {
+	var kept, binds []string
+	if rest, ok := cutKeyword(text, "import"); ok {
+		for _, item := range splitTopLevel(rest) {
+			module, alias := splitAlias(item)
+			if _, linked := l.modules[module]; !linked {
+				kept = append(kept, item)
+				continue
+			}
+			if alias == "" {
+				alias = module
+			}
+			aliases[alias] = module
+		}
+		if len(kept) == len(splitTopLevel(rest)) {
+			return "", false
+		}
+		if len(kept) > 0 {
+			binds = append(binds, "import "+strings.Join(kept, ", "))
+		}
+	} else if rest, ok := cutKeyword(text, "from"); ok {
+		module, names, ok := strings.Cut(rest, " import ")
+		module = strings.TrimSpace(module)
+		if _, linked := l.modules[module]; !ok || !linked {
+			return "", false
+		}
+		names = strings.Trim(strings.TrimSpace(names), "()")
+		for _, item := range splitTopLevel(names) {
+			name, alias := splitAlias(item)
+			if name == "*" {
+				for _, export := range l.exports[module] {
+					if !strings.HasPrefix(export, "_") {
+						binds = append(binds, export+" = "+linkedName(module, export))
+					}
+				}
+				continue
+			}
+			if alias == "" {
+				alias = name
+			}
+			binds = append(binds, alias+" = "+linkedName(module, name))
+		}
+	} else {
+		return "", false
+	}
+	if len(binds) == 0 {
+		return "pass", true
+	}
+	return strings.Join(binds, "; "), true
+}
+
+// rewriteAttrs replaces the references alias.name to the exports of modules
+// imported under aliases by their mangled names.
+func (l *linker) rewriteAttrs(code string, aliases map[string]string) string {
+	if len(aliases) == 0 {
+		return code
+	}
+	s := codeOnly(code)
+	var b strings.Builder
+	last := 0
+	for _, loc := range attrPattern.FindAllStringSubmatchIndex(s, -1) {
+		alias, attr := s[loc[2]:loc[3]], s[loc[4]:loc[5]]
+		module, ok := aliases[alias]
+		if !ok || (loc[0] > 0 && (isIdentByte(s[loc[0]-1]) || prevNonSpace(s, loc[0]) == '.')) {
+			continue
+		}
+		b.WriteString(code[last:loc[0]])
+		b.WriteString(linkedName(module, attr))
+		last = loc[1]
+	}
+	b.WriteString(code[last:])
+	return b.String()
+}
+
+var identPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+var attrPattern = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)[ \t]*\.[ \t]*([A-Za-z_][A-Za-z0-9_]*)`)
+
+// linkedName is the top-level name under which name, exported by module,
+// is bound in the linked program.
+func linkedName(module, name string) string {
+	return "_" + module + "__" + name
+}
+
+// topLevelNames lists the names bound by the top-level def, class, and
+// assignment statements of lines, in order.
+func topLevelNames(lines []logicalLine) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if isIdentifier(name) && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for i, l := range lines {
+		if l.indent != 0 {
+			continue
+		}
+		if fn, ok := parseDef(lines, i); ok {
+			add(fn.Name)
+			continue
+		}
+		if c, ok := parseClass(lines, i); ok {
+			add(c.Name)
+			continue
+		}
+		if name, _, ok := parseAnnotation(l.text); ok && indexAssign(l.text) >= 0 {
+			add(name)
+			continue
+		}
+		text := l.text
+		for {
+			eq := indexAssign(text)
+			if eq < 0 {
+				break
+			}
+			for _, target := range splitTopLevel(strings.Trim(strings.TrimSpace(text[:eq]), "()[]")) {
+				add(strings.TrimSpace(target))
+			}
+			text = text[eq+1:]
+		}
+	}
+	return names
+}
+
+// indexAssign returns the index of the first top-level = of an assignment
+// in text, or -1.
+func indexAssign(text string) int {
+	found := -1
+	scanTopLevel(text, func(i int) bool {
+		if text[i] != '=' {
+			return true
+		}
+		if i+1 < len(text) && text[i+1] == '=' || i > 0 && strings.IndexByte("=!<>+-*/%&|^@:", text[i-1]) >= 0 {
+			return true
+		}
+		found = i
+		return false
+	})
+	return found
+}
+
+func replaceFirst(re *regexp.Regexp, s, repl string) string {
+	loc := re.FindStringIndex(s)
+	if loc == nil {
+		return s
+	}
+	return s[:loc[0]] + repl + s[loc[1]:]
+}
@@ -0,0 +1,41 @@
+//go:build cgo && !nomonty && (monty_dlopen || monty_embed)
+
+package monty
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <stdlib.h>
+
+int monty_dlopen_load(const char *path);
+const char *monty_dlopen_error(void);
+void monty_dlopen_disable(const char *reason);
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// LibraryEnv names the environment variable consulted for the shared library
+// path when LoadLibrary has not been called.
+const LibraryEnv = "MONTY_FFI_LIBRARY"
+
+// LoadLibrary loads libmonty_ffi from path and resolves its symbols. Without
+// an explicit call the library is loaded on first use from $MONTY_FFI_LIBRARY,
+// falling back to the platform's shared library search path.
+func LoadLibrary(path string) error {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+	if C.monty_dlopen_load(cPath) == 0 {
+		return errors.New("monty: " + C.GoString(C.monty_dlopen_error()))
+	}
+	return nil
+}
+
+// disableLibrary makes every FFI call fail with reason until LoadLibrary succeeds.
+func disableLibrary(reason string) {
+	cReason := C.CString(reason)
+	defer C.free(unsafe.Pointer(cReason))
+	C.monty_dlopen_disable(cReason)
+}
@@ -0,0 +1,21 @@
+//go:build cgo && !nomonty && !monty_dlopen && !monty_embed
+
+package monty
+
+/*
+#cgo darwin,amd64 LDFLAGS: -L${SRCDIR}/../../dist/darwin-amd64 -lmonty_ffi -framework Security -framework Foundation
+#cgo darwin,arm64 LDFLAGS: -L${SRCDIR}/../../dist/darwin-arm64 -lmonty_ffi -framework Security -framework Foundation
+#cgo linux,amd64,!musl LDFLAGS: -L${SRCDIR}/../../dist/linux-amd64 -lmonty_ffi -ldl -lpthread -lm
+#cgo linux,arm64,!musl LDFLAGS: -L${SRCDIR}/../../dist/linux-arm64 -lmonty_ffi -ldl -lpthread -lm
+#cgo linux,amd64,musl LDFLAGS: -L${SRCDIR}/../../dist/linux-amd64-musl -lmonty_ffi -lpthread -lm
+#cgo linux,arm64,musl LDFLAGS: -L${SRCDIR}/../../dist/linux-arm64-musl -lmonty_ffi -lpthread -lm
+*/
+import "C"
+
+import "errors"
+
+// LoadLibrary is only meaningful in builds tagged monty_dlopen; static builds
+// link libmonty_ffi at compile time.
+func LoadLibrary(path string) error {
+	return errors.New("monty: LoadLibrary requires the monty_dlopen build tag")
+}
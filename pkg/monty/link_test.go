@@ -0,0 +1,96 @@
+package monty
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var linkModules = []Module{
+	{Name: "main", Code: "import text as t\nfrom stats import (\n    mean,\n)\nimport json\nresult = t.slug(title) + str(mean([1, 2, 3]))\n"},
+	{Name: "text", Code: "\"\"\"Text helpers.\n\nSlugs.\"\"\"\nfrom stats import *\nSEP = '-'\ncalls = 0\n\ndef slug(s):\n    global calls\n    calls += 1\n    return SEP.join(s.lower().split()) + SEP + str(count)\n"},
+	{Name: "stats", Code: "count = 3\n_hidden = 1\ndef mean(xs):\n    return sum(xs) / count\n"},
+}
+
+func TestLink(t *testing.T) {
+	code, origins, err := link(linkModules, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `def _link_stats():
+    count = 3
+    _hidden = 1
+    def mean(xs):
+        return sum(xs) / count
+    return (count, _hidden, mean,)
+_stats__count, _stats___hidden, _stats__mean, = _link_stats()
+def _link_text():
+    """Text helpers.
+
+Slugs."""
+    count = _stats__count; mean = _stats__mean
+    SEP = '-'
+    calls = 0
+
+    def slug(s):
+        nonlocal calls
+        calls += 1
+        return SEP.join(s.lower().split()) + SEP + str(count)
+    return (SEP, calls, slug,)
+_text__SEP, _text__calls, _text__slug, = _link_text()
+pass
+mean = _stats__mean
+
+
+import json
+result = _text__slug(title) + str(mean([1, 2, 3]))
+`
+	if code != want {
+		t.Errorf("linked source:\n%s\nwant:\n%s", code, want)
+	}
+	for i, line := range strings.Split(code, "\n") {
+		if strings.Contains(line, "def slug") && origins[i] != (linkOrigin{"text", 8}) {
+			t.Errorf("def slug is at %+v, want text:8", origins[i])
+		}
+	}
+
+	for _, code := range []string{
+		"import util\ndef f(util):\n    return util.x\n",
+		"import util as u\nvalues = [u.x for u in items]\n",
+		"import util\nutil = None\n",
+		"import util\nfor util in range(3):\n    pass\n",
+		"import util\nprint(util)\n",
+	} {
+		shadowing := []Module{{Name: "main", Code: code}, {Name: "util", Code: "x = 1\n"}}
+		if _, _, err := link(shadowing, "main"); !errors.As(err, new(*CompileError)) {
+			t.Errorf("linking %q: err = %v, want a CompileError", code, err)
+		}
+	}
+	attrs := []Module{{Name: "main", Code: "import util\nresult = obj.util + util . x  # util\ns = 'util'\n"}, {Name: "util", Code: "x = 1\n"}}
+	if _, _, err := link(attrs, "main"); err != nil {
+		t.Errorf("linking attribute uses: %v", err)
+	}
+
+	cyclic := []Module{{Name: "a", Code: "from b import x\n"}, {Name: "b", Code: "import a\nx = 1\n"}}
+	if _, _, err := link(cyclic, "a"); !errors.As(err, new(*CompileError)) || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Errorf("linking a cycle: err = %v", err)
+	}
+}
+
+func TestNewLinkedCompileError(t *testing.T) {
+	sb := scriptedBridge(bridgeScript{"compile": func(req sandboxRequest) sandboxResponse {
+		// Report an error on the linked line holding stats' def.
+		for i, line := range strings.Split(req.Code, "\n") {
+			if strings.Contains(line, "def mean") {
+				return sandboxResponse{Err: fmt.Sprintf("SyntaxError: invalid syntax at line %d column 9", i+1)}
+			}
+		}
+		return sandboxResponse{Handle: 1}
+	}})
+	_, err := NewLinked(linkModules, "main", []string{"title"}, nil, WithSandbox(sb))
+	var ce *CompileError
+	if !errors.As(err, &ce) || ce.Script != "stats" || ce.Line != 3 || ce.Column != 5 || !strings.Contains(ce.Error(), "line 3") {
+		t.Fatalf("NewLinked: err = %#v", err)
+	}
+}
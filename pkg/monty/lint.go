@@ -0,0 +1,205 @@
+package monty
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lint rules, as reported in LintFinding.Rule.
+const (
+	// LintUnboundedLoop flags a `while True` loop that can neither exit,
+	// through break, return, or raise, nor yield to the host, through await
+	// or a call to one of LintConfig.Yields.
+	LintUnboundedLoop = "unbounded-loop"
+	// LintNesting flags blocks nested deeper than LintConfig.MaxNesting.
+	LintNesting = "nesting"
+	// LintBanned flags uses of LintConfig.Banned names and keywords.
+	LintBanned = "banned"
+)
+
+// DefaultBanned lists the names Lint rejects when LintConfig.Banned is nil:
+// the builtins that run or inspect code dynamically.
+var DefaultBanned = []string{"eval", "exec", "compile", "__import__", "globals", "locals"}
+
+// LintConfig sets the policy Lint checks. Zero fields take their defaults.
+type LintConfig struct {
+	// MaxNesting caps the depth of nested blocks, 6 by default. Function and
+	// class bodies count as blocks.
+	MaxNesting int
+	// Banned lists names and keywords scripts may not use, such as "while",
+	// "lambda", "global", or a dotted name like "time.sleep". Nil means
+	// DefaultBanned; an empty slice bans nothing.
+	Banned []string
+	// Yields lists the functions, usually the program's external functions,
+	// whose calls hand control to the host, so a loop calling one is not
+	// unbounded.
+	Yields []string
+}
+
+// LintFinding is one policy violation found by Lint.
+type LintFinding struct {
+	Rule string
+	// Line is the 1-based line of the offending statement.
+	Line    int
+	Message string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("line %d: %s (%s)", f.Line, f.Message, f.Rule)
+}
+
+// Lint checks code against the default policy; see LintConfig.Lint.
+func Lint(code string) []LintFinding {
+	return LintConfig{}.Lint(code)
+}
+
+// Lint reports where code breaks the policy of c, in line order, so
+// platforms can gate script acceptance on more than syntax. Like the other
+// source analysis in this package it reads the text rather than the syntax
+// tree: it does not follow calls into functions or see code built at run
+// time, and it does not report syntax errors, which New does.
+func (c LintConfig) Lint(code string) []LintFinding {
+	if c.MaxNesting <= 0 {
+		c.MaxNesting = 6
+	}
+	if c.Banned == nil {
+		c.Banned = DefaultBanned
+	}
+	banned := make(map[string]bool, len(c.Banned))
+	for _, name := range c.Banned {
+		banned[name] = true
+	}
+	yields := make(map[string]bool, len(c.Yields))
+	for _, name := range c.Yields {
+		yields[name] = true
+	}
+
+	lines := logicalLines(code)
+	var findings []LintFinding
+	var open []int // indents of the enclosing blocks
+	for i, l := range lines {
+		for len(open) > 0 && open[len(open)-1] >= l.indent {
+			open = open[:len(open)-1]
+		}
+		keyword, suite, header := blockHeader(l.text)
+		if header && suite == "" {
+			open = append(open, l.indent)
+			if len(open) == c.MaxNesting+1 {
+				findings = append(findings, LintFinding{Rule: LintNesting, Line: l.line,
+					Message: fmt.Sprintf("blocks nested %d deep, more than %d", len(open), c.MaxNesting)})
+			}
+		}
+		if keyword == "while" && alwaysTrue(l.text) && !loopEscapes(lines, i, suite, yields) {
+			findings = append(findings, LintFinding{Rule: LintUnboundedLoop, Line: l.line,
+				Message: "loop never exits or yields to the host"})
+		}
+		for _, ref := range nameRefs(l.text) {
+			if ref.attr {
+				continue
+			}
+			for n := len(ref.parts); n > 0; n-- {
+				if name := strings.Join(ref.parts[:n], "."); banned[name] {
+					findings = append(findings, LintFinding{Rule: LintBanned, Line: l.line,
+						Message: fmt.Sprintf("%s is not allowed", name)})
+					break
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// blockKeywords are the keywords that open a block.
+var blockKeywords = []string{"if", "elif", "else", "while", "for", "try", "except", "finally", "with", "def", "class", "match", "case", "async"}
+
+// blockHeader reports whether text opens a block, with its keyword and the
+// statements that follow the colon on the same line.
+func blockHeader(text string) (keyword, suite string, ok bool) {
+	for _, kw := range blockKeywords {
+		rest, found := strings.CutPrefix(text, kw)
+		if !found || rest != "" && isIdentByte(rest[0]) {
+			continue
+		}
+		if kw == "async" {
+			return blockHeader(strings.TrimSpace(rest))
+		}
+		colon := indexTopLevel(text, ":")
+		for colon >= 0 && strings.HasPrefix(text[colon:], ":=") {
+			next := indexTopLevel(text[colon+2:], ":")
+			if next < 0 {
+				colon = -1
+				break
+			}
+			colon += 2 + next
+		}
+		if colon < 0 {
+			return "", "", false
+		}
+		return kw, strings.TrimSpace(text[colon+1:]), true
+	}
+	return "", "", false
+}
+
+// alwaysTrue reports whether a while header's condition is a constant true.
+func alwaysTrue(header string) bool {
+	cond, _ := cutKeyword(header, "while")
+	if colon := indexTopLevel(cond, ":"); colon >= 0 {
+		cond = cond[:colon]
+	}
+	cond = strings.TrimSpace(cond)
+	for strings.HasPrefix(cond, "(") && matchingParen(cond, 0) == len(cond)-1 {
+		cond = strings.TrimSpace(cond[1 : len(cond)-1])
+	}
+	switch cond {
+	case "True", "1", "not False", "not None", "not 0":
+		return true
+	}
+	return false
+}
+
+// loopEscapes reports whether the while loop opened by lines[i], whose
+// statements after the colon are suite, can leave its body or hand control
+// to the host.
+func loopEscapes(lines []logicalLine, i int, suite string, yields map[string]bool) bool {
+	body := []string{suite}
+	if isBreak(suite) {
+		return true
+	}
+	var inner []int // indents of the loops nested in the body
+	for _, l := range lines[i+1:] {
+		if suite != "" || l.indent <= lines[i].indent {
+			break
+		}
+		for len(inner) > 0 && inner[len(inner)-1] >= l.indent {
+			inner = inner[:len(inner)-1]
+		}
+		stmt := l.text
+		if kw, rest, ok := blockHeader(l.text); ok {
+			stmt = rest
+			if kw == "for" || kw == "while" {
+				inner = append(inner, l.indent)
+				stmt = ""
+			}
+		}
+		if len(inner) == 0 && isBreak(stmt) {
+			return true
+		}
+		body = append(body, l.text)
+	}
+	for _, ref := range nameRefs(strings.Join(body, "\n")) {
+		switch first := ref.parts[0]; {
+		case ref.attr:
+		case first == "return" || first == "raise" || first == "await" || first == "yield":
+			return true
+		case ref.call && yields[strings.Join(ref.parts, ".")]:
+			return true
+		}
+	}
+	return false
+}
+
+// isBreak reports whether the statements stmt begin with break.
+func isBreak(stmt string) bool {
+	rest, ok := strings.CutPrefix(stmt, "break")
+	return ok && (rest == "" || !isIdentByte(rest[0]))
+}
@@ -0,0 +1,64 @@
+package monty
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	code := strings.Join([]string{
+		`while True:`,              // 1: unbounded
+		`    x = 1`,                // 2
+		`    for i in range(3):`,   // 3
+		`        break`,            // 4: leaves only the for loop
+		`while (True):`,            // 5: polls the host
+		`    msg = fetch()`,        // 6
+		`while True: break`,        // 7
+		`while 1:`,                 // 8
+		`    if done(): break`,     // 9
+		`while n < 3:`,             // 10: bounded by its condition
+		`    n += 1`,               // 11
+		`def f():`,                 // 12
+		`    while True:`,          // 13
+		`        return eval("1")`, // 14: banned
+		`s = "eval(x) # exec"`,     // 15: only a string
+		`y = exec`,                 // 16: banned
+	}, "\n")
+	got := LintConfig{Yields: []string{"fetch"}}.Lint(code)
+	want := []LintFinding{
+		{Rule: LintUnboundedLoop, Line: 1},
+		{Rule: LintBanned, Line: 14, Message: "eval is not allowed"},
+		{Rule: LintBanned, Line: 16, Message: "exec is not allowed"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Lint = %v", got)
+	}
+	for i, w := range want {
+		if got[i].Rule != w.Rule || got[i].Line != w.Line || w.Message != "" && got[i].Message != w.Message {
+			t.Errorf("finding %d = %v, want %v", i, got[i], w)
+		}
+	}
+	if got := Lint(code); len(got) != 4 || got[1].Rule != LintUnboundedLoop || got[1].Line != 5 {
+		t.Fatalf("without yields the polling loop should be flagged, got %v", got)
+	}
+}
+
+func TestLintNestingAndBans(t *testing.T) {
+	code := "def f():\n" +
+		"    if a:\n" +
+		"        for x in y:\n" +
+		"            with z:\n" +
+		"                pass\n" +
+		"    else:\n" +
+		"        g = lambda: time.sleep(1)\n"
+	got := LintConfig{MaxNesting: 2, Banned: []string{"lambda", "time.sleep"}}.Lint(code)
+	if len(got) != 3 || got[0].Rule != LintNesting || got[0].Line != 3 {
+		t.Fatalf("Lint = %v", got)
+	}
+	if got[1].Message != "lambda is not allowed" || got[2].Message != "time.sleep is not allowed" || got[2].Line != 7 {
+		t.Fatalf("Lint = %v", got)
+	}
+	if got := (LintConfig{Banned: []string{}}).Lint("eval('1')"); len(got) != 0 {
+		t.Fatalf("an empty ban list should allow eval, got %v", got)
+	}
+}
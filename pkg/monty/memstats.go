@@ -0,0 +1,25 @@
+package monty
+
+// MemStats reports the native library's allocator statistics, counted by
+// its global allocator since the library was loaded. Compare them with
+// runtime.MemStats to tell whether memory growth is in the Go heap or in the
+// interpreter's. Fields are read without a common lock, so figures taken
+// while other goroutines run scripts may disagree by allocations in flight.
+type MemStats struct {
+	// TotalAlloc is the number of bytes allocated, including bytes since freed.
+	TotalAlloc uint64 `json:"total_alloc"`
+	// Live is the number of bytes currently allocated.
+	Live uint64 `json:"live"`
+	// Peak is the highest value Live has reached.
+	Peak uint64 `json:"peak"`
+	// Mallocs and Frees count allocations made and freed.
+	Mallocs uint64 `json:"mallocs"`
+	Frees   uint64 `json:"frees"`
+}
+
+// NativeMemStats returns the allocator statistics of the in-process library.
+// Programs compiled WithSandbox allocate in the child process instead; use
+// Sandbox.NativeMemStats for those.
+func NativeMemStats() (MemStats, error) {
+	return native.memStats()
+}
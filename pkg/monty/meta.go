@@ -0,0 +1,22 @@
+package monty
+
+// Annotated is a resume value carrying out-of-band metadata, such as cache
+// hints or the cost of the call. The script receives only Value; Meta is
+// recorded alongside the resume in traces and in the CallTracker. It is
+// accepted wherever a call is answered: Snapshot.Resume, FutureResult.Result,
+// Progress.SetResult, and the values returned by Runner handlers.
+type Annotated struct {
+	Value any
+	Meta  map[string]any
+}
+
+// splitMeta separates the metadata of an Annotated result from its value.
+func splitMeta(result any) (any, map[string]any) {
+	if a, ok := result.(Annotated); ok {
+		if a.Value == nil {
+			return none{}, a.Meta
+		}
+		return a.Value, a.Meta
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package monty
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestResumeAnnotated(t *testing.T) {
+	var buf bytes.Buffer
+	tracker := NewCallTracker()
+	m, err := New("double(21)", "main.py", nil, []string{"double"},
+		WithSandbox(callBridge("double", 1)), WithTrace(&buf), WithCallTracker(tracker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := p.Snapshot.Resume(p.CallID, Annotated{Value: 42, Meta: map[string]any{"cache": "hit", "cost": 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(done.Result) != "42" {
+		t.Errorf("script received %s, want the value without its metadata", done.Result)
+	}
+
+	runs, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resume TraceEvent
+	for _, e := range runs[0].Events {
+		if e.Type == "resume" {
+			resume = e
+		}
+	}
+	if string(resume.Result) != "42" || string(resume.Meta.Get("cache")) != `"hit"` {
+		t.Errorf("resume event = %+v", resume)
+	}
+	calls := tracker.Calls(p.RunID)
+	if len(calls) != 1 || calls[0].Meta["cost"] != 3 {
+		t.Errorf("Calls = %+v", calls)
+	}
+}
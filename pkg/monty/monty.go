@@ -1,23 +1,13 @@
 package monty
 
-/*
-#cgo darwin,amd64 LDFLAGS: -L${SRCDIR}/../../dist/darwin-amd64 -lmonty_ffi -framework Security -framework Foundation
-#cgo darwin,arm64 LDFLAGS: -L${SRCDIR}/../../dist/darwin-arm64 -lmonty_ffi -framework Security -framework Foundation
-#cgo darwin CFLAGS: -I${SRCDIR}/../../include
-#cgo linux,amd64 LDFLAGS: -L${SRCDIR}/../../dist/linux-amd64 -lmonty_ffi -ldl -lpthread -lm
-#cgo linux,arm64 LDFLAGS: -L${SRCDIR}/../../dist/linux-arm64 -lmonty_ffi -ldl -lpthread -lm
-#cgo linux CFLAGS: -I${SRCDIR}/../../include
-#include <stdlib.h>
-#include "monty_ffi.h"
-*/
-import "C"
-
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"runtime"
-	"unsafe"
+	"slices"
+	"sync/atomic"
+	"time"
 )
 
 // ProgressKind mirrors the C enum constants.
@@ -28,12 +18,34 @@ const (
 	FunctionCall
 	OsCall
 	ResolveFutures
+	// Timer reports a script sleep; resume the snapshot with Wake once Duration has elapsed.
+	Timer
 )
 
+var progressKindNames = map[ProgressKind]string{
+	Complete:       "complete",
+	FunctionCall:   "function_call",
+	OsCall:         "os_call",
+	ResolveFutures: "resolve_futures",
+	Timer:          "timer",
+}
+
+// String returns the kind's name as written in traces, such as
+// "function_call".
+func (k ProgressKind) String() string {
+	if name, ok := progressKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", int(k))
+}
+
 // Progress represents the result of a start/resume call.
 type Progress struct {
-	Kind           ProgressKind
-	Result         Object
+	Kind   ProgressKind
+	Result Object
+	// ResultStream holds the final result instead of Result when it is
+	// larger than the threshold set with WithResultStreaming.
+	ResultStream   *ResultStream
 	FunctionName   string
 	OsFunction     string
 	Args           []Object
@@ -43,6 +55,42 @@ type Progress struct {
 	Snapshot       *Snapshot
 	PendingIDs     []uint32
 	FutureSnapshot *FutureSnapshot
+	Duration       time.Duration
+	// Token replaces the snapshot of a detached progress event; see Detach.
+	Token string
+	// Program is the ProgramHash of the program the run executes, empty for
+	// snapshots restored from bytes.
+	Program string
+	// RunID identifies the run and Labels are those set with WithLabels;
+	// the map is shared and must not be modified.
+	RunID  string
+	Labels map[string]string
+	// Context holds the run's context variables, see WithContextVars.
+	Context map[string]Object
+	// Exception describes the uncaught exception that ended the run, under
+	// WithExceptionResults.
+	Exception *Exception
+	// Warnings lists the warnings issued since the previous progress event,
+	// under WithWarnings.
+	Warnings []Warning
+
+	// answer receives the reply set on a Progress yielded by Monty.Events.
+	answer *answer
+}
+
+// PendingCall describes an external call deferred with
+// Snapshot.ResumeFuture, so hosts can persist it alongside the
+// FutureSnapshot that later waits on it and match the result by CallID.
+// Snapshots restored with SnapshotFromBytes only know the CallID, and
+// those restored with Progress.Attach do not know CreatedAt.
+type PendingCall struct {
+	CallID       uint32
+	FunctionName string
+	MethodCall   bool
+	Args         []Object
+	Kwargs       []KV
+	// CreatedAt is when the script made the call, by the run's clock.
+	CreatedAt time.Time
 }
 
 // FutureResult matches the JSON shape accepted by monty_future_snapshot_resume.
@@ -54,64 +102,109 @@ type FutureResult struct {
 
 // Monty wraps a compiled MontyRun handle.
 type Monty struct {
-	handle *C.MontyRunHandle
+	handle any
+	eng    engine
+	cfg    *config
+	id     uint64
+	// src is nil for programs restored from bytes.
+	src *source
+	// program hashes the source or bytes the program was built from.
+	program string
+	use     useGuard
 }
 
 // Snapshot holds a paused synchronous execution state.
 type Snapshot struct {
-	handle *C.SnapshotHandle
+	handle any
+	run    *run
+	id     uint64
+	use    useGuard
+	// call describes the call the snapshot is paused at.
+	call PendingCall
+	// dumped is set once the snapshot was dumped, so closing it does not
+	// cancel its run's calls.
+	dumped atomic.Bool
 }
 
 // FutureSnapshot holds a paused async execution state.
 type FutureSnapshot struct {
-	handle  *C.FutureSnapshotHandle
+	handle  any
 	pending []uint32
-}
-
-// New compiles Python code into a Monty handle.
-func New(code, scriptName string, inputNames, extFuncs []string) (*Monty, error) {
-	cCode, freeCode := cString(code)
-	defer freeCode()
-	cScript, freeScript := cString(scriptName)
-	defer freeScript()
-	inputs, freeInputs := cStringArray(inputNames)
-	defer freeInputs()
-	exts, freeExts := cStringArray(extFuncs)
-	defer freeExts()
-
-	var out *C.MontyRunHandle
-	status := C.monty_run_new(cCode, cScript, (**C.char)(inputs), (**C.char)(exts), &out)
-	if err := statusError(status); err != nil {
+	run     *run
+	id      uint64
+	use     useGuard
+	dumped  atomic.Bool
+}
+
+// New compiles Python code into a Monty handle. Problems with the code are
+// reported as a *CompileError, failures of the library as an *InternalError,
+// and code longer than Limits.MaxSourceBytes as a *LimitError.
+func New(code, scriptName string, inputNames, extFuncs []string, opts ...Option) (*Monty, error) {
+	cfg := newConfig(opts)
+	if err := cfg.checkWatchdog(); err != nil {
+		return nil, err
+	}
+	if max := cfg.limits.MaxSourceBytes; max > 0 && int64(len(code)) > max {
+		return nil, &LimitError{Limit: "source", Max: max, Size: int64(len(code))}
+	}
+	program := programHash([]byte(code))
+	var handle any
+	var err error
+	began := time.Now()
+	cfg.labeled(scriptName, program, "compile", func() {
+		handle, err = cfg.eng.compile(code, scriptName, inputNames, cfg.declareBuiltins(extFuncs))
+	})
+	if err != nil {
+		err = compileError(scriptName, err)
+	}
+	fire(hookCompile, func() ExecEvent {
+		return ExecEvent{Script: scriptName, Err: err, Time: began, Duration: time.Since(began)}
+	})
+	if err != nil {
 		return nil, err
 	}
-	return newMonty(out), nil
+	m := newMonty(handle, cfg.eng, cfg)
+	m.program = program
+	m.src = &source{code: code, scriptName: intern(scriptName), inputNames: internAll(inputNames), extFuncs: internAll(extFuncs)}
+	return m, nil
 }
 
 // NewFromBytes restores a Monty handle from postcard bytes.
-func NewFromBytes(data []byte) (*Monty, error) {
+func NewFromBytes(data []byte, opts ...Option) (*Monty, error) {
 	if len(data) == 0 {
-		return nil, errors.New("monty: empty snapshot")
+		return nil, newError(ErrInvalidInput, "monty: empty snapshot")
 	}
-	var out *C.MontyRunHandle
-	status := C.monty_run_load((*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &out)
-	if err := statusError(status); err != nil {
+	_, data, err := undump(MontyHandle, data)
+	if err != nil {
+		return nil, err
+	}
+	cfg := newConfig(opts)
+	if err := cfg.checkWatchdog(); err != nil {
 		return nil, err
 	}
-	return newMonty(out), nil
+	handle, err := cfg.eng.loadRun(data)
+	if err != nil {
+		return nil, loadError(err)
+	}
+	m := newMonty(handle, cfg.eng, cfg)
+	m.program = programHash(data)
+	return m, nil
 }
 
 // Dump serializes the compiled Monty run to postcard bytes.
 func (m *Monty) Dump() ([]byte, error) {
-	if m == nil || m.handle == nil {
-		return nil, errors.New("monty: nil handle")
+	if m == nil {
+		return nil, newError(ErrClosed, "monty: nil handle")
 	}
-	var buf *C.uint8_t
-	var length C.size_t
-	status := C.monty_run_dump(m.handle, &buf, &length)
-	if err := statusError(status); err != nil {
+	if err := m.use.acquire(MontyHandle, "dump", true); err != nil {
 		return nil, err
 	}
-	return copyBytes(buf, length), nil
+	defer m.use.release(true)
+	if m.handle == nil {
+		return nil, newError(ErrClosed, "monty: nil handle")
+	}
+	state, err := m.eng.dumpRun(m.handle)
+	return dump(m.eng, MontyHandle, 0, state, err)
 }
 
 // Run executes code to completion in one shot.
@@ -123,89 +216,282 @@ func (m *Monty) Run(inputs ...any) (Object, error) {
 	if progress.Kind != Complete {
 		return nil, fmt.Errorf("monty: execution paused unexpectedly (%v)", progress.Kind)
 	}
-	return progress.Result, nil
+	return completeResult(progress)
 }
 
-// Start begins execution and returns the first progress result.
+// Start begins execution and returns the first progress result. Exceptions
+// raised by the script are returned as is, failures of the library as an
+// *InternalError.
 func (m *Monty) Start(inputs ...any) (Progress, error) {
-	if m == nil || m.handle == nil {
-		return Progress{}, errors.New("monty: nil handle")
+	if m == nil {
+		return Progress{}, newError(ErrClosed, "monty: nil handle")
 	}
-	payload, freePayload, err := marshalInputs(inputs)
+	return m.start(context.Background(), m.cfg, inputs)
+}
+
+func (m *Monty) start(ctx context.Context, cfg *config, inputs []any) (Progress, error) {
+	if m == nil {
+		return Progress{}, newError(ErrClosed, "monty: nil handle")
+	}
+	if err := m.use.acquire(MontyHandle, "start", true); err != nil {
+		return Progress{}, err
+	}
+	defer m.use.release(true)
+	if m.handle == nil {
+		return Progress{}, newError(ErrClosed, "monty: nil handle")
+	}
+	if err := cfg.checkWatchdog(); err != nil {
+		return Progress{}, err
+	}
+	if err := cfg.quota.admit(); err != nil {
+		return Progress{}, err
+	}
+	payload, err := marshalInputs(inputs)
 	if err != nil {
 		return Progress{}, err
 	}
-	defer freePayload()
 
-	var raw C.ProgressResult
-	status := C.monty_run_start(m.handle, payload, &raw)
-	defer C.monty_progress_result_free_strings(&raw)
-	if err := statusError(status); err != nil {
+	r := cfg.newRun()
+	r.eng = m.eng
+	r.program = m.program
+	r.ctx = ctx
+	if err := r.bindContext(ctx); err != nil {
+		return Progress{}, err
+	}
+	if m.src != nil {
+		r.script = m.src.scriptName
+	}
+	if err := r.sendInput(int64(len(payload))); err != nil {
 		return Progress{}, err
 	}
-	return convertProgress(&raw)
+	if err := r.enterGroup(); err != nil {
+		return Progress{}, err
+	}
+	fire(hookStart, r.event)
+	if r.trace != nil {
+		start := TraceEvent{Type: "start", RunID: r.id, Labels: r.cfg.labels, Script: r.script, Inputs: payload}
+		if m.src != nil {
+			start.Code, start.InputNames, start.Functions = m.src.code, m.src.inputNames, m.src.extFuncs
+		}
+		r.record(start)
+	}
+	raw, err := r.watch("start", &m.use, func() (rawProgress, error) {
+		return m.eng.start(m.handle, payload, r)
+	})
+	if err != nil {
+		return r.traced(Progress{}, runError("start", err))
+	}
+	progress, err := convertProgress(raw, r)
+	if err != nil {
+		return r.traced(Progress{}, err)
+	}
+	return r.traced(r.intercept(progress))
 }
 
-// Close releases the underlying Monty handle.
-func (m *Monty) Close() {
-	if m != nil && m.handle != nil {
-		C.monty_run_free(m.handle)
-		m.handle = nil
+// Close releases the underlying Monty handle. The handle is released even
+// when the library reports an error. Closing twice is a no-op. Closing while
+// another goroutine starts or dumps the program fails with ErrConcurrentUse
+// and leaves the handle open.
+func (m *Monty) Close() error {
+	return m.close(false)
+}
+
+// IsClosed reports whether the handle has been released.
+func (m *Monty) IsClosed() bool {
+	return m == nil || m.handle == nil
+}
+
+func (m *Monty) close(finalized bool) error {
+	if m == nil {
+		return nil
+	}
+	if err := m.use.acquire(MontyHandle, "close", false); err != nil {
+		return err
+	}
+	defer m.use.release(false)
+	if m.handle == nil {
+		return nil
+	}
+	var err error
+	if d, ok := m.eng.(deferredFreer); ok && finalized {
+		d.freeRunLater(m.handle)
+	} else {
+		err = m.eng.freeRun(m.handle)
 	}
+	m.handle = nil
+	leakRegistry.untrack(MontyHandle, m.id, finalized)
+	return err
 }
 
 // SnapshotFromBytes restores a snapshot from postcard bytes.
-func SnapshotFromBytes(data []byte) (*Snapshot, error) {
+func SnapshotFromBytes(data []byte, opts ...Option) (*Snapshot, error) {
+	return loadSnapshot(data, newConfig(opts).newRun())
+}
+
+// FutureSnapshotFromBytes restores a future snapshot from postcard bytes.
+func FutureSnapshotFromBytes(data []byte, opts ...Option) (*FutureSnapshot, error) {
+	return loadFutureSnapshot(data, newConfig(opts).newRun())
+}
+
+// loadSnapshot restores a snapshot of r from postcard bytes.
+func loadSnapshot(data []byte, r *run) (*Snapshot, error) {
 	if len(data) == 0 {
-		return nil, errors.New("monty: empty snapshot bytes")
+		return nil, newError(ErrInvalidInput, "monty: empty snapshot bytes")
 	}
-	var out *C.SnapshotHandle
-	status := C.monty_snapshot_load((*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &out)
-	if err := statusError(status); err != nil {
+	info, data, err := undump(SnapshotHandle, data)
+	if err != nil {
 		return nil, err
 	}
-	return newSnapshot(out), nil
+	if err := r.cfg.checkWatchdog(); err != nil {
+		return nil, err
+	}
+	handle, err := r.eng.loadSnapshot(data)
+	if err != nil {
+		return nil, loadError(err)
+	}
+	r.clock = info.Clock
+	return newSnapshot(handle, r), nil
 }
 
-// FutureSnapshotFromBytes restores a future snapshot from postcard bytes.
-func FutureSnapshotFromBytes(data []byte) (*FutureSnapshot, error) {
+// loadFutureSnapshot restores a future snapshot of r from postcard bytes.
+func loadFutureSnapshot(data []byte, r *run) (*FutureSnapshot, error) {
 	if len(data) == 0 {
-		return nil, errors.New("monty: empty snapshot bytes")
+		return nil, newError(ErrInvalidInput, "monty: empty snapshot bytes")
+	}
+	info, data, err := undump(FutureSnapshotHandle, data)
+	if err != nil {
+		return nil, err
 	}
-	var out *C.FutureSnapshotHandle
-	status := C.monty_future_snapshot_load((*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &out)
-	if err := statusError(status); err != nil {
+	if err := r.cfg.checkWatchdog(); err != nil {
 		return nil, err
 	}
-	return newFutureSnapshot(out, nil), nil
+	handle, err := r.eng.loadFutureSnapshot(data)
+	if err != nil {
+		return nil, loadError(err)
+	}
+	r.clock = info.Clock
+	return newFutureSnapshot(handle, nil, r), nil
 }
 
 // Dump serializes the snapshot without consuming it.
 func (s *Snapshot) Dump() ([]byte, error) {
-	if s == nil || s.handle == nil {
-		return nil, errors.New("monty: snapshot closed")
+	if s == nil {
+		return nil, newError(ErrClosed, "monty: snapshot closed")
 	}
-	var buf *C.uint8_t
-	var length C.size_t
-	status := C.monty_snapshot_dump(s.handle, &buf, &length)
-	if err := statusError(status); err != nil {
+	if err := s.use.acquire(SnapshotHandle, "dump", true); err != nil {
 		return nil, err
 	}
-	return copyBytes(buf, length), nil
+	defer s.use.release(true)
+	if s.handle == nil {
+		return nil, newError(ErrClosed, "monty: snapshot closed")
+	}
+	state, err := s.run.eng.dumpSnapshot(s.handle)
+	data, err := dump(s.run.eng, SnapshotHandle, s.run.clock, state, err)
+	if err == nil {
+		err = s.run.checkSnapshot(int64(len(data)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.dumped.Store(true)
+	return data, nil
 }
 
-// Dump serializes the future snapshot without consuming it.
+// Dump serializes the future snapshot without consuming it. Dumps may run
+// while other goroutines dispatch the pending calls, but not while the
+// future snapshot is resumed or closed.
 func (fs *FutureSnapshot) Dump() ([]byte, error) {
-	if fs == nil || fs.handle == nil {
-		return nil, errors.New("monty: future snapshot closed")
+	if fs == nil {
+		return nil, newError(ErrClosed, "monty: future snapshot closed")
+	}
+	if err := fs.use.acquire(FutureSnapshotHandle, "dump", true); err != nil {
+		return nil, err
 	}
-	var buf *C.uint8_t
-	var length C.size_t
-	status := C.monty_future_snapshot_dump(fs.handle, &buf, &length)
-	if err := statusError(status); err != nil {
+	defer fs.use.release(true)
+	if fs.handle == nil {
+		return nil, newError(ErrClosed, "monty: future snapshot closed")
+	}
+	state, err := fs.run.eng.dumpFutureSnapshot(fs.handle)
+	data, err := dump(fs.run.eng, FutureSnapshotHandle, fs.run.clock, state, err)
+	if err == nil {
+		err = fs.run.checkSnapshot(int64(len(data)))
+	}
+	if err != nil {
 		return nil, err
 	}
-	return copyBytes(buf, length), nil
+	fs.dumped.Store(true)
+	return data, nil
+}
+
+// EstimateSize returns the length of the bytes Dump would return, computed
+// by the library without serializing the snapshot, so hosts can pick where
+// to store it first.
+func (s *Snapshot) EstimateSize() (int64, error) {
+	if s == nil {
+		return 0, newError(ErrClosed, "monty: snapshot closed")
+	}
+	if err := s.use.acquire(SnapshotHandle, "size", true); err != nil {
+		return 0, err
+	}
+	defer s.use.release(true)
+	if s.handle == nil {
+		return 0, newError(ErrClosed, "monty: snapshot closed")
+	}
+	size, err := s.run.eng.snapshotSize(s.handle)
+	if err != nil {
+		return 0, err
+	}
+	return size + int64(len(dumpHeader(s.run.eng, SnapshotHandle, s.run.clock))), nil
+}
+
+// DumpPending serializes the future snapshot like Dump and returns the IDs
+// of the calls it waits on, read from the library together with the state,
+// so that both agree even for future snapshots restored from bytes.
+func (fs *FutureSnapshot) DumpPending() ([]byte, []uint32, error) {
+	if fs == nil {
+		return nil, nil, newError(ErrClosed, "monty: future snapshot closed")
+	}
+	if err := fs.use.acquire(FutureSnapshotHandle, "dump", true); err != nil {
+		return nil, nil, err
+	}
+	defer fs.use.release(true)
+	if fs.handle == nil {
+		return nil, nil, newError(ErrClosed, "monty: future snapshot closed")
+	}
+	state, pending, err := fs.run.eng.dumpFutureSnapshotPending(fs.handle)
+	data, err := dump(fs.run.eng, FutureSnapshotHandle, fs.run.clock, state, err)
+	if err == nil {
+		err = fs.run.checkSnapshot(int64(len(data)))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	ids, err := decodeUint32ArrayString(string(pending))
+	if err != nil {
+		return nil, nil, err
+	}
+	fs.dumped.Store(true)
+	return data, ids, nil
+}
+
+// EstimateSize returns the length of the bytes Dump would return, computed
+// by the library without serializing the future snapshot.
+func (fs *FutureSnapshot) EstimateSize() (int64, error) {
+	if fs == nil {
+		return 0, newError(ErrClosed, "monty: future snapshot closed")
+	}
+	if err := fs.use.acquire(FutureSnapshotHandle, "size", true); err != nil {
+		return 0, err
+	}
+	defer fs.use.release(true)
+	if fs.handle == nil {
+		return 0, newError(ErrClosed, "monty: future snapshot closed")
+	}
+	size, err := fs.run.eng.futureSnapshotSize(fs.handle)
+	if err != nil {
+		return 0, err
+	}
+	return size + int64(len(dumpHeader(fs.run.eng, FutureSnapshotHandle, fs.run.clock))), nil
 }
 
 // PendingCallIDs returns the cached pending call IDs for the snapshot.
@@ -216,280 +502,439 @@ func (fs *FutureSnapshot) PendingCallIDs() []uint32 {
 	return append([]uint32(nil), fs.pending...)
 }
 
-// Resume continues execution of a function call with a result value.
+// Resume continues execution of a function call with a result value. A nil
+// result resumes it with None.
 func (s *Snapshot) Resume(callID uint32, result any) (Progress, error) {
+	if result == nil {
+		result = none{}
+	}
 	return s.resume(callID, result, "")
 }
 
 // ResumeError continues execution by raising an exception message.
 func (s *Snapshot) ResumeError(callID uint32, message string) (Progress, error) {
 	if message == "" {
-		return Progress{}, errors.New("monty: empty error message")
+		return Progress{}, newError(ErrInvalidInput, "monty: empty error message")
 	}
 	return s.resume(callID, nil, message)
 }
 
-// ResumeFuture continues execution treating the call as pending (returns ExternalFuture).
-func (s *Snapshot) ResumeFuture(callID uint32) (Progress, error) {
-	return s.resume(callID, nil, "")
+// Wake continues execution after a Timer progress event.
+func (s *Snapshot) Wake(callID uint32) (Progress, error) {
+	return s.resume(callID, none{}, "")
+}
+
+// ResumeFuture continues execution treating the call as pending: the script
+// receives an ExternalFuture for it. The returned PendingCall describes the
+// deferred call.
+func (s *Snapshot) ResumeFuture(callID uint32) (Progress, PendingCall, error) {
+	call := PendingCall{CallID: callID}
+	if s != nil && s.call.CallID == callID {
+		call = s.call
+	}
+	p, err := s.resume(callID, nil, "")
+	if err != nil {
+		return Progress{}, PendingCall{}, err
+	}
+	return p, call, nil
 }
 
 func (s *Snapshot) resume(callID uint32, result any, errMsg string) (Progress, error) {
+	if s == nil {
+		return Progress{}, s.closedError()
+	}
+	if err := s.use.acquire(SnapshotHandle, "resume", false); err != nil {
+		return Progress{}, err
+	}
+	defer s.use.release(false)
+	if s.handle == nil {
+		return Progress{}, s.closedError()
+	}
+	r := s.run
+	if err := r.enterGroup(); err != nil {
+		return Progress{}, err
+	}
+	var name string
+	if s.call.CallID == callID {
+		name = s.call.FunctionName
+	}
+	r.traceResume(callID, name, result, errMsg)
+	r.trackResume(callID, name, result, errMsg)
+	fire(hookResume, func() ExecEvent {
+		e := r.event()
+		e.CallID, e.Name = callID, name
+		return e
+	})
+	progress, err := s.step(callID, result, errMsg)
+	if err != nil {
+		return r.traced(Progress{}, err)
+	}
+	return r.traced(r.intercept(progress))
+}
+
+// step resumes the snapshot once without answering virtualized OS calls.
+func (s *Snapshot) step(callID uint32, result any, errMsg string) (Progress, error) {
 	if s == nil || s.handle == nil {
-		return Progress{}, errors.New("monty: snapshot closed")
+		return Progress{}, newError(ErrClosed, "monty: snapshot closed")
 	}
-	var resultJSON *C.char
-	var freeResult func()
-	var err error
+	var resultJSON []byte
 	if errMsg == "" && result != nil {
-		resultJSON, freeResult, err = marshalValue(result)
+		var err error
+		resultJSON, err = marshalValue(result)
 		if err != nil {
 			return Progress{}, err
 		}
-		defer freeResult()
-	}
-
-	var errC *C.char
-	var freeErr func()
-	if errMsg != "" {
-		errC, freeErr = cString(errMsg)
-		defer freeErr()
+		if err := s.run.sendValue(int64(len(resultJSON))); err != nil {
+			return Progress{}, err
+		}
 	}
 
-	var raw C.ProgressResult
-	status := C.monty_snapshot_resume(s.handle, C.uint32_t(callID), resultJSON, errC, &raw)
+	handle := s.handle
 	s.handle = nil
-	defer C.monty_progress_result_free_strings(&raw)
-	if err := statusError(status); err != nil {
-		return Progress{}, err
+	leakRegistry.untrack(SnapshotHandle, s.id, false)
+	raw, err := s.run.watch("resume", nil, func() (rawProgress, error) {
+		return s.run.eng.resume(handle, callID, resultJSON, errMsg, s.run)
+	})
+	if err != nil {
+		return Progress{}, runError("resume", err)
 	}
-	return convertProgress(&raw)
+	return convertProgress(raw, s.run)
 }
 
 // Resume resumes futures with provided results.
 func (fs *FutureSnapshot) Resume(results []FutureResult) (Progress, error) {
-	if fs == nil || fs.handle == nil {
-		return Progress{}, errors.New("monty: future snapshot closed")
+	if fs == nil {
+		return Progress{}, fs.closedError()
+	}
+	if err := fs.use.acquire(FutureSnapshotHandle, "resume", false); err != nil {
+		return Progress{}, err
 	}
-	payload, freePayload, err := marshalFutureResults(results)
+	defer fs.use.release(false)
+	if fs.handle == nil {
+		return Progress{}, fs.closedError()
+	}
+	payload, err := marshalFutureResults(results)
 	if err != nil {
 		return Progress{}, err
 	}
-	defer freePayload()
+	if err := fs.run.sendValue(int64(len(payload))); err != nil {
+		return Progress{}, err
+	}
+	if err := fs.run.enterGroup(); err != nil {
+		return Progress{}, err
+	}
+	fs.run.traceFutures(results)
+	fs.run.trackFutures(results)
+	fire(hookResume, func() ExecEvent {
+		e := fs.run.event()
+		e.Kind = ResolveFutures
+		return e
+	})
 
-	var raw C.ProgressResult
-	status := C.monty_future_snapshot_resume(fs.handle, payload, &raw)
+	handle := fs.handle
 	fs.handle = nil
-	defer C.monty_progress_result_free_strings(&raw)
-	if err := statusError(status); err != nil {
-		return Progress{}, err
+	leakRegistry.untrack(FutureSnapshotHandle, fs.id, false)
+	raw, err := fs.run.watch("resume futures", nil, func() (rawProgress, error) {
+		return fs.run.eng.resumeFutures(handle, payload, fs.run)
+	})
+	if err != nil {
+		return fs.run.traced(Progress{}, runError("resume futures", err))
 	}
-	return convertProgress(&raw)
+	progress, err := convertProgress(raw, fs.run)
+	if err != nil {
+		return fs.run.traced(Progress{}, err)
+	}
+	return fs.run.traced(fs.run.intercept(progress))
 }
 
-// Close frees the snapshot handle.
-func (s *Snapshot) Close() {
-	if s != nil && s.handle != nil {
-		C.monty_snapshot_free(s.handle)
-		s.handle = nil
+// ResumeOne applies a single result as it arrives. While other calls remain
+// pending the returned progress is ResolveFutures with a new FutureSnapshot
+// holding the remaining calls; otherwise the run continues to its next event.
+// Like Resume, it consumes fs.
+func (fs *FutureSnapshot) ResumeOne(result FutureResult) (Progress, error) {
+	if fs != nil && fs.pending != nil && !slices.Contains(fs.pending, result.CallID) {
+		return Progress{}, newError(ErrInvalidInput, fmt.Sprintf("monty: call %d is not pending", result.CallID))
 	}
+	return fs.Resume([]FutureResult{result})
+}
+
+// Close frees the snapshot handle. Closing a snapshot that was already
+// closed or resumed is a no-op.
+func (s *Snapshot) Close() error {
+	return s.close(false)
 }
 
-// Close frees the future snapshot handle.
-func (fs *FutureSnapshot) Close() {
-	if fs != nil && fs.handle != nil {
-		C.monty_future_snapshot_free(fs.handle)
-		fs.handle = nil
-		fs.pending = nil
+// IsClosed reports whether the snapshot was closed or consumed by resuming it.
+func (s *Snapshot) IsClosed() bool {
+	return s == nil || s.handle == nil
+}
+
+func (s *Snapshot) close(finalized bool) error {
+	if s == nil {
+		return nil
+	}
+	if err := s.use.acquire(SnapshotHandle, "close", false); err != nil {
+		return err
 	}
+	defer s.use.release(false)
+	if s.handle == nil {
+		return nil
+	}
+	s.run.forgetPaused(s, nil)
+	s.run.trackClose(s.dumped.Load())
+	var err error
+	if d, ok := s.run.eng.(deferredFreer); ok && finalized {
+		d.freeSnapshotLater(s.handle)
+	} else {
+		err = s.run.eng.freeSnapshot(s.handle)
+	}
+	s.handle = nil
+	leakRegistry.untrack(SnapshotHandle, s.id, finalized)
+	return err
+}
+
+// Close frees the future snapshot handle. Closing a future snapshot that was
+// already closed or resumed is a no-op.
+func (fs *FutureSnapshot) Close() error {
+	return fs.close(false)
 }
 
-func newMonty(handle *C.MontyRunHandle) *Monty {
-	m := &Monty{handle: handle}
-	runtime.SetFinalizer(m, func(m *Monty) { m.Close() })
+// IsClosed reports whether the future snapshot was closed or consumed by
+// resuming it.
+func (fs *FutureSnapshot) IsClosed() bool {
+	return fs == nil || fs.handle == nil
+}
+
+func (fs *FutureSnapshot) close(finalized bool) error {
+	if fs == nil {
+		return nil
+	}
+	if err := fs.use.acquire(FutureSnapshotHandle, "close", false); err != nil {
+		return err
+	}
+	defer fs.use.release(false)
+	if fs.handle == nil {
+		return nil
+	}
+	fs.run.forgetPaused(nil, fs)
+	fs.run.trackClose(fs.dumped.Load())
+	var err error
+	if d, ok := fs.run.eng.(deferredFreer); ok && finalized {
+		d.freeFutureSnapshotLater(fs.handle)
+	} else {
+		err = fs.run.eng.freeFutureSnapshot(fs.handle)
+	}
+	fs.handle = nil
+	fs.pending = nil
+	leakRegistry.untrack(FutureSnapshotHandle, fs.id, finalized)
+	return err
+}
+
+func newMonty(handle any, eng engine, cfg *config) *Monty {
+	m := &Monty{handle: handle, eng: eng, cfg: cfg, id: leakRegistry.track(MontyHandle)}
+	if !cfg.noFinalizer {
+		runtime.SetFinalizer(m, func(m *Monty) { m.close(true) })
+	}
 	return m
 }
 
-func newSnapshot(handle *C.SnapshotHandle) *Snapshot {
-	snap := &Snapshot{handle: handle}
-	runtime.SetFinalizer(snap, func(s *Snapshot) { s.Close() })
+func newSnapshot(handle any, r *run) *Snapshot {
+	snap := &Snapshot{handle: handle, run: r, id: leakRegistry.track(SnapshotHandle)}
+	if !r.cfg.noFinalizer {
+		runtime.SetFinalizer(snap, func(s *Snapshot) { s.close(true) })
+	}
 	return snap
 }
 
-func newFutureSnapshot(handle *C.FutureSnapshotHandle, pending []uint32) *FutureSnapshot {
-	fs := &FutureSnapshot{handle: handle, pending: pending}
-	runtime.SetFinalizer(fs, func(fs *FutureSnapshot) { fs.Close() })
+func newFutureSnapshot(handle any, pending []uint32, r *run) *FutureSnapshot {
+	fs := &FutureSnapshot{handle: handle, pending: pending, run: r, id: leakRegistry.track(FutureSnapshotHandle)}
+	if !r.cfg.noFinalizer {
+		runtime.SetFinalizer(fs, func(fs *FutureSnapshot) { fs.close(true) })
+	}
 	return fs
 }
 
-func copyBytes(buf *C.uint8_t, length C.size_t) []byte {
-	if buf == nil || length == 0 {
-		return nil
+// marshalInputs encodes Start inputs as a JSON array, copying Objects and
+// json.RawMessages into it verbatim.
+func marshalInputs(inputs []any) ([]byte, error) {
+	data := []byte{'['}
+	for i, in := range inputs {
+		item, err := marshalValue(in)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			data = append(data, ',')
+		}
+		data = append(data, item...)
 	}
-	goBuf := C.GoBytes(unsafe.Pointer(buf), C.int(length))
-	C.monty_free_bytes(buf, length)
-	return goBuf
+	return append(data, ']'), nil
 }
 
-func marshalInputs(values []any) (*C.char, func(), error) {
-	data, err := json.Marshal(values)
-	if err != nil {
-		return nil, nil, err
+// marshalValue encodes one value after applying any registered Converter.
+// Already-encoded JSON is validated but not decoded and re-encoded.
+func marshalValue(value any) ([]byte, error) {
+	value, _ = splitMeta(value)
+	if data, ok, err := convertValue(value); ok || err != nil {
+		if err != nil {
+			return nil, wrapError(ErrInvalidInput, err)
+		}
+		value = json.RawMessage(data)
 	}
-	str, free := cBytes(data)
-	return str, free, nil
-}
-
-func marshalValue(value any) (*C.char, func(), error) {
-	normalized, err := normalizeValue(value)
-	if err != nil {
-		return nil, nil, err
+	if raw, ok := rawJSON(value); ok {
+		return raw, checkRawJSON(raw)
 	}
-	data, err := json.Marshal(normalized)
+	normalized, err := normalizeValue(value)
 	if err != nil {
-		return nil, nil, err
+		return nil, wrapError(ErrInvalidInput, err)
 	}
-	str, free := cBytes(data)
-	return str, free, nil
+	data, err := jsonMarshal(normalized)
+	return data, wrapError(ErrInvalidInput, err)
 }
 
-func marshalFutureResults(results []FutureResult) (*C.char, func(), error) {
+func marshalFutureResults(results []FutureResult) ([]byte, error) {
 	payload := make([]map[string]any, 0, len(results))
 	for _, item := range results {
 		entry := map[string]any{"call_id": item.CallID}
 		if item.Err != "" {
 			entry["error"] = item.Err
 		} else if item.Result != nil {
-			normalized, err := normalizeValue(item.Result)
+			data, err := marshalValue(item.Result)
 			if err != nil {
-				return nil, nil, err
+				return nil, err
 			}
-			entry["result"] = normalized
+			entry["result"] = json.RawMessage(data)
 		}
 		payload = append(payload, entry)
 	}
-	data, err := json.Marshal(payload)
-	if err != nil {
-		return nil, nil, err
+	data, err := jsonMarshal(payload)
+	return data, wrapError(ErrInvalidInput, err)
+}
+
+// rawJSON reports whether value is already-encoded JSON; an empty payload
+// stands for null.
+func rawJSON(value any) ([]byte, bool) {
+	var raw []byte
+	switch v := value.(type) {
+	case Object:
+		raw = v
+	case json.RawMessage:
+		raw = v
+	default:
+		return nil, false
 	}
-	str, free := cBytes(data)
-	return str, free, nil
+	if len(raw) == 0 {
+		return []byte("null"), true
+	}
+	return raw, true
+}
+
+// checkRawJSON validates already-encoded JSON with the installed codec,
+// without keeping what it decodes.
+func checkRawJSON(raw []byte) error {
+	var v json.RawMessage
+	if err := jsonUnmarshal(raw, &v); err != nil {
+		return newError(ErrInvalidInput, fmt.Sprintf("monty: invalid JSON in raw value: %v", err))
+	}
+	return nil
 }
 
+// normalizeValue prepares value for the codec, passing already-encoded JSON
+// through as json.RawMessage and tagging non-finite floats.
 func normalizeValue(value any) (any, error) {
-	if obj, ok := value.(Object); ok {
-		return objectToInterface(obj)
+	if raw, ok := rawJSON(value); ok {
+		return json.RawMessage(raw), checkRawJSON(raw)
 	}
 	switch v := value.(type) {
 	case []Object:
-		elems := make([]any, len(v))
+		elems := make([]json.RawMessage, len(v))
 		for i, item := range v {
-			normalized, err := objectToInterface(item)
-			if err != nil {
+			elems[i], _ = rawJSON(item)
+			if err := checkRawJSON(elems[i]); err != nil {
 				return nil, err
 			}
-			elems[i] = normalized
 		}
 		return elems, nil
 	default:
-		return value, nil
+		return tagFloats(value), nil
 	}
 }
 
-func convertProgress(raw *C.ProgressResult) (Progress, error) {
+func convertProgress(raw rawProgress, r *run) (Progress, error) {
 	progress := Progress{
-		Kind:       ProgressKind(raw.kind),
-		CallID:     uint32(raw.call_id),
-		MethodCall: raw.method_call != 0,
+		Kind:         raw.kind,
+		CallID:       raw.callID,
+		MethodCall:   raw.methodCall,
+		FunctionName: intern(raw.functionName),
+		OsFunction:   intern(raw.osFunction),
+	}
+	fail := func(err error) (Progress, error) {
+		raw.release(r.eng)
+		return Progress{}, err
+	}
+	if err := r.checkDepth("call arguments", raw.args, 1); err != nil {
+		return fail(err)
+	}
+	if err := r.checkDepth("call arguments", raw.kwargs, 2); err != nil {
+		return fail(err)
+	}
+	result := raw.result
+	if raw.resultStream != nil {
+		result = raw.resultStream.data
+	}
+	if err := r.checkDepth("result", result, 0); err != nil {
+		return fail(err)
 	}
 
-	if raw.result_json != nil {
-		obj, err := decodeObjectString(C.GoString(raw.result_json))
+	if raw.resultStream != nil {
+		progress.ResultStream = raw.resultStream
+	} else if max := r.cfg.streamResults; max > 0 && int64(len(raw.result)) > max {
+		progress.ResultStream = newResultStream(raw.result, nil)
+	} else if raw.result != nil {
+		obj, err := decodeObjectString(string(raw.result))
 		if err != nil {
-			return Progress{}, err
+			return fail(err)
 		}
 		progress.Result = obj
 	}
-	if raw.function_name != nil {
-		progress.FunctionName = C.GoString(raw.function_name)
-	}
-	if raw.os_function != nil {
-		progress.OsFunction = C.GoString(raw.os_function)
-	}
-	if raw.args_json != nil {
-		args, err := decodeObjectArrayString(C.GoString(raw.args_json))
+	if raw.args != nil {
+		args, err := decodeObjectArrayString(string(raw.args))
 		if err != nil {
-			return Progress{}, err
+			return fail(err)
 		}
 		progress.Args = args
 	}
-	if raw.kwargs_json != nil {
-		kwargs, err := decodeKwargsString(C.GoString(raw.kwargs_json))
+	if raw.kwargs != nil {
+		kwargs, err := decodeKwargsString(string(raw.kwargs))
 		if err != nil {
-			return Progress{}, err
+			return fail(err)
 		}
 		progress.Kwargs = kwargs
 	}
-	if raw.pending_call_ids_json != nil {
-		ids, err := decodeUint32ArrayString(C.GoString(raw.pending_call_ids_json))
+	if raw.pendingIDs != nil {
+		ids, err := decodeUint32ArrayString(string(raw.pendingIDs))
 		if err != nil {
-			return Progress{}, err
+			return fail(err)
 		}
 		progress.PendingIDs = ids
 	}
 	if raw.snapshot != nil {
-		progress.Snapshot = newSnapshot(raw.snapshot)
-		raw.snapshot = nil
-	}
-	if raw.future_snapshot != nil {
-		progress.FutureSnapshot = newFutureSnapshot(raw.future_snapshot, progress.PendingIDs)
-		raw.future_snapshot = nil
-	}
-	return progress, nil
-}
-
-func cString(value string) (*C.char, func()) {
-	cstr := C.CString(value)
-	return cstr, func() {
-		C.free(unsafe.Pointer(cstr))
-	}
-}
-
-func cBytes(data []byte) (*C.char, func()) {
-	if len(data) == 0 {
-		cstr := C.CString("")
-		return cstr, func() { C.free(unsafe.Pointer(cstr)) }
-	}
-	cstr := C.CString(string(data))
-	return cstr, func() { C.free(unsafe.Pointer(cstr)) }
-}
-
-func cStringArray(values []string) (**C.char, func()) {
-	if len(values) == 0 {
-		return nil, func() {}
-	}
-	items := make([]*C.char, len(values)+1)
-	for i, v := range values {
-		items[i] = C.CString(v)
-	}
-	items[len(values)] = nil
-	return (**C.char)(unsafe.Pointer(&items[0])), func() {
-		for _, ptr := range items[:len(values)] {
-			C.free(unsafe.Pointer(ptr))
+		progress.Snapshot = newSnapshot(raw.snapshot, r)
+		progress.Snapshot.call = PendingCall{
+			CallID:       progress.CallID,
+			FunctionName: progress.FunctionName,
+			MethodCall:   progress.MethodCall,
+			Args:         progress.Args,
+			Kwargs:       progress.Kwargs,
+			CreatedAt:    r.now(),
 		}
 	}
-}
-
-func statusError(status C.MontyStatus) error {
-	if status.ok != 0 {
-		return nil
+	if raw.futureSnapshot != nil {
+		progress.FutureSnapshot = newFutureSnapshot(raw.futureSnapshot, progress.PendingIDs, r)
 	}
-	var message string
-	if status.error != nil {
-		message = C.GoString(status.error)
-		C.monty_free_string(status.error)
-	} else {
-		message = "monty: unknown error"
+	if err := r.checkPause(progress); err != nil {
+		closeProgress(progress)
+		return Progress{}, err
 	}
-	return errors.New(message)
+	return progress, nil
 }
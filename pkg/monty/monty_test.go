@@ -1,6 +1,10 @@
 package monty
 
-import "testing"
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
 
 func TestMontyRunComplete(t *testing.T) {
 	m := newTestMonty(t, "x + 1", []string{"x"}, nil)
@@ -107,12 +111,38 @@ func TestExternalCallResumeExample(t *testing.T) {
 	}
 }
 
-func newTestMonty(t *testing.T, code string, inputs, exts []string) *Monty {
+func newTestMonty(t *testing.T, code string, inputs, exts []string, opts ...Option) *Monty {
 	t.Helper()
-	m, err := New(code, "test.py", inputs, exts)
+	m, err := New(code, "test.py", inputs, exts, opts...)
+	if errors.Is(err, ErrUnavailable) {
+		t.Skip("native library unavailable")
+	}
 	if err != nil {
 		t.Fatalf("New failed: %v", err)
 	}
 	t.Cleanup(func() { m.Close() })
 	return m
 }
+
+func TestRawJSONInputsPassThrough(t *testing.T) {
+	body := json.RawMessage(`{"b": 1,  "a": [1.50, "x"]}`)
+	data, err := marshalInputs([]any{body, Object(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"b": 1,  "a": [1.50, "x"]},null]`; string(data) != want {
+		t.Fatalf("marshalInputs = %s, want %s", data, want)
+	}
+	if _, err := marshalValue(json.RawMessage(`{"a":`)); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("invalid raw JSON: expected ErrInvalidInput, got %v", err)
+	}
+	if _, err := marshalValue([]Object{Object(`1`), Object(`[`)}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("invalid Object in a list: expected ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestProgressKindString(t *testing.T) {
+	if FunctionCall.String() != "function_call" || ResolveFutures.String() != "resolve_futures" || ProgressKind(9).String() != "unknown(9)" {
+		t.Fatalf("unexpected names %v %v %v", FunctionCall, ResolveFutures, ProgressKind(9))
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/ricochet1k/monty-go/pkg/monty/montypb
+
+go 1.23
+
+require (
+	github.com/ricochet1k/monty-go v0.0.0-20261016142615-7e3c4211d52f
+	google.golang.org/protobuf v1.36.5
+)
@@ -0,0 +1,63 @@
+// Package montypb passes protobuf messages to and from scripts. Messages
+// cross the boundary as dicts keyed by proto field names, converted with
+// protojson, so services with proto-first data models need not map fields
+// by hand:
+//
+//	montypb.Register()                // proto.Message inputs and results convert automatically
+//	result, err := runner.Run(ctx, req)
+//	var resp pb.Response
+//	err = montypb.Unmarshal(result, &resp)
+//
+// protojson encodes 64-bit integers as strings and enums by name, and the
+// script sees them that way.
+package montypb
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+// MarshalOptions converts messages for scripts. Unpopulated fields are
+// included so scripts can index any declared field.
+var MarshalOptions = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+// UnmarshalOptions converts script values back into messages.
+var UnmarshalOptions = protojson.UnmarshalOptions{}
+
+// Value converts m into the dict a script receives.
+func Value(m proto.Message) (monty.Object, error) {
+	return MarshalOptions.Marshal(m)
+}
+
+// Unmarshal decodes a script value, such as a run result or call argument,
+// into m. Dict keys may use proto field names or JSON names.
+func Unmarshal(o monty.Object, m proto.Message) error {
+	plain, err := o.Plain()
+	if err != nil {
+		return err
+	}
+	return UnmarshalOptions.Unmarshal(plain, m)
+}
+
+var registerOnce sync.Once
+
+// Register installs a monty.Converter so that proto.Message values passed as
+// Start inputs, call results, and future results are converted with Value.
+// Messages nested inside other values must be converted explicitly. It is
+// safe to call more than once.
+func Register() {
+	registerOnce.Do(func() {
+		monty.RegisterConverter(func(v any) ([]byte, bool, error) {
+			m, ok := v.(proto.Message)
+			if !ok {
+				return nil, false, nil
+			}
+			data, err := Value(m)
+			return data, true, err
+		})
+	})
+}
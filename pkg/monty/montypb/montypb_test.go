@@ -0,0 +1,58 @@
+package montypb
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+	"github.com/ricochet1k/monty-go/pkg/monty/montytest"
+)
+
+func TestRoundTrip(t *testing.T) {
+	in := &descriptorpb.FieldDescriptorProto{Name: proto.String("user_id"), Number: proto.Int32(3), JsonName: proto.String("userId")}
+	obj, err := Value(in)
+	if err != nil {
+		t.Fatalf("Value failed: %v", err)
+	}
+	if got := obj.Get("json_name"); string(got) != `"userId"` {
+		t.Fatalf("expected proto field names, got %s", obj)
+	}
+
+	// Scripts return dicts in the interpreter's tagged encoding.
+	result := monty.Object(`{"$dict": [["name", "total"], ["number", 7], ["label", "LABEL_REPEATED"]]}`)
+	var out descriptorpb.FieldDescriptorProto
+	if err := Unmarshal(result, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.GetName() != "total" || out.GetNumber() != 7 || out.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		t.Fatalf("unexpected message %v", &out)
+	}
+}
+
+func TestRegisterConvertsHandlerResults(t *testing.T) {
+	Register()
+	Register()
+	msg := &descriptorpb.FieldDescriptorProto{Name: proto.String("id")}
+	want, err := Value(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := montytest.New(t)
+	s.Call("field", "id").WantResult(want)
+	s.Complete(nil)
+	m, err := monty.New("field('id')", "main.py", nil, []string{"field"}, s.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	r := monty.NewRunner(m)
+	r.RegisterFunc("field", func(ctx context.Context, name string) (*descriptorpb.FieldDescriptorProto, error) {
+		return &descriptorpb.FieldDescriptorProto{Name: proto.String(name)}, nil
+	}, monty.Param{Name: "name"})
+	if _, err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package montytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+var update = flag.Bool("montytest.update", false, "rewrite golden files with the current transcripts")
+
+// Answer responds to a call made during Record. A nil value answers None.
+type Answer func(call monty.CallInfo) (any, error)
+
+// Record runs m with inputs, answering each call with answer, and returns a
+// canonical transcript of the run: one line per progress event, with every
+// value re-encoded as compact JSON with sorted object keys. Sleeps are
+// recorded and woken immediately, so transcripts do not depend on timing.
+//
+//	start [7]
+//	call fetch("a", retries=2) -> {"retries":2,"url":"a"}
+//	os call pathlib.Path.read_text("/etc/hostname") raise "denied"
+//	sleep 1.5s
+//	complete 42
+func Record(m *monty.Monty, answer Answer, inputs ...any) (string, error) {
+	var b strings.Builder
+	args := make([]monty.Object, len(inputs))
+	for i, in := range inputs {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return "", err
+		}
+		args[i] = data
+	}
+	fmt.Fprintf(&b, "start [%s]\n", joinValues(args))
+	p, err := m.Start(inputs...)
+	for err == nil {
+		switch p.Kind {
+		case monty.Complete:
+			fmt.Fprintf(&b, "complete %s\n", canonical(p.Result))
+			return b.String(), nil
+		case monty.FunctionCall, monty.OsCall:
+			call := monty.CallInfo{Kind: p.Kind, Name: p.FunctionName, Args: p.Args, Kwargs: p.Kwargs, CallID: p.CallID, MethodCall: p.MethodCall}
+			prefix := "call"
+			if p.Kind == monty.OsCall {
+				call.Name, prefix = p.OsFunction, "os call"
+			} else if p.MethodCall {
+				prefix = "method call"
+			}
+			fmt.Fprintf(&b, "%s %s(%s)", prefix, call.Name, joinCall(call))
+			value, herr := answer(call)
+			if herr != nil {
+				fmt.Fprintf(&b, " raise %q\n", herr.Error())
+				p, err = p.Snapshot.ResumeError(p.CallID, herr.Error())
+				continue
+			}
+			data, merr := json.Marshal(value)
+			if merr != nil {
+				p.Snapshot.Close()
+				return b.String(), merr
+			}
+			fmt.Fprintf(&b, " -> %s\n", canonical(data))
+			p, err = p.Snapshot.Resume(p.CallID, json.RawMessage(data))
+		case monty.Timer:
+			fmt.Fprintf(&b, "sleep %s\n", p.Duration)
+			p, err = p.Snapshot.Wake(p.CallID)
+		default:
+			p.Snapshot.Close()
+			p.FutureSnapshot.Close()
+			return b.String(), fmt.Errorf("montytest: cannot record progress kind %v", p.Kind)
+		}
+	}
+	fmt.Fprintf(&b, "error %q\n", err.Error())
+	return b.String(), err
+}
+
+// Golden compares got with testdata/<name>.golden and fails t with a line
+// diff when they differ. Running the tests with -montytest.update rewrites
+// the file instead.
+func Golden(t testing.TB, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("montytest: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("montytest: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("montytest: %v (run with -montytest.update to create it)", err)
+	}
+	if string(want) != got {
+		t.Errorf("montytest: %s differs from golden file (-want +got):\n%s", path, diff(string(want), got))
+	}
+}
+
+// canonical re-encodes a JSON value compactly with sorted keys.
+func canonical(o monty.Object) string {
+	if len(o) == 0 {
+		return "null"
+	}
+	dec := json.NewDecoder(bytes.NewReader(o))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return string(o)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return string(o)
+	}
+	return string(data)
+}
+
+func joinValues(objs []monty.Object) string {
+	parts := make([]string, len(objs))
+	for i, o := range objs {
+		parts[i] = canonical(o)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func joinCall(call monty.CallInfo) string {
+	parts := []string{joinValues(call.Args)}
+	if len(call.Args) == 0 {
+		parts = parts[:0]
+	}
+	for _, kv := range call.Kwargs {
+		key := canonical(kv.Key)
+		var s string
+		if kv.Key.Unmarshal(&s) == nil {
+			key = s
+		}
+		parts = append(parts, key+"="+canonical(kv.Value))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// diff returns a minimal line diff of a and b.
+func diff(a, b string) string {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			fmt.Fprintf(&out, "  %s\n", x[i])
+			i++
+			j++
+		case j < len(y) && (i == len(x) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Fprintf(&out, "+ %s\n", y[j])
+			j++
+		default:
+			fmt.Fprintf(&out, "- %s\n", x[i])
+			i++
+		}
+	}
+	return out.String()
+}
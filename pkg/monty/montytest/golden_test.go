@@ -0,0 +1,45 @@
+package montytest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+func TestRecordGolden(t *testing.T) {
+	s := New(t)
+	s.Call("fetch", "a").Kwarg("retries", 2)
+	s.OsCall("pathlib.Path.read_text", "/etc/hostname")
+	s.Sleep(1500 * time.Millisecond)
+	s.Complete(map[string]any{"z": 1, "a": []int{1, 2}})
+
+	m, err := monty.New("...", "main.py", []string{"x"}, []string{"fetch"}, s.Option())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+	got, err := Record(m, func(call monty.CallInfo) (any, error) {
+		if call.Kind == monty.OsCall {
+			return nil, errors.New("denied")
+		}
+		return map[string]any{"url": "a", "retries": 2}, nil
+	}, 7)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	Golden(t, "record", got)
+}
+
+func TestDiff(t *testing.T) {
+	got := diff("a\nb\nc", "a\nc\nd")
+	want := "  a\n- b\n  c\n+ d\n"
+	if got != want {
+		t.Fatalf("unexpected diff:\n%s", got)
+	}
+	if strings.Contains(diff("x", "x"), "+") {
+		t.Fatalf("identical inputs should not differ")
+	}
+}
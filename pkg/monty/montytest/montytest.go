@@ -0,0 +1,302 @@
+// Package montytest fakes the interpreter for unit tests of host code. A
+// Script lists the calls a program is expected to make and the result it
+// completes with; programs compiled with Script.Option replay that sequence
+// through the real monty API, so Runners, handlers, policies, and virtualized
+// OS calls all behave as they would against the native library, which need
+// not be present.
+//
+//	s := montytest.New(t)
+//	s.Call("fetch", "https://example.com").WantResult(map[string]any{"status": 200})
+//	s.Complete("ok")
+//	m, _ := monty.New("...", "main.py", nil, []string{"fetch"}, s.Option())
+package montytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+// Script is a scripted fake program. It is safe for concurrent use, but its
+// steps are consumed in order by whichever run asks first.
+type Script struct {
+	t testing.TB
+
+	mu      sync.Mutex
+	steps   []*Step
+	pos     int
+	handles uint64
+	inputs  [][]json.RawMessage
+	sandbox *monty.Sandbox
+}
+
+// Step is one scripted progress event.
+type Step struct {
+	kind       monty.ProgressKind
+	name       string
+	args       []json.RawMessage
+	kwargs     [][2]json.RawMessage
+	methodCall bool
+	result     json.RawMessage
+
+	wantResult json.RawMessage
+	wantError  *string
+}
+
+// New returns an empty script that reports problems to t and, when t
+// finishes, fails if any step was not reached.
+func New(t testing.TB) *Script {
+	s := &Script{t: t}
+	s.sandbox = monty.NewSandboxBridge(s.roundTrip)
+	t.Cleanup(s.Verify)
+	return s
+}
+
+// Option makes monty.New and the snapshot loaders use the script.
+func (s *Script) Option() monty.Option {
+	return monty.WithSandbox(s.sandbox)
+}
+
+// Call scripts an external function call.
+func (s *Script) Call(name string, args ...any) *Step {
+	return s.add(&Step{kind: monty.FunctionCall, name: name, args: s.values(args)})
+}
+
+// OsCall scripts an OS call such as "os.getenv".
+func (s *Script) OsCall(name string, args ...any) *Step {
+	return s.add(&Step{kind: monty.OsCall, name: name, args: s.values(args)})
+}
+
+// Sleep scripts time.sleep(d), which the host sees as a Timer event.
+func (s *Script) Sleep(d time.Duration) *Step {
+	return s.OsCall(monty.OsSleep, d.Seconds())
+}
+
+// Complete scripts the end of a run with result.
+func (s *Script) Complete(result any) {
+	s.add(&Step{kind: monty.Complete, result: s.value(result)})
+}
+
+// Kwarg adds a keyword argument to a call.
+func (st *Step) Kwarg(key string, value any) *Step {
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		panic(fmt.Sprintf("montytest: kwarg %s: %v", key, err))
+	}
+	st.kwargs = append(st.kwargs, [2]json.RawMessage{k, v})
+	return st
+}
+
+// Method marks a call as a method call.
+func (st *Step) Method() *Step {
+	st.methodCall = true
+	return st
+}
+
+// WantResult expects the host to answer the call with a value JSON-equal to v.
+func (st *Step) WantResult(v any) *Step {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("montytest: want result: %v", err))
+	}
+	st.wantResult = data
+	return st
+}
+
+// WantError expects the host to answer the call by raising an error whose
+// message contains substr.
+func (st *Step) WantError(substr string) *Step {
+	st.wantError = &substr
+	return st
+}
+
+// Inputs returns the inputs of every run started so far.
+func (s *Script) Inputs() [][]json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]json.RawMessage(nil), s.inputs...)
+}
+
+// Verify fails the test if any scripted step was not reached. New registers
+// it as a cleanup.
+func (s *Script) Verify() {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pos < len(s.steps) {
+		s.t.Errorf("montytest: %d of %d scripted steps not reached; next is %s", len(s.steps)-s.pos, len(s.steps), s.steps[s.pos])
+	}
+}
+
+func (st *Step) String() string {
+	switch st.kind {
+	case monty.Complete:
+		return fmt.Sprintf("complete(%s)", st.result)
+	case monty.OsCall:
+		return fmt.Sprintf("os call %s", st.name)
+	}
+	return fmt.Sprintf("call %s", st.name)
+}
+
+func (s *Script) add(st *Step) *Step {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.steps = append(s.steps, st)
+	return st
+}
+
+func (s *Script) value(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		s.t.Fatalf("montytest: %v", err)
+	}
+	return data
+}
+
+func (s *Script) values(vs []any) []json.RawMessage {
+	out := make([]json.RawMessage, len(vs))
+	for i, v := range vs {
+		out[i] = s.value(v)
+	}
+	return out
+}
+
+// request and response mirror the sandbox protocol monty.SandboxHost speaks.
+type request struct {
+	Op      string          `json:"op"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	CallID  uint32          `json:"call_id,omitempty"`
+	ErrMsg  string          `json:"error,omitempty"`
+}
+
+type response struct {
+	Err      string    `json:"error,omitempty"`
+	Handle   uint64    `json:"handle,omitempty"`
+	Data     []byte    `json:"data,omitempty"`
+	Size     int64     `json:"size,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	ABI      uint32    `json:"abi,omitempty"`
+	Progress *progress `json:"progress,omitempty"`
+}
+
+type progress struct {
+	Kind         monty.ProgressKind `json:"kind"`
+	CallID       uint32             `json:"call_id"`
+	MethodCall   bool               `json:"method_call,omitempty"`
+	Result       json.RawMessage    `json:"result,omitempty"`
+	FunctionName string             `json:"function_name,omitempty"`
+	OsFunction   string             `json:"os_function,omitempty"`
+	Args         json.RawMessage    `json:"args,omitempty"`
+	Kwargs       json.RawMessage    `json:"kwargs,omitempty"`
+	Snapshot     uint64             `json:"snapshot,omitempty"`
+}
+
+func (s *Script) roundTrip(data []byte) ([]byte, error) {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	resp := s.serve(req)
+	s.mu.Unlock()
+	return json.Marshal(resp)
+}
+
+func (s *Script) serve(req request) response {
+	switch req.Op {
+	case "version":
+		return response{Version: "montytest", ABI: monty.ABIVersion}
+	case "compile", "load_run", "load_snapshot", "load_future_snapshot":
+		s.handles++
+		return response{Handle: s.handles}
+	case "dump_run", "dump_snapshot", "dump_future_snapshot":
+		return response{Data: []byte("montytest")}
+	case "snapshot_size", "future_snapshot_size":
+		return response{Size: int64(len("montytest"))}
+	case "mem_stats", "free_run", "free_snapshot", "free_future_snapshot":
+		return response{}
+	case "start":
+		var inputs []json.RawMessage
+		json.Unmarshal(req.Payload, &inputs)
+		s.inputs = append(s.inputs, inputs)
+		return s.next()
+	case "resume":
+		if err := s.check(req); err != nil {
+			s.t.Errorf("montytest: %v", err)
+			return response{Err: err.Error()}
+		}
+		return s.next()
+	}
+	err := fmt.Sprintf("montytest: operation %s is not supported", req.Op)
+	s.t.Errorf("%s", err)
+	return response{Err: err}
+}
+
+// check compares the host's answer with the expectations of the current call.
+func (s *Script) check(req request) error {
+	if s.pos == 0 {
+		return fmt.Errorf("resumed before any step was reached")
+	}
+	st := s.steps[s.pos-1]
+	if req.ErrMsg != "" {
+		if st.wantError == nil && st.wantResult != nil {
+			return fmt.Errorf("%s: expected result %s, host raised %q", st, st.wantResult, req.ErrMsg)
+		}
+		if st.wantError != nil && !strings.Contains(req.ErrMsg, *st.wantError) {
+			return fmt.Errorf("%s: expected error containing %q, host raised %q", st, *st.wantError, req.ErrMsg)
+		}
+		return nil
+	}
+	if st.wantError != nil {
+		return fmt.Errorf("%s: expected error containing %q, host returned %s", st, *st.wantError, req.Payload)
+	}
+	if st.wantResult != nil && !jsonEqual(st.wantResult, req.Payload) {
+		return fmt.Errorf("%s: expected result %s, host returned %s", st, st.wantResult, req.Payload)
+	}
+	return nil
+}
+
+func (s *Script) next() response {
+	if s.pos >= len(s.steps) {
+		err := "montytest: program ran past the end of the script"
+		s.t.Errorf("%s", err)
+		return response{Err: err}
+	}
+	st := s.steps[s.pos]
+	s.pos++
+	p := &progress{Kind: st.kind, CallID: uint32(s.pos), MethodCall: st.methodCall}
+	switch st.kind {
+	case monty.Complete:
+		p.Result = st.result
+	default:
+		if st.kind == monty.OsCall {
+			p.OsFunction = st.name
+		} else {
+			p.FunctionName = st.name
+		}
+		p.Args, _ = json.Marshal(st.args)
+		kwargs := st.kwargs
+		if kwargs == nil {
+			kwargs = [][2]json.RawMessage{}
+		}
+		p.Kwargs, _ = json.Marshal(kwargs)
+		s.handles++
+		p.Snapshot = s.handles
+	}
+	return response{Progress: p}
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,77 @@
+package montytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+func TestScriptDrivesRunner(t *testing.T) {
+	s := New(t)
+	s.Call("fetch", "a").Kwarg("retries", 2).WantResult(map[string]any{"url": "a", "retries": 2.0})
+	s.Call("store", 1).WantError("denied")
+	s.Sleep(time.Millisecond)
+	s.Complete(42)
+
+	m, err := monty.New("...", "main.py", []string{"x"}, []string{"fetch", "store"}, s.Option())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+	r := monty.NewRunner(m)
+	r.Register("fetch", func(_ context.Context, call monty.CallInfo) (any, error) {
+		var url string
+		var retries int
+		call.Args[0].Unmarshal(&url)
+		call.Kwargs[0].Value.Unmarshal(&retries)
+		return map[string]any{"url": url, "retries": retries}, nil
+	})
+	r.Register("store", func(context.Context, monty.CallInfo) (any, error) {
+		return nil, errors.New("access denied")
+	})
+	result, err := r.Run(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if string(result) != "42" {
+		t.Fatalf("expected 42, got %s", result)
+	}
+	if inputs := s.Inputs(); len(inputs) != 1 || string(inputs[0][0]) != "7" {
+		t.Fatalf("unexpected inputs: %s", inputs)
+	}
+}
+
+func TestScriptReportsWrongAnswer(t *testing.T) {
+	ft := &fakeTB{}
+	s := New(ft)
+	s.Call("fetch").WantResult(1)
+	s.Complete(nil)
+	m, _ := monty.New("...", "main.py", nil, []string{"fetch"}, s.Option())
+	p, err := m.Start()
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := p.Snapshot.Resume(p.CallID, 2); err == nil {
+		t.Fatalf("expected resume with the wrong value to fail")
+	}
+	if len(ft.errors) != 1 {
+		t.Fatalf("expected one reported error, got %v", ft.errors)
+	}
+}
+
+// fakeTB records errors instead of failing the enclosing test.
+type fakeTB struct {
+	testing.TB
+	errors []string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Cleanup(func()) {}
+
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, format)
+}
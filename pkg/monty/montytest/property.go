@@ -0,0 +1,328 @@
+package montytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+// Schema describes the values an input accepts. Schemas are usually parsed
+// from the script's own annotations by InputSchema; ParseSchema accepts the
+// same Python annotation syntax:
+//
+//	int  float  str  bool  None  Any
+//	list[T]  tuple[T, ...]  tuple[A, B]  dict[str, T]
+//	Optional[T]  Union[A, B]  A | B  Literal["a", 1]
+//
+// typing aliases such as List and Dict are accepted too.
+type Schema struct {
+	// Type is "int", "float", "str", "bool", "none", "any", "list", "tuple",
+	// "dict", "union", or "literal".
+	Type string
+	// Elems holds the element type of a list, the value type of a dict, the
+	// items of a tuple (one, repeated, when Variadic), or the options of a
+	// union.
+	Elems    []Schema
+	Variadic bool
+	// Values holds the choices of a literal.
+	Values []any
+}
+
+// ParseSchema parses a Python type annotation. An empty annotation accepts
+// any value.
+func ParseSchema(annotation string) (Schema, error) {
+	if strings.TrimSpace(annotation) == "" {
+		return Schema{Type: "any"}, nil
+	}
+	t, err := monty.ParseType(annotation)
+	if err != nil {
+		return Schema{}, err
+	}
+	s, err := schemaOf(t)
+	if err != nil {
+		return Schema{}, fmt.Errorf("montytest: annotation %q: %w", annotation, err)
+	}
+	return s, nil
+}
+
+// InputSchema returns the schema of each of m's inputs, in order, from the
+// top-level `name: type` annotations of its source. Unannotated inputs
+// accept any value.
+func InputSchema(m *monty.Monty) ([]Schema, error) {
+	doc, err := m.Docs()
+	if err != nil {
+		return nil, err
+	}
+	schemas := make([]Schema, len(doc.Inputs))
+	for i, in := range doc.Inputs {
+		if schemas[i], err = ParseSchema(in.Annotation); err != nil {
+			return nil, err
+		}
+	}
+	return schemas, nil
+}
+
+// Generate returns a random value matching s, encodable as a Start input.
+// size bounds the magnitude of numbers and the length of strings and
+// containers, as in testing/quick.
+func (s Schema) Generate(r *rand.Rand, size int) any {
+	size = max(size, 0)
+	switch s.Type {
+	case "int":
+		if r.Intn(8) == 0 {
+			return []int64{0, -1, 1, math.MaxInt32, math.MinInt64}[r.Intn(5)]
+		}
+		return r.Int63n(int64(2*size+1)) - int64(size)
+	case "float":
+		if r.Intn(8) == 0 {
+			return []float64{0, -0.5, 1e-300, 1e300}[r.Intn(4)]
+		}
+		return r.NormFloat64() * float64(size)
+	case "str":
+		runes := make([]rune, r.Intn(size+1))
+		for i := range runes {
+			runes[i] = randomRune(r)
+		}
+		return string(runes)
+	case "bool":
+		return r.Intn(2) == 1
+	case "none":
+		return nil
+	case "list":
+		out := make([]any, r.Intn(size+1))
+		for i := range out {
+			out[i] = s.Elems[0].Generate(r, size/2)
+		}
+		return out
+	case "tuple":
+		if s.Variadic {
+			return Schema{Type: "list", Elems: s.Elems}.Generate(r, size)
+		}
+		out := make([]any, len(s.Elems))
+		for i, e := range s.Elems {
+			out[i] = e.Generate(r, size/2)
+		}
+		return out
+	case "dict":
+		out := make(map[string]any)
+		for n := r.Intn(size + 1); n > 0; n-- {
+			out[Schema{Type: "str"}.Generate(r, size).(string)] = s.Elems[0].Generate(r, size/2)
+		}
+		return out
+	case "union":
+		return s.Elems[r.Intn(len(s.Elems))].Generate(r, size)
+	case "literal":
+		return s.Values[r.Intn(len(s.Values))]
+	}
+	return anySchemas[r.Intn(len(anySchemas))].Generate(r, size)
+}
+
+// anySchemas are the shapes an unannotated input is drawn from.
+var anySchemas = []Schema{
+	{Type: "int"}, {Type: "float"}, {Type: "str"}, {Type: "bool"}, {Type: "none"},
+	{Type: "list", Elems: []Schema{{Type: "int"}}},
+	{Type: "dict", Elems: []Schema{{Type: "str"}}},
+}
+
+// randomRune favors ASCII but mixes in the characters encoders get wrong.
+func randomRune(r *rand.Rand) rune {
+	switch r.Intn(10) {
+	case 0:
+		return []rune{0, '"', '\\', '\n', 'é', '漢', '🙂'}[r.Intn(7)]
+	case 1:
+		for {
+			if c := rune(r.Intn(0x10000)); unicode.IsPrint(c) {
+				return c
+			}
+		}
+	}
+	return rune(' ' + r.Intn(95))
+}
+
+// Property checks one randomized run. It receives the inputs the run started
+// with and the run's result or error, and returns an error describing any
+// violated invariant.
+type Property func(inputs []any, result monty.Object, err error) error
+
+// Config tunes Check. Zero values pick the defaults.
+type Config struct {
+	// Runs is the number of executions; the default is 100.
+	Runs int
+	// Seed seeds the generator; the default is the current time. Failures
+	// report the seed so they can be reproduced.
+	Seed int64
+	// MaxSize is the size reached by the last run; the default is 50.
+	MaxSize int
+	// Schema overrides InputSchema(m).
+	Schema []Schema
+	// Answer responds to the calls the script makes. Without one, every
+	// call raises RuntimeError.
+	Answer Answer
+}
+
+// Check runs m Runs times with random inputs drawn from its input schema,
+// growing their size from run to run, and fails t at the first run whose
+// outcome violates prop. Calls are answered by cfg.Answer and sleeps are
+// woken immediately.
+//
+//	montytest.Check(t, m, montytest.Config{}, func(in []any, got monty.Object, err error) error {
+//		if err != nil {
+//			return err
+//		}
+//		return nil
+//	})
+func Check(t testing.TB, m *monty.Monty, cfg Config, prop Property) {
+	t.Helper()
+	schemas := cfg.Schema
+	if schemas == nil {
+		var err error
+		if schemas, err = InputSchema(m); err != nil {
+			t.Fatalf("montytest: input schema: %v", err)
+			return
+		}
+	}
+	if cfg.Runs <= 0 {
+		cfg.Runs = 100
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 50
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(cfg.Seed))
+	for i := 0; i < cfg.Runs; i++ {
+		size := 1 + i*cfg.MaxSize/cfg.Runs
+		inputs := make([]any, len(schemas))
+		for j, s := range schemas {
+			inputs[j] = s.Generate(r, size)
+		}
+		result, err := execute(m, cfg.Answer, inputs)
+		if perr := prop(inputs, result, err); perr != nil {
+			data, _ := json.Marshal(inputs)
+			t.Fatalf("montytest: run %d of %d (seed %d) failed on inputs %s: %v", i+1, cfg.Runs, cfg.Seed, data, perr)
+			return
+		}
+	}
+}
+
+// execute runs m to completion, answering calls with answer.
+func execute(m *monty.Monty, answer Answer, inputs []any) (monty.Object, error) {
+	p, err := m.Start(inputs...)
+	for err == nil {
+		switch p.Kind {
+		case monty.Complete:
+			return p.Result, nil
+		case monty.FunctionCall, monty.OsCall:
+			call := monty.CallInfo{Kind: p.Kind, Name: p.FunctionName, Args: p.Args, Kwargs: p.Kwargs, CallID: p.CallID, MethodCall: p.MethodCall}
+			if p.Kind == monty.OsCall {
+				call.Name = p.OsFunction
+			}
+			if answer == nil {
+				p, err = p.Snapshot.ResumeError(p.CallID, "RuntimeError: no answer for "+call.Name)
+				continue
+			}
+			value, herr := answer(call)
+			if herr != nil {
+				p, err = p.Snapshot.ResumeError(p.CallID, herr.Error())
+				continue
+			}
+			if value == nil {
+				value = json.RawMessage("null")
+			}
+			p, err = p.Snapshot.Resume(p.CallID, value)
+		case monty.Timer:
+			p, err = p.Snapshot.Wake(p.CallID)
+		default:
+			p.Snapshot.Close()
+			p.FutureSnapshot.Close()
+			return nil, fmt.Errorf("montytest: cannot check progress kind %v", p.Kind)
+		}
+	}
+	return nil, err
+}
+
+// schemaOf converts a parsed annotation into the Schema of its values.
+func schemaOf(t monty.TypeExpr) (Schema, error) {
+	elems := func(args []monty.TypeExpr) ([]Schema, error) {
+		out := make([]Schema, len(args))
+		for i, a := range args {
+			var err error
+			if out[i], err = schemaOf(a); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	bare := t.Args == nil
+	switch t.Name {
+	case "int", "float", "str", "bool", "Any":
+		if bare {
+			return Schema{Type: strings.ToLower(t.Name)}, nil
+		}
+	case "None", "NoneType":
+		if bare {
+			return Schema{Type: "none"}, nil
+		}
+	case "list", "List", "Sequence":
+		if bare {
+			return Schema{Type: "list", Elems: []Schema{{Type: "any"}}}, nil
+		}
+		if len(t.Args) != 1 || t.Ellipsis {
+			return Schema{}, fmt.Errorf("%s takes one type argument", t.Name)
+		}
+		es, err := elems(t.Args)
+		return Schema{Type: "list", Elems: es}, err
+	case "tuple", "Tuple":
+		if bare {
+			return Schema{Type: "tuple", Elems: []Schema{{Type: "any"}}, Variadic: true}, nil
+		}
+		if t.Ellipsis && len(t.Args) != 1 {
+			return Schema{}, fmt.Errorf("variadic tuple takes one type argument")
+		}
+		es, err := elems(t.Args)
+		return Schema{Type: "tuple", Elems: es, Variadic: t.Ellipsis}, err
+	case "dict", "Dict", "Mapping":
+		if bare {
+			return Schema{Type: "dict", Elems: []Schema{{Type: "any"}}}, nil
+		}
+		es, err := elems(t.Args)
+		switch {
+		case err != nil:
+		case len(es) != 2 || t.Ellipsis:
+			err = fmt.Errorf("%s takes key and value types", t.Name)
+		case es[0].Type != "str":
+			err = fmt.Errorf("input dict keys must be str")
+		}
+		if err != nil {
+			return Schema{}, err
+		}
+		return Schema{Type: "dict", Elems: es[1:]}, nil
+	case "Optional":
+		inner, ok := t.Optional()
+		if !ok {
+			return Schema{}, fmt.Errorf("Optional takes one type argument")
+		}
+		s, err := schemaOf(inner)
+		return Schema{Type: "union", Elems: []Schema{s, {Type: "none"}}}, err
+	case "Union", "|":
+		if bare || t.Ellipsis {
+			return Schema{}, fmt.Errorf("Union takes type arguments")
+		}
+		es, err := elems(t.Args)
+		return Schema{Type: "union", Elems: es}, err
+	case "Literal":
+		if t.Values == nil {
+			return Schema{}, fmt.Errorf("Literal takes values")
+		}
+		return Schema{Type: "literal", Values: t.Values}, nil
+	}
+	return Schema{}, fmt.Errorf("unsupported type %q", t.Name)
+}
@@ -0,0 +1,101 @@
+package montytest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+func TestParseSchema(t *testing.T) {
+	for ann, want := range map[string]string{
+		"int":                     `{"Type":"int"}`,
+		"list[str]":               `{"Type":"list","Elems":[{"Type":"str"}]}`,
+		"Optional[float]":         `{"Type":"union","Elems":[{"Type":"float"},{"Type":"none"}]}`,
+		"int | None":              `{"Type":"union","Elems":[{"Type":"int"},{"Type":"none"}]}`,
+		"typing.Dict[str, bool]":  `{"Type":"dict","Elems":[{"Type":"bool"}]}`,
+		"tuple[int, ...]":         `{"Type":"tuple","Elems":[{"Type":"int"}],"Variadic":true}`,
+		`Literal["a", 2, True]`:   `{"Type":"literal","Values":["a",2,true]}`,
+		"'list[tuple[int, str]]'": `{"Type":"list","Elems":[{"Type":"tuple","Elems":[{"Type":"int"},{"Type":"str"}]}]}`,
+		"":                        `{"Type":"any"}`,
+	} {
+		s, err := ParseSchema(ann)
+		if err != nil {
+			t.Errorf("ParseSchema(%q) failed: %v", ann, err)
+			continue
+		}
+		got, _ := json.Marshal(s)
+		got = []byte(strings.NewReplacer(`"Elems":null,`, "", `,"Elems":null`, "", `,"Variadic":false`, "", `,"Values":null`, "").Replace(string(got)))
+		if string(got) != want {
+			t.Errorf("ParseSchema(%q) = %s, want %s", ann, got, want)
+		}
+	}
+	for _, ann := range []string{"dict[int, str]", "list[int", "Widget", `Literal["a`} {
+		if _, err := ParseSchema(ann); err == nil {
+			t.Errorf("ParseSchema(%q) should fail", ann)
+		}
+	}
+}
+
+func TestGenerateMatchesSchema(t *testing.T) {
+	s, _ := ParseSchema("dict[str, list[int | None]]")
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		v := s.Generate(r, i)
+		for _, items := range v.(map[string]any) {
+			for _, item := range items.([]any) {
+				if _, ok := item.(int64); !ok && item != nil {
+					t.Fatalf("generated %#v for int | None", item)
+				}
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	const runs = 20
+	s := New(t)
+	for i := 0; i < runs; i++ {
+		s.Complete("ok")
+	}
+	m, err := monty.New("n: int\nnames: list[str]\n...", "main.py", []string{"n", "names"}, nil, s.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	Check(t, m, Config{Runs: runs, Seed: 7}, func(in []any, got monty.Object, err error) error {
+		if err != nil {
+			return err
+		}
+		if _, ok := in[0].(int64); !ok {
+			return errors.New("n is not an int")
+		}
+		if _, ok := in[1].([]any); !ok {
+			return errors.New("names is not a list")
+		}
+		return nil
+	})
+	if got := len(s.Inputs()); got != runs {
+		t.Fatalf("expected %d runs, got %d", runs, got)
+	}
+
+	ft := &fakeTB{}
+	s2 := New(t)
+	s2.Complete("bad")
+	m2, _ := monty.New("x: bool\n...", "main.py", []string{"x"}, nil, s2.Option())
+	defer m2.Close()
+	Check(ft, m2, Config{Runs: 1, Seed: 3}, func([]any, monty.Object, error) error {
+		return errors.New("invariant broken")
+	})
+	if len(ft.errors) == 0 || !strings.Contains(ft.errors[0], "seed 3") {
+		t.Fatalf("expected a failure naming the seed, got %q", ft.errors)
+	}
+}
+
+func (f *fakeTB) Fatalf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
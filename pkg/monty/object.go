@@ -1,8 +1,11 @@
 package monty
 
 import (
+	"bytes"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"io"
 )
 
 // Object is a thin wrapper around JSON returned by the FFI layer.
@@ -21,7 +24,70 @@ func (o Object) Unmarshal(target any) error {
 	if len(o) == 0 {
 		return fmt.Errorf("monty: empty object payload")
 	}
-	return json.Unmarshal(o, target)
+	return jsonUnmarshal(o, target)
+}
+
+// UnmarshalStrict is Unmarshal with numbers decoded into interface values
+// as json.Number rather than float64, so integers beyond 2^53, such as IDs
+// and big ints, keep every digit. Typed targets decode as with Unmarshal.
+// It always uses encoding/json; install NumberJSON to make every decode
+// behave this way.
+func (o Object) UnmarshalStrict(target any) error {
+	if len(o) == 0 {
+		return fmt.Errorf("monty: empty object payload")
+	}
+	return unmarshalNumbers(o, target)
+}
+
+// MarshalJSON returns the payload as is, so Objects nest inside values
+// encoded with encoding/json. An empty Object encodes as null.
+func (o Object) MarshalJSON() ([]byte, error) {
+	if len(o) == 0 {
+		return []byte("null"), nil
+	}
+	return o, nil
+}
+
+// UnmarshalJSON stores a copy of data.
+func (o *Object) UnmarshalJSON(data []byte) error {
+	*o = append(Object(nil), data...)
+	return nil
+}
+
+// String returns the JSON text, or "null" for an empty Object.
+func (o Object) String() string {
+	if len(o) == 0 {
+		return "null"
+	}
+	return string(o)
+}
+
+// Value stores the Object in a database as JSON text; an empty Object is
+// NULL.
+func (o Object) Value() (driver.Value, error) {
+	if len(o) == 0 {
+		return nil, nil
+	}
+	return string(o), nil
+}
+
+// Scan reads a JSON or text column. NULL yields an empty Object.
+func (o *Object) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*o = nil
+	case []byte:
+		*o = append(Object{}, v...)
+	case string:
+		*o = Object(v)
+	default:
+		return fmt.Errorf("monty: cannot scan %T into Object", src)
+	}
+	if len(*o) > 0 && !json.Valid(*o) {
+		*o = nil
+		return fmt.Errorf("monty: scanned column is not valid JSON")
+	}
+	return nil
 }
 
 func decodeObjectString(s string) (Object, error) {
@@ -36,7 +102,7 @@ func decodeObjectArrayString(s string) ([]Object, error) {
 		return nil, nil
 	}
 	var raw []json.RawMessage
-	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+	if err := jsonUnmarshal([]byte(s), &raw); err != nil {
 		return nil, err
 	}
 	out := make([]Object, len(raw))
@@ -51,7 +117,7 @@ func decodeKwargsString(s string) ([]KV, error) {
 		return nil, nil
 	}
 	var raw [][]json.RawMessage
-	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+	if err := jsonUnmarshal([]byte(s), &raw); err != nil {
 		return nil, err
 	}
 	kvs := make([]KV, len(raw))
@@ -72,7 +138,7 @@ func decodeUint32ArrayString(s string) ([]uint32, error) {
 		return nil, nil
 	}
 	var ids []uint32
-	if err := json.Unmarshal([]byte(s), &ids); err != nil {
+	if err := jsonUnmarshal([]byte(s), &ids); err != nil {
 		return nil, err
 	}
 	return ids, nil
@@ -83,8 +149,20 @@ func objectToInterface(obj Object) (any, error) {
 		return nil, nil
 	}
 	var value any
-	if err := json.Unmarshal(obj, &value); err != nil {
+	if err := jsonUnmarshal(obj, &value); err != nil {
 		return nil, err
 	}
 	return value, nil
 }
+
+func unmarshalNumbers(data []byte, target any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(target); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("monty: invalid character after top-level value")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package monty
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	_ json.Marshaler   = Object(nil)
+	_ json.Unmarshaler = (*Object)(nil)
+	_ fmt.Stringer     = Object(nil)
+	_ driver.Valuer    = Object(nil)
+	_ sql.Scanner      = (*Object)(nil)
+)
+
+func TestObjectStandardInterfaces(t *testing.T) {
+	data, err := json.Marshal(map[string]any{"result": Object(`{"a":1}`), "none": Object(nil)})
+	if err != nil || string(data) != `{"none":null,"result":{"a":1}}` {
+		t.Fatalf("Marshal = %s, %v", data, err)
+	}
+
+	var decoded struct{ Result Object }
+	if err := json.Unmarshal([]byte(`{"Result":[1,"x"]}`), &decoded); err != nil || decoded.Result.String() != `[1,"x"]` {
+		t.Fatalf("Unmarshal = %s, %v", decoded.Result, err)
+	}
+	if s := fmt.Sprint(Object(`"hi"`)); s != `"hi"` {
+		t.Fatalf("Sprint = %s", s)
+	}
+
+	if v, _ := Object(nil).Value(); v != nil {
+		t.Fatalf("empty Object should be NULL, got %v", v)
+	}
+	var o Object
+	if err := o.Scan([]byte(`{"b":2}`)); err != nil || string(o) != `{"b":2}` {
+		t.Fatalf("Scan = %s, %v", o, err)
+	}
+	if v, _ := o.Value(); v != `{"b":2}` {
+		t.Fatalf("Value = %v", v)
+	}
+	if err := o.Scan("not json"); err == nil {
+		t.Fatal("Scan should reject invalid JSON")
+	}
+	if err := o.Scan(nil); err != nil || o != nil {
+		t.Fatalf("Scan(nil) = %s, %v", o, err)
+	}
+}
+
+func TestObjectGet(t *testing.T) {
+	o := Object(`{"$dict": [
+		["user", {"$dict": [["name", "ada"], ["items", [{"price": 3}, {"price": 5.5}, {"$dict": [["price", 7], ["a.b", true]]}]]]}],
+		[2, {"$tuple": ["x", "y"]}],
+		["point", {"$named_tuple": {"type": "P", "field_names": ["x", "y"], "values": [1, 2]}}],
+		["cfg", {"$dataclass": {"name": "C", "type_id": 1, "field_names": ["debug"], "attrs": [["debug", false]], "frozen": true}}],
+		["plain", {"k": "v \" }"}]
+	]}`)
+	for path, want := range map[string]string{
+		"user.name":            `"ada"`,
+		"user.items[2].price":  `7`,
+		"user.items.1.price":   `5.5`,
+		"user.items[-3].price": `3`,
+		`user.items[2].a\.b`:   `true`,
+		"[2][1]":               `"y"`,
+		"2.0":                  `"x"`,
+		"point.y":              `2`,
+		"point[0]":             `1`,
+		"cfg.debug":            `false`,
+		"plain.k":              `"v \" }"`,
+		"user.items[3]":        ``,
+		"user.missing":         ``,
+		"user.name.first":      ``,
+		"user.items[x]":        ``,
+	} {
+		if got := o.Get(path); string(got) != want {
+			t.Errorf("Get(%q) = %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestObjectUnmarshalStrict(t *testing.T) {
+	o := Object(`{"id": 9007199254740993, "score": 1.5, "tags": [12345678901234567890]}`)
+	var loose map[string]any
+	if err := o.Unmarshal(&loose); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if _, ok := loose["id"].(float64); !ok {
+		t.Fatalf("Unmarshal should decode numbers as float64, got %T", loose["id"])
+	}
+	var strict map[string]any
+	if err := o.UnmarshalStrict(&strict); err != nil {
+		t.Fatalf("UnmarshalStrict failed: %v", err)
+	}
+	if strict["id"] != json.Number("9007199254740993") || strict["score"] != json.Number("1.5") {
+		t.Fatalf("UnmarshalStrict = %v", strict)
+	}
+	if tags := strict["tags"].([]any); tags[0] != json.Number("12345678901234567890") {
+		t.Fatalf("nested number = %v", tags[0])
+	}
+	var typed struct{ ID int64 }
+	if err := o.UnmarshalStrict(&typed); err != nil || typed.ID != 9007199254740993 {
+		t.Fatalf("typed UnmarshalStrict = %d, %v", typed.ID, err)
+	}
+	for _, bad := range []Object{nil, Object(`1 2`), Object(`{`)} {
+		var v any
+		if err := bad.UnmarshalStrict(&v); err == nil {
+			t.Errorf("UnmarshalStrict(%q) should fail", bad)
+		}
+	}
+}
+
+func TestObjectItems(t *testing.T) {
+	for o, want := range map[string]string{
+		` [1, "a", {"b": [2]}, []] `: `1|"a"|{"b": [2]}|[]`,
+		`{"$tuple":[1,2]}`:           `1|2`,
+		`{"$set":["x"]}`:             `"x"`,
+		`{"$named_tuple":{"type":"P","fields":["x"],"values":[3]}}`: `3`,
+		`[]`:      ``,
+		`{"a":1}`: ``,
+		`"s"`:     ``,
+	} {
+		var got []string
+		for item := range Object(o).Items() {
+			got = append(got, string(item))
+		}
+		if strings.Join(got, "|") != want {
+			t.Errorf("Items(%s) = %q, want %q", o, got, want)
+		}
+	}
+	n := 0
+	for range Object(`[1,2,3]`).Items() {
+		if n++; n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("break stopped after %d items", n)
+	}
+}
+
+func TestObjectMalformed(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, raw := range []string{`[:`, `[1:2]`, `[1,,]`, `{"a"::}`, `{"a":,"b":1}`, `[[`, `[`} {
+			o := Object(raw)
+			o.Get("[-1]")
+			o.Get("a")
+			for range o.Items() {
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("walking malformed objects did not terminate")
+	}
+}
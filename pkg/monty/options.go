@@ -0,0 +1,196 @@
+package monty
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Option configures how a compiled program executes.
+type Option func(*config)
+
+type config struct {
+	eng     engine
+	newRand func() *rand.Rand
+	env     map[string]string
+	hostEnv map[string]bool
+	http    *httpTransport
+	policy  *Policy
+	limits  Limits
+
+	noFinalizer   bool
+	deterministic bool
+	profileLabels bool
+
+	exceptionResults bool
+	warnings         bool
+	onWarning        func(Warning)
+
+	authorize Authorizer
+	redactor  Redactor
+	logger    *slog.Logger
+	// asyncCalls names the functions a Runner promotes to futures.
+	asyncCalls map[string]bool
+	// builtins holds the overridden builtins, nil for disabled ones.
+	builtins map[string]BuiltinFunc
+
+	maxCalls int
+	watchdog time.Duration
+	store    Store
+	quota    *tenantQuota
+	queue    *Queue
+	priority Priority
+	profile  Profile
+	tracer   *tracer
+	calls    *CallTracker
+	clock    *Clock
+	group    *Group
+
+	streamResults int64
+
+	contextVars []ContextVar
+	labels      map[string]string
+	runID       string
+	location    *time.Location
+	locale      *Locale
+}
+
+func newConfig(opts []Option) *config {
+	return (&config{eng: native}).with(opts)
+}
+
+// with returns a copy of c with opts applied. Options replace rather than
+// mutate shared fields, so the copy never aliases c's state.
+func (c *config) with(opts []Option) *config {
+	cfg := *c
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	return &cfg
+}
+
+// WithRandomSeed answers `random` OS calls from a generator seeded with seed.
+// Every run starts from the same seed, so executions are reproducible.
+func WithRandomSeed(seed int64) Option {
+	return func(c *config) {
+		c.newRand = func() *rand.Rand { return rand.New(rand.NewSource(seed)) }
+	}
+}
+
+// WithRandomSource answers `random` OS calls from a host-supplied source.
+// The source is shared by every run and must be safe for the caller's concurrency.
+func WithRandomSource(src rand.Source) Option {
+	return func(c *config) {
+		r := rand.New(src)
+		c.newRand = func() *rand.Rand { return r }
+	}
+}
+
+// WithEnv grants scripts read access to the given environment variables.
+// Scripts never see the host process environment; keys not granted through
+// WithEnv or WithHostEnv read as unset.
+func WithEnv(env map[string]string) Option {
+	return func(c *config) {
+		merged := make(map[string]string, len(c.env)+len(env))
+		for k, v := range c.env {
+			merged[k] = v
+		}
+		for k, v := range env {
+			merged[k] = v
+		}
+		c.env = merged
+	}
+}
+
+// WithHostEnv grants scripts read access to specific host environment variables.
+func WithHostEnv(keys ...string) Option {
+	return func(c *config) {
+		merged := make(map[string]bool, len(c.hostEnv)+len(keys))
+		for k := range c.hostEnv {
+			merged[k] = true
+		}
+		for _, k := range keys {
+			merged[k] = true
+		}
+		c.hostEnv = merged
+	}
+}
+
+// lookupEnv resolves key against the virtual environment.
+func (c *config) lookupEnv(key string) (string, bool) {
+	if v, ok := c.env[key]; ok {
+		return v, true
+	}
+	if c.hostEnv[key] {
+		return os.LookupEnv(key)
+	}
+	return "", false
+}
+
+// environ returns every granted environment variable that is set.
+func (c *config) environ() map[string]string {
+	out := make(map[string]string, len(c.env)+len(c.hostEnv))
+	for k := range c.hostEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			out[k] = v
+		}
+	}
+	for k, v := range c.env {
+		out[k] = v
+	}
+	return out
+}
+
+// WithLogger logs every call a Runner dispatches at debug level. Arguments
+// pass through the configured Redactor first.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// run carries per-execution state shared by every snapshot a run produces.
+type run struct {
+	id          string
+	cfg         *config
+	eng         engine
+	rand        *rand.Rand
+	transferred int64
+	// script and location identify the run and the last event it passed,
+	// for watchdog reports; program is the hash used for profiler labels.
+	script   string
+	program  string
+	location string
+	// clock is the virtual time of a deterministic run.
+	clock time.Duration
+	trace *runTrace
+	// context holds the run's context variables by name.
+	context map[string]Object
+	// warnings holds the warnings issued since the last progress event.
+	warnings []Warning
+	// ctx is the context the run was started with; it bounds the run's
+	// network requests.
+	ctx context.Context
+	// paused is the event the run last paused at in its group, and draining
+	// is set while the group's cancellation unwinds it.
+	paused   Progress
+	draining bool
+}
+
+func (c *config) newRun() *run {
+	r := &run{id: c.runID, cfg: c, eng: c.eng, ctx: context.Background()}
+	if r.id == "" {
+		r.id = newRunID()
+	}
+	if c.tracer != nil {
+		r.trace = c.tracer.begin()
+	}
+	if c.newRand != nil {
+		r.rand = c.newRand()
+	}
+	return r
+}
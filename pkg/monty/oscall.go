@@ -0,0 +1,240 @@
+package monty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+)
+
+// OS function names reported in Progress.OsFunction that the package can virtualize.
+const (
+	OsRandom      = "random.random"
+	OsRandint     = "random.randint"
+	OsUniform     = "random.uniform"
+	OsGetrandbits = "random.getrandbits"
+	OsGetenv      = "os.getenv"
+	OsEnviron     = "os.environ"
+	OsSleep       = "time.sleep"
+	OsAsyncSleep  = "asyncio.sleep"
+)
+
+// osReply is the host's answer to a virtualized OS call.
+type osReply struct {
+	value  any
+	errMsg string
+}
+
+// none marshals to JSON null so virtualized OS calls can return Python None.
+type none struct{}
+
+func (none) MarshalJSON() ([]byte, error) { return []byte("null"), nil }
+
+// intercept answers every call the run resolves on the host's behalf — policy
+// violations and virtualized OS calls — and returns the first progress event
+// the host has to handle itself. Sleeps surface as Timer events.
+func (r *run) intercept(p Progress) (Progress, error) {
+	for p.Snapshot != nil {
+		reply, ok := r.answer(&p)
+		if !ok {
+			if err := r.nondeterministic(p); err != nil {
+				closeProgress(p)
+				return Progress{}, err
+			}
+			r.annotate(&p)
+			return p, nil
+		}
+		if reply.value == nil && reply.errMsg == "" {
+			reply.value = none{}
+		}
+		next, err := p.Snapshot.step(p.CallID, reply.value, reply.errMsg)
+		if err != nil {
+			return Progress{}, err
+		}
+		p = next
+	}
+	r.annotate(&p)
+	return p, nil
+}
+
+// answer resolves p when policy or virtualization allows it, reporting false
+// when the host must handle the event.
+func (r *run) answer(p *Progress) (osReply, bool) {
+	if reply, ok := r.contextCall(*p); ok {
+		return reply, true
+	}
+	if reply, ok := r.warnCall(*p); ok {
+		return reply, true
+	}
+	if reply, ok := r.builtinCall(*p); ok {
+		return reply, true
+	}
+	switch p.Kind {
+	case FunctionCall:
+		if err := r.cfg.policy.checkFunction(*p); err != nil {
+			return osReply{errMsg: err.Error()}, true
+		}
+	case OsCall:
+		if err := r.cfg.policy.check(*p); err != nil {
+			return osReply{errMsg: err.Error()}, true
+		}
+		if reply, ok := r.osCall(*p); ok {
+			return reply, true
+		}
+		if p.OsFunction == OsSleep || p.OsFunction == OsAsyncSleep {
+			d, err := sleepDuration(*p)
+			if err != nil {
+				return osReply{errMsg: err.Error()}, true
+			}
+			if r.cfg.clock != nil {
+				r.cfg.clock.Advance(d)
+				return osReply{}, true
+			}
+			p.Kind = Timer
+			p.Duration = d
+			r.advance(d)
+		}
+	}
+	return osReply{}, false
+}
+
+func (r *run) osCall(p Progress) (osReply, bool) {
+	switch p.OsFunction {
+	case OsRandom, OsRandint, OsUniform, OsGetrandbits:
+		if r.rand == nil {
+			return osReply{}, false
+		}
+		return r.randomCall(p), true
+	case OsGetenv, OsEnviron:
+		return r.envCall(p), true
+	case OsLocaltime, OsGmtime, OsMktime, OsStrftime, OsStrptime, OsGetlocale:
+		if !r.cfg.localized() {
+			return osReply{}, false
+		}
+		return r.localeCall(p), true
+	case OsHTTPRequest:
+		if r.cfg.deterministic {
+			return osReply{}, false
+		}
+		return r.httpCall(p), true
+	case OsTime, OsTimeNs, OsMonotonic, OsPerfCounter:
+		if !r.cfg.deterministic && !r.cfg.hostClock() && r.cfg.clock == nil {
+			return osReply{}, false
+		}
+		return r.clockCall(p), true
+	}
+	return osReply{}, false
+}
+
+// envCall answers environment lookups from the virtual environment.
+func (r *run) envCall(p Progress) osReply {
+	if p.OsFunction == OsEnviron {
+		if err := unmarshalArgs(p); err != nil {
+			return osReply{errMsg: err.Error()}
+		}
+		return osReply{value: r.cfg.policy.allowsEnv(r.cfg.environ())}
+	}
+	if len(p.Args) == 0 || len(p.Args) > 2 {
+		return osReply{errMsg: fmt.Sprintf("getenv() takes 1 or 2 arguments but %d were given", len(p.Args))}
+	}
+	var key string
+	if err := p.Args[0].Unmarshal(&key); err != nil {
+		return osReply{errMsg: fmt.Sprintf("getenv() key: %v", err)}
+	}
+	if v, ok := r.cfg.lookupEnv(key); ok {
+		return osReply{value: v}
+	}
+	if len(p.Args) == 2 {
+		return osReply{value: json.RawMessage(p.Args[1])}
+	}
+	for _, kv := range p.Kwargs {
+		var name string
+		if kv.Key.Unmarshal(&name) == nil && name == "default" {
+			return osReply{value: json.RawMessage(kv.Value)}
+		}
+	}
+	return osReply{}
+}
+
+func (r *run) randomCall(p Progress) osReply {
+	switch p.OsFunction {
+	case OsRandom:
+		if err := unmarshalArgs(p); err != nil {
+			return osReply{errMsg: err.Error()}
+		}
+		return osReply{value: r.rand.Float64()}
+	case OsUniform:
+		var a, b float64
+		if err := unmarshalArgs(p, &a, &b); err != nil {
+			return osReply{errMsg: err.Error()}
+		}
+		return osReply{value: a + (b-a)*r.rand.Float64()}
+	case OsRandint:
+		var a, b int64
+		if err := unmarshalArgs(p, &a, &b); err != nil {
+			return osReply{errMsg: err.Error()}
+		}
+		if b < a {
+			return osReply{errMsg: fmt.Sprintf("ValueError: empty range in randint(%d, %d)", a, b)}
+		}
+		span := new(big.Int).Sub(big.NewInt(b), big.NewInt(a))
+		span.Add(span, big.NewInt(1))
+		n := new(big.Int).Rand(r.rand, span)
+		return osReply{value: n.Add(n, big.NewInt(a)).Int64()}
+	case OsGetrandbits:
+		var k int
+		if err := unmarshalArgs(p, &k); err != nil {
+			return osReply{errMsg: err.Error()}
+		}
+		if k < 0 {
+			return osReply{errMsg: "ValueError: number of bits must be non-negative"}
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(k))
+		return osReply{value: bigIntValue(new(big.Int).Rand(r.rand, limit))}
+	}
+	return osReply{errMsg: fmt.Sprintf("unsupported OS function %s", p.OsFunction)}
+}
+
+// sleepDuration reads the seconds argument of a sleep call. Lengths beyond
+// the largest time.Duration raise OverflowError, as CPython does for ones
+// its clock cannot hold.
+func sleepDuration(p Progress) (time.Duration, error) {
+	if len(p.Args) == 0 {
+		return 0, fmt.Errorf("%s() missing required argument 'seconds'", p.OsFunction)
+	}
+	var seconds float64
+	if err := p.Args[0].Unmarshal(&seconds); err != nil {
+		return 0, fmt.Errorf("%s() seconds: %v", p.OsFunction, err)
+	}
+	if seconds < 0 || math.IsNaN(seconds) {
+		return 0, errors.New("ValueError: sleep length must be non-negative")
+	}
+	if seconds*float64(time.Second) >= math.MaxInt64 {
+		return 0, errors.New("OverflowError: sleep length is too large")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// unmarshalArgs decodes the positional arguments of p into targets.
+func unmarshalArgs(p Progress, targets ...any) error {
+	if len(p.Args) != len(targets) || len(p.Kwargs) != 0 {
+		return fmt.Errorf("%s() takes %d positional arguments but %d were given", p.OsFunction, len(targets), len(p.Args)+len(p.Kwargs))
+	}
+	for i, target := range targets {
+		if err := p.Args[i].Unmarshal(target); err != nil {
+			return fmt.Errorf("%s() argument %d: %v", p.OsFunction, i+1, err)
+		}
+	}
+	return nil
+}
+
+// bigIntValue encodes n as a JSON int, falling back to the $bigint tag when it
+// does not fit in an int64.
+func bigIntValue(n *big.Int) any {
+	if n.IsInt64() {
+		return n.Int64()
+	}
+	return map[string]string{"$bigint": n.String()}
+}
@@ -0,0 +1,128 @@
+package monty
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRandomSeedReproducible(t *testing.T) {
+	cfg := newConfig([]Option{WithRandomSeed(7)})
+	call := Progress{Kind: OsCall, OsFunction: OsRandint, Args: []Object{Object("1"), Object("100")}}
+
+	first, second := cfg.newRun(), cfg.newRun()
+	for i := 0; i < 10; i++ {
+		a, b := first.randomCall(call), second.randomCall(call)
+		if a.errMsg != "" || b.errMsg != "" {
+			t.Fatalf("randint failed: %q %q", a.errMsg, b.errMsg)
+		}
+		if a.value != b.value {
+			t.Fatalf("draw %d differs between runs: %v vs %v", i, a.value, b.value)
+		}
+		if n := a.value.(int64); n < 1 || n > 100 {
+			t.Fatalf("randint out of range: %d", n)
+		}
+	}
+}
+
+func TestRandomCallArgumentErrors(t *testing.T) {
+	r := newConfig([]Option{WithRandomSeed(1)}).newRun()
+
+	reply := r.randomCall(Progress{OsFunction: OsRandint, Args: []Object{Object("5"), Object("1")}})
+	if reply.errMsg == "" {
+		t.Fatalf("expected empty range error")
+	}
+	reply = r.randomCall(Progress{OsFunction: OsRandom, Args: []Object{Object("1")}})
+	if reply.errMsg == "" {
+		t.Fatalf("expected arity error")
+	}
+}
+
+func TestRandomErrorsRaiseInScript(t *testing.T) {
+	m := newTestMonty(t, `
+import random
+caught = []
+for call in [lambda: random.randint(5, 1), lambda: random.getrandbits(-1)]:
+    try:
+        call()
+    except ValueError:
+        caught.append('value')
+caught
+`, nil, nil, WithRandomSeed(1))
+	out, err := m.Run()
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	var caught []string
+	if err := out.Unmarshal(&caught); err != nil || len(caught) != 2 {
+		t.Fatalf("caught = %v (%v), want [value value]", caught, err)
+	}
+}
+
+func TestRandomCallsPassThroughWithoutSeed(t *testing.T) {
+	r := newConfig(nil).newRun()
+	if _, ok := r.osCall(Progress{Kind: OsCall, OsFunction: OsRandom}); ok {
+		t.Fatalf("random calls should reach the host when no seed is configured")
+	}
+}
+
+func TestEnvDefaultDeny(t *testing.T) {
+	t.Setenv("MONTY_SECRET", "hunter2")
+	r := newConfig([]Option{WithEnv(map[string]string{"REGION": "us-east"}), WithHostEnv("HOME")}).newRun()
+
+	reply := r.envCall(Progress{OsFunction: OsGetenv, Args: []Object{Object(`"REGION"`)}})
+	if reply.value != "us-east" {
+		t.Fatalf("expected granted key, got %v", reply.value)
+	}
+	reply = r.envCall(Progress{OsFunction: OsGetenv, Args: []Object{Object(`"MONTY_SECRET"`)}})
+	if reply.value != nil || reply.errMsg != "" {
+		t.Fatalf("host variable leaked: %+v", reply)
+	}
+	reply = r.envCall(Progress{OsFunction: OsGetenv, Args: []Object{Object(`"MONTY_SECRET"`), Object(`"fallback"`)}})
+	if string(reply.value.(json.RawMessage)) != `"fallback"` {
+		t.Fatalf("expected default value, got %v", reply.value)
+	}
+	env := r.envCall(Progress{OsFunction: OsEnviron}).value.(map[string]string)
+	if _, ok := env["MONTY_SECRET"]; ok {
+		t.Fatalf("environ leaked ungranted key")
+	}
+	if env["REGION"] != "us-east" {
+		t.Fatalf("environ missing granted key: %v", env)
+	}
+}
+
+func TestSleepDuration(t *testing.T) {
+	d, err := sleepDuration(Progress{OsFunction: OsSleep, Args: []Object{Object("1.5")}})
+	if err != nil {
+		t.Fatalf("sleepDuration failed: %v", err)
+	}
+	if d != 1500*time.Millisecond {
+		t.Fatalf("expected 1.5s, got %v", d)
+	}
+	if _, err := sleepDuration(Progress{OsFunction: OsAsyncSleep, Args: []Object{Object("-1")}}); err == nil {
+		t.Fatalf("expected negative sleep to fail")
+	}
+}
+
+func TestSleepErrorsRaiseInScript(t *testing.T) {
+	m := newTestMonty(t, `
+import time
+caught = []
+for seconds in [1e20, -1]:
+    try:
+        time.sleep(seconds)
+    except OverflowError:
+        caught.append('overflow')
+    except ValueError:
+        caught.append('value')
+caught
+`, nil, nil, WithDeterministic())
+	out, err := m.Run()
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	var caught []string
+	if err := out.Unmarshal(&caught); err != nil || len(caught) != 2 || caught[0] != "overflow" || caught[1] != "value" {
+		t.Fatalf("caught = %v (%v), want [overflow value]", caught, err)
+	}
+}
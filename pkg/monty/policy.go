@@ -0,0 +1,153 @@
+package monty
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Policy restricts the OS surface a program may touch. Calls that violate the
+// policy raise inside the script and never reach host handlers. A nil list
+// leaves that dimension unrestricted; an empty non-nil list denies everything.
+type Policy struct {
+	// Functions lists the external functions scripts may call. Method calls
+	// on objects the host passed in are listed by method name after a dot,
+	// such as ".read".
+	Functions []string
+	// OsFunctions lists the OS functions scripts may call, e.g. "Path.read_text".
+	OsFunctions []string
+	// Paths lists virtual path prefixes that Path operations may touch.
+	Paths []string
+	// EnvKeys lists environment variables scripts may read.
+	EnvKeys []string
+}
+
+// WithPolicy enforces p on every OS call before it is virtualized or surfaced.
+func WithPolicy(p Policy) Option {
+	return func(c *config) {
+		c.policy = &p
+	}
+}
+
+// WithCapabilities declares the external and OS functions a program may call.
+// Any other call raises inside the script immediately instead of surfacing as
+// a progress event. A nil list leaves that kind of call unrestricted. Method
+// calls are listed in funcs by name after a dot, as in Policy.Functions.
+func WithCapabilities(funcs, osFuncs []string) Option {
+	return func(c *config) {
+		p := Policy{}
+		if c.policy != nil {
+			p = *c.policy
+		}
+		if funcs != nil {
+			p.Functions = append([]string{}, funcs...)
+		}
+		if osFuncs != nil {
+			p.OsFunctions = append([]string{}, osFuncs...)
+		}
+		c.policy = &p
+	}
+}
+
+// checkFunction reports why the external call in p is not permitted.
+func (p *Policy) checkFunction(call Progress) error {
+	if p == nil || p.Functions == nil {
+		return nil
+	}
+	if call.MethodCall {
+		if !slices.Contains(p.Functions, "."+call.FunctionName) {
+			return fmt.Errorf("permission denied: method %s is not allowed", call.FunctionName)
+		}
+		return nil
+	}
+	if !slices.Contains(p.Functions, call.FunctionName) {
+		return fmt.Errorf("permission denied: %s is not an allowed function", call.FunctionName)
+	}
+	return nil
+}
+
+// check reports why the OS call in p is not permitted, or nil when it is.
+func (p *Policy) check(call Progress) error {
+	if p == nil {
+		return nil
+	}
+	if p.OsFunctions != nil && !slices.Contains(p.OsFunctions, call.OsFunction) {
+		return fmt.Errorf("permission denied: %s is not allowed", call.OsFunction)
+	}
+	if p.Paths != nil && strings.HasPrefix(call.OsFunction, "Path.") {
+		for _, target := range pathArgs(call) {
+			if !p.allowsPath(target) {
+				return fmt.Errorf("permission denied: %s(%q)", call.OsFunction, target)
+			}
+		}
+	}
+	if p.EnvKeys != nil && call.OsFunction == OsGetenv && len(call.Args) > 0 {
+		var key string
+		if err := call.Args[0].Unmarshal(&key); err == nil && !slices.Contains(p.EnvKeys, key) {
+			return fmt.Errorf("permission denied: environment variable %q", key)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) allowsPath(target string) bool {
+	clean := path.Clean(target)
+	for _, prefix := range p.Paths {
+		prefix = path.Clean(prefix)
+		if clean == prefix || prefix == "/" && strings.HasPrefix(clean, "/") || strings.HasPrefix(clean, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsEnv filters env down to the keys the policy permits.
+func (p *Policy) allowsEnv(env map[string]string) map[string]string {
+	if p == nil || p.EnvKeys == nil {
+		return env
+	}
+	for k := range env {
+		if !slices.Contains(p.EnvKeys, k) {
+			delete(env, k)
+		}
+	}
+	return env
+}
+
+// twoPathFunctions are the Path functions whose second argument, or target
+// keyword, names a second path, which may be a plain string.
+var twoPathFunctions = map[string]bool{
+	"Path.rename": true, "Path.replace": true, "Path.symlink_to": true, "Path.hardlink_to": true,
+}
+
+// pathArgs returns the paths a Path call touches: every $path argument, a
+// plain string receiver, and the plain string target of a two-path call.
+// Unreadable path-typed arguments are returned empty, so they are denied.
+func pathArgs(call Progress) []string {
+	var out []string
+	add := func(arg Object, plainIsPath bool) {
+		var tagged struct {
+			Path *string `json:"$path"`
+		}
+		if err := arg.Unmarshal(&tagged); err == nil && tagged.Path != nil {
+			out = append(out, *tagged.Path)
+			return
+		}
+		if !plainIsPath {
+			return
+		}
+		var plain string
+		arg.Unmarshal(&plain)
+		out = append(out, plain)
+	}
+	for i, arg := range call.Args {
+		add(arg, i == 0 || i == 1 && twoPathFunctions[call.OsFunction])
+	}
+	for _, kv := range call.Kwargs {
+		var name string
+		kv.Key.Unmarshal(&name)
+		add(kv.Value, name == "target" && twoPathFunctions[call.OsFunction])
+	}
+	return out
+}
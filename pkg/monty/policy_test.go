@@ -0,0 +1,78 @@
+package monty
+
+import "testing"
+
+func TestPolicyCheck(t *testing.T) {
+	p := &Policy{
+		OsFunctions: []string{"Path.read_text", "Path.write_text", "Path.rename", "Path.replace", "Path.symlink_to", "Path.hardlink_to", OsGetenv},
+		Paths:       []string{"/data"},
+		EnvKeys:     []string{"REGION"},
+	}
+	cases := []struct {
+		name    string
+		call    Progress
+		allowed bool
+	}{
+		{"read inside sandbox", Progress{OsFunction: "Path.read_text", Args: []Object{Object(`{"$path":"/data/in.txt"}`)}}, true},
+		{"write content is not a path", Progress{OsFunction: "Path.write_text", Args: []Object{Object(`{"$path":"/data/out.txt"}`), Object(`"/etc/passwd"`)}}, true},
+		{"escape with dotdot", Progress{OsFunction: "Path.read_text", Args: []Object{Object(`{"$path":"/data/../etc/passwd"}`)}}, false},
+		{"sibling prefix", Progress{OsFunction: "Path.read_text", Args: []Object{Object(`{"$path":"/database"}`)}}, false},
+		{"function not listed", Progress{OsFunction: "Path.unlink", Args: []Object{Object(`{"$path":"/data/in.txt"}`)}}, false},
+		{"granted env key", Progress{OsFunction: OsGetenv, Args: []Object{Object(`"REGION"`)}}, true},
+		{"denied env key", Progress{OsFunction: OsGetenv, Args: []Object{Object(`"AWS_SECRET"`)}}, false},
+	}
+	for _, fn := range []string{"Path.rename", "Path.replace", "Path.symlink_to", "Path.hardlink_to"} {
+		src := Object(`{"$path":"/data/in.txt"}`)
+		cases = append(cases, []struct {
+			name    string
+			call    Progress
+			allowed bool
+		}{
+			{fn + " inside sandbox", Progress{OsFunction: fn, Args: []Object{src, Object(`"/data/out.txt"`)}}, true},
+			{fn + " to a plain path outside", Progress{OsFunction: fn, Args: []Object{src, Object(`"/etc/passwd"`)}}, false},
+			{fn + " to a path outside", Progress{OsFunction: fn, Args: []Object{src, Object(`{"$path":"/etc/passwd"}`)}}, false},
+			{fn + " to a relative path", Progress{OsFunction: fn, Args: []Object{src, Object(`"../etc/passwd"`)}}, false},
+			{fn + " to a target keyword outside", Progress{OsFunction: fn, Args: []Object{src}, Kwargs: []KV{{Key: Object(`"target"`), Value: Object(`"/etc/passwd"`)}}}, false},
+		}...)
+	}
+	for _, tc := range cases {
+		if err := p.check(tc.call); (err == nil) != tc.allowed {
+			t.Errorf("%s: allowed=%v, err=%v", tc.name, tc.allowed, err)
+		}
+	}
+
+	var unrestricted *Policy
+	if err := unrestricted.check(Progress{OsFunction: "Path.unlink"}); err != nil {
+		t.Fatalf("nil policy should allow everything: %v", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	cfg := newConfig([]Option{WithCapabilities([]string{"fetch", ".read"}, []string{OsGetenv})})
+	if err := cfg.policy.checkFunction(Progress{FunctionName: "fetch"}); err != nil {
+		t.Fatalf("allowed function rejected: %v", err)
+	}
+	if err := cfg.policy.checkFunction(Progress{FunctionName: "delete_all"}); err == nil {
+		t.Fatalf("expected function outside the allowlist to be rejected")
+	}
+	if err := cfg.policy.checkFunction(Progress{FunctionName: "read", MethodCall: true}); err != nil {
+		t.Fatalf("allowed method rejected: %v", err)
+	}
+	for _, call := range []Progress{{FunctionName: "delete_all", MethodCall: true}, {FunctionName: "fetch", MethodCall: true}} {
+		if err := cfg.policy.checkFunction(call); err == nil {
+			t.Fatalf("expected method %s outside the allowlist to be rejected", call.FunctionName)
+		}
+	}
+	if err := cfg.policy.checkFunction(Progress{FunctionName: "read"}); err == nil {
+		t.Fatalf("a listed method should not allow a plain function of the same name")
+	}
+	if err := cfg.policy.check(Progress{OsFunction: "Path.read_text"}); err == nil {
+		t.Fatalf("expected OS function outside the allowlist to be rejected")
+	}
+
+	r := cfg.newRun()
+	p := Progress{Kind: FunctionCall, FunctionName: "delete_all"}
+	if reply, ok := r.answer(&p); !ok || reply.errMsg == "" {
+		t.Fatalf("expected the run to raise for a denied call, got %+v", reply)
+	}
+}
@@ -0,0 +1,189 @@
+package monty
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// PoolStats counts the lookups of a SnapshotPool.
+type PoolStats struct {
+	// Hits were served from memory, StoreHits from the store, and Misses
+	// found no run.
+	Hits      uint64
+	StoreHits uint64
+	Misses    uint64
+	// Evictions counts runs moved to the store to stay within the size.
+	Evictions uint64
+	// Len is the number of runs in memory, and Bytes the sum of their
+	// dumped sizes.
+	Len   int
+	Bytes int64
+}
+
+// SnapshotPool keeps the paused runs of workflows in memory between steps,
+// so chatty workflows are not dumped to a Store and loaded back at every
+// event. Runs are kept by workflow ID up to a total dumped size; the least
+// recently used ones are evicted to the store, and taken back from it, so a
+// pool can also be emptied into the store on shutdown and refilled by
+// another process. Evictions write to the store while holding the pool's
+// lock. It is safe for concurrent use.
+type SnapshotPool struct {
+	maxBytes int64
+	store    Store
+	opts     []Option
+
+	mu    sync.Mutex
+	lru   *list.List // of *poolEntry, most recently used first
+	byID  map[string]*list.Element
+	stats PoolStats
+}
+
+type poolEntry struct {
+	id   string
+	p    Progress
+	size int64
+}
+
+// NewSnapshotPool returns a pool holding paused runs of up to maxBytes
+// dumped bytes in memory, and evicting the rest to store. opts configure the
+// runs it loads back from the store, as for Progress.Attach.
+func NewSnapshotPool(maxBytes int64, store Store, opts ...Option) *SnapshotPool {
+	return &SnapshotPool{
+		maxBytes: maxBytes,
+		store:    store,
+		opts:     opts,
+		lru:      list.New(),
+		byID:     make(map[string]*list.Element),
+	}
+}
+
+// Put keeps the paused event p of workflow id, closing the run held for id
+// in memory, if any. The pool owns p's snapshot until it is taken back.
+// Complete events cannot be pooled.
+func (sp *SnapshotPool) Put(ctx context.Context, id string, p Progress) error {
+	if p.Snapshot == nil && p.FutureSnapshot == nil {
+		return newError(ErrInvalidInput, "monty: only paused runs can be pooled")
+	}
+	size, err := estimateSize(p)
+	if err != nil {
+		return err
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if e, ok := sp.byID[id]; ok {
+		closeProgress(sp.remove(e).p)
+	}
+	sp.byID[id] = sp.lru.PushFront(&poolEntry{id: id, p: p, size: size})
+	sp.stats.Bytes += size
+	var errs []error
+	for sp.stats.Bytes > sp.maxBytes && sp.lru.Len() > 0 {
+		entry := sp.remove(sp.lru.Back())
+		sp.stats.Evictions++
+		errs = append(errs, sp.evict(ctx, entry))
+	}
+	return errors.Join(errs...)
+}
+
+// Take removes the paused run of workflow id from the pool and returns it,
+// loading it from the store if it was evicted. It returns ErrNotStored if
+// the pool holds no run for id.
+func (sp *SnapshotPool) Take(ctx context.Context, id string) (Progress, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if e, ok := sp.byID[id]; ok {
+		sp.stats.Hits++
+		return sp.remove(e).p, nil
+	}
+	data, err := sp.store.Get(ctx, poolKey(id))
+	if errors.Is(err, ErrNotStored) {
+		sp.stats.Misses++
+	}
+	if err != nil {
+		return Progress{}, err
+	}
+	var p Progress
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Progress{}, err
+	}
+	if err := p.Attach(ctx, sp.store, sp.opts...); err != nil {
+		return Progress{}, err
+	}
+	if err := sp.store.Delete(ctx, poolKey(id)); err != nil {
+		closeProgress(p)
+		return Progress{}, err
+	}
+	sp.stats.StoreHits++
+	return p, nil
+}
+
+// Delete closes the run of workflow id, in memory or in the store.
+func (sp *SnapshotPool) Delete(ctx context.Context, id string) error {
+	p, err := sp.Take(ctx, id)
+	if errors.Is(err, ErrNotStored) {
+		return nil
+	}
+	closeProgress(p)
+	return err
+}
+
+// Stats returns the pool's counters.
+func (sp *SnapshotPool) Stats() PoolStats {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	s := sp.stats
+	s.Len = sp.lru.Len()
+	return s
+}
+
+// Close evicts every run in memory to the store, where Take finds them
+// again, and empties the pool.
+func (sp *SnapshotPool) Close(ctx context.Context) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	var errs []error
+	for sp.lru.Len() > 0 {
+		errs = append(errs, sp.evict(ctx, sp.remove(sp.lru.Front())))
+	}
+	return errors.Join(errs...)
+}
+
+// remove drops e from memory and returns its entry.
+func (sp *SnapshotPool) remove(e *list.Element) *poolEntry {
+	entry := sp.lru.Remove(e).(*poolEntry)
+	delete(sp.byID, entry.id)
+	sp.stats.Bytes -= entry.size
+	return entry
+}
+
+// evict detaches the run of entry into the store, with its event stored
+// under the workflow ID. The run is closed if that fails.
+func (sp *SnapshotPool) evict(ctx context.Context, entry *poolEntry) error {
+	p := entry.p
+	if err := p.Detach(ctx, sp.store); err != nil {
+		closeProgress(p)
+		return err
+	}
+	data, err := json.Marshal(p)
+	if err == nil {
+		err = sp.store.Put(ctx, poolKey(entry.id), data)
+	}
+	if err != nil {
+		sp.store.Delete(ctx, p.Token)
+	}
+	return err
+}
+
+// estimateSize returns the estimated size of the dump of p's snapshot.
+func estimateSize(p Progress) (int64, error) {
+	if p.Snapshot != nil {
+		return p.Snapshot.EstimateSize()
+	}
+	return p.FutureSnapshot.EstimateSize()
+}
+
+func poolKey(id string) string {
+	return "monty-pool-" + id
+}
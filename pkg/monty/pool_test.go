@@ -0,0 +1,82 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSnapshotPool(t *testing.T) {
+	ctx := context.Background()
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(callBridge("double", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	start := func() Progress {
+		p, err := m.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	first := start()
+	size, err := estimateSize(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewMemoryStore()
+	pool := NewSnapshotPool(2*size, store, WithSandbox(callBridge("double", 1)))
+	for _, id := range []string{"a", "b", "c"} {
+		p := first
+		if id != "a" {
+			p = start()
+		}
+		if err := pool.Put(ctx, id, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := pool.Stats(); s.Len != 2 || s.Bytes != 2*size || s.Evictions != 1 {
+		t.Fatalf("Stats = %+v, want a evicted", s)
+	}
+	if !first.Snapshot.IsClosed() {
+		t.Error("evicted snapshot left open")
+	}
+
+	for _, id := range []string{"a", "c"} {
+		p, err := pool.Take(ctx, id)
+		if err != nil {
+			t.Fatalf("Take(%s): %v", id, err)
+		}
+		done, err := p.Snapshot.Resume(p.CallID, 42)
+		if err != nil || string(done.Result) != "42" {
+			t.Fatalf("resuming %s = %+v, %v", id, done, err)
+		}
+	}
+	if _, err := pool.Take(ctx, "a"); !errors.Is(err, ErrNotStored) {
+		t.Errorf("taking a twice: err = %v, want ErrNotStored", err)
+	}
+	if s := pool.Stats(); s.Hits != 1 || s.StoreHits != 1 || s.Misses != 1 || s.Len != 1 {
+		t.Errorf("Stats = %+v", s)
+	}
+
+	// Close moves b to the store, where a new pool finds it.
+	if err := pool.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	other := NewSnapshotPool(2*size, store, WithSandbox(callBridge("double", 1)))
+	p, err := other.Take(ctx, "b")
+	if err != nil || p.Kind != FunctionCall || p.FunctionName != "double" {
+		t.Fatalf("Take(b) after Close = %+v, %v", p, err)
+	}
+	if err := other.Put(ctx, "b", p); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Delete(ctx, "b"); err != nil || !p.Snapshot.IsClosed() {
+		t.Errorf("Delete = %v", err)
+	}
+	if err := other.Put(ctx, "x", Progress{Kind: Complete}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("pooling a complete run: err = %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package monty
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"runtime/pprof"
+)
+
+// Profiler label keys set by WithProfileLabels.
+const (
+	LabelScript  = "monty.script"
+	LabelProgram = "monty.program"
+	LabelOp      = "monty.op"
+)
+
+// WithProfileLabels tags the goroutine running each interpreter step with
+// pprof labels, so Go CPU profiles attribute time spent inside the
+// interpreter to the program that used it instead of one opaque cgo frame:
+//
+//   - monty.script, the script name given to New;
+//   - monty.program, a short hash of the program's source, or of its bytes
+//     for programs restored with NewFromBytes, telling versions apart;
+//   - monty.op, the step: "compile", "start", "resume", or "resume futures".
+//
+// Filter or group a profile by them with `go tool pprof -tagfocus` or
+// `-tagroot`. Steps running in a WithSandbox child spend their CPU in that
+// process, so only the time waiting on it is labeled. Labeled steps run on
+// a goroutine of their own, which leaves the caller's labels intact but
+// costs a goroutine switch per step, so labels are off by default.
+func WithProfileLabels() Option {
+	return func(c *config) {
+		c.profileLabels = true
+	}
+}
+
+// ProgramHash returns the monty.program label of m.
+func (m *Monty) ProgramHash() string {
+	return m.program
+}
+
+// programHash identifies a program by its source or serialized bytes.
+func programHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// labeled runs fn under profiler labels when c asks for them. fn runs on its
+// own goroutine so that labels the caller set on its goroutine survive.
+func (c *config) labeled(script, program, op string, fn func()) {
+	if !c.profileLabels {
+		fn()
+		return
+	}
+	pairs := []string{LabelScript, script, LabelProgram, program, LabelOp, op}
+	for _, k := range sortedKeys(c.labels) {
+		pairs = append(pairs, k, c.labels[k])
+	}
+	labels := pprof.Labels(pairs...)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pprof.Do(context.Background(), labels, func(context.Context) { fn() })
+	}()
+	<-done
+}
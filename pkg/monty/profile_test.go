@@ -0,0 +1,48 @@
+package monty
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime/pprof"
+	"strings"
+	"testing"
+)
+
+func TestProfileLabels(t *testing.T) {
+	labels := make(map[string]string)
+	// labelled records the profiler labels on the goroutine serving each op.
+	labelled := func(resp sandboxResponse) func(sandboxRequest) sandboxResponse {
+		return func(req sandboxRequest) sandboxResponse {
+			var buf bytes.Buffer
+			pprof.Lookup("goroutine").WriteTo(&buf, 1)
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if strings.Contains(line, `"monty.op"`) {
+					labels[req.Op] += line
+				}
+			}
+			return resp
+		}
+	}
+	sb := scriptedBridge(bridgeScript{
+		"compile": labelled(sandboxResponse{Handle: 1}),
+		"start":   labelled(completeProgress(json.RawMessage("1"))),
+		anyOp:     labelled(sandboxResponse{}),
+	})
+	m, err := New("1", "main.py", nil, nil, WithSandbox(sb), WithProfileLabels())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := `"monty.program":"` + m.ProgramHash() + `"`
+	for op, label := range map[string]string{"compile": `"monty.op":"compile"`, "start": `"monty.op":"start"`} {
+		if !strings.Contains(labels[op], label) || !strings.Contains(labels[op], want) || !strings.Contains(labels[op], `"monty.script":"main.py"`) {
+			t.Errorf("%s ran with labels %q", op, labels[op])
+		}
+	}
+	if len(m.ProgramHash()) != 12 {
+		t.Errorf("unexpected program hash %q", m.ProgramHash())
+	}
+}
@@ -0,0 +1,95 @@
+package monty
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Profile is a curated OS-call posture selected with WithProfile. Each
+// profile answers the OS calls the package can virtualize without the host
+// and denies every other OS call inside the script, so a program is safe
+// by default without configuring each capability.
+type Profile string
+
+const (
+	// ProfilePure allows only calls the run answers from its inputs: random
+	// numbers seeded with 0, the virtual clock, environment variables granted
+	// with WithEnv, time zone and locale calls, and sleeps. It implies
+	// WithDeterministic.
+	ProfilePure Profile = "pure"
+	// ProfileReadOnlyFS adds Path calls that read the filesystem, such as
+	// Path.read_text and Path.exists, to the calls of ProfileFullVirtual
+	// other than network requests. They surface to the host, which serves
+	// them from its virtual filesystem; combine with Policy.Paths to confine
+	// them. Writes, deletes, and network requests are denied.
+	ProfileReadOnlyFS Profile = "read-only-fs"
+	// ProfileFullVirtual allows every OS call the package virtualizes:
+	// random numbers from a freshly seeded generator, the host's clock,
+	// granted environment variables, time zone and locale calls, sleeps,
+	// and network requests, which are answered through WithHTTPClient and
+	// raise without it. Filesystem calls are denied.
+	ProfileFullVirtual Profile = "full-virtual"
+)
+
+// pathReads are the Path functions ProfileReadOnlyFS allows.
+var pathReads = []string{
+	"Path.exists", "Path.is_file", "Path.is_dir", "Path.is_symlink",
+	"Path.read_text", "Path.read_bytes", "Path.iterdir", "Path.stat",
+	"Path.resolve", "Path.absolute",
+}
+
+// virtualOsFunctions are the OS functions every profile answers in the
+// package.
+var virtualOsFunctions = []string{
+	OsRandom, OsRandint, OsUniform, OsGetrandbits,
+	OsGetenv, OsEnviron, OsSleep, OsAsyncSleep,
+	OsTime, OsTimeNs, OsMonotonic, OsPerfCounter,
+	OsLocaltime, OsGmtime, OsMktime, OsStrftime, OsStrptime, OsGetlocale,
+}
+
+// OsFunctions lists the OS functions p allows, or none for an unknown
+// profile.
+func (p Profile) OsFunctions() []string {
+	allowed := append([]string{}, virtualOsFunctions...)
+	switch p {
+	case ProfilePure:
+		return allowed
+	case ProfileReadOnlyFS:
+		return append(allowed, pathReads...)
+	case ProfileFullVirtual:
+		return append(allowed, OsHTTPRequest)
+	}
+	return []string{}
+}
+
+// WithProfile selects the OS-call profile of the programs compiled with it,
+// restricting their OS functions to p.OsFunctions() and answering those the
+// package can virtualize. An unknown profile denies every OS call. The rest
+// of a Policy given before WithProfile is kept; options given after it
+// refine it: WithCapabilities narrows the allowed OS functions,
+// WithRandomSeed fixes the generator, and WithTimezone or WithLocale choose
+// other than UTC and LocaleC. External functions are unaffected.
+func WithProfile(p Profile) Option {
+	return func(c *config) {
+		policy := Policy{}
+		if c.policy != nil {
+			policy = *c.policy
+		}
+		policy.OsFunctions = p.OsFunctions()
+		c.policy = &policy
+		c.profile = p
+		if p == ProfilePure {
+			WithDeterministic()(c)
+		} else if c.newRand == nil {
+			c.newRand = func() *rand.Rand { return rand.New(rand.NewSource(time.Now().UnixNano())) }
+		}
+	}
+}
+
+// started anchors the monotonic clock of runs reading the host's clock.
+var started = time.Now()
+
+// hostClock reports whether clock reads are answered from the host's clock.
+func (c *config) hostClock() bool {
+	return c.profile != "" && !c.deterministic
+}
@@ -0,0 +1,52 @@
+package monty
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	cases := []struct {
+		profile            Profile
+		answered, surfaced []string
+		denied             []string
+	}{
+		{ProfilePure, []string{OsRandom, OsTime, OsGetenv, OsLocaltime}, nil,
+			[]string{"Path.read_text", OsHTTPRequest}},
+		{ProfileReadOnlyFS, []string{OsRandom, OsTime, OsGetlocale}, []string{"Path.read_text", "Path.exists"},
+			[]string{"Path.write_text", "Path.unlink", OsHTTPRequest}},
+		{ProfileFullVirtual, []string{OsRandom, OsMonotonic, OsHTTPRequest}, nil,
+			[]string{"Path.read_text"}},
+		{Profile("bogus"), nil, nil, []string{OsRandom, "Path.read_text"}},
+	}
+	for _, tc := range cases {
+		r := newConfig([]Option{WithProfile(tc.profile)}).newRun()
+		call := func(fn string) (osReply, bool) {
+			p := Progress{Kind: OsCall, OsFunction: fn}
+			return r.answer(&p)
+		}
+		for _, fn := range tc.answered {
+			if reply, ok := call(fn); !ok || strings.HasPrefix(reply.errMsg, "permission denied") {
+				t.Errorf("%s: %s = %+v, %v; want it answered", tc.profile, fn, reply, ok)
+			}
+		}
+		for _, fn := range tc.surfaced {
+			if reply, ok := call(fn); ok {
+				t.Errorf("%s: %s = %+v; want it surfaced to the host", tc.profile, fn, reply)
+			}
+		}
+		for _, fn := range tc.denied {
+			if reply, ok := call(fn); !ok || !strings.HasPrefix(reply.errMsg, "permission denied") {
+				t.Errorf("%s: %s = %+v, %v; want it denied", tc.profile, fn, reply, ok)
+			}
+		}
+	}
+
+	if cfg := newConfig([]Option{WithProfile(ProfilePure)}); !cfg.deterministic {
+		t.Error("ProfilePure should imply WithDeterministic")
+	}
+	cfg := newConfig([]Option{WithProfile(ProfileFullVirtual), WithCapabilities(nil, []string{OsRandom})})
+	if err := cfg.policy.check(Progress{OsFunction: OsTime}); err == nil {
+		t.Error("WithCapabilities after WithProfile should narrow the allowed OS functions")
+	}
+}
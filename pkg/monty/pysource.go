@@ -0,0 +1,493 @@
+package monty
+
+import (
+	"strings"
+)
+
+// source is the program text a Monty was compiled from, kept for
+// introspection. The interpreter does not expose its syntax tree, so the
+// helpers here read the top-level structure of the script directly; they
+// only need to be as exact as Python's tokenizer about strings, comments,
+// brackets, and indentation.
+type source struct {
+	code       string
+	scriptName string
+	inputNames []string
+	extFuncs   []string
+}
+
+// logicalLine is one Python logical line: physical lines joined across
+// brackets and backslash continuations, with comments removed.
+type logicalLine struct {
+	text   string
+	line   int // 1-based line of the first physical line
+	indent int
+}
+
+// logicalLines splits code into logical lines, skipping blank ones.
+func logicalLines(code string) []logicalLine {
+	var (
+		lines   []logicalLine
+		cur     strings.Builder
+		depth   int
+		line    = 1
+		start   = 1
+		indent  = 0
+		atStart = true
+	)
+	flush := func() {
+		if text := strings.TrimSpace(cur.String()); text != "" {
+			lines = append(lines, logicalLine{text: text, line: start, indent: indent})
+		}
+		cur.Reset()
+		atStart = true
+	}
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		if atStart {
+			// Measure indentation of the first physical line.
+			j, width := i, 0
+			for j < len(code) && (code[j] == ' ' || code[j] == '\t') {
+				if code[j] == '\t' {
+					width += 8 - width%8
+				} else {
+					width++
+				}
+				j++
+			}
+			indent, start, atStart = width, line, false
+			i = j - 1
+			continue
+		}
+		switch {
+		case c == '#':
+			i = commentEnd(code, i) - 1
+		case c == '\\' && i+1 < len(code) && code[i+1] == '\n':
+			i++
+			line++
+			cur.WriteByte(' ')
+		case c == '\'' || c == '"':
+			end := stringEnd(code, i)
+			lit := code[i:end]
+			line += strings.Count(lit, "\n")
+			cur.WriteString(lit)
+			i = end - 1
+		case c == '(' || c == '[' || c == '{':
+			depth++
+			cur.WriteByte(c)
+		case c == ')' || c == ']' || c == '}':
+			if depth > 0 {
+				depth--
+			}
+			cur.WriteByte(c)
+		case c == '\n':
+			line++
+			if depth > 0 {
+				cur.WriteByte(' ')
+				continue
+			}
+			flush()
+		case c == ';' && depth == 0:
+			// Statements after a semicolon belong to the same block.
+			keep := indent
+			flush()
+			indent, start, atStart = keep, line, false
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return lines
+}
+
+// commentEnd returns the index of the newline ending the comment that starts
+// at code[i], or len(code).
+func commentEnd(code string, i int) int {
+	if j := strings.IndexByte(code[i:], '\n'); j >= 0 {
+		return i + j
+	}
+	return len(code)
+}
+
+// continuationLines reports the 1-based physical lines of code that begin
+// inside a string literal, whose text indenting would change.
+func continuationLines(code string) map[int]bool {
+	inside := make(map[int]bool)
+	line := 1
+	for i := 0; i < len(code); i++ {
+		switch code[i] {
+		case '\n':
+			line++
+		case '#':
+			i = commentEnd(code, i) - 1
+		case '\'', '"':
+			end := stringEnd(code, i)
+			for _, c := range []byte(code[i:end]) {
+				if c == '\n' {
+					line++
+					inside[line] = true
+				}
+			}
+			i = end - 1
+		}
+	}
+	return inside
+}
+
+// stringEnd returns the index just past the string literal whose opening
+// quote is code[i], or len(code) if it is unterminated.
+func stringEnd(code string, i int) int {
+	end, _ := scanString(code, i)
+	return end
+}
+
+// scanString is stringEnd, also reporting whether the literal was closed.
+func scanString(code string, i int) (int, bool) {
+	q := code[i]
+	triple := strings.HasPrefix(code[i:], strings.Repeat(string(q), 3))
+	j := i + 1
+	if triple {
+		j = i + 3
+	}
+	for j < len(code) {
+		switch c := code[j]; {
+		case c == '\\':
+			j += 2
+			continue
+		case c == '\n' && !triple:
+			return j, false
+		case c == q && !triple:
+			return j + 1, true
+		case c == q && strings.HasPrefix(code[j:], strings.Repeat(string(q), 3)):
+			return j + 3, true
+		}
+		j++
+	}
+	return len(code), false
+}
+
+// scanTopLevel calls fn with the index of each byte of s that lies outside
+// strings and brackets, stopping when fn returns false.
+func scanTopLevel(s string, fn func(i int) bool) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'', '"':
+			i = stringEnd(s, i) - 1
+			continue
+		case '(', '[', '{':
+			if depth == 0 && !fn(i) {
+				return
+			}
+			depth++
+			continue
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+			continue
+		}
+		if depth == 0 && !fn(i) {
+			return
+		}
+	}
+}
+
+// indexTopLevel returns the index of the first top-level occurrence of sep in s, or -1.
+func indexTopLevel(s, sep string) int {
+	found := -1
+	scanTopLevel(s, func(i int) bool {
+		if strings.HasPrefix(s[i:], sep) {
+			found = i
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// splitTopLevel splits s at top-level commas, dropping empty trailing parts.
+func splitTopLevel(s string) []string {
+	var parts []string
+	last := 0
+	scanTopLevel(s, func(i int) bool {
+		if s[i] == ',' {
+			parts = append(parts, strings.TrimSpace(s[last:i]))
+			last = i + 1
+		}
+		return true
+	})
+	if rest := strings.TrimSpace(s[last:]); rest != "" {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+// matchingParen returns the index of the bracket closing the one at s[open].
+func matchingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"':
+			i = stringEnd(s, i) - 1
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// stringLiteral decodes text if it is a single plain or raw string literal,
+// as used for docstrings.
+func stringLiteral(text string) (string, bool) {
+	i := 0
+	raw := false
+	for i < len(text) && strings.IndexByte("rRuU", text[i]) >= 0 {
+		raw = raw || text[i] == 'r' || text[i] == 'R'
+		i++
+	}
+	if i >= len(text) || (text[i] != '\'' && text[i] != '"') {
+		return "", false
+	}
+	if end, closed := scanString(text, i); !closed || end != len(text) {
+		return "", false
+	}
+	q := 1
+	if strings.HasPrefix(text[i:], strings.Repeat(text[i:i+1], 3)) && len(text)-i >= 6 {
+		q = 3
+	}
+	body := text[i+q : len(text)-q]
+	if !raw {
+		body = unescapePython(body)
+	}
+	return body, true
+}
+
+// unescapePython resolves the common backslash escapes of a string literal.
+func unescapePython(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case '\n':
+			// Line continuation inside the literal.
+		case '\\', '\'', '"':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// cleanDoc normalizes docstring indentation like inspect.cleandoc.
+func cleanDoc(doc string) string {
+	lines := strings.Split(strings.ReplaceAll(doc, "\t", "        "), "\n")
+	margin := -1
+	for _, l := range lines[1:] {
+		if t := strings.TrimLeft(l, " "); t != "" {
+			if n := len(l) - len(t); margin < 0 || n < margin {
+				margin = n
+			}
+		}
+	}
+	lines[0] = strings.TrimLeft(lines[0], " ")
+	for i := 1; i < len(lines); i++ {
+		if margin > 0 && len(lines[i]) >= margin {
+			lines[i] = lines[i][margin:]
+		} else {
+			lines[i] = strings.TrimLeft(lines[i], " ")
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// codeOnly returns code with comments removed and string literals blanked,
+// keeping the replacement fields of f-strings, so identifiers can be found
+// by scanning the result.
+func codeOnly(code string) string {
+	b := []byte(code)
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; c {
+		case '#':
+			for i < len(b) && b[i] != '\n' {
+				b[i] = ' '
+				i++
+			}
+		case '\'', '"':
+			end := stringEnd(code, i)
+			fstring := false
+			for j := i - 1; j >= 0 && isIdentByte(code[j]); j-- {
+				fstring = fstring || code[j] == 'f' || code[j] == 'F'
+			}
+			blankString(b, code, i, end, fstring)
+			i = end - 1
+		}
+	}
+	return string(b)
+}
+
+// blankString overwrites the literal code[start:end] in b with spaces,
+// except newlines and, for f-strings, the expressions inside braces.
+func blankString(b []byte, code string, start, end int, fstring bool) {
+	depth := 0
+	for j := start; j < end; j++ {
+		c := code[j]
+		if fstring {
+			switch {
+			case depth == 0 && c == '{' && j+1 < end && code[j+1] == '{':
+				b[j], b[j+1] = ' ', ' '
+				j++
+				continue
+			case c == '{':
+				if depth++; depth == 1 {
+					b[j] = ' '
+					continue
+				}
+			case c == '}' && depth > 0:
+				if depth--; depth == 0 {
+					b[j] = ' '
+					continue
+				}
+			}
+			if depth > 0 {
+				continue
+			}
+		}
+		if c != '\n' {
+			b[j] = ' '
+		}
+	}
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// nameRef is a dotted name such as os.path.join found in code.
+type nameRef struct {
+	parts []string
+	// attr is set when the name follows a dot, e.g. the read_text of
+	// Path(p).read_text().
+	attr bool
+	// call is set when the name is called.
+	call bool
+}
+
+// nameRefs lists the dotted names code reads, skipping keyword argument
+// names and plain assignment targets.
+func nameRefs(code string) []nameRef {
+	s := codeOnly(code)
+	var refs []nameRef
+	for i := 0; i < len(s); {
+		c := s[i]
+		if !isIdentByte(c) || c >= '0' && c <= '9' {
+			i++
+			continue
+		}
+		ref := nameRef{attr: prevNonSpace(s, i) == '.'}
+		for {
+			j := i
+			for j < len(s) && isIdentByte(s[j]) {
+				j++
+			}
+			ref.parts = append(ref.parts, s[i:j])
+			i = j
+			k := skipSpaces(s, i)
+			if k < len(s) && s[k] == '.' {
+				if n := skipSpaces(s, k+1); n < len(s) && isIdentByte(s[n]) && !(s[n] >= '0' && s[n] <= '9') {
+					i = n
+					continue
+				}
+			}
+			break
+		}
+		k := skipSpaces(s, i)
+		if k < len(s) && s[k] == '=' && (k+1 == len(s) || s[k+1] != '=') {
+			continue
+		}
+		ref.call = k < len(s) && s[k] == '('
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\\' || s[i] == '\n' || s[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+func prevNonSpace(s string, i int) byte {
+	for i--; i >= 0; i-- {
+		if c := s[i]; c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != '\\' {
+			return c
+		}
+	}
+	return 0
+}
+
+// imports maps the names bound by the script's import statements to the
+// qualified names they refer to, e.g. "sleep" to "time.sleep".
+func imports(lines []logicalLine) map[string]string {
+	bound := make(map[string]string)
+	for _, l := range lines {
+		if rest, ok := cutKeyword(l.text, "import"); ok {
+			for _, item := range splitTopLevel(rest) {
+				name, alias := splitAlias(item)
+				if alias == "" {
+					// `import a.b` binds a.
+					alias, _, _ = strings.Cut(name, ".")
+					name = alias
+				}
+				bound[alias] = name
+			}
+		} else if rest, ok := cutKeyword(l.text, "from"); ok {
+			module, names, ok := strings.Cut(rest, " import ")
+			if !ok {
+				continue
+			}
+			module = strings.TrimSpace(module)
+			names = strings.Trim(strings.TrimSpace(names), "()")
+			for _, item := range splitTopLevel(names) {
+				name, alias := splitAlias(item)
+				if alias == "" {
+					alias = name
+				}
+				bound[alias] = module + "." + name
+			}
+		}
+	}
+	return bound
+}
+
+// splitAlias splits "name as alias".
+func splitAlias(item string) (name, alias string) {
+	if n, a, ok := strings.Cut(item, " as "); ok {
+		return strings.TrimSpace(n), strings.TrimSpace(a)
+	}
+	return strings.TrimSpace(item), ""
+}
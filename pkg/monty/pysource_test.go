@@ -0,0 +1,37 @@
+package monty
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLogicalLines(t *testing.T) {
+	code := "x = ('a#b',  # comment\n     2)\ny = 1; z = \\\n  3\n\n    s = '''one\ntwo'''\n"
+	want := []logicalLine{
+		{text: "x = ('a#b',        2)", line: 1},
+		{text: "y = 1", line: 3},
+		{text: "z =    3", line: 3},
+		{text: "s = '''one\ntwo'''", line: 6, indent: 4},
+	}
+	if got := logicalLines(code); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected lines:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	for text, want := range map[string]string{
+		`"plain"`:           "plain",
+		`'a\'b\n'`:          "a'b\n",
+		`r"raw\n"`:          `raw\n`,
+		`"""tri "q" ple"""`: `tri "q" ple`,
+	} {
+		if got, ok := stringLiteral(text); !ok || got != want {
+			t.Errorf("stringLiteral(%s) = %q, %v; want %q", text, got, ok, want)
+		}
+	}
+	for _, text := range []string{`f"x"`, `"a" + "b"`, `x`, `"unterminated`} {
+		if _, ok := stringLiteral(text); ok {
+			t.Errorf("stringLiteral(%s) should not be a docstring", text)
+		}
+	}
+}
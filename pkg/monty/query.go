@@ -0,0 +1,376 @@
+package monty
+
+import (
+	"bytes"
+	"encoding/json"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// Get returns the value at path, or nil when there is none. A path is a
+// sequence of keys separated by dots, with list and tuple positions written
+// as [n] (or as a numeric key); negative positions count from the end:
+//
+//	o.Get("user.items[2].price")
+//	o.Get("rows[-1].0")
+//
+// Keys look through dicts, dataclass attributes, and named tuple fields as
+// the interpreter encodes them, so paths read like the Python expression.
+// A backslash escapes a dot or bracket that is part of a key. Only the
+// values along the path are scanned; nothing is unmarshaled.
+func (o Object) Get(path string) Object {
+	steps, ok := parsePath(path)
+	if !ok {
+		return nil
+	}
+	cur := bytes.TrimSpace(o)
+	for _, st := range steps {
+		if cur = lookupStep(cur, st); cur == nil {
+			return nil
+		}
+	}
+	return cur
+}
+
+// Items returns an iterator over the elements of a list, tuple, named
+// tuple, set, or frozenset. It scans o one element at a time instead of
+// decoding it into a []Object, so results of hundreds of thousands of
+// records are walked without allocating a slice for them. Elements share
+// o's memory. For other values it yields nothing.
+//
+//	for row := range result.Items() {
+//		var r Record
+//		if err := row.Decode(&r); err != nil { ... }
+//	}
+func (o Object) Items() iter.Seq[Object] {
+	return func(yield func(Object) bool) {
+		eachElement(sequenceElements(bytes.TrimSpace(o)), func(_ int, val []byte) bool {
+			return yield(Object(val))
+		})
+	}
+}
+
+// sequenceElements returns the JSON array holding the elements of v.
+func sequenceElements(v []byte) []byte {
+	if len(v) == 0 {
+		return nil
+	}
+	switch v[0] {
+	case '[':
+		return v
+	case '{':
+	default:
+		return nil
+	}
+	switch tag, inner := taggedValue(v); tag {
+	case "$tuple", "$set", "$frozenset":
+		return inner
+	case "$named_tuple":
+		return memberValue(inner, "values")
+	}
+	return nil
+}
+
+type pathStep struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+func parsePath(path string) ([]pathStep, bool) {
+	var steps []pathStep
+	var key strings.Builder
+	pending := false
+	flush := func() {
+		if pending {
+			steps = append(steps, pathStep{key: key.String()})
+		}
+		key.Reset()
+		pending = false
+	}
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			if i+1 < len(path) {
+				i++
+				key.WriteByte(path[i])
+				pending = true
+			}
+		case '.':
+			flush()
+		case '[':
+			flush()
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, false
+			}
+			n, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil {
+				return nil, false
+			}
+			steps = append(steps, pathStep{index: n, isIdx: true})
+			i += end
+		default:
+			key.WriteByte(c)
+			pending = true
+		}
+	}
+	flush()
+	return steps, true
+}
+
+// lookupStep applies one path step to the JSON value v.
+func lookupStep(v []byte, st pathStep) []byte {
+	if len(v) == 0 {
+		return nil
+	}
+	if !st.isIdx {
+		if n, err := strconv.Atoi(st.key); err == nil && v[0] == '[' {
+			return elementAt(v, n)
+		}
+	}
+	switch v[0] {
+	case '[':
+		if st.isIdx {
+			return elementAt(v, st.index)
+		}
+		return nil
+	case '{':
+	default:
+		return nil
+	}
+
+	tag, inner := taggedValue(v)
+	switch tag {
+	case "$tuple", "$named_tuple":
+		if tag == "$named_tuple" {
+			if !st.isIdx {
+				return namedField(inner, st.key)
+			}
+			inner = memberValue(inner, "values")
+		}
+		if st.isIdx {
+			return elementAt(inner, st.index)
+		}
+		if n, err := strconv.Atoi(st.key); err == nil {
+			return elementAt(inner, n)
+		}
+		return nil
+	case "$dict":
+		return pairValue(inner, st)
+	case "$dataclass":
+		if st.isIdx {
+			return nil
+		}
+		return pairValue(memberValue(inner, "attrs"), st)
+	}
+	if st.isIdx {
+		return nil
+	}
+	return memberValue(v, st.key)
+}
+
+// taggedValue returns the tag and payload of a single-key object whose key
+// starts with "$".
+func taggedValue(v []byte) (tag string, inner []byte) {
+	count := 0
+	eachMember(v, func(key string, val []byte) bool {
+		count++
+		tag, inner = key, val
+		return count < 2
+	})
+	if count != 1 || !strings.HasPrefix(tag, "$") {
+		return "", nil
+	}
+	return tag, inner
+}
+
+// pairValue finds the value whose key matches st in a [[key, value], ...]
+// list. Keys match as strings, or as ints for index steps and numeric keys.
+func pairValue(pairs []byte, st pathStep) []byte {
+	var found []byte
+	eachElement(pairs, func(_ int, pair []byte) bool {
+		k, val := elementAt(pair, 0), elementAt(pair, 1)
+		if k == nil || val == nil {
+			return true
+		}
+		var match bool
+		switch {
+		case st.isIdx:
+			match = string(k) == strconv.Itoa(st.index)
+		case k[0] == '"':
+			s, ok := jsonString(k)
+			match = ok && s == st.key
+		default:
+			match = string(k) == st.key
+		}
+		if match {
+			found = val
+		}
+		return !match
+	})
+	return found
+}
+
+// namedField finds field name in a $named_tuple payload.
+func namedField(inner []byte, name string) []byte {
+	idx := -1
+	eachElement(memberValue(inner, "field_names"), func(i int, f []byte) bool {
+		if s, ok := jsonString(f); ok && s == name {
+			idx = i
+			return false
+		}
+		return true
+	})
+	if idx < 0 {
+		return nil
+	}
+	return elementAt(memberValue(inner, "values"), idx)
+}
+
+func memberValue(v []byte, key string) []byte {
+	var found []byte
+	eachMember(v, func(k string, val []byte) bool {
+		if k == key {
+			found = val
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+func elementAt(v []byte, n int) []byte {
+	if n < 0 {
+		count := 0
+		eachElement(v, func(int, []byte) bool { count++; return true })
+		n += count
+		if n < 0 {
+			return nil
+		}
+	}
+	var found []byte
+	eachElement(v, func(i int, val []byte) bool {
+		if i == n {
+			found = val
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// eachMember calls fn with each key and value of the JSON object v until fn
+// returns false. It does nothing if v is not an object.
+func eachMember(v []byte, fn func(key string, val []byte) bool) {
+	if len(v) == 0 || v[0] != '{' {
+		return
+	}
+	i := skipJSONSpace(v, 1)
+	for i < len(v) && v[i] != '}' {
+		end := skipJSONValue(v, i)
+		if end < 0 || v[i] != '"' {
+			return
+		}
+		key, ok := jsonString(v[i:end])
+		if !ok {
+			return
+		}
+		i = skipJSONSpace(v, end)
+		if i >= len(v) || v[i] != ':' {
+			return
+		}
+		start := skipJSONSpace(v, i+1)
+		end = skipJSONValue(v, start)
+		if end <= start || !fn(key, v[start:end]) {
+			return
+		}
+		i = skipJSONSpace(v, end)
+		if i < len(v) && v[i] == ',' {
+			i = skipJSONSpace(v, i+1)
+		}
+	}
+}
+
+// eachElement calls fn with each element of the JSON array v until fn
+// returns false. It does nothing if v is not an array.
+func eachElement(v []byte, fn func(i int, val []byte) bool) {
+	if len(v) == 0 || v[0] != '[' {
+		return
+	}
+	i := skipJSONSpace(v, 1)
+	for n := 0; i < len(v) && v[i] != ']'; n++ {
+		end := skipJSONValue(v, i)
+		// Malformed input, such as a stray colon, has no value to skip.
+		if end <= i || !fn(n, v[i:end]) {
+			return
+		}
+		i = skipJSONSpace(v, end)
+		if i < len(v) && v[i] == ',' {
+			i = skipJSONSpace(v, i+1)
+		}
+	}
+}
+
+// skipJSONValue returns the offset just past the value starting at i, or -1
+// if the input ends first. It returns i itself when no value starts there.
+func skipJSONValue(v []byte, i int) int {
+	if i >= len(v) {
+		return -1
+	}
+	depth := 0
+	for ; i < len(v); i++ {
+		switch v[i] {
+		case '"':
+			for i++; i < len(v) && v[i] != '"'; i++ {
+				if v[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(v) {
+				return -1
+			}
+			if depth == 0 {
+				return i + 1
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+			if depth < 0 {
+				return i
+			}
+		case ',', ' ', '\t', '\n', '\r', ':':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	if depth != 0 {
+		return -1
+	}
+	return i
+}
+
+func skipJSONSpace(v []byte, i int) int {
+	for i < len(v) && (v[i] == ' ' || v[i] == '\t' || v[i] == '\n' || v[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// jsonString decodes a JSON string literal, unescaping only when needed.
+func jsonString(raw []byte) (string, bool) {
+	if len(raw) < 2 || raw[0] != '"' {
+		return "", false
+	}
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw[1 : len(raw)-1]), true
+	}
+	var s string
+	return s, json.Unmarshal(raw, &s) == nil
+}
@@ -0,0 +1,173 @@
+package monty
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// Priority orders work waiting in a Queue; higher runs first.
+type Priority int
+
+const (
+	PriorityBatch       Priority = -10
+	PriorityNormal      Priority = 0
+	PriorityInteractive Priority = 10
+)
+
+// WithQueue makes every interpreter step of a run, from Start or a resume to
+// the next event, take a slot in q at priority p, so runs of different
+// priorities share the native library fairly. Handlers run outside the slot.
+// A step waits for its slot under the run's context and fails with the
+// context's error if it ends first. A step abandoned by WithWatchdog keeps
+// its slot until it returns.
+func WithQueue(q *Queue, p Priority) Option {
+	return func(c *config) {
+		c.queue = q
+		c.priority = p
+	}
+}
+
+// Queue bounds how many interpreter steps run at once. Waiting steps are
+// admitted highest priority first and in arrival order within a priority,
+// so interactive runs are not starved by batch jobs in the same process.
+type Queue struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	seq     uint64
+	waiting waitHeap
+	stats   QueueStats
+}
+
+// QueueStats describes a Queue's load.
+type QueueStats struct {
+	// Running counts the slots in use.
+	Running int
+	// Depth counts the steps waiting for a slot, by priority.
+	Depth map[Priority]int
+	// MaxDepth is the most steps ever waiting at once.
+	MaxDepth int
+	// Admitted counts the steps given a slot.
+	Admitted uint64
+	// TotalWait and MaxWait sum and bound the time admitted steps waited.
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// NewQueue returns a queue that runs at most concurrency steps at once.
+func NewQueue(concurrency int) *Queue {
+	return &Queue{limit: max(concurrency, 1)}
+}
+
+// Acquire waits for a slot at priority p. Each successful Acquire must be
+// paired with a Release.
+func (q *Queue) Acquire(ctx context.Context, p Priority) error {
+	began := time.Now()
+	q.mu.Lock()
+	if q.running < q.limit && q.waiting.Len() == 0 {
+		q.running++
+		q.admitted(0)
+		q.mu.Unlock()
+		return nil
+	}
+	q.seq++
+	w := &waiter{priority: p, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiting, w)
+	q.stats.MaxDepth = max(q.stats.MaxDepth, q.waiting.Len())
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		q.mu.Lock()
+		q.admitted(time.Since(began))
+		q.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if w.index < 0 {
+		// Granted while giving up; pass the slot on.
+		q.release()
+	} else {
+		heap.Remove(&q.waiting, w.index)
+	}
+	return contextError(ctx)
+}
+
+// Release returns a slot taken by Acquire.
+func (q *Queue) Release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.release()
+}
+
+func (q *Queue) release() {
+	q.running--
+	if q.waiting.Len() > 0 {
+		w := heap.Pop(&q.waiting).(*waiter)
+		q.running++
+		close(w.ready)
+	}
+}
+
+func (q *Queue) admitted(wait time.Duration) {
+	q.stats.Admitted++
+	q.stats.TotalWait += wait
+	q.stats.MaxWait = max(q.stats.MaxWait, wait)
+}
+
+// Stats reports the queue's current load and cumulative wait times.
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := q.stats
+	stats.Running = q.running
+	stats.Depth = make(map[Priority]int)
+	for _, w := range q.waiting {
+		stats.Depth[w.priority]++
+	}
+	return stats
+}
+
+type waiter struct {
+	priority Priority
+	seq      uint64
+	index    int
+	ready    chan struct{}
+}
+
+// waitHeap orders waiters by descending priority, then arrival.
+type waitHeap []*waiter
+
+func (h waitHeap) Len() int { return len(h) }
+
+func (h waitHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waitHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waitHeap) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waitHeap) Pop() any {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	w.index = -1
+	return w
+}
@@ -0,0 +1,82 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitDepth polls until n steps are waiting in q.
+func waitDepth(t *testing.T, q *Queue, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		depth := 0
+		for _, d := range q.Stats().Depth {
+			depth += d
+		}
+		if depth == n {
+			return
+		}
+	}
+	t.Fatalf("queue never reached depth %d", n)
+}
+
+func TestQueueAdmitsByPriority(t *testing.T) {
+	q := NewQueue(1)
+	ctx := context.Background()
+	if err := q.Acquire(ctx, PriorityNormal); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan Priority, 3)
+	for i, p := range []Priority{PriorityBatch, PriorityBatch, PriorityInteractive} {
+		go func() {
+			q.Acquire(ctx, p)
+			order <- p
+			q.Release()
+		}()
+		waitDepth(t, q, i+1)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := q.Acquire(cancelled, PriorityInteractive); !errors.Is(err, context.Canceled) {
+		t.Fatalf("a cancelled wait should fail, got %v", err)
+	}
+	if depth := q.Stats().Depth; depth[PriorityBatch] != 2 || depth[PriorityInteractive] != 1 {
+		t.Fatalf("unexpected depth %v", depth)
+	}
+
+	q.Release()
+	want := []Priority{PriorityInteractive, PriorityBatch, PriorityBatch}
+	for _, p := range want {
+		if got := <-order; got != p {
+			t.Fatalf("expected %v to run next, got %v", p, got)
+		}
+	}
+	stats := q.Stats()
+	if stats.Admitted != 4 || stats.MaxDepth != 4 || stats.MaxWait <= 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestWithQueueGatesSteps(t *testing.T) {
+	var ops []string
+	q := NewQueue(2)
+	m, err := New("double(21)", "main.py", nil, []string{"double"},
+		WithSandbox(eventsBridge(&ops)), WithQueue(q, PriorityInteractive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Snapshot.Resume(p.CallID, 42); err != nil {
+		t.Fatal(err)
+	}
+	if stats := q.Stats(); stats.Admitted != 2 || stats.Running != 0 {
+		t.Fatalf("expected Start and Resume to pass through the queue, got %+v", stats)
+	}
+}
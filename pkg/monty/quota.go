@@ -0,0 +1,244 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Budget caps what one tenant may use. Zero fields are unlimited.
+type Budget struct {
+	// CPU caps the wall time spent inside the interpreter, between Start or
+	// a resume and the next event.
+	CPU time.Duration
+	// Memory caps the bytes of values moved across the bridge.
+	Memory int64
+	// Storage caps the bytes held in stores wrapped by Quotas.Store.
+	Storage int64
+	// Calls caps the external and OS calls scripts make.
+	Calls int64
+	// Window, when positive, restarts CPU, memory, and call accounting each
+	// time it elapses; storage is held until deleted. Zero accumulates use
+	// until Reset.
+	Window time.Duration
+}
+
+// Usage is what a tenant has used in the current window.
+type Usage struct {
+	CPU     time.Duration
+	Memory  int64
+	Storage int64
+	Calls   int64
+}
+
+// QuotaError reports a start refused because a tenant exhausted a budget.
+// RetryAfter is when the tenant's window resets, or zero if waiting will not
+// help.
+type QuotaError struct {
+	Tenant     string
+	Resource   string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("monty: tenant %s exhausted its %s budget", e.Tenant, e.Resource)
+}
+
+// Unwrap classifies quota violations as ErrQuotaExceeded.
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// Quotas tracks cumulative resource use per tenant across runs. A program
+// started WithQuota is charged to its tenant as it runs, and Start fails
+// with a *QuotaError once any of the tenant's budgets is exhausted. Runs in
+// progress are not stopped; bound them with Limits, WithWatchdog, or
+// WithMaxCalls.
+type Quotas struct {
+	// Throttle makes Runner.Run wait for an exhausted tenant's window to
+	// reset instead of failing.
+	Throttle bool
+
+	mu      sync.Mutex
+	tenants map[string]*tenantUsage
+	now     func() time.Time
+}
+
+type tenantUsage struct {
+	budget Budget
+	used   Usage
+	since  time.Time
+}
+
+// NewQuotas returns a quota manager with no budgets set.
+func NewQuotas() *Quotas {
+	return &Quotas{tenants: make(map[string]*tenantUsage), now: time.Now}
+}
+
+// WithQuota charges runs to tenant in q.
+func WithQuota(q *Quotas, tenant string) Option {
+	return func(c *config) {
+		c.quota = &tenantQuota{q: q, tenant: tenant}
+	}
+}
+
+// SetBudget sets tenant's budget, keeping its usage so far.
+func (q *Quotas) SetBudget(tenant string, b Budget) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.tenant(tenant).budget = b
+}
+
+// Usage reports what tenant has used in the current window.
+func (q *Quotas) Usage(tenant string) Usage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.tenant(tenant).used
+}
+
+// Reset clears tenant's CPU, memory, and call usage and starts a new window.
+func (q *Quotas) Reset(tenant string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := q.tenant(tenant)
+	t.used = Usage{Storage: t.used.Storage}
+	t.since = q.now()
+}
+
+// tenant returns the usage record for name, rolling its window over.
+func (q *Quotas) tenant(name string) *tenantUsage {
+	t, ok := q.tenants[name]
+	now := q.now()
+	if !ok {
+		t = &tenantUsage{since: now}
+		q.tenants[name] = t
+	}
+	if w := t.budget.Window; w > 0 && now.Sub(t.since) >= w {
+		t.used = Usage{Storage: t.used.Storage}
+		t.since = now
+	}
+	return t
+}
+
+// admit checks tenant's budgets before a start.
+func (q *Quotas) admit(tenant string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := q.tenant(tenant)
+	b, u := t.budget, t.used
+	resource := ""
+	switch {
+	case b.Storage > 0 && u.Storage >= b.Storage:
+		return &QuotaError{Tenant: tenant, Resource: "storage"}
+	case b.CPU > 0 && u.CPU >= b.CPU:
+		resource = "cpu"
+	case b.Memory > 0 && u.Memory >= b.Memory:
+		resource = "memory"
+	case b.Calls > 0 && u.Calls >= b.Calls:
+		resource = "calls"
+	default:
+		return nil
+	}
+	var retry time.Duration
+	if b.Window > 0 {
+		retry = t.since.Add(b.Window).Sub(q.now())
+	}
+	return &QuotaError{Tenant: tenant, Resource: resource, RetryAfter: retry}
+}
+
+func (q *Quotas) charge(tenant string, u Usage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := q.tenant(tenant)
+	t.used.CPU += u.CPU
+	t.used.Memory += u.Memory
+	t.used.Storage += u.Storage
+	t.used.Calls += u.Calls
+}
+
+// Store wraps s so the bytes it holds count against tenant's storage budget.
+// Puts that would exceed the budget fail with a *QuotaError.
+func (q *Quotas) Store(tenant string, s Store) Store {
+	return &quotaStore{q: q, tenant: tenant, s: s, sizes: make(map[string]int64)}
+}
+
+type quotaStore struct {
+	q      *Quotas
+	tenant string
+	s      Store
+
+	mu    sync.Mutex
+	sizes map[string]int64
+}
+
+func (s *quotaStore) Put(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delta := int64(len(data)) - s.sizes[key]
+	s.q.mu.Lock()
+	t := s.q.tenant(s.tenant)
+	over := delta > 0 && t.budget.Storage > 0 && t.used.Storage+delta > t.budget.Storage
+	s.q.mu.Unlock()
+	if over {
+		return &QuotaError{Tenant: s.tenant, Resource: "storage"}
+	}
+	if err := s.s.Put(ctx, key, data); err != nil {
+		return err
+	}
+	s.sizes[key] = int64(len(data))
+	s.q.charge(s.tenant, Usage{Storage: delta})
+	return nil
+}
+
+func (s *quotaStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.s.Get(ctx, key)
+}
+
+func (s *quotaStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.s.Delete(ctx, key); err != nil {
+		return err
+	}
+	s.q.charge(s.tenant, Usage{Storage: -s.sizes[key]})
+	delete(s.sizes, key)
+	return nil
+}
+
+// tenantQuota is the tenant a run is charged to.
+type tenantQuota struct {
+	q      *Quotas
+	tenant string
+}
+
+func (t *tenantQuota) admit() error {
+	if t == nil {
+		return nil
+	}
+	return t.q.admit(t.tenant)
+}
+
+func (t *tenantQuota) charge(u Usage) {
+	if t != nil {
+		t.q.charge(t.tenant, u)
+	}
+}
+
+// throttle waits for an exhausted tenant's window to reset when the run's
+// Quotas throttle, reporting whether the start should be retried.
+func (r *Runner) throttle(ctx context.Context, err error) bool {
+	var qe *QuotaError
+	if r.cfg.quota == nil || !r.cfg.quota.q.Throttle || !errors.As(err, &qe) || qe.RetryAfter <= 0 {
+		return false
+	}
+	timer := time.NewTimer(qe.RetryAfter)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,70 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuotasRejectAndThrottle(t *testing.T) {
+	var ops []string
+	q := NewQuotas()
+	q.SetBudget("acme", Budget{Calls: 1})
+	m, err := New("double(21)", "main.py", nil, []string{"double"},
+		WithSandbox(eventsBridge(&ops)), WithQuota(q, "acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	r := NewRunner(m)
+	r.Register("double", func(ctx context.Context, call CallInfo) (any, error) { return 42, nil })
+	if _, err := r.Run(context.Background()); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	if u := q.Usage("acme"); u.Calls != 1 || u.Memory == 0 {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+	_, err = r.Run(context.Background())
+	var qe *QuotaError
+	if !errors.As(err, &qe) || !errors.Is(err, ErrQuotaExceeded) || qe.Resource != "calls" || qe.RetryAfter != 0 {
+		t.Fatalf("expected the calls budget to refuse the start, got %v", err)
+	}
+
+	q.Throttle = true
+	q.SetBudget("acme", Budget{Calls: 1, Window: 20 * time.Millisecond})
+	q.Reset("acme")
+	if _, err := r.Run(context.Background()); err != nil {
+		t.Fatalf("run in a fresh window failed: %v", err)
+	}
+	began := time.Now()
+	if _, err := r.Run(context.Background()); err != nil {
+		t.Fatalf("throttled run failed: %v", err)
+	}
+	if waited := time.Since(began); waited < 10*time.Millisecond {
+		t.Fatalf("throttled run should wait for the window to reset, waited %v", waited)
+	}
+}
+
+func TestQuotaStoreChargesStorage(t *testing.T) {
+	ctx := context.Background()
+	q := NewQuotas()
+	q.SetBudget("acme", Budget{Storage: 8})
+	s := q.Store("acme", NewMemoryStore())
+	if err := s.Put(ctx, "a", []byte("12345")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "b", []byte("12345")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected the storage budget to refuse the put, got %v", err)
+	}
+	if err := s.Put(ctx, "a", []byte("1234567")); err != nil {
+		t.Fatalf("overwriting within budget failed: %v", err)
+	}
+	if err := s.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if u := q.Usage("acme"); u.Storage != 0 {
+		t.Fatalf("expected deletes to release storage, got %d", u.Storage)
+	}
+}
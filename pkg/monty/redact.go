@@ -0,0 +1,145 @@
+package monty
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Redactor scrubs sensitive values from a call before it reaches logs,
+// traces, or audit records. It must not modify the call it is given.
+type Redactor interface {
+	Redact(call CallInfo) CallInfo
+}
+
+// DefaultRedaction replaces redacted values when RedactionRules leaves it unset.
+const DefaultRedaction = "[REDACTED]"
+
+// RedactionRules is a Redactor combining value patterns with per-function field rules.
+type RedactionRules struct {
+	// Patterns are matched against every string value; matches are replaced.
+	Patterns []*regexp.Regexp
+	// Fields maps a function name to the kwarg names and dict keys whose values
+	// are replaced wholesale. The "*" entry applies to every function.
+	Fields map[string][]string
+	// Replacement substitutes redacted values; empty means DefaultRedaction.
+	Replacement string
+}
+
+// WithRedactor scrubs call arguments before they are logged, traced, or audited.
+func WithRedactor(r Redactor) Option {
+	return func(c *config) {
+		c.redactor = r
+	}
+}
+
+// Redact implements Redactor.
+func (rr RedactionRules) Redact(call CallInfo) CallInfo {
+	fields := append(append([]string{}, rr.Fields["*"]...), rr.Fields[call.Name]...)
+	out := call
+	out.Args = make([]Object, len(call.Args))
+	for i, arg := range call.Args {
+		out.Args[i] = rr.redactObject(arg, fields)
+	}
+	out.Kwargs = make([]KV, len(call.Kwargs))
+	for i, kv := range call.Kwargs {
+		var name string
+		if kv.Key.Unmarshal(&name) == nil && slices.Contains(fields, name) {
+			out.Kwargs[i] = KV{Key: kv.Key, Value: rr.replacement()}
+			continue
+		}
+		out.Kwargs[i] = KV{Key: kv.Key, Value: rr.redactObject(kv.Value, fields)}
+	}
+	return out
+}
+
+func (rr RedactionRules) replacement() Object {
+	data, _ := json.Marshal(rr.replacementText())
+	return Object(data)
+}
+
+func (rr RedactionRules) redactObject(obj Object, fields []string) Object {
+	value, err := objectToInterface(obj)
+	if err != nil || value == nil {
+		return obj
+	}
+	data, err := json.Marshal(rr.redactValue(value, fields))
+	if err != nil {
+		return obj
+	}
+	return Object(data)
+}
+
+func (rr RedactionRules) redactValue(value any, fields []string) any {
+	switch v := value.(type) {
+	case string:
+		for _, re := range rr.Patterns {
+			v = re.ReplaceAllString(v, rr.replacementText())
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = rr.redactValue(v[i], fields)
+		}
+		return v
+	case map[string]any:
+		if pairs, ok := v["$dict"].([]any); ok && len(v) == 1 {
+			for _, entry := range pairs {
+				pair, ok := entry.([]any)
+				if !ok || len(pair) != 2 {
+					continue
+				}
+				if key, ok := pair[0].(string); ok && slices.Contains(fields, key) {
+					pair[1] = rr.replacementText()
+					continue
+				}
+				pair[1] = rr.redactValue(pair[1], fields)
+			}
+			return v
+		}
+		for k, item := range v {
+			if slices.Contains(fields, k) {
+				v[k] = rr.replacementText()
+				continue
+			}
+			v[k] = rr.redactValue(item, fields)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+func (rr RedactionRules) replacementText() string {
+	if rr.Replacement == "" {
+		return DefaultRedaction
+	}
+	return rr.Replacement
+}
+
+// redact applies the configured Redactor, if any.
+func (c *config) redact(call CallInfo) CallInfo {
+	if c.redactor == nil {
+		return call
+	}
+	return c.redactor.Redact(call)
+}
+
+// joinObjects renders objects for log output.
+func joinObjects(objs []Object) string {
+	parts := make([]string, len(objs))
+	for i, obj := range objs {
+		parts[i] = string(obj)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// joinKwargs renders keyword arguments for log output.
+func joinKwargs(kvs []KV) string {
+	parts := make([]string, len(kvs))
+	for i, kv := range kvs {
+		parts[i] = string(kv.Key) + ": " + string(kv.Value)
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
@@ -0,0 +1,36 @@
+package monty
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedactionRules(t *testing.T) {
+	rules := RedactionRules{
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`sk_[a-z0-9]+`)},
+		Fields:   map[string][]string{"login": {"password"}},
+	}
+	call := CallInfo{
+		Name: "login",
+		Args: []Object{
+			Object(`"token sk_abc123 here"`),
+			Object(`{"$dict":[["user","ada"],["password","hunter2"]]}`),
+		},
+		Kwargs: []KV{{Key: Object(`"password"`), Value: Object(`"hunter2"`)}},
+	}
+
+	red := rules.Redact(call)
+	if strings.Contains(string(red.Args[0]), "sk_abc123") {
+		t.Fatalf("pattern not redacted: %s", red.Args[0])
+	}
+	if strings.Contains(string(red.Args[1]), "hunter2") || !strings.Contains(string(red.Args[1]), "ada") {
+		t.Fatalf("dict field not redacted correctly: %s", red.Args[1])
+	}
+	if string(red.Kwargs[0].Value) != `"[REDACTED]"` {
+		t.Fatalf("kwarg not redacted: %s", red.Kwargs[0].Value)
+	}
+	if !strings.Contains(string(call.Args[0]), "sk_abc123") {
+		t.Fatalf("Redact modified the original call")
+	}
+}
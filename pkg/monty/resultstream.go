@@ -0,0 +1,104 @@
+package monty
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// WithResultStreaming hands final results larger than threshold bytes to
+// the host as a ResultStream in Progress.ResultStream, leaving
+// Progress.Result nil, so hosts can stream huge outputs to storage. The
+// in-process library's JSON is read where it lies rather than copied into
+// Go memory. Runner.Run, Monty.Run, and Drive read streamed results back
+// into an Object, and traces do not record them.
+//
+// The library does not deliver results in chunks: it encodes the whole
+// result before returning, so streaming saves the copy into Go memory but
+// not the library's allocation. Results from a sandbox child or a
+// monty_wasm host are received in full before they are streamed.
+func WithResultStreaming(threshold int64) Option {
+	return func(c *config) {
+		c.streamResults = threshold
+	}
+}
+
+// ResultStream reads the JSON encoding of a final result, in the form of
+// Object. Close it once done, or when abandoning it, to free the memory it
+// holds; a finalizer frees it otherwise.
+type ResultStream struct {
+	mu   sync.Mutex
+	data []byte
+	r    *bytes.Reader
+	size int64
+	free func()
+}
+
+func newResultStream(data []byte, free func()) *ResultStream {
+	s := &ResultStream{data: data, r: bytes.NewReader(data), size: int64(len(data)), free: free}
+	if free != nil {
+		runtime.SetFinalizer(s, func(s *ResultStream) { s.Close() })
+	}
+	return s
+}
+
+// Size returns the length of the result in bytes.
+func (s *ResultStream) Size() int64 {
+	return s.size
+}
+
+func (s *ResultStream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.r == nil {
+		return 0, newError(ErrClosed, "monty: result stream closed")
+	}
+	return s.r.Read(p)
+}
+
+// WriteTo writes the rest of the result to w, without an intermediate
+// buffer.
+func (s *ResultStream) WriteTo(w io.Writer) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.r == nil {
+		return 0, newError(ErrClosed, "monty: result stream closed")
+	}
+	return s.r.WriteTo(w)
+}
+
+// Object reads the rest of the result into memory and closes s.
+func (s *ResultStream) Object() (Object, error) {
+	defer s.Close()
+	var buf bytes.Buffer
+	buf.Grow(int(s.size))
+	if _, err := s.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return Object(buf.Bytes()), nil
+}
+
+// Close frees the result. Closing twice is a no-op.
+func (s *ResultStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.r == nil {
+		return nil
+	}
+	s.data, s.r = nil, nil
+	if s.free != nil {
+		s.free()
+		s.free = nil
+	}
+	return nil
+}
+
+// completeResult returns the result of a Complete event, reading it from its
+// stream if it was streamed.
+func completeResult(p Progress) (Object, error) {
+	if p.ResultStream != nil {
+		return p.ResultStream.Object()
+	}
+	return p.Result, nil
+}
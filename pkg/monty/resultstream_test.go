@@ -0,0 +1,73 @@
+package monty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+)
+
+// resultBridge completes every run with result.
+func resultBridge(result string) *Sandbox {
+	return scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(completeProgress(json.RawMessage(result))),
+	})
+}
+
+func TestResultStreaming(t *testing.T) {
+	big := `"` + string(bytes.Repeat([]byte("x"), 100)) + `"`
+	m, err := New("x", "main.py", nil, nil, WithSandbox(resultBridge(big)), WithResultStreaming(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Result != nil || p.ResultStream == nil || p.ResultStream.Size() != int64(len(big)) {
+		t.Fatalf("Start = %+v, want a streamed result of %d bytes", p, len(big))
+	}
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(p.ResultStream, head); err != nil {
+		t.Fatal(err)
+	}
+	var rest bytes.Buffer
+	if _, err := io.Copy(&rest, p.ResultStream); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(head) + rest.String(); got != big {
+		t.Errorf("streamed %q", got)
+	}
+	p.ResultStream.Close()
+	if _, err := p.ResultStream.Read(head); !errors.Is(err, ErrClosed) {
+		t.Errorf("Read after Close: err = %v", err)
+	}
+
+	// Runner.Run reads the stream back.
+	obj, err := NewRunner(m).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(obj) != big {
+		t.Errorf("Run = %s", obj)
+	}
+}
+
+func TestResultStreamingThreshold(t *testing.T) {
+	m, err := New("x", "main.py", nil, nil, WithSandbox(resultBridge(`[1,2,3]`)), WithResultStreaming(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ResultStream != nil || string(p.Result) != `[1,2,3]` {
+		t.Errorf("small result streamed: %+v", p)
+	}
+}
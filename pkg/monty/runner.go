@@ -0,0 +1,268 @@
+package monty
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// CallInfo describes an external or OS call surfaced by a running program.
+type CallInfo struct {
+	Kind       ProgressKind
+	Name       string
+	Args       []Object
+	Kwargs     []KV
+	CallID     uint32
+	MethodCall bool
+}
+
+// Handler answers a call. Returning an error raises it inside the script.
+type Handler func(ctx context.Context, call CallInfo) (any, error)
+
+// Authorizer decides whether a call may be dispatched. Returning an error
+// raises it inside the script without invoking the handler.
+type Authorizer func(ctx context.Context, call CallInfo) error
+
+// WithAuthorizer consults auth before a Runner dispatches any external or OS call.
+func WithAuthorizer(auth Authorizer) Option {
+	return func(c *config) {
+		c.authorize = auth
+	}
+}
+
+// WithMaxCalls aborts a Runner's run with a *CallLimitError once the script
+// makes more than n external and OS calls, guarding against scripts that
+// call a host function in an endless loop. Zero means no limit.
+func WithMaxCalls(n int) Option {
+	return func(c *config) {
+		c.maxCalls = n
+	}
+}
+
+// CallLimitError reports a run aborted by WithMaxCalls. Name is the call
+// that exceeded the limit.
+type CallLimitError struct {
+	Max  int
+	Name string
+}
+
+func (e *CallLimitError) Error() string {
+	return fmt.Sprintf("monty: call to %s exceeds limit of %d external calls", e.Name, e.Max)
+}
+
+// Unwrap classifies call limit violations as ErrStepLimit.
+func (e *CallLimitError) Unwrap() error {
+	return ErrStepLimit
+}
+
+// RunWithHandlers runs m with inputs, answering external function calls from
+// handlers and aborting with a *CallLimitError after maxCalls calls. It is
+// shorthand for a Runner with WithMaxCalls.
+func RunWithHandlers(ctx context.Context, m *Monty, handlers map[string]Handler, maxCalls int, inputs ...any) (Object, error) {
+	r := NewRunner(m, WithMaxCalls(maxCalls))
+	for name, h := range handlers {
+		r.Register(name, h)
+	}
+	return r.Run(ctx, inputs...)
+}
+
+// Runner drives a program to completion, dispatching the calls it makes to
+// registered host handlers.
+type Runner struct {
+	m          *Monty
+	cfg        *config
+	handlers   map[string]Handler
+	osHandlers map[string]Handler
+	transforms []ResultTransform
+
+	// stop is cancelled when Shutdown interrupts the runs in flight.
+	stop      context.Context
+	interrupt context.CancelFunc
+	mu        sync.Mutex
+	closed    bool
+	runs      sync.WaitGroup
+}
+
+// NewRunner creates a Runner for m. Options override the ones m was compiled with.
+func NewRunner(m *Monty, opts ...Option) *Runner {
+	base := &config{}
+	if m != nil && m.cfg != nil {
+		base = m.cfg
+	}
+	stop, interrupt := context.WithCancel(context.Background())
+	return &Runner{
+		m:          m,
+		cfg:        base.with(opts),
+		handlers:   make(map[string]Handler),
+		osHandlers: make(map[string]Handler),
+		stop:       stop,
+		interrupt:  interrupt,
+	}
+}
+
+// Register installs the handler for an external function.
+func (r *Runner) Register(name string, h Handler) {
+	r.handlers[name] = h
+}
+
+// RegisterOs installs the handler for an OS function the run does not virtualize.
+func (r *Runner) RegisterOs(name string, h Handler) {
+	r.osHandlers[name] = h
+}
+
+// Run executes the program with inputs and returns its final result, passed
+// through the transforms registered with Transform. After Shutdown it fails
+// with ErrShutdown.
+func (r *Runner) Run(ctx context.Context, inputs ...any) (Object, error) {
+	if !r.enter() {
+		return nil, ErrShutdown
+	}
+	defer r.runs.Done()
+	if ctx.Err() != nil {
+		return nil, contextError(ctx)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(r.stop, cancel)()
+	if g := r.cfg.group; g != nil {
+		defer context.AfterFunc(g.ctx, cancel)()
+	}
+	progress, err := r.m.start(ctx, r.cfg, inputs)
+	for err != nil && r.throttle(ctx, err) {
+		progress, err = r.m.start(ctx, r.cfg, inputs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	calls := 0
+	async := newAsyncCalls()
+	defer close(async.done)
+	for {
+		if progress.Kind != Complete && r.interrupted() {
+			return nil, r.suspend(ctx, progress)
+		}
+		if ctx.Err() != nil {
+			closeProgress(progress)
+			if err := r.cfg.group.Err(); err != nil {
+				return nil, err
+			}
+			return nil, contextError(ctx)
+		}
+		switch progress.Kind {
+		case Complete:
+			result, err := completeResult(progress)
+			return r.transform(ctx, result, err)
+		case FunctionCall, OsCall:
+			if calls++; r.cfg.maxCalls > 0 && calls > r.cfg.maxCalls {
+				closeProgress(progress)
+				return nil, &CallLimitError{Max: r.cfg.maxCalls, Name: callInfo(progress).Name}
+			}
+			if progress.Kind == FunctionCall && r.cfg.asyncCalls[progress.FunctionName] {
+				progress, err = r.promote(ctx, progress, async)
+			} else {
+				progress, err = r.dispatch(ctx, progress)
+			}
+		case ResolveFutures:
+			progress, err = r.resolve(ctx, progress, async)
+		case Timer:
+			progress, err = r.sleep(ctx, progress)
+		default:
+			closeProgress(progress)
+			return nil, fmt.Errorf("monty: runner cannot handle progress kind %v", progress.Kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// dispatch answers a call through its handler and resumes the snapshot.
+func (r *Runner) dispatch(ctx context.Context, p Progress) (Progress, error) {
+	value, err := r.call(ctx, p)
+	if r.interrupted() {
+		// Leave the call unanswered so the suspended run repeats it.
+		return p, nil
+	}
+	if err != nil {
+		return p.Snapshot.ResumeError(p.CallID, errorMessage(err))
+	}
+	if value == nil {
+		value = none{}
+	}
+	return p.Snapshot.Resume(p.CallID, value)
+}
+
+// call authorizes the call p is paused at and invokes its handler, without
+// touching the snapshot. Errors are raised inside the script.
+func (r *Runner) call(ctx context.Context, p Progress) (any, error) {
+	call := callInfo(p)
+	if r.cfg.logger != nil {
+		logged := r.cfg.redact(call)
+		r.cfg.logger.LogAttrs(ctx, slog.LevelDebug, "monty call", append(progressAttrs(p),
+			slog.String("name", logged.Name),
+			slog.Uint64("call_id", uint64(logged.CallID)),
+			slog.String("args", joinObjects(logged.Args)),
+			slog.String("kwargs", joinKwargs(logged.Kwargs)),
+		)...)
+	}
+	if r.cfg.authorize != nil {
+		if err := r.cfg.authorize(ctx, call); err != nil {
+			return nil, err
+		}
+	}
+	handlers := r.handlers
+	if p.Kind == OsCall {
+		handlers = r.osHandlers
+	}
+	h, ok := handlers[call.Name]
+	if !ok {
+		return nil, fmt.Errorf("no handler registered for %s", call.Name)
+	}
+	return h(ctx, call)
+}
+
+// sleep waits out a Timer event, giving up when ctx is done.
+func (r *Runner) sleep(ctx context.Context, p Progress) (Progress, error) {
+	timer := time.NewTimer(p.Duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		if r.interrupted() {
+			return p, nil
+		}
+		p.Snapshot.Close()
+		return Progress{}, contextError(ctx)
+	case <-timer.C:
+		return p.Snapshot.Wake(p.CallID)
+	}
+}
+
+func callInfo(p Progress) CallInfo {
+	name := p.FunctionName
+	if p.Kind == OsCall {
+		name = p.OsFunction
+	}
+	return CallInfo{
+		Kind:       p.Kind,
+		Name:       name,
+		Args:       p.Args,
+		Kwargs:     p.Kwargs,
+		CallID:     p.CallID,
+		MethodCall: p.MethodCall,
+	}
+}
+
+// closeProgress frees whichever snapshot handle p carries.
+func closeProgress(p Progress) {
+	p.Snapshot.Close()
+	p.FutureSnapshot.Close()
+}
+
+func errorMessage(err error) string {
+	if msg := err.Error(); msg != "" {
+		return msg
+	}
+	return "monty: host error"
+}
@@ -0,0 +1,87 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRunnerDispatchesHandlers(t *testing.T) {
+	m := newTestMonty(t, "fetch(x) + 1", []string{"x"}, []string{"fetch"})
+
+	r := NewRunner(m)
+	r.Register("fetch", func(ctx context.Context, call CallInfo) (any, error) {
+		var x int
+		if err := call.Args[0].Unmarshal(&x); err != nil {
+			return nil, err
+		}
+		return x * 2, nil
+	})
+	result, err := r.Run(context.Background(), 20)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	var got int
+	if err := result.Unmarshal(&got); err != nil {
+		t.Fatalf("unmarshal result: %v", err)
+	}
+	if got != 41 {
+		t.Fatalf("expected 41, got %d", got)
+	}
+}
+
+func TestRunnerAuthorizerDenies(t *testing.T) {
+	m := newTestMonty(t, "delete_all()", nil, []string{"delete_all"})
+
+	called := false
+	r := NewRunner(m, WithAuthorizer(func(ctx context.Context, call CallInfo) error {
+		if call.Name == "delete_all" {
+			return errors.New("tenant may not delete")
+		}
+		return nil
+	}))
+	r.Register("delete_all", func(ctx context.Context, call CallInfo) (any, error) {
+		called = true
+		return nil, nil
+	})
+	if _, err := r.Run(context.Background()); err == nil {
+		t.Fatalf("expected denied call to raise")
+	}
+	if called {
+		t.Fatalf("handler ran despite authorizer denial")
+	}
+}
+
+func TestRunWithHandlersMaxCalls(t *testing.T) {
+	// The bridge scripts `while True: tick()`, recording freed snapshots.
+	var freed int
+	sb := scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(callProgress(1, "tick", "[]", 2)),
+		"resume":  reply(callProgress(1, "tick", "[]", 2)),
+		"free_snapshot": func(sandboxRequest) sandboxResponse {
+			freed++
+			return sandboxResponse{}
+		},
+	})
+	m, err := New("while True: tick()", "main.py", nil, []string{"tick"}, WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	ticks := 0
+	_, err = RunWithHandlers(context.Background(), m, map[string]Handler{
+		"tick": func(context.Context, CallInfo) (any, error) {
+			ticks++
+			return nil, nil
+		},
+	}, 5)
+	var limit *CallLimitError
+	if !errors.As(err, &limit) || limit.Name != "tick" || !errors.Is(err, ErrStepLimit) {
+		t.Fatalf("expected a CallLimitError for tick, got %v", err)
+	}
+	if ticks != 5 || freed != 1 {
+		t.Fatalf("expected 5 handled calls and the paused snapshot freed, got %d and %d", ticks, freed)
+	}
+}
@@ -0,0 +1,703 @@
+package monty
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSandboxExited is returned when the sandbox process dies mid-call. The
+// next call starts a fresh process; handles from the old one are lost.
+var ErrSandboxExited = errors.New("monty: sandbox process exited")
+
+// maxFrameBytes bounds a single protocol frame.
+const maxFrameBytes = 1 << 30
+
+// sandboxCloseGrace is how long Close waits for the child to exit after
+// closing its stdin before killing it.
+var sandboxCloseGrace = 5 * time.Second
+
+// Sandbox runs libmonty_ffi in a child process so a panic or memory bug in
+// native code kills only the child. The process is started on first use and
+// restarted after it dies. The child must call ServeSandbox on its stdin and
+// stdout; cmd/monty-sandbox is a ready-made binary that does.
+type Sandbox struct {
+	// CallTimeout, when positive, bounds each request to the child. A call
+	// that overruns it kills the child and fails with ErrTimeout and
+	// ErrSandboxExited. Bridged sandboxes ignore it.
+	CallTimeout time.Duration
+
+	path string
+	args []string
+	// bridge, when set, carries each encoded request to a host that runs the
+	// library instead of a child process.
+	bridge func(req []byte) ([]byte, error)
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	out    *bufio.Reader
+	gen    uint64
+	closed bool
+	// proc is the running child, readable without mu so the watchdog can
+	// kill it while a call holds mu.
+	proc atomic.Pointer[os.Process]
+
+	// frees holds the handles of finalized values, released by a background
+	// goroutine so finalizers never wait on the child.
+	freeMu  sync.Mutex
+	frees   []pendingFree
+	freeing bool
+}
+
+type pendingFree struct {
+	op string
+	h  any
+}
+
+// NewSandbox returns a sandbox that runs path with args when first used.
+func NewSandbox(path string, args ...string) *Sandbox {
+	return &Sandbox{path: path, args: append([]string(nil), args...)}
+}
+
+// NewSandboxBridge returns a sandbox that hands each JSON request to
+// roundTrip instead of a child process and decodes the JSON it returns,
+// e.g. to reach a SandboxHost over another transport.
+func NewSandboxBridge(roundTrip func(req []byte) ([]byte, error)) *Sandbox {
+	return &Sandbox{bridge: roundTrip}
+}
+
+// WithSandbox compiles and runs programs in s instead of in process.
+func WithSandbox(s *Sandbox) Option {
+	return func(c *config) {
+		c.eng = s
+	}
+}
+
+// Close stops the child process, killing it if it does not exit within a
+// grace period. Handles created by the sandbox become unusable.
+func (s *Sandbox) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.bridge != nil || s.cmd == nil {
+		return nil
+	}
+	s.in.Close()
+	exited := make(chan error, 1)
+	go func(cmd *exec.Cmd) { exited <- cmd.Wait() }(s.cmd)
+	var err error
+	select {
+	case err = <-exited:
+	case <-time.After(sandboxCloseGrace):
+		s.cmd.Process.Kill()
+		err = <-exited
+	}
+	s.cmd = nil
+	s.proc.Store(nil)
+	return err
+}
+
+// sandboxHandle identifies a native handle held by one generation of the child.
+type sandboxHandle struct {
+	gen uint64
+	id  uint64
+}
+
+type sandboxRequest struct {
+	Op         string          `json:"op"`
+	Handle     uint64          `json:"handle,omitempty"`
+	Code       string          `json:"code,omitempty"`
+	ScriptName string          `json:"script_name,omitempty"`
+	InputNames []string        `json:"input_names,omitempty"`
+	ExtFuncs   []string        `json:"ext_funcs,omitempty"`
+	Data       []byte          `json:"data,omitempty"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	CallID     uint32          `json:"call_id,omitempty"`
+	ErrMsg     string          `json:"error,omitempty"`
+}
+
+type sandboxResponse struct {
+	// gen is the generation of the child that sent the response, which the
+	// handles in it belong to.
+	gen uint64
+
+	Err         string           `json:"error,omitempty"`
+	ErrKind     int              `json:"error_kind,omitempty"`
+	Unavailable bool             `json:"unavailable,omitempty"`
+	Handle      uint64           `json:"handle,omitempty"`
+	Data        []byte           `json:"data,omitempty"`
+	Version     string           `json:"version,omitempty"`
+	ABI         uint32           `json:"abi,omitempty"`
+	MemStats    *MemStats        `json:"mem_stats,omitempty"`
+	Size        int64            `json:"size,omitempty"`
+	PendingIDs  json.RawMessage  `json:"pending_call_ids,omitempty"`
+	Progress    *sandboxProgress `json:"progress,omitempty"`
+}
+
+type sandboxProgress struct {
+	Kind           ProgressKind    `json:"kind"`
+	CallID         uint32          `json:"call_id"`
+	MethodCall     bool            `json:"method_call,omitempty"`
+	Result         json.RawMessage `json:"result,omitempty"`
+	FunctionName   string          `json:"function_name,omitempty"`
+	OsFunction     string          `json:"os_function,omitempty"`
+	Args           json.RawMessage `json:"args,omitempty"`
+	Kwargs         json.RawMessage `json:"kwargs,omitempty"`
+	PendingIDs     json.RawMessage `json:"pending_call_ids,omitempty"`
+	Snapshot       uint64          `json:"snapshot,omitempty"`
+	FutureSnapshot uint64          `json:"future_snapshot,omitempty"`
+}
+
+// call sends req to the child, starting it if needed. target is the handle
+// req refers to, or nil.
+func (s *Sandbox) call(req sandboxRequest, target any) (sandboxResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return sandboxResponse{}, newError(ErrClosed, "monty: sandbox closed")
+	}
+	if target != nil {
+		h := target.(sandboxHandle)
+		if !s.live() || h.gen != s.gen {
+			return sandboxResponse{}, newError(ErrClosed, "monty: handle belongs to a sandbox process that has exited")
+		}
+		req.Handle = h.id
+	}
+	resp, err := s.roundTrip(req)
+	if err != nil {
+		return sandboxResponse{}, err
+	}
+	resp.gen = s.gen
+	if resp.Unavailable {
+		return sandboxResponse{}, ErrUnavailable
+	}
+	if resp.Err != "" {
+		return sandboxResponse{}, nativeError(resp.ErrKind, resp.Err)
+	}
+	return resp, nil
+}
+
+// roundTrip delivers req through the bridge or the child process, starting
+// the child if needed.
+func (s *Sandbox) roundTrip(req sandboxRequest) (sandboxResponse, error) {
+	var resp sandboxResponse
+	if s.bridge != nil {
+		data, err := jsonMarshal(req)
+		if err != nil {
+			return sandboxResponse{}, err
+		}
+		out, err := s.bridge(data)
+		if err != nil {
+			return sandboxResponse{}, err
+		}
+		return resp, jsonUnmarshal(out, &resp)
+	}
+	if s.cmd == nil {
+		if err := s.spawn(); err != nil {
+			return sandboxResponse{}, err
+		}
+	}
+	var timer *time.Timer
+	if s.CallTimeout > 0 {
+		// Kill this child, not whichever one is running when the timer fires.
+		proc := s.cmd.Process
+		timer = time.AfterFunc(s.CallTimeout, func() { proc.Kill() })
+	}
+	err := writeFrame(s.in, req)
+	if err == nil {
+		err = readFrame(s.out, &resp)
+	}
+	// A timer that already fired kills the child even if the response
+	// arrived in time, so the call fails either way.
+	if timer != nil && !timer.Stop() {
+		s.kill()
+		return sandboxResponse{}, fmt.Errorf("%w: %w: %s exceeded %v", ErrTimeout, ErrSandboxExited, req.Op, s.CallTimeout)
+	}
+	if err != nil {
+		s.kill()
+		return sandboxResponse{}, fmt.Errorf("%w: %v", ErrSandboxExited, err)
+	}
+	return resp, nil
+}
+
+// live reports whether handles of the current generation are still usable.
+func (s *Sandbox) live() bool {
+	return s.bridge != nil || s.cmd != nil
+}
+
+func (s *Sandbox) spawn() error {
+	cmd := exec.Command(s.path, s.args...)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("monty: start sandbox: %w", err)
+	}
+	s.cmd, s.in, s.out = cmd, in, bufio.NewReader(out)
+	s.proc.Store(cmd.Process)
+	s.gen++
+	return nil
+}
+
+func (s *Sandbox) kill() {
+	s.proc.Store(nil)
+	s.cmd.Process.Kill()
+	s.cmd.Wait()
+	s.cmd = nil
+}
+
+// interrupt kills the child, failing the call in flight with
+// ErrSandboxExited. Bridged sandboxes cannot be interrupted.
+func (s *Sandbox) interrupt() {
+	if p := s.proc.Load(); p != nil {
+		p.Kill()
+	}
+}
+
+// free releases a child handle. Handles of a dead process are already gone.
+func (s *Sandbox) free(op string, h any) error {
+	s.mu.Lock()
+	stale := !s.live() || h.(sandboxHandle).gen != s.gen
+	s.mu.Unlock()
+	if stale {
+		return nil
+	}
+	_, err := s.call(sandboxRequest{Op: op}, h)
+	return err
+}
+
+// freeLater queues free(op, h) for a background goroutine. Finalizers use it
+// instead of free, which waits for the child and for any call in flight.
+func (s *Sandbox) freeLater(op string, h any) {
+	s.freeMu.Lock()
+	s.frees = append(s.frees, pendingFree{op, h})
+	start := !s.freeing
+	s.freeing = true
+	s.freeMu.Unlock()
+	if start {
+		go s.drainFrees()
+	}
+}
+
+// drainFrees frees queued handles until the queue is empty. Errors are
+// dropped, as there is no caller left to report them to.
+func (s *Sandbox) drainFrees() {
+	for {
+		s.freeMu.Lock()
+		batch := s.frees
+		s.frees = nil
+		if len(batch) == 0 {
+			s.freeing = false
+			s.freeMu.Unlock()
+			return
+		}
+		s.freeMu.Unlock()
+		for _, f := range batch {
+			s.free(f.op, f.h)
+		}
+	}
+}
+
+// handle identifies the child handle id sent in resp.
+func (resp sandboxResponse) handle(id uint64) any {
+	return sandboxHandle{gen: resp.gen, id: id}
+}
+
+func (s *Sandbox) progress(resp sandboxResponse, r *run) (rawProgress, error) {
+	p := resp.Progress
+	if p == nil {
+		return rawProgress{}, errors.New("monty: sandbox returned no progress")
+	}
+	raw := rawProgress{
+		kind:         p.Kind,
+		callID:       p.CallID,
+		methodCall:   p.MethodCall,
+		result:       p.Result,
+		functionName: p.FunctionName,
+		osFunction:   p.OsFunction,
+		args:         p.Args,
+		kwargs:       p.Kwargs,
+		pendingIDs:   p.PendingIDs,
+	}
+	if p.Snapshot != 0 {
+		raw.snapshot = resp.handle(p.Snapshot)
+	}
+	if p.FutureSnapshot != 0 {
+		raw.futureSnapshot = resp.handle(p.FutureSnapshot)
+	}
+	if err := r.receive(int64(len(raw.args)+len(raw.kwargs)), int64(len(raw.result))); err != nil {
+		raw.release(s)
+		return rawProgress{}, err
+	}
+	return raw, nil
+}
+
+func (s *Sandbox) version() (string, uint32, error) {
+	resp, err := s.call(sandboxRequest{Op: "version"}, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	return resp.Version, resp.ABI, nil
+}
+
+// Version reports the library and ABI versions loaded by the child process.
+func (s *Sandbox) Version() (VersionInfo, error) {
+	library, abi, err := s.version()
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	return VersionInfo{Wrapper: WrapperVersion, Library: library, ABI: abi}, nil
+}
+
+func (s *Sandbox) memStats() (MemStats, error) {
+	resp, err := s.call(sandboxRequest{Op: "mem_stats"}, nil)
+	if err != nil || resp.MemStats == nil {
+		return MemStats{}, err
+	}
+	return *resp.MemStats, nil
+}
+
+// NativeMemStats reports the allocator statistics of the library loaded by
+// the child process.
+func (s *Sandbox) NativeMemStats() (MemStats, error) {
+	return s.memStats()
+}
+
+func (s *Sandbox) compile(code, scriptName string, inputNames, extFuncs []string) (any, error) {
+	resp, err := s.call(sandboxRequest{Op: "compile", Code: code, ScriptName: scriptName, InputNames: inputNames, ExtFuncs: extFuncs}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.handle(resp.Handle), nil
+}
+
+func (s *Sandbox) load(op string, data []byte) (any, error) {
+	resp, err := s.call(sandboxRequest{Op: op, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.handle(resp.Handle), nil
+}
+
+func (s *Sandbox) dump(op string, h any) ([]byte, error) {
+	resp, err := s.call(sandboxRequest{Op: op}, h)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (s *Sandbox) size(op string, h any) (int64, error) {
+	resp, err := s.call(sandboxRequest{Op: op}, h)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Size, nil
+}
+
+func (s *Sandbox) loadRun(data []byte) (any, error) {
+	return s.load("load_run", data)
+}
+
+func (s *Sandbox) dumpRun(h any) ([]byte, error) {
+	return s.dump("dump_run", h)
+}
+
+func (s *Sandbox) freeRun(h any) error {
+	return s.free("free_run", h)
+}
+
+func (s *Sandbox) freeRunLater(h any) {
+	s.freeLater("free_run", h)
+}
+
+func (s *Sandbox) loadSnapshot(data []byte) (any, error) {
+	return s.load("load_snapshot", data)
+}
+
+func (s *Sandbox) dumpSnapshot(h any) ([]byte, error) {
+	return s.dump("dump_snapshot", h)
+}
+
+func (s *Sandbox) snapshotSize(h any) (int64, error) {
+	return s.size("snapshot_size", h)
+}
+
+func (s *Sandbox) freeSnapshot(h any) error {
+	return s.free("free_snapshot", h)
+}
+
+func (s *Sandbox) freeSnapshotLater(h any) {
+	s.freeLater("free_snapshot", h)
+}
+
+func (s *Sandbox) loadFutureSnapshot(data []byte) (any, error) {
+	return s.load("load_future_snapshot", data)
+}
+
+func (s *Sandbox) dumpFutureSnapshot(h any) ([]byte, error) {
+	return s.dump("dump_future_snapshot", h)
+}
+
+func (s *Sandbox) futureSnapshotSize(h any) (int64, error) {
+	return s.size("future_snapshot_size", h)
+}
+
+func (s *Sandbox) dumpFutureSnapshotPending(h any) ([]byte, []byte, error) {
+	resp, err := s.call(sandboxRequest{Op: "dump_future_snapshot_pending"}, h)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Data, resp.PendingIDs, nil
+}
+
+func (s *Sandbox) freeFutureSnapshot(h any) error {
+	return s.free("free_future_snapshot", h)
+}
+
+func (s *Sandbox) freeFutureSnapshotLater(h any) {
+	s.freeLater("free_future_snapshot", h)
+}
+
+func (s *Sandbox) start(h any, inputs []byte, r *run) (rawProgress, error) {
+	resp, err := s.call(sandboxRequest{Op: "start", Payload: inputs}, h)
+	if err != nil {
+		return rawProgress{}, err
+	}
+	return s.progress(resp, r)
+}
+
+func (s *Sandbox) resume(h any, callID uint32, result []byte, errMsg string, r *run) (rawProgress, error) {
+	resp, err := s.call(sandboxRequest{Op: "resume", CallID: callID, Payload: result, ErrMsg: errMsg}, h)
+	if err != nil {
+		return rawProgress{}, err
+	}
+	return s.progress(resp, r)
+}
+
+func (s *Sandbox) resumeFutures(h any, results []byte, r *run) (rawProgress, error) {
+	resp, err := s.call(sandboxRequest{Op: "resume_futures", Payload: results}, h)
+	if err != nil {
+		return rawProgress{}, err
+	}
+	return s.progress(resp, r)
+}
+
+// ServeSandbox answers sandbox requests read from r, writing responses to w,
+// until r is closed. It is the child side of Sandbox; nothing else may write
+// to w while it runs.
+func ServeSandbox(r io.Reader, w io.Writer) error {
+	return serveSandbox(native, r, w)
+}
+
+func serveSandbox(eng engine, r io.Reader, w io.Writer) error {
+	in := bufio.NewReader(r)
+	out := bufio.NewWriter(w)
+	srv := &sandboxServer{eng: eng, handles: make(map[uint64]any)}
+	for {
+		var req sandboxRequest
+		if err := readFrame(in, &req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := writeFrame(out, srv.serve(req)); err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// SandboxHost answers sandbox requests one message at a time. It is the host
+// side of a wasm guest built with the monty_wasm tag: the host's
+// implementation of the guest's monty_host.call import passes each request
+// to Handle. A SandboxHost is safe for concurrent use.
+type SandboxHost struct {
+	mu  sync.Mutex
+	srv *sandboxServer
+}
+
+// NewSandboxHost returns a host backed by the library linked into this process.
+func NewSandboxHost() *SandboxHost {
+	return newSandboxHost(native)
+}
+
+func newSandboxHost(eng engine) *SandboxHost {
+	return &SandboxHost{srv: &sandboxServer{eng: eng, handles: make(map[uint64]any)}}
+}
+
+// Handle decodes one JSON request and returns the JSON response.
+func (h *SandboxHost) Handle(req []byte) []byte {
+	var r sandboxRequest
+	resp := sandboxResponse{Err: "monty: malformed sandbox request"}
+	if err := jsonUnmarshal(req, &r); err == nil {
+		h.mu.Lock()
+		resp = h.srv.serve(r)
+		h.mu.Unlock()
+	}
+	out, err := jsonMarshal(resp)
+	if err != nil {
+		out, _ = jsonMarshal(sandboxResponse{Err: err.Error()})
+	}
+	return out
+}
+
+// sandboxServer owns the native handles of one child process.
+type sandboxServer struct {
+	eng     engine
+	handles map[uint64]any
+	next    uint64
+}
+
+func (srv *sandboxServer) put(h any) uint64 {
+	if h == nil {
+		return 0
+	}
+	srv.next++
+	srv.handles[srv.next] = h
+	return srv.next
+}
+
+func (srv *sandboxServer) take(id uint64) any {
+	h := srv.handles[id]
+	delete(srv.handles, id)
+	return h
+}
+
+func (srv *sandboxServer) serve(req sandboxRequest) sandboxResponse {
+	var resp sandboxResponse
+	var err error
+	h, ok := srv.handles[req.Handle]
+	switch req.Op {
+	case "version", "mem_stats", "compile", "load_run", "load_snapshot", "load_future_snapshot":
+		ok = true
+	}
+	if !ok {
+		return sandboxResponse{Err: fmt.Sprintf("monty: unknown sandbox handle %d", req.Handle)}
+	}
+
+	r := (&config{}).newRun()
+	r.eng = srv.eng
+	var raw rawProgress
+	switch req.Op {
+	case "version":
+		resp.Version, resp.ABI, err = srv.eng.version()
+	case "mem_stats":
+		var stats MemStats
+		stats, err = srv.eng.memStats()
+		resp.MemStats = &stats
+	case "compile":
+		h, err = srv.eng.compile(req.Code, req.ScriptName, req.InputNames, req.ExtFuncs)
+		resp.Handle = srv.put(h)
+	case "load_run":
+		h, err = srv.eng.loadRun(req.Data)
+		resp.Handle = srv.put(h)
+	case "load_snapshot":
+		h, err = srv.eng.loadSnapshot(req.Data)
+		resp.Handle = srv.put(h)
+	case "load_future_snapshot":
+		h, err = srv.eng.loadFutureSnapshot(req.Data)
+		resp.Handle = srv.put(h)
+	case "dump_run":
+		resp.Data, err = srv.eng.dumpRun(h)
+	case "dump_snapshot":
+		resp.Data, err = srv.eng.dumpSnapshot(h)
+	case "dump_future_snapshot":
+		resp.Data, err = srv.eng.dumpFutureSnapshot(h)
+	case "dump_future_snapshot_pending":
+		resp.Data, resp.PendingIDs, err = srv.eng.dumpFutureSnapshotPending(h)
+	case "snapshot_size":
+		resp.Size, err = srv.eng.snapshotSize(h)
+	case "future_snapshot_size":
+		resp.Size, err = srv.eng.futureSnapshotSize(h)
+	case "free_run":
+		err = srv.eng.freeRun(srv.take(req.Handle))
+	case "free_snapshot":
+		err = srv.eng.freeSnapshot(srv.take(req.Handle))
+	case "free_future_snapshot":
+		err = srv.eng.freeFutureSnapshot(srv.take(req.Handle))
+	case "start":
+		raw, err = srv.eng.start(h, req.Payload, r)
+		resp.Progress = srv.progress(raw, err)
+	case "resume":
+		raw, err = srv.eng.resume(srv.take(req.Handle), req.CallID, req.Payload, req.ErrMsg, r)
+		resp.Progress = srv.progress(raw, err)
+	case "resume_futures":
+		raw, err = srv.eng.resumeFutures(srv.take(req.Handle), req.Payload, r)
+		resp.Progress = srv.progress(raw, err)
+	default:
+		err = fmt.Errorf("monty: unknown sandbox op %q", req.Op)
+	}
+	if err != nil {
+		return sandboxResponse{Err: err.Error(), ErrKind: nativeKind(err), Unavailable: errors.Is(err, ErrUnavailable)}
+	}
+	return resp
+}
+
+func (srv *sandboxServer) progress(raw rawProgress, err error) *sandboxProgress {
+	if err != nil {
+		return nil
+	}
+	return &sandboxProgress{
+		Kind:           raw.kind,
+		CallID:         raw.callID,
+		MethodCall:     raw.methodCall,
+		Result:         raw.result,
+		FunctionName:   raw.functionName,
+		OsFunction:     raw.osFunction,
+		Args:           raw.args,
+		Kwargs:         raw.kwargs,
+		PendingIDs:     raw.pendingIDs,
+		Snapshot:       srv.put(raw.snapshot),
+		FutureSnapshot: srv.put(raw.futureSnapshot),
+	}
+}
+
+// writeFrame writes v as JSON prefixed with its big-endian uint32 length.
+func writeFrame(w io.Writer, v any) error {
+	data, err := jsonMarshal(v)
+	if err != nil {
+		return err
+	}
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(data)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func readFrame(r io.Reader, v any) error {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	if n > maxFrameBytes {
+		return fmt.Errorf("monty: sandbox frame of %d bytes exceeds limit", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	return jsonUnmarshal(data, v)
+}
@@ -0,0 +1,240 @@
+package monty
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoEngine surfaces the start inputs as a call to echo and completes with
+// whatever the call is resumed with. Compiling "crash" kills the process,
+// and compiling "hang" never returns.
+type echoEngine struct{ stubEngine }
+
+func (echoEngine) compile(code, _ string, _, _ []string) (any, error) {
+	switch code {
+	case "crash":
+		os.Exit(2)
+	case "hang":
+		time.Sleep(time.Hour)
+	}
+	return code, nil
+}
+
+func (echoEngine) memStats() (MemStats, error) {
+	return MemStats{TotalAlloc: 300, Live: 100, Peak: 200, Mallocs: 3, Frees: 1}, nil
+}
+
+func (echoEngine) start(_ any, inputs []byte, _ *run) (rawProgress, error) {
+	return rawProgress{kind: FunctionCall, callID: 1, functionName: "echo", args: inputs, kwargs: []byte("[]"), snapshot: "snap"}, nil
+}
+
+func (echoEngine) resume(_ any, _ uint32, result []byte, _ string, _ *run) (rawProgress, error) {
+	return rawProgress{kind: Complete, result: result}, nil
+}
+
+func TestSandboxHelperProcess(t *testing.T) {
+	if os.Getenv("MONTY_SANDBOX_HELPER") != "1" {
+		t.Skip("helper process for sandbox tests")
+	}
+	if err := serveSandbox(echoEngine{}, os.Stdin, os.Stdout); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func newTestSandbox(t *testing.T) *Sandbox {
+	t.Helper()
+	t.Setenv("MONTY_SANDBOX_HELPER", "1")
+	sb := NewSandbox(os.Args[0], "-test.run=^TestSandboxHelperProcess$")
+	t.Cleanup(func() { sb.Close() })
+	return sb
+}
+
+// anyOp is the bridgeScript entry that answers ops the script does not name.
+const anyOp = "*"
+
+// bridgeScript says how a scripted bridge answers each sandbox op. Ops with
+// no entry, and no anyOp entry, get an empty response.
+type bridgeScript map[string]func(req sandboxRequest) sandboxResponse
+
+// scriptedBridge is an in-process sandbox that answers each request from
+// script, for tests that drive a run through canned progress.
+func scriptedBridge(script bridgeScript) *Sandbox {
+	return NewSandboxBridge(func(data []byte) ([]byte, error) {
+		var req sandboxRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, err
+		}
+		answer, ok := script[req.Op]
+		if !ok {
+			answer = script[anyOp]
+		}
+		if answer == nil {
+			return json.Marshal(sandboxResponse{})
+		}
+		return json.Marshal(answer(req))
+	})
+}
+
+// reply is a bridgeScript entry that always answers resp.
+func reply(resp sandboxResponse) func(sandboxRequest) sandboxResponse {
+	return func(sandboxRequest) sandboxResponse { return resp }
+}
+
+// callProgress pauses a run at a call to name with the JSON array args, no
+// keyword arguments, and the snapshot handle snapshot.
+func callProgress(id uint32, name, args string, snapshot uint64) sandboxResponse {
+	return sandboxResponse{Progress: &sandboxProgress{
+		Kind: FunctionCall, CallID: id, FunctionName: name,
+		Args: json.RawMessage(args), Kwargs: json.RawMessage("[]"), Snapshot: snapshot,
+	}}
+}
+
+// completeProgress finishes a run with the JSON result.
+func completeProgress(result json.RawMessage) sandboxResponse {
+	return sandboxResponse{Progress: &sandboxProgress{Kind: Complete, Result: result}}
+}
+
+// echoResume finishes a run with the value its call was resumed with.
+func echoResume(req sandboxRequest) sandboxResponse {
+	return completeProgress(req.Payload)
+}
+
+func TestSandboxRoundTrip(t *testing.T) {
+	sb := newTestSandbox(t)
+	m, err := New("echo(a, b)", "test.py", []string{"a", "b"}, []string{"echo"}, WithSandbox(sb))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+
+	progress, err := m.Start(1, 2)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if progress.Kind != FunctionCall || progress.FunctionName != "echo" || len(progress.Args) != 2 {
+		t.Fatalf("unexpected progress: %+v", progress)
+	}
+	progress, err = progress.Snapshot.Resume(progress.CallID, "done")
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	var got string
+	if err := progress.Result.Unmarshal(&got); err != nil || got != "done" {
+		t.Fatalf("expected done, got %q (%v)", got, err)
+	}
+}
+
+func TestSandboxRestartsAfterCrash(t *testing.T) {
+	sb := newTestSandbox(t)
+	before, err := New("1", "test.py", nil, nil, WithSandbox(sb))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := New("crash", "test.py", nil, nil, WithSandbox(sb)); !errors.Is(err, ErrSandboxExited) {
+		t.Fatalf("expected ErrSandboxExited, got %v", err)
+	}
+	if _, err := before.Start(); err == nil {
+		t.Fatalf("expected handle from crashed process to fail")
+	}
+	after, err := New("1", "test.py", nil, nil, WithSandbox(sb))
+	if err != nil {
+		t.Fatalf("New after restart failed: %v", err)
+	}
+	if _, err := after.Start(); err != nil {
+		t.Fatalf("Start after restart failed: %v", err)
+	}
+}
+
+func TestSandboxHostBridge(t *testing.T) {
+	host := newSandboxHost(echoEngine{})
+	sb := NewSandboxBridge(func(req []byte) ([]byte, error) { return host.Handle(req), nil })
+	m, err := New("echo(a)", "test.py", []string{"a"}, []string{"echo"}, WithSandbox(sb))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+
+	progress, err := m.Start("hi")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	progress, err = progress.Snapshot.Resume(progress.CallID, 7)
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	var got int
+	if err := progress.Result.Unmarshal(&got); err != nil || got != 7 {
+		t.Fatalf("expected 7, got %d (%v)", got, err)
+	}
+	if stats, err := sb.NativeMemStats(); err != nil || stats.Live != 100 || stats.Peak != 200 {
+		t.Fatalf("unexpected child allocator stats %+v (%v)", stats, err)
+	}
+	if resp := host.Handle([]byte("{")); !strings.Contains(string(resp), "malformed") {
+		t.Fatalf("expected malformed request error, got %s", resp)
+	}
+}
+
+func TestSandboxCallTimeout(t *testing.T) {
+	sb := newTestSandbox(t)
+	sb.CallTimeout = 100 * time.Millisecond
+	_, err := New("hang", "test.py", nil, nil, WithSandbox(sb))
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, ErrSandboxExited) {
+		t.Fatalf("expected ErrTimeout and ErrSandboxExited, got %v", err)
+	}
+	if _, err := New("1", "test.py", nil, nil, WithSandbox(sb)); err != nil {
+		t.Fatalf("New after timeout failed: %v", err)
+	}
+}
+
+func TestSandboxFreeLaterDoesNotBlock(t *testing.T) {
+	freed := make(chan string, 1)
+	sb := scriptedBridge(bridgeScript{anyOp: func(req sandboxRequest) sandboxResponse {
+		freed <- req.Op
+		return sandboxResponse{}
+	}})
+	h := sandboxHandle{id: 1}
+	// A call in flight holds mu; queueing the free must not wait for it.
+	sb.mu.Lock()
+	sb.freeRunLater(h)
+	sb.mu.Unlock()
+	select {
+	case op := <-freed:
+		if op != "free_run" {
+			t.Fatalf("expected free_run, got %s", op)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued free never ran")
+	}
+}
+
+func TestSandboxCloseKillsHungChild(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("no sleep binary")
+	}
+	defer func(grace time.Duration) { sandboxCloseGrace = grace }(sandboxCloseGrace)
+	sandboxCloseGrace = 50 * time.Millisecond
+	// sleep ignores its stdin closing, like a hung child.
+	sb := NewSandbox("sleep", "60")
+	sb.mu.Lock()
+	err := sb.spawn()
+	sb.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		sb.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close waited on the hung child")
+	}
+}
@@ -0,0 +1,86 @@
+package monty
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Session evaluates code a snippet at a time with globals that persist
+// between snippets, as a REPL does. The interpreter has no incremental mode,
+// so each Eval recompiles the accepted history plus the new snippet and
+// replays the calls earlier snippets made from a record instead of invoking
+// their handlers again. Virtualized OS calls such as random and time are
+// re-evaluated on replay.
+type Session struct {
+	opts     []Option
+	handlers map[string]Handler
+	history  []string
+	calls    []recordedCall
+}
+
+// recordedCall is the answer one call received when its snippet was accepted.
+type recordedCall struct {
+	name  string
+	value any
+	err   error
+}
+
+// NewSession returns an empty session. opts apply to every compiled snippet.
+func NewSession(opts ...Option) *Session {
+	return &Session{opts: opts, handlers: make(map[string]Handler)}
+}
+
+// Register declares an external function and installs its handler. Snippets
+// can only call functions registered before they are evaluated.
+func (s *Session) Register(name string, h Handler) {
+	s.handlers[name] = h
+}
+
+// Eval runs code after the session's history and returns the value of its
+// final expression. The snippet joins the history only if it succeeds.
+func (s *Session) Eval(ctx context.Context, code string) (Object, error) {
+	source := strings.Join(append(append([]string(nil), s.history...), code), "\n")
+	names := make([]string, 0, len(s.handlers))
+	for name := range s.handlers {
+		names = append(names, name)
+	}
+	m, err := New(source, "<session>", nil, names, s.opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	replayed := 0
+	var calls []recordedCall
+	r := NewRunner(m)
+	for name, h := range s.handlers {
+		name, h := name, h
+		r.Register(name, func(ctx context.Context, call CallInfo) (any, error) {
+			if replayed < len(s.calls) {
+				rec := s.calls[replayed]
+				replayed++
+				if rec.name != name {
+					return nil, fmt.Errorf("monty: session replay expected a call to %s, got %s", rec.name, name)
+				}
+				return rec.value, rec.err
+			}
+			value, err := h(ctx, call)
+			calls = append(calls, recordedCall{name: name, value: value, err: err})
+			return value, err
+		})
+	}
+	result, err := r.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.history = append(s.history, code)
+	s.calls = append(s.calls, calls...)
+	return result, nil
+}
+
+// Reset forgets every evaluated snippet. Registered functions are kept.
+func (s *Session) Reset() {
+	s.history = nil
+	s.calls = nil
+}
@@ -0,0 +1,36 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSessionPersistsGlobalsAndReplaysCalls(t *testing.T) {
+	s := NewSession()
+	calls := 0
+	s.Register("fetch", func(context.Context, CallInfo) (any, error) {
+		calls++
+		return calls * 10, nil
+	})
+	ctx := context.Background()
+	if _, err := s.Eval(ctx, "x = fetch()"); errors.Is(err, ErrUnavailable) {
+		t.Skip("native library unavailable")
+	} else if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	if _, err := s.Eval(ctx, "undefined_name"); err == nil {
+		t.Fatalf("expected error for undefined name")
+	}
+	result, err := s.Eval(ctx, "x + 1")
+	if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	var got int
+	if err := result.Unmarshal(&got); err != nil || got != 11 {
+		t.Fatalf("expected 11, got %d (%v)", got, err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected fetch to run once, ran %d times", calls)
+	}
+}
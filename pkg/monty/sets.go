@@ -0,0 +1,122 @@
+package monty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Set is a Python set as a Go map[T]struct{}. It crosses the bridge as
+// {"$set": [...]}, with elements in the order of their JSON encoding so that
+// equal sets encode identically. Decoding accepts sets, frozensets, tuples,
+// and lists; duplicate elements collapse. Decode a result with
+// Object.Unmarshal into a Set, or with DecodeSet.
+type Set[T comparable] map[T]struct{}
+
+// FrozenSet is Set encoded as {"$frozenset": [...]}, for values the script
+// must be able to hash.
+type FrozenSet[T comparable] map[T]struct{}
+
+// NewSet returns a Set holding items.
+func NewSet[T comparable](items ...T) Set[T] {
+	s := make(Set[T], len(items))
+	for _, item := range items {
+		s[item] = struct{}{}
+	}
+	return s
+}
+
+// Has reports whether s holds item.
+func (s Set[T]) Has(item T) bool {
+	_, ok := s[item]
+	return ok
+}
+
+// MarshalJSON encodes s as a $set.
+func (s Set[T]) MarshalJSON() ([]byte, error) { return marshalSet("$set", s) }
+
+// UnmarshalJSON decodes a set, frozenset, tuple, or list into s.
+func (s *Set[T]) UnmarshalJSON(data []byte) error { return unmarshalSet(data, (*map[T]struct{})(s)) }
+
+// MarshalJSON encodes s as a $frozenset.
+func (s FrozenSet[T]) MarshalJSON() ([]byte, error) { return marshalSet("$frozenset", s) }
+
+// UnmarshalJSON decodes a set, frozenset, tuple, or list into s.
+func (s *FrozenSet[T]) UnmarshalJSON(data []byte) error {
+	return unmarshalSet(data, (*map[T]struct{})(s))
+}
+
+// DecodeSet decodes o, a set, frozenset, tuple, or list, into a Set.
+func DecodeSet[T comparable](o Object) (Set[T], error) {
+	if len(o) == 0 {
+		return nil, fmt.Errorf("monty: empty object payload")
+	}
+	var s Set[T]
+	if err := unmarshalSet(o, (*map[T]struct{})(&s)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func marshalSet[T comparable](tag string, s map[T]struct{}) ([]byte, error) {
+	items := make([][]byte, 0, len(s))
+	for item := range s {
+		data, err := marshalValue(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, data)
+	}
+	sort.Slice(items, func(i, j int) bool { return bytes.Compare(items[i], items[j]) < 0 })
+	out := append([]byte(`{"`), tag...)
+	out = append(out, `":[`...)
+	out = append(out, bytes.Join(items, []byte{','})...)
+	return append(out, "]}"...), nil
+}
+
+func unmarshalSet[T comparable](data []byte, s *map[T]struct{}) error {
+	if string(data) == "null" {
+		return nil
+	}
+	items, err := setElements(data)
+	if err != nil {
+		return err
+	}
+	out := make(map[T]struct{}, len(items))
+	for _, raw := range items {
+		var item T
+		if err := jsonUnmarshal(raw, &item); err != nil {
+			return fmt.Errorf("monty: set element %s: %w", raw, err)
+		}
+		out[item] = struct{}{}
+	}
+	*s = out
+	return nil
+}
+
+// setElements returns the elements of a tagged set, frozenset, or tuple, or
+// of a plain list.
+func setElements(data []byte) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	if len(data) > 0 && data[0] == '{' {
+		var tagged map[string]json.RawMessage
+		if err := json.Unmarshal(data, &tagged); err != nil {
+			return nil, err
+		}
+		var inner json.RawMessage
+		for _, tag := range []string{"$set", "$frozenset", "$tuple"} {
+			if v, ok := tagged[tag]; ok && len(tagged) == 1 {
+				inner = v
+			}
+		}
+		if inner == nil {
+			return nil, fmt.Errorf("monty: cannot decode %.40s as a set", data)
+		}
+		data = inner
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("monty: cannot decode %.40s as a set: %w", data, err)
+	}
+	return items, nil
+}
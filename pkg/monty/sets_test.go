@@ -0,0 +1,54 @@
+package monty
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSetMarshal(t *testing.T) {
+	data, err := marshalValue(NewSet("b", "a", "c"))
+	if err != nil || string(data) != `{"$set":["a","b","c"]}` {
+		t.Fatalf("marshalValue(Set) = %s, %v", data, err)
+	}
+	data, err = marshalValue(map[string]any{"ids": FrozenSet[int]{3: {}, 1: {}}})
+	if err != nil || string(data) != `{"ids":{"$frozenset":[1,3]}}` {
+		t.Fatalf("marshalValue(FrozenSet) = %s, %v", data, err)
+	}
+	data, err = marshalValue(Set[string](nil))
+	if err != nil || string(data) != `{"$set":[]}` {
+		t.Fatalf("marshalValue(nil Set) = %s, %v", data, err)
+	}
+}
+
+func TestDecodeSet(t *testing.T) {
+	for _, in := range []string{`{"$set":[1,2,2]}`, `{"$frozenset":[2,1]}`, `{"$tuple":[1,2]}`, `[2,1,1]`} {
+		s, err := DecodeSet[int](Object(in))
+		if err != nil || len(s) != 2 || !s.Has(1) || !s.Has(2) {
+			t.Errorf("DecodeSet(%s) = %v, %v", in, s, err)
+		}
+	}
+	for _, in := range []string{``, `{"$dict":[]}`, `{"$set":["x"]}`, `3`} {
+		if _, err := DecodeSet[int](Object(in)); err == nil {
+			t.Errorf("DecodeSet(%s) should fail", in)
+		}
+	}
+
+	var v struct {
+		Tags   Set[string]
+		Frozen FrozenSet[string]
+		None   Set[string]
+	}
+	if err := Object(`{"Tags":{"$set":["x","y"]},"Frozen":{"$frozenset":["z"]},"None":null}`).Unmarshal(&v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !v.Tags.Has("y") || len(v.Frozen) != 1 || v.None != nil {
+		t.Fatalf("Unmarshal = %+v", v)
+	}
+	var plain map[string]struct{} = v.Tags
+	if _, ok := plain["x"]; !ok {
+		t.Fatal("a Set should be usable as a map[T]struct{}")
+	}
+	if _, err := json.Marshal(v.Frozen); err != nil {
+		t.Fatalf("json.Marshal(FrozenSet) failed: %v", err)
+	}
+}
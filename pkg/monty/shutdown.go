@@ -0,0 +1,118 @@
+package monty
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrShutdown is returned by Runner.Run once Shutdown has been called, and
+// by runs that Shutdown interrupted.
+var ErrShutdown = errors.New("monty: runner shut down")
+
+// WithStore gives a Runner somewhere to persist runs that Shutdown
+// interrupts, so they can be resumed elsewhere.
+func WithStore(s Store) Option {
+	return func(c *config) {
+		c.store = s
+	}
+}
+
+// SuspendedError reports a run that Shutdown interrupted and persisted. Key
+// holds the dump of the run's Snapshot, or of its FutureSnapshot when Kind is
+// ResolveFutures; reload it with SnapshotFromBytes or FutureSnapshotFromBytes
+// and answer CallID to continue. The call was not answered before the run
+// was suspended. RunID and Labels identify the run; restore it WithRunID to
+// keep its ID.
+type SuspendedError struct {
+	Key    string
+	Kind   ProgressKind
+	CallID uint32
+	RunID  string
+	Labels map[string]string
+}
+
+func (e *SuspendedError) Error() string {
+	return fmt.Sprintf("monty: run suspended by shutdown at call %d, stored as %s", e.CallID, e.Key)
+}
+
+// Unwrap classifies suspended runs as ErrShutdown.
+func (e *SuspendedError) Unwrap() error {
+	return ErrShutdown
+}
+
+// Shutdown stops r accepting new runs and waits for the runs in flight to
+// finish, then closes the program r drives, so its native handle is freed
+// before Shutdown returns. If ctx is done first, Shutdown returns ctx's error
+// without waiting further, and the remaining runs are interrupted at their
+// next event: handler contexts are cancelled, the paused run is persisted to
+// the store configured with WithStore, and Run returns a *SuspendedError, or
+// ErrShutdown without a store. The program is then closed once every run has
+// returned.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		r.runs.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return r.m.Close()
+	case <-ctx.Done():
+	}
+	r.interrupt()
+	go func() {
+		<-drained
+		if err := r.m.Close(); err != nil && r.cfg.logger != nil {
+			r.cfg.logger.Warn("monty: closing the program after shutdown failed", "err", err)
+		}
+	}()
+	return contextError(ctx)
+}
+
+// enter admits a run unless r is shut down.
+func (r *Runner) enter() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	r.runs.Add(1)
+	return true
+}
+
+// interrupted reports whether Shutdown is interrupting runs.
+func (r *Runner) interrupted() bool {
+	return r.stop.Err() != nil
+}
+
+// suspend persists the run paused at p and frees its snapshot.
+func (r *Runner) suspend(ctx context.Context, p Progress) error {
+	defer closeProgress(p)
+	if r.cfg.store == nil {
+		return ErrShutdown
+	}
+	var data []byte
+	var err error
+	if p.Kind == ResolveFutures {
+		data, err = p.FutureSnapshot.Dump()
+	} else {
+		data, err = p.Snapshot.Dump()
+	}
+	if err != nil {
+		return err
+	}
+	var b [16]byte
+	rand.Read(b[:])
+	key := "monty-suspended-" + hex.EncodeToString(b[:])
+	if err := r.cfg.store.Put(context.WithoutCancel(ctx), key, data); err != nil {
+		return err
+	}
+	return &SuspendedError{Key: key, Kind: p.Kind, CallID: p.CallID, RunID: p.RunID, Labels: p.Labels}
+}
@@ -0,0 +1,105 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunnerShutdownSuspendsRuns(t *testing.T) {
+	// The bridge scripts a run that calls wait() and records freed handles.
+	var freed []string
+	closed := make(chan struct{})
+	sb := scriptedBridge(bridgeScript{
+		"compile":       reply(sandboxResponse{Handle: 1}),
+		"start":         reply(callProgress(7, "wait", "[]", 2)),
+		"dump_snapshot": reply(sandboxResponse{Data: []byte("paused")}),
+		"free_run": func(req sandboxRequest) sandboxResponse {
+			freed = append(freed, req.Op)
+			close(closed)
+			return sandboxResponse{}
+		},
+		"free_snapshot": func(req sandboxRequest) sandboxResponse {
+			freed = append(freed, req.Op)
+			return sandboxResponse{}
+		},
+	})
+	m, err := New("wait()", "main.py", nil, []string{"wait"}, WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore()
+	r := NewRunner(m, WithStore(store))
+	entered := make(chan struct{})
+	r.Register("wait", func(ctx context.Context, call CallInfo) (any, error) {
+		close(entered)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Run(context.Background())
+		done <- err
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Shutdown(ctx); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Shutdown should report the expired context, got %v", err)
+	}
+	var se *SuspendedError
+	if err := <-done; !errors.As(err, &se) || !errors.Is(err, ErrShutdown) || se.CallID != 7 {
+		t.Fatalf("expected the run to be suspended at call 7, got %v", err)
+	}
+	data, err := store.Get(context.Background(), se.Key)
+	if err == nil {
+		_, data, err = undump(SnapshotHandle, data)
+	}
+	if err != nil || string(data) != "paused" {
+		t.Fatalf("expected the snapshot dump under %s, got %q, %v", se.Key, data, err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("the program was not closed once the run returned")
+	}
+	if len(freed) != 2 || freed[0] != "free_snapshot" || freed[1] != "free_run" {
+		t.Fatalf("expected the snapshot and program freed, got %v", freed)
+	}
+	if _, err := r.Run(context.Background()); !errors.Is(err, ErrShutdown) {
+		t.Fatalf("Run after Shutdown should fail with ErrShutdown, got %v", err)
+	}
+}
+
+func TestRunnerShutdownReturnsWhenContextEnds(t *testing.T) {
+	m, err := New("wait()", "main.py", nil, []string{"wait"}, WithSandbox(callBridge("wait", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRunner(m)
+	entered, release := make(chan struct{}), make(chan struct{})
+	r.Register("wait", func(ctx context.Context, call CallInfo) (any, error) {
+		close(entered)
+		<-release // ignores ctx
+		return nil, nil
+	})
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Run(context.Background())
+		done <- err
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Shutdown(ctx); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Shutdown = %v, want the expired context", err)
+	}
+	close(release)
+	if err := <-done; !errors.Is(err, ErrShutdown) {
+		t.Fatalf("the interrupted run returned %v", err)
+	}
+}
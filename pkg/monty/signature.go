@@ -0,0 +1,212 @@
+package monty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Param declares a parameter of a typed external function.
+type Param struct {
+	Name string
+	// Optional parameters may be omitted by the script; they receive their
+	// Go zero value.
+	Optional bool
+}
+
+// TypeError returns an error that raises TypeError, rather than RuntimeError,
+// inside the script when a handler returns it.
+func TypeError(format string, args ...any) error {
+	return errors.New("TypeError: " + fmt.Sprintf(format, args...))
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Func adapts fn into a Handler that binds the script's positional and
+// keyword arguments to params by name and converts each into the type of the
+// matching Go parameter. fn has the form
+//
+//	func([context.Context,] T1, ..., Tn) ([R,] error)
+//
+// with one Param per Ti. Calls with missing, extra, or ill-typed arguments
+// raise TypeError in the script without invoking fn. Declarations that do
+// not fit fn are reported as ErrInvalidInput.
+func Func(fn any, params ...Param) (Handler, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() || v.Type().IsVariadic() {
+		return nil, newError(ErrInvalidInput, fmt.Sprintf("monty: Func needs a non-nil, non-variadic function, got %T", fn))
+	}
+	t := v.Type()
+	offset := 0
+	if t.NumIn() > 0 && t.In(0) == contextType {
+		offset = 1
+	}
+	if t.NumIn()-offset != len(params) {
+		return nil, newError(ErrInvalidInput, fmt.Sprintf("monty: function takes %d arguments but %d params were declared", t.NumIn()-offset, len(params)))
+	}
+	switch {
+	case t.NumOut() == 1 && t.Out(0) == errorType:
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+	default:
+		return nil, newError(ErrInvalidInput, fmt.Sprintf("monty: function must return error or (value, error), got %T", fn))
+	}
+	seen := make(map[string]bool, len(params))
+	for i, p := range params {
+		if p.Name == "" || seen[p.Name] {
+			return nil, newError(ErrInvalidInput, fmt.Sprintf("monty: param %d has an empty or duplicate name", i+1))
+		}
+		if i > 0 && params[i-1].Optional && !p.Optional {
+			return nil, newError(ErrInvalidInput, fmt.Sprintf("monty: required param %s follows an optional one", p.Name))
+		}
+		seen[p.Name] = true
+	}
+
+	return func(ctx context.Context, call CallInfo) (any, error) {
+		bound, err := bindArgs(call, params)
+		if err != nil {
+			return nil, err
+		}
+		in := make([]reflect.Value, t.NumIn())
+		if offset == 1 {
+			in[0] = reflect.ValueOf(ctx)
+		}
+		for i, p := range params {
+			typ := t.In(i + offset)
+			arg := reflect.New(typ)
+			if raw := bound[i]; raw != nil {
+				if err := decodeArg(raw, arg.Interface()); err != nil {
+					return nil, TypeError("%s() argument '%s' must be %s, not %s", call.Name, p.Name, pythonTypeName(typ), jsonTypeName(raw))
+				}
+			}
+			in[i+offset] = arg.Elem()
+		}
+		out := v.Call(in)
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return nil, err
+		}
+		if len(out) == 1 {
+			return nil, nil
+		}
+		return out[0].Interface(), nil
+	}, nil
+}
+
+// RegisterFunc installs fn, adapted by Func, as the handler for an external function.
+func (r *Runner) RegisterFunc(name string, fn any, params ...Param) error {
+	h, err := Func(fn, params...)
+	if err != nil {
+		return err
+	}
+	r.Register(name, h)
+	return nil
+}
+
+// bindArgs matches call arguments to params, returning one raw value per
+// param, nil for omitted optional params.
+func bindArgs(call CallInfo, params []Param) ([]Object, error) {
+	if len(call.Args) > len(params) {
+		return nil, TypeError("%s() takes %d positional arguments but %d were given", call.Name, len(params), len(call.Args))
+	}
+	bound := make([]Object, len(params))
+	copy(bound, call.Args)
+	for _, kv := range call.Kwargs {
+		var key string
+		if err := kv.Key.Unmarshal(&key); err != nil {
+			return nil, TypeError("%s() keywords must be strings", call.Name)
+		}
+		i := paramIndex(params, key)
+		if i < 0 {
+			return nil, TypeError("%s() got an unexpected keyword argument '%s'", call.Name, key)
+		}
+		if bound[i] != nil {
+			return nil, TypeError("%s() got multiple values for argument '%s'", call.Name, key)
+		}
+		bound[i] = kv.Value
+	}
+	var missing []string
+	for i, p := range params {
+		if bound[i] == nil && !p.Optional {
+			missing = append(missing, "'"+p.Name+"'")
+		}
+	}
+	if len(missing) > 0 {
+		return nil, TypeError("%s() missing %d required argument(s): %s", call.Name, len(missing), strings.Join(missing, ", "))
+	}
+	return bound, nil
+}
+
+func paramIndex(params []Param, name string) int {
+	for i, p := range params {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// decodeArg unmarshals raw into target, rejecting null for non-nillable types.
+func decodeArg(raw Object, target any) error {
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		switch reflect.TypeOf(target).Elem().Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Slice, reflect.Map:
+			return nil
+		}
+		return errors.New("null argument")
+	}
+	return jsonUnmarshal(raw, target)
+}
+
+// pythonTypeName names the Python type a Go parameter type accepts.
+func pythonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "str"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.Map, reflect.Struct:
+		return "dict"
+	case reflect.Pointer:
+		return pythonTypeName(t.Elem()) + " or None"
+	}
+	return t.String()
+}
+
+// jsonTypeName names the Python type of a JSON-encoded value.
+func jsonTypeName(raw Object) string {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if dec.Decode(&v) != nil {
+		return "object"
+	}
+	switch v := v.(type) {
+	case nil:
+		return "NoneType"
+	case bool:
+		return "bool"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "float"
+		}
+		return "int"
+	case string:
+		return "str"
+	case []any:
+		return "list"
+	}
+	return "dict"
+}
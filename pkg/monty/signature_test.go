@@ -0,0 +1,71 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func kwarg(key, value string) KV {
+	return KV{Key: Object(`"` + key + `"`), Value: Object(value)}
+}
+
+func TestFuncBindsTypedArguments(t *testing.T) {
+	h, err := Func(func(ctx context.Context, url string, retries int, tags []string) (map[string]any, error) {
+		return map[string]any{"url": url, "retries": retries, "tags": tags}, nil
+	}, Param{Name: "url"}, Param{Name: "retries", Optional: true}, Param{Name: "tags", Optional: true})
+	if err != nil {
+		t.Fatalf("Func failed: %v", err)
+	}
+	got, err := h(context.Background(), CallInfo{Name: "fetch", Args: []Object{Object(`"a"`)}, Kwargs: []KV{kwarg("retries", "3")}})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	if m := got.(map[string]any); m["url"] != "a" || m["retries"] != 3 || m["tags"].([]string) != nil {
+		t.Fatalf("unexpected result: %v", got)
+	}
+}
+
+func TestFuncRaisesTypeError(t *testing.T) {
+	h, err := Func(func(n int, s string) error { return nil }, Param{Name: "n"}, Param{Name: "s"})
+	if err != nil {
+		t.Fatalf("Func failed: %v", err)
+	}
+	for _, tc := range []struct {
+		call CallInfo
+		want string
+	}{
+		{CallInfo{Args: []Object{Object("1")}}, "missing 1 required argument(s): 's'"},
+		{CallInfo{Args: []Object{Object("1"), Object(`"x"`), Object("2")}}, "takes 2 positional arguments but 3 were given"},
+		{CallInfo{Args: []Object{Object(`"1"`), Object(`"x"`)}}, "argument 'n' must be int, not str"},
+		{CallInfo{Args: []Object{Object("1.5"), Object(`"x"`)}}, "argument 'n' must be int, not float"},
+		{CallInfo{Args: []Object{Object("1")}, Kwargs: []KV{kwarg("s", `"x"`), kwarg("z", "1")}}, "unexpected keyword argument 'z'"},
+		{CallInfo{Args: []Object{Object("1"), Object(`"x"`)}, Kwargs: []KV{kwarg("n", "2")}}, "multiple values for argument 'n'"},
+	} {
+		tc.call.Name = "f"
+		_, err := h(context.Background(), tc.call)
+		if err == nil || !strings.HasPrefix(err.Error(), "TypeError: f() ") || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("expected TypeError containing %q, got %v", tc.want, err)
+		}
+	}
+}
+
+func TestFuncRejectsBadDeclarations(t *testing.T) {
+	for _, tc := range []struct {
+		fn     any
+		params []Param
+	}{
+		{nil, nil},
+		{"not a func", nil},
+		{(func() error)(nil), nil},
+		{func(int) error { return nil }, nil},
+		{func(int) int { return 0 }, []Param{{Name: "a"}}},
+		{func(int, int) error { return nil }, []Param{{Name: "a"}, {Name: "a"}}},
+		{func(int, int) error { return nil }, []Param{{Name: "a", Optional: true}, {Name: "b"}}},
+	} {
+		if _, err := Func(tc.fn, tc.params...); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("expected Func(%T, %v) to fail with ErrInvalidInput, got %v", tc.fn, tc.params, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotStored is returned by a Store for keys it does not hold.
+var ErrNotStored = errors.New("monty: key not in store")
+
+// Store persists serialized runs, such as snapshot dumps, between the
+// process that pauses a run and the one that resumes it.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns ErrNotStored for unknown keys.
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore is a Store backed by a map, for tests and single-process hosts.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string][]byte)}
+}
+
+func (s *MemoryStore) Put(_ context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.data[key]
+	if !ok {
+		return nil, ErrNotStored
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+// DirStore is a Store that keeps each key in a file of a directory, so
+// stored data survives process restarts.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore returns a DirStore in dir, creating the directory if needed.
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+// path returns the file of key. Keys are escaped so that none names a file
+// outside dir or one of the hidden temporary files of Put.
+func (s *DirStore) path(key string) string {
+	name := url.PathEscape(key)
+	switch {
+	case name == "":
+		name = "%"
+	case name[0] == '.':
+		name = "%2E" + name[1:]
+	}
+	return filepath.Join(s.dir, name)
+}
+
+// Put writes data to a temporary file and renames it into place, so readers
+// never see a partial write.
+func (s *DirStore) Put(_ context.Context, key string, data []byte) error {
+	f, err := os.CreateTemp(s.dir, ".put-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func (s *DirStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotStored
+	}
+	return data, err
+}
+
+func (s *DirStore) Delete(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
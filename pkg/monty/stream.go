@@ -0,0 +1,212 @@
+package monty
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultStreamChunk caps a single sized read when Streams.Chunk is unset.
+const DefaultStreamChunk = 64 << 10
+
+// streamTypeID identifies the dataclass that carries stream handles.
+const streamTypeID = 0x6d6f6e7479 // "monty"
+
+// Streams lends host io.Readers and io.Writers to scripts as file-like
+// objects. A handle passed to the script as an input or call result supports
+//
+//	f.read(size=-1)   f.readline()   f.write(data)   f.close()
+//
+// and each method call reaches the host as one external call, answered by
+// the handlers Register installs. Scripts process large data a chunk at a
+// time with `while chunk := f.read(65536): ...` instead of receiving it as
+// one JSON value. Sized reads return at most Chunk characters or bytes.
+type Streams struct {
+	// Chunk caps a sized read; zero means DefaultStreamChunk.
+	Chunk int
+
+	refs handles
+}
+
+type stream struct {
+	r      *bufio.Reader
+	w      io.Writer
+	closer io.Closer
+	binary bool
+}
+
+// NewStreams returns an empty stream registry.
+func NewStreams() *Streams {
+	return &Streams{refs: newHandles(streamTypeID, "ValueError: I/O operation on closed stream")}
+}
+
+// Reader lends r to the script as a text stream; reads return str.
+func (s *Streams) Reader(r io.Reader) Object {
+	return s.refs.add("TextStream", &stream{r: bufio.NewReader(r), closer: asCloser(r)})
+}
+
+// BinaryReader lends r to the script as a binary stream; reads return bytes.
+func (s *Streams) BinaryReader(r io.Reader) Object {
+	return s.refs.add("BinaryStream", &stream{r: bufio.NewReader(r), closer: asCloser(r), binary: true})
+}
+
+// Writer lends w to the script as a writable stream accepting str or bytes.
+func (s *Streams) Writer(w io.Writer) Object {
+	return s.refs.add("WriteStream", &stream{w: w, closer: asCloser(w)})
+}
+
+// Register installs the stream methods on r, in front of the handlers r
+// already has under their names. Reads to the end of a stream are bounded
+// by r's Limits.MaxResultBytes.
+func (s *Streams) Register(r *Runner) {
+	registerMethods(r, []string{"read", "readline", "write", "close"}, func(name string, next Handler) Handler {
+		return s.handler(name, next, r.cfg.limits.MaxResultBytes)
+	})
+}
+
+// Handler returns the handler for the stream method name, passing calls that
+// are not stream method calls to next, which may be nil.
+func (s *Streams) Handler(name string, next Handler) Handler {
+	return s.handler(name, next, 0)
+}
+
+// handler is Handler with reads to the end of a stream limited to maxRead
+// bytes, or unlimited when zero.
+func (s *Streams) handler(name string, next Handler, maxRead int64) Handler {
+	return s.refs.method(next, func(ctx context.Context, v any, self Object, args []Object) (any, error) {
+		st := v.(*stream)
+		switch name {
+		case "read":
+			size := -1
+			if len(args) > 0 {
+				if err := args[0].Unmarshal(&size); err != nil {
+					return nil, TypeError("read() size must be int")
+				}
+			}
+			return st.read(size, s.chunk(), maxRead)
+		case "readline":
+			return st.readline()
+		case "write":
+			if len(args) != 1 {
+				return nil, TypeError("write() takes exactly one argument (%d given)", len(args))
+			}
+			return st.write(args[0])
+		case "close":
+			s.refs.release(self)
+			if st.closer != nil {
+				return nil, st.closer.Close()
+			}
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unsupported stream method %s", name)
+	})
+}
+
+func (s *Streams) chunk() int {
+	if s.Chunk > 0 {
+		return s.Chunk
+	}
+	return DefaultStreamChunk
+}
+
+// read reads size characters or bytes, at most chunk of them, or with a
+// negative size the rest of the stream, failing past maxRead bytes when set.
+func (st *stream) read(size, chunk int, maxRead int64) (any, error) {
+	if st.r == nil {
+		return nil, errors.New("ValueError: stream is not readable")
+	}
+	if size < 0 {
+		var r io.Reader = st.r
+		if maxRead > 0 {
+			r = io.LimitReader(st.r, maxRead+1)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if maxRead > 0 && int64(len(data)) > maxRead {
+			return nil, fmt.Errorf("ValueError: read() of the whole stream exceeds the result limit of %d bytes; read it in chunks", maxRead)
+		}
+		return st.value(data), nil
+	}
+	if size > chunk {
+		size = chunk
+	}
+	if st.binary {
+		buf := make([]byte, size)
+		n, err := io.ReadFull(st.r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		return st.value(buf[:n]), nil
+	}
+	var b strings.Builder
+	for i := 0; i < size; i++ {
+		r, _, err := st.r.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
+
+func (st *stream) readline() (any, error) {
+	if st.r == nil {
+		return nil, errors.New("ValueError: stream is not readable")
+	}
+	line, err := st.r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return st.value([]byte(line)), nil
+}
+
+func (st *stream) write(arg Object) (any, error) {
+	if st.w == nil {
+		return nil, errors.New("ValueError: stream is not writable")
+	}
+	var data []byte
+	var text string
+	var raw struct {
+		Ints []int `json:"$bytes"`
+	}
+	switch {
+	case arg.Unmarshal(&text) == nil:
+		data = []byte(text)
+	case arg.Unmarshal(&raw) == nil && raw.Ints != nil:
+		data = make([]byte, len(raw.Ints))
+		for i, b := range raw.Ints {
+			data[i] = byte(b)
+		}
+	default:
+		return nil, TypeError("write() argument must be str or bytes")
+	}
+	if _, err := st.w.Write(data); err != nil {
+		return nil, err
+	}
+	if text != "" {
+		return utf8.RuneCountInString(text), nil
+	}
+	return len(data), nil
+}
+
+// value converts read data into str or bytes for the script.
+func (st *stream) value(data []byte) any {
+	if st.binary {
+		return map[string]any{"$bytes": bytesToInts(data)}
+	}
+	return string(data)
+}
+
+func asCloser(v any) io.Closer {
+	c, _ := v.(io.Closer)
+	return c
+}
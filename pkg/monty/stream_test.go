@@ -0,0 +1,92 @@
+package monty
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func streamCall(t *testing.T, h Handler, self Object, args ...string) any {
+	t.Helper()
+	call := CallInfo{Kind: FunctionCall, MethodCall: true, Args: []Object{self}}
+	for _, a := range args {
+		call.Args = append(call.Args, Object(a))
+	}
+	v, err := h(context.Background(), call)
+	if err != nil {
+		t.Fatalf("stream call failed: %v", err)
+	}
+	return v
+}
+
+func TestStreamsReadAndWrite(t *testing.T) {
+	s := NewStreams()
+	s.Chunk = 4
+	in := s.Reader(strings.NewReader("héllo world\nbye"))
+	var out bytes.Buffer
+	w := s.Writer(&out)
+	read, readline, write, closeH := s.Handler("read", nil), s.Handler("readline", nil), s.Handler("write", nil), s.Handler("close", nil)
+
+	if got := streamCall(t, read, in, "3"); got != "hél" {
+		t.Fatalf("read(3) = %q", got)
+	}
+	if got := streamCall(t, read, in, "100"); got != "lo w" {
+		t.Fatalf("read(100) should be capped at the chunk size, got %q", got)
+	}
+	if got := streamCall(t, readline, in); got != "orld\n" {
+		t.Fatalf("readline() = %q", got)
+	}
+	if got := streamCall(t, read, in); got != "bye" {
+		t.Fatalf("read() = %q", got)
+	}
+	if got := streamCall(t, write, w, `"né"`); got != 2 {
+		t.Fatalf("write(str) = %v", got)
+	}
+	if got := streamCall(t, write, w, `{"$bytes":[33]}`); got != 1 {
+		t.Fatalf("write(bytes) = %v", got)
+	}
+	if out.String() != "né!" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	streamCall(t, closeH, in)
+	if _, err := read(context.Background(), CallInfo{MethodCall: true, Args: []Object{in}}); err == nil || !strings.HasPrefix(err.Error(), "ValueError:") {
+		t.Fatalf("read after close should raise ValueError, got %v", err)
+	}
+	if n := s.refs.len(); n != 1 {
+		t.Fatalf("closed streams should be forgotten, %d left", n)
+	}
+}
+
+func TestStreamsBoundReadAllByLimits(t *testing.T) {
+	m, err := New("f.read()", "main.py", []string{"f"}, nil, WithSandbox(callBridge("read", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	r := NewRunner(m, WithLimits(Limits{MaxResultBytes: 8}))
+	s := NewStreams()
+	s.Register(r)
+	read := r.handlers["read"]
+	small, large := s.Reader(strings.NewReader("12345678")), s.Reader(strings.NewReader("123456789"))
+	if got := streamCall(t, read, small); got != "12345678" {
+		t.Fatalf("read() = %q", got)
+	}
+	if _, err := read(context.Background(), CallInfo{MethodCall: true, Args: []Object{large}}); err == nil || !strings.HasPrefix(err.Error(), "ValueError:") {
+		t.Fatalf("reading past the result limit should raise ValueError, got %v", err)
+	}
+}
+
+func TestStreamsBinaryAndFallthrough(t *testing.T) {
+	s := NewStreams()
+	in := s.BinaryReader(bytes.NewReader([]byte{1, 2, 3}))
+	read := s.Handler("read", func(context.Context, CallInfo) (any, error) { return "fallback", nil })
+	got := streamCall(t, read, in, "2").(map[string]any)
+	if ints := got["$bytes"].([]int); len(ints) != 2 || ints[0] != 1 || ints[1] != 2 {
+		t.Fatalf("unexpected bytes %v", got)
+	}
+	v, err := read(context.Background(), CallInfo{Name: "read", Args: []Object{Object(`"x"`)}})
+	if err != nil || v != "fallback" {
+		t.Fatalf("plain calls should reach the previous handler, got %v, %v", v, err)
+	}
+}
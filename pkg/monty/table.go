@@ -0,0 +1,324 @@
+package monty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// tableTypeID identifies the dataclass that carries table references.
+const tableTypeID = 0x6d6f6e7474 // "montt"
+
+// Table is columnar data held as Go slices. The package does not read Arrow or
+// Parquet itself: hosts convert a record batch or row group by passing its
+// value slices as Columns, which are shared rather than copied.
+type Table struct {
+	Columns []Column
+}
+
+// Column is one named column. Values is a slice, typically []int64,
+// []float64, []string, []bool, or []any. Valid, when non-nil, marks which
+// rows hold a value; the others are None in the script.
+type Column struct {
+	Name   string
+	Values any
+	Valid  []bool
+}
+
+// NumRows reports the length of the table's columns.
+func (t Table) NumRows() int {
+	if len(t.Columns) == 0 {
+		return 0
+	}
+	return reflect.ValueOf(t.Columns[0].Values).Len()
+}
+
+// Column returns the column called name.
+func (t Table) Column(name string) (Column, bool) {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+func (t Table) validate() error {
+	rows := -1
+	for _, c := range t.Columns {
+		v := reflect.ValueOf(c.Values)
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("monty: column %s holds %T, not a slice", c.Name, c.Values)
+		}
+		if rows >= 0 && v.Len() != rows {
+			return fmt.Errorf("monty: column %s has %d rows, want %d", c.Name, v.Len(), rows)
+		}
+		if c.Valid != nil && len(c.Valid) != v.Len() {
+			return fmt.Errorf("monty: column %s has %d validity entries for %d rows", c.Name, len(c.Valid), v.Len())
+		}
+		rows = v.Len()
+	}
+	return nil
+}
+
+// slice returns rows [start, stop) of c as a list, with None for invalid rows.
+func (c Column) slice(start, stop int) any {
+	v := reflect.ValueOf(c.Values).Slice(start, stop)
+	if c.Valid == nil {
+		return v.Interface()
+	}
+	out := make([]any, v.Len())
+	for i := range out {
+		if c.Valid[start+i] {
+			out[i] = v.Index(i).Interface()
+		}
+	}
+	return out
+}
+
+// Tables lends columnar data to scripts by reference, so a dataset is not
+// exploded into a list of dicts to cross the bridge. A reference supports
+//
+//	t.columns   t.num_rows   t.column(name, start=0, stop=None)   t.rows(start, stop)
+//
+// where column returns a list of values and rows a list of dicts, each
+// answered by the handlers Register installs and capped at Chunk rows. To
+// hand a table back, a script returns a dict of equal-length lists, which
+// DecodeTable reads.
+type Tables struct {
+	// Chunk caps the rows a single column or rows call returns; zero means
+	// DefaultTableChunk.
+	Chunk int
+
+	refs handles
+}
+
+// DefaultTableChunk caps a single column or rows call when Tables.Chunk is
+// unset.
+const DefaultTableChunk = 10000
+
+// NewTables returns an empty table registry.
+func NewTables() *Tables {
+	return &Tables{refs: newHandles(tableTypeID, "ValueError: table was released")}
+}
+
+// Add registers t and returns a reference to it. The columns are shared, not
+// copied.
+func (ts *Tables) Add(t Table) (Object, error) {
+	if err := t.validate(); err != nil {
+		return nil, newError(ErrInvalidInput, err.Error())
+	}
+	names := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i] = c.Name
+	}
+	return ts.refs.add("Table", t, [2]any{"columns", names}, [2]any{"num_rows", t.NumRows()}), nil
+}
+
+// Table resolves a reference returned by Add.
+func (ts *Tables) Table(o Object) (Table, bool) {
+	v, ok := ts.refs.get(o)
+	if !ok {
+		return Table{}, false
+	}
+	return v.(Table), true
+}
+
+// Release forgets the table o refers to.
+func (ts *Tables) Release(o Object) {
+	ts.refs.release(o)
+}
+
+// Register installs the table methods on r, in front of the handlers r
+// already has under their names.
+func (ts *Tables) Register(r *Runner) {
+	registerMethods(r, []string{"column", "rows"}, ts.Handler)
+}
+
+// Handler returns the handler for the table method name, passing calls that
+// are not table method calls to next, which may be nil.
+func (ts *Tables) Handler(name string, next Handler) Handler {
+	return ts.refs.method(next, func(ctx context.Context, v any, self Object, args []Object) (any, error) {
+		t := v.(Table)
+		switch name {
+		case "column":
+			var col string
+			if len(args) == 0 || args[0].Unmarshal(&col) != nil {
+				return nil, TypeError("column() name must be str")
+			}
+			c, ok := t.Column(col)
+			if !ok {
+				return nil, fmt.Errorf("KeyError: %q", col)
+			}
+			start, stop, err := ts.bounds("column", args[1:], t.NumRows())
+			if err != nil {
+				return nil, err
+			}
+			return c.slice(start, stop), nil
+		case "rows":
+			start, stop, err := ts.bounds("rows", args, t.NumRows())
+			if err != nil {
+				return nil, err
+			}
+			cols := make([]reflect.Value, len(t.Columns))
+			for i, c := range t.Columns {
+				cols[i] = reflect.ValueOf(c.slice(start, stop))
+			}
+			rows := make([]map[string]any, stop-start)
+			for r := range rows {
+				row := make(map[string]any, len(cols))
+				for i, c := range t.Columns {
+					row[c.Name] = cols[i].Index(r).Interface()
+				}
+				rows[r] = row
+			}
+			return rows, nil
+		}
+		return nil, fmt.Errorf("unsupported table method %s", name)
+	})
+}
+
+// bounds reads optional start and stop arguments, clamped like a Python
+// slice and capped at the chunk size.
+func (ts *Tables) bounds(method string, args []Object, rows int) (int, int, error) {
+	start, stop := 0, rows
+	if len(args) > 0 && args[0].Unmarshal(&start) != nil {
+		return 0, 0, TypeError("%s() start must be int", method)
+	}
+	if len(args) > 1 && !bytes.Equal(args[1], []byte("null")) && args[1].Unmarshal(&stop) != nil {
+		return 0, 0, TypeError("%s() stop must be int or None", method)
+	}
+	start, stop = clampIndex(start, rows), clampIndex(stop, rows)
+	chunk := ts.Chunk
+	if chunk <= 0 {
+		chunk = DefaultTableChunk
+	}
+	return start, max(start, min(stop, start+chunk)), nil
+}
+
+// DecodeTable reads a table a script returned as a dict mapping column names
+// to equal-length lists. Columns keep the dict's order; a column whose values
+// are all ints, floats, strs, or bools becomes []int64, []float64, []string,
+// or []bool, with None recorded in Valid, and any other column becomes []any.
+func DecodeTable(o Object) (Table, error) {
+	o = bytes.TrimSpace(o)
+	var t Table
+	var err error
+	add := func(name string, values []byte) bool {
+		var c Column
+		if c, err = decodeColumn(name, values); err == nil {
+			t.Columns = append(t.Columns, c)
+		}
+		return err == nil
+	}
+	if tag, inner := taggedValue(o); tag == "$dict" {
+		eachElement(inner, func(_ int, pair []byte) bool {
+			name, ok := jsonString(elementAt(pair, 0))
+			if !ok {
+				err = fmt.Errorf("monty: table column names must be str")
+				return false
+			}
+			return add(name, elementAt(pair, 1))
+		})
+	} else if len(o) > 0 && o[0] == '{' {
+		eachMember(o, add)
+	} else {
+		return Table{}, newError(ErrInvalidInput, "monty: table must be a dict of lists")
+	}
+	if err == nil {
+		err = t.validate()
+	}
+	if err != nil {
+		return Table{}, wrapError(ErrInvalidInput, err)
+	}
+	return t, nil
+}
+
+func decodeColumn(name string, raw []byte) (Column, error) {
+	if tag, inner := taggedValue(raw); tag == "$tuple" {
+		raw = inner
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return Column{}, fmt.Errorf("monty: column %s is not a list", name)
+	}
+	c := Column{Name: name}
+	kind := byte(0)
+	nulls := 0
+	for _, item := range items {
+		k := columnKind(item)
+		switch {
+		case k == 'n':
+			nulls++
+		case kind == 0, kind == k:
+			kind = k
+		case kind == 'i' && k == 'f', kind == 'f' && k == 'i':
+			kind = 'f'
+		default:
+			kind = 'a'
+		}
+	}
+	if nulls == len(items) {
+		kind = 'a'
+	}
+	if nulls > 0 && kind != 'a' {
+		c.Valid = make([]bool, len(items))
+	}
+	var target reflect.Value
+	switch kind {
+	case 'i':
+		target = reflect.ValueOf(make([]int64, len(items)))
+	case 'f':
+		target = reflect.ValueOf(make([]float64, len(items)))
+	case 's':
+		target = reflect.ValueOf(make([]string, len(items)))
+	case 'b':
+		target = reflect.ValueOf(make([]bool, len(items)))
+	default:
+		values := make([]any, len(items))
+		for i, item := range items {
+			v, err := Object(item).Plain()
+			if err != nil {
+				return Column{}, err
+			}
+			if values[i], err = objectToInterface(v); err != nil {
+				return Column{}, err
+			}
+		}
+		c.Values = values
+		return c, nil
+	}
+	for i, item := range items {
+		if columnKind(item) == 'n' {
+			continue
+		}
+		if c.Valid != nil {
+			c.Valid[i] = true
+		}
+		if err := json.Unmarshal(item, target.Index(i).Addr().Interface()); err != nil {
+			return Column{}, fmt.Errorf("monty: column %s: %w", name, err)
+		}
+	}
+	c.Values = target.Interface()
+	return c, nil
+}
+
+// columnKind classifies a JSON value as int, float, str, bool, null, or any.
+func columnKind(raw []byte) byte {
+	switch raw[0] {
+	case 'n':
+		return 'n'
+	case 't', 'f':
+		return 'b'
+	case '"':
+		return 's'
+	case '{', '[':
+		return 'a'
+	}
+	if bytes.ContainsAny(raw, ".eE") {
+		return 'f'
+	}
+	return 'i'
+}
@@ -0,0 +1,72 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTablesLendColumns(t *testing.T) {
+	ts := NewTables()
+	ts.Chunk = 2
+	ref, err := ts.Add(Table{Columns: []Column{
+		{Name: "id", Values: []int64{1, 2, 3}},
+		{Name: "price", Values: []float64{9.5, 0, 3}, Valid: []bool{true, false, true}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ref.Get("num_rows"); string(got) != "3" {
+		t.Fatalf("num_rows = %s", got)
+	}
+	call := func(name string, args ...string) any {
+		t.Helper()
+		c := CallInfo{Name: name, MethodCall: true, Args: []Object{ref}}
+		for _, a := range args {
+			c.Args = append(c.Args, Object(a))
+		}
+		v, err := ts.Handler(name, nil)(context.Background(), c)
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		return v
+	}
+	if got := call("column", `"id"`, "1", "null"); !reflect.DeepEqual(got, []int64{2, 3}) {
+		t.Fatalf("column(id, 1) = %v", got)
+	}
+	if got := call("column", `"price"`); !reflect.DeepEqual(got, []any{9.5, nil}) {
+		t.Fatalf("column(price) should stop at the chunk size, got %v", got)
+	}
+	want := []map[string]any{{"id": int64(3), "price": 3.0}}
+	if got := call("rows", "-1"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rows(-1) = %v", got)
+	}
+	if _, err := ts.Add(Table{Columns: []Column{{Name: "a", Values: []int{1}}, {Name: "b", Values: []int{}}}}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ragged table: expected ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestDecodeTable(t *testing.T) {
+	table, err := DecodeTable(Object(`{"$dict": [
+		["name", ["a", "b", null]],
+		["n", [1, 2.5, 3]],
+		["ok", {"$tuple": [true, false, true]}],
+		["misc", [1, "x", {"$tuple": [1]}]]
+	]}`))
+	if err != nil {
+		t.Fatalf("DecodeTable failed: %v", err)
+	}
+	want := Table{Columns: []Column{
+		{Name: "name", Values: []string{"a", "b", ""}, Valid: []bool{true, true, false}},
+		{Name: "n", Values: []float64{1, 2.5, 3}},
+		{Name: "ok", Values: []bool{true, false, true}},
+		{Name: "misc", Values: []any{1.0, "x", []any{1.0}}},
+	}}
+	if !reflect.DeepEqual(table, want) {
+		t.Fatalf("DecodeTable = %+v", table)
+	}
+	if _, err := DecodeTable(Object(`{"a": [1], "b": [1, 2]}`)); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("ragged result: expected ErrInvalidInput, got %v", err)
+	}
+}
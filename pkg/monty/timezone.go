@@ -0,0 +1,384 @@
+package monty
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OS functions that depend on the time zone or locale, answered under
+// WithTimezone and WithLocale.
+const (
+	OsLocaltime = "time.localtime"
+	OsGmtime    = "time.gmtime"
+	OsMktime    = "time.mktime"
+	OsStrftime  = "time.strftime"
+	OsStrptime  = "time.strptime"
+	OsGetlocale = "locale.getlocale"
+)
+
+// Locale holds the names and formats strftime and strptime use.
+type Locale struct {
+	// Name is what locale.getlocale reports, e.g. "de_DE".
+	Name string
+	// Days and ShortDays start with Sunday.
+	Days, ShortDays     [7]string
+	Months, ShortMonths [12]string
+	AM, PM              string
+	// DateTime, Date, and Time are the formats of %c, %x, and %X.
+	DateTime, Date, Time string
+}
+
+// LocaleC is the POSIX locale, used when no other is configured.
+var LocaleC = Locale{
+	Name:        "C",
+	Days:        [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	ShortDays:   [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	Months:      [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	ShortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	AM:          "AM",
+	PM:          "PM",
+	DateTime:    "%a %b %e %H:%M:%S %Y",
+	Date:        "%m/%d/%y",
+	Time:        "%H:%M:%S",
+}
+
+// WithTimezone makes loc the local time zone of runs, so that
+// time.localtime, time.mktime, time.strftime, and time.strptime give every
+// tenant's workflows the same local times wherever the host runs. Struct
+// times cross the bridge as 9-tuples in the order of time.struct_time.
+func WithTimezone(loc *time.Location) Option {
+	return func(c *config) {
+		c.location = loc
+	}
+}
+
+// WithLocale sets the day and month names, AM/PM markers, and %c/%x/%X
+// formats that time.strftime and time.strptime use, and the locale that
+// locale.getlocale reports. Runs without WithTimezone use UTC.
+func WithLocale(l Locale) Option {
+	return func(c *config) {
+		c.locale = &l
+	}
+}
+
+// localized reports whether the run answers time zone and locale calls. A
+// deterministic run does, in UTC, so its results do not depend on the host,
+// and so does a run under a Profile or WithClock.
+func (c *config) localized() bool {
+	return c.location != nil || c.locale != nil || c.deterministic || c.profile != "" || c.clock != nil
+}
+
+func (c *config) zone() *time.Location {
+	if c.location != nil {
+		return c.location
+	}
+	return time.UTC
+}
+
+func (c *config) lc() *Locale {
+	if c.locale != nil {
+		return c.locale
+	}
+	return &LocaleC
+}
+
+// now is the run's wall clock: the Clock given with WithClock, the virtual
+// clock of a deterministic run, or otherwise the host's.
+func (r *run) now() time.Time {
+	if r.cfg.clock != nil {
+		return r.cfg.clock.Now()
+	}
+	if r.cfg.deterministic {
+		return time.Unix(0, 0).Add(r.clock)
+	}
+	return time.Now()
+}
+
+// localeCall answers the time zone and locale dependent OS calls.
+func (r *run) localeCall(p Progress) osReply {
+	loc, lc := r.cfg.zone(), r.cfg.lc()
+	switch p.OsFunction {
+	case OsLocaltime, OsGmtime:
+		if p.OsFunction == OsGmtime {
+			loc = time.UTC
+		}
+		var secs *float64
+		if len(p.Args) > 0 {
+			if err := unmarshalArgs(p, &secs); err != nil {
+				return osReply{errMsg: "TypeError: " + err.Error()}
+			}
+		} else if err := unmarshalArgs(p); err != nil {
+			return osReply{errMsg: "TypeError: " + err.Error()}
+		}
+		t := r.now()
+		if secs != nil {
+			t = time.Unix(0, int64(*secs*1e9))
+		}
+		return osReply{value: structTime(t.In(loc), 0)}
+	case OsMktime:
+		fields, ok := structTimeArg(p, 0, 1)
+		if !ok {
+			return osReply{errMsg: "TypeError: mktime() takes a 9-tuple"}
+		}
+		t := fromStructTime(fields, loc)
+		return osReply{value: float64(t.Unix())}
+	case OsStrftime:
+		var format string
+		if len(p.Args) == 0 || len(p.Kwargs) != 0 || p.Args[0].Unmarshal(&format) != nil {
+			return osReply{errMsg: "TypeError: strftime() takes a format string"}
+		}
+		switch len(p.Args) {
+		case 1:
+			return osReply{value: strftime(format, r.now().In(loc), lc)}
+		case 2:
+			fields, ok := structTimeArg(p, 1, 2)
+			if !ok {
+				return osReply{errMsg: "TypeError: strftime() takes a format and a 9-tuple"}
+			}
+			return osReply{value: strftime(format, fromStructTime(fields, loc), lc)}
+		}
+		return osReply{errMsg: fmt.Sprintf("TypeError: strftime() takes 1 or 2 arguments but %d were given", len(p.Args))}
+	case OsStrptime:
+		var s, format string
+		if err := unmarshalArgs(p, &s, &format); err != nil {
+			return osReply{errMsg: "TypeError: " + err.Error()}
+		}
+		t, err := strptime(s, format, loc, lc)
+		if err != nil {
+			return osReply{errMsg: "ValueError: " + err.Error()}
+		}
+		return osReply{value: structTime(t, -1)}
+	case OsGetlocale:
+		if err := unmarshalArgs(p); err != nil {
+			return osReply{errMsg: "TypeError: " + err.Error()}
+		}
+		if lc.Name == "C" {
+			return osReply{value: map[string]any{"$tuple": []any{nil, nil}}}
+		}
+		return osReply{value: map[string]any{"$tuple": []any{lc.Name, "UTF-8"}}}
+	}
+	return osReply{}
+}
+
+// structTime encodes t as a time.struct_time tuple. isDST is 0 or 1 for a
+// known zone, or -1 when unknown, as strptime reports it.
+func structTime(t time.Time, isDST int) any {
+	if isDST == 0 && t.IsDST() {
+		isDST = 1
+	}
+	return map[string]any{"$tuple": []int{
+		t.Year(), int(t.Month()), t.Day(), t.Hour(), t.Minute(), t.Second(),
+		(int(t.Weekday()) + 6) % 7, t.YearDay(), isDST,
+	}}
+}
+
+// structTimeArg decodes argument i of a call taking n arguments as a 9-tuple.
+func structTimeArg(p Progress, i, n int) ([]int, bool) {
+	if len(p.Args) != n || len(p.Kwargs) != 0 {
+		return nil, false
+	}
+	plain, err := p.Args[i].Plain()
+	var fields []int
+	if err != nil || plain.Unmarshal(&fields) != nil || len(fields) != 9 {
+		return nil, false
+	}
+	return fields, true
+}
+
+func fromStructTime(f []int, loc *time.Location) time.Time {
+	return time.Date(f[0], time.Month(f[1]), f[2], f[3], f[4], f[5], 0, loc)
+}
+
+// strftime formats t as C's strftime does in locale lc. Unknown directives
+// are copied through.
+func strftime(format string, t time.Time, lc *Locale) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch c := format[i]; c {
+		case 'a':
+			b.WriteString(lc.ShortDays[t.Weekday()])
+		case 'A':
+			b.WriteString(lc.Days[t.Weekday()])
+		case 'b', 'h':
+			b.WriteString(lc.ShortMonths[t.Month()-1])
+		case 'B':
+			b.WriteString(lc.Months[t.Month()-1])
+		case 'c':
+			b.WriteString(strftime(lc.DateTime, t, lc))
+		case 'x':
+			b.WriteString(strftime(lc.Date, t, lc))
+		case 'X':
+			b.WriteString(strftime(lc.Time, t, lc))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'e':
+			fmt.Fprintf(&b, "%2d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'I':
+			fmt.Fprintf(&b, "%02d", (t.Hour()+11)%12+1)
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'p':
+			if t.Hour() < 12 {
+				b.WriteString(lc.AM)
+			} else {
+				b.WriteString(lc.PM)
+			}
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 'w':
+			fmt.Fprintf(&b, "%d", int(t.Weekday()))
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'Y':
+			fmt.Fprintf(&b, "%d", t.Year())
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'Z':
+			name, _ := t.Zone()
+			b.WriteString(name)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// strptime parses s against format in loc and locale lc. A %z offset in s
+// overrides loc.
+func strptime(s, format string, loc *time.Location, lc *Locale) (time.Time, error) {
+	year, month, day, hour, minute, second := 1900, 1, 1, 0, 0, 0
+	yday, pm, hour12 := 0, -1, false
+	rest := s
+	mismatch := func() (time.Time, error) {
+		return time.Time{}, fmt.Errorf("time data %q does not match format %q", s, format)
+	}
+	num := func(width int) (int, bool) {
+		n := 0
+		for n < width && n < len(rest) && rest[n] >= '0' && rest[n] <= '9' {
+			n++
+		}
+		v, err := strconv.Atoi(rest[:n])
+		rest = rest[n:]
+		return v, err == nil
+	}
+	name := func(names []string) (int, bool) {
+		for i, n := range names {
+			if n != "" && len(rest) >= len(n) && strings.EqualFold(rest[:len(n)], n) {
+				rest = rest[len(n):]
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c == ' ' || c == '\t' || c == '\n' {
+			rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+			continue
+		}
+		if c != '%' || i+1 == len(format) {
+			if rest == "" || rest[0] != c {
+				return mismatch()
+			}
+			rest = rest[1:]
+			continue
+		}
+		i++
+		var ok bool
+		switch format[i] {
+		case 'Y':
+			year, ok = num(4)
+		case 'y':
+			if year, ok = num(2); year < 69 {
+				year += 2000
+			} else {
+				year += 1900
+			}
+		case 'm':
+			month, ok = num(2)
+		case 'd', 'e':
+			rest = strings.TrimLeft(rest, " ")
+			day, ok = num(2)
+		case 'H':
+			hour, ok = num(2)
+		case 'I':
+			hour, ok = num(2)
+			hour12 = true
+		case 'M':
+			minute, ok = num(2)
+		case 'S':
+			second, ok = num(2)
+		case 'j':
+			yday, ok = num(3)
+		case 'b', 'h', 'B':
+			var m int
+			if m, ok = name(append(lc.Months[:], lc.ShortMonths[:]...)); ok {
+				month = m%12 + 1
+			}
+		case 'a', 'A':
+			_, ok = name(append(lc.Days[:], lc.ShortDays[:]...))
+		case 'p':
+			pm, ok = name([]string{lc.AM, lc.PM})
+		case 'z':
+			if len(rest) >= 5 && (rest[0] == '+' || rest[0] == '-') {
+				off, err := time.Parse("-0700", rest[:5])
+				if ok = err == nil; ok {
+					_, secs := off.Zone()
+					loc = time.FixedZone("", secs)
+					rest = rest[5:]
+				}
+			} else if strings.HasPrefix(rest, "Z") {
+				loc, rest, ok = time.UTC, rest[1:], true
+			}
+		case 'Z':
+			n := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsLetter(r) })
+			if n < 0 {
+				n = len(rest)
+			}
+			rest, ok = rest[n:], n > 0
+		case '%':
+			if ok = strings.HasPrefix(rest, "%"); ok {
+				rest = rest[1:]
+			}
+		default:
+			return time.Time{}, fmt.Errorf("%q is a bad directive in format %q", "%"+string(format[i]), format)
+		}
+		if !ok {
+			return mismatch()
+		}
+	}
+	if rest != "" {
+		return time.Time{}, fmt.Errorf("unconverted data remains: %s", rest)
+	}
+	if hour12 && pm >= 0 {
+		hour = hour%12 + 12*pm
+	}
+	if month < 1 || month > 12 {
+		return time.Time{}, fmt.Errorf("month %d is out of range in %q", month, s)
+	}
+	if yday > 0 {
+		return time.Date(year, 1, yday, hour, minute, second, 0, loc), nil
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, second, 0, loc)
+	if t.Day() != day || hour > 23 || minute > 59 || second > 61 {
+		return time.Time{}, fmt.Errorf("day or time is out of range in %q", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,63 @@
+package monty
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func osCallProgress(fn string, args ...string) Progress {
+	p := Progress{Kind: OsCall, OsFunction: fn}
+	for _, a := range args {
+		p.Args = append(p.Args, Object(a))
+	}
+	return p
+}
+
+func TestTimezoneCalls(t *testing.T) {
+	berlin := time.FixedZone("CET", 3600)
+	r := newConfig([]Option{WithTimezone(berlin)}).newRun()
+
+	reply, ok := r.osCall(osCallProgress(OsLocaltime, "0"))
+	got, _ := json.Marshal(reply.value)
+	if !ok || string(got) != `{"$tuple":[1970,1,1,1,0,0,3,1,0]}` {
+		t.Fatalf("unexpected localtime(0): %s (%v)", got, ok)
+	}
+	reply, _ = r.osCall(osCallProgress(OsStrftime, `"%Y-%m-%d %H:%M %Z %z"`, `{"$tuple":[2026,3,1,9,5,0,6,60,0]}`))
+	if reply.value != "2026-03-01 09:05 CET +0100" {
+		t.Fatalf("unexpected strftime: %v (%s)", reply.value, reply.errMsg)
+	}
+	reply, _ = r.osCall(osCallProgress(OsMktime, `[1970,1,1,1,0,0,3,1,0]`))
+	if reply.value != 0.0 {
+		t.Fatalf("unexpected mktime: %v (%s)", reply.value, reply.errMsg)
+	}
+	reply, _ = r.osCall(osCallProgress(OsStrptime, `"Tue 03 Mar 2026 02:30 PM"`, `"%a %d %b %Y %I:%M %p"`))
+	got, _ = json.Marshal(reply.value)
+	if string(got) != `{"$tuple":[2026,3,3,14,30,0,1,62,-1]}` {
+		t.Fatalf("unexpected strptime: %s (%s)", got, reply.errMsg)
+	}
+	if reply, _ = r.osCall(osCallProgress(OsStrptime, `"31/02/2026"`, `"%d/%m/%Y"`)); reply.errMsg == "" {
+		t.Fatalf("strptime should reject February 31st")
+	}
+
+	if _, ok := newConfig(nil).newRun().osCall(osCallProgress(OsLocaltime)); ok {
+		t.Fatalf("localtime should reach the host without a configured zone")
+	}
+}
+
+func TestLocaleCalls(t *testing.T) {
+	de := LocaleC
+	de.Name = "de_DE"
+	de.Days[0], de.ShortMonths[2] = "Sonntag", "Mär"
+	de.Date = "%d.%m.%Y"
+	r := newConfig([]Option{WithLocale(de)}).newRun()
+	reply, _ := r.osCall(osCallProgress(OsStrftime, `"%A %x %b"`, `[2026,3,1,0,0,0,6,60,0]`))
+	if reply.value != "Sonntag 01.03.2026 Mär" {
+		t.Fatalf("unexpected localized strftime: %v (%s)", reply.value, reply.errMsg)
+	}
+	reply, _ = r.osCall(osCallProgress(OsGetlocale))
+	got, _ := json.Marshal(reply.value)
+	if string(got) != `{"$tuple":["de_DE","UTF-8"]}` {
+		t.Fatalf("unexpected getlocale: %s", got)
+	}
+}
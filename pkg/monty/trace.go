@@ -0,0 +1,382 @@
+package monty
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// WithTrace writes a JSON-lines trace of every run to w: its program and
+// inputs, each progress event the host sees, each value the host resumes
+// with, and the final result or error. Runs sharing w are told apart by the
+// run field of each line. Calls the package answers itself, such as
+// virtualized OS calls, are not traced. Call arguments, resume values, and
+// their metadata pass through the configured Redactor first, so a trace of
+// redacted values replays only up to the first call it scrubbed. Failures
+// encoding or writing a line do not affect the runs; the first is logged at
+// warning level to the WithLogger logger, or to slog's default logger.
+func WithTrace(w io.Writer) Option {
+	return func(c *config) {
+		c.tracer = &tracer{w: w}
+	}
+}
+
+// TraceEvent is one line of a trace. Type is "start", "progress", "error",
+// "resume", or "resume_futures"; the other fields are set as they apply.
+type TraceEvent struct {
+	Run  uint64 `json:"run"`
+	Type string `json:"type"`
+
+	// Start.
+	RunID      string            `json:"run_id,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Script     string            `json:"script,omitempty"`
+	Code       string            `json:"code,omitempty"`
+	InputNames []string          `json:"input_names,omitempty"`
+	Functions  []string          `json:"functions,omitempty"`
+	Inputs     json.RawMessage   `json:"inputs,omitempty"`
+
+	// Progress. Kind is "complete", "function_call", "os_call",
+	// "resolve_futures", or "timer".
+	Kind       string   `json:"kind,omitempty"`
+	CallID     uint32   `json:"call_id,omitempty"`
+	Function   string   `json:"function,omitempty"`
+	MethodCall bool     `json:"method_call,omitempty"`
+	Args       []Object `json:"args,omitempty"`
+	Kwargs     []Object `json:"kwargs,omitempty"`
+	PendingIDs []uint32 `json:"pending_ids,omitempty"`
+	Seconds    float64  `json:"seconds,omitempty"`
+
+	// Result is the final result of a complete event or the value of a
+	// resume; Error the message of an error or of a raising resume; Future
+	// marks a resume that left the call pending.
+	Result  Object        `json:"result,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Future  bool          `json:"future,omitempty"`
+	Results []TraceFuture `json:"results,omitempty"`
+	// Meta is the metadata of an Annotated resume value.
+	Meta Object `json:"meta,omitempty"`
+}
+
+// TraceFuture is one future result of a resume_futures line.
+type TraceFuture struct {
+	CallID uint32 `json:"call_id"`
+	Result Object `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Meta   Object `json:"meta,omitempty"`
+}
+
+// tracer numbers runs and serializes their lines onto one writer.
+type tracer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	runs uint64
+	// failed is set once a failure was reported.
+	failed bool
+}
+
+func (t *tracer) begin() *runTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.runs++
+	return &runTrace{t: t, id: t.runs}
+}
+
+type runTrace struct {
+	t  *tracer
+	id uint64
+}
+
+func (r *run) record(e TraceEvent) {
+	if r.trace == nil {
+		return
+	}
+	e.Run = r.trace.id
+	t := r.trace.t
+	line, err := json.Marshal(e)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil {
+		_, err = t.w.Write(append(line, '\n'))
+	}
+	if err != nil && !t.failed {
+		t.failed = true
+		logger := r.cfg.logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.Warn("monty: trace line dropped", "run", r.id, "type", e.Type, "err", err)
+	}
+}
+
+// traced records the outcome of a step the host will see, once uncaught
+// exceptions are turned into results under WithExceptionResults.
+func (r *run) traced(p Progress, err error) (Progress, error) {
+	p, err = r.raised(p, err)
+	p, err = r.leaveGroup(p, err)
+	r.trackProgress(p, err)
+	r.fireProgress(p, err)
+	if r.trace == nil {
+		return p, err
+	}
+	if err != nil {
+		r.record(TraceEvent{Type: "error", Error: err.Error()})
+	} else {
+		r.record(r.redactEvent(progressEvent(p), p))
+	}
+	return p, err
+}
+
+// redactEvent scrubs the arguments of the call e records.
+func (r *run) redactEvent(e TraceEvent, p Progress) TraceEvent {
+	if r.cfg.redactor == nil || (p.Kind != FunctionCall && p.Kind != OsCall) {
+		return e
+	}
+	call := r.cfg.redact(CallInfo{Kind: p.Kind, Name: e.Function, Args: p.Args, Kwargs: p.Kwargs, CallID: p.CallID, MethodCall: p.MethodCall})
+	e.Args, e.Kwargs = call.Args, nil
+	for _, kv := range call.Kwargs {
+		e.Kwargs = append(e.Kwargs, kv.Key, kv.Value)
+	}
+	return e
+}
+
+// redactValue scrubs a value the host answered the call name with, as the
+// Redactor scrubs an argument of it. name is empty when the call is unknown.
+func (r *run) redactValue(name string, v Object) Object {
+	if r.cfg.redactor == nil || v == nil {
+		return v
+	}
+	call := r.cfg.redact(CallInfo{Name: name, Args: []Object{v}})
+	if len(call.Args) != 1 {
+		return nil
+	}
+	return call.Args[0]
+}
+
+// traceResume records the answer to the call name.
+func (r *run) traceResume(callID uint32, name string, result any, errMsg string) {
+	if r.trace == nil {
+		return
+	}
+	e := TraceEvent{Type: "resume", CallID: callID, Error: errMsg}
+	if errMsg == "" && result == nil {
+		e.Future = true
+	} else if errMsg == "" {
+		e.Result, _ = marshalValue(result)
+		e.Result = r.redactValue(name, e.Result)
+	}
+	e.Meta = r.redactValue(name, traceMeta(result))
+	r.record(e)
+}
+
+// traceFutures records the results of pending futures.
+func (r *run) traceFutures(results []FutureResult) {
+	if r.trace == nil {
+		return
+	}
+	e := TraceEvent{Type: "resume_futures", Results: make([]TraceFuture, len(results))}
+	for i, res := range results {
+		e.Results[i] = TraceFuture{CallID: res.CallID, Error: res.Err, Meta: r.redactValue("", traceMeta(res.Result))}
+		if res.Err == "" {
+			e.Results[i].Result, _ = marshalValue(res.Result)
+			e.Results[i].Result = r.redactValue("", e.Results[i].Result)
+		}
+	}
+	r.record(e)
+}
+
+// traceMeta encodes the metadata of an Annotated result, if any.
+func traceMeta(result any) Object {
+	if _, meta := splitMeta(result); meta != nil {
+		data, _ := marshalValue(meta)
+		return data
+	}
+	return nil
+}
+
+func progressEvent(p Progress) TraceEvent {
+	e := TraceEvent{
+		Type:       "progress",
+		Kind:       p.Kind.String(),
+		CallID:     p.CallID,
+		Function:   p.FunctionName,
+		MethodCall: p.MethodCall,
+		Args:       p.Args,
+		PendingIDs: p.PendingIDs,
+		Seconds:    p.Duration.Seconds(),
+		Result:     p.Result,
+	}
+	if p.Kind == OsCall {
+		e.Function = p.OsFunction
+	}
+	for _, kv := range p.Kwargs {
+		e.Kwargs = append(e.Kwargs, kv.Key, kv.Value)
+	}
+	return e
+}
+
+// TraceRun is the trace of one run.
+type TraceRun struct {
+	ID     uint64
+	Events []TraceEvent
+}
+
+// ReadTrace parses a trace written by WithTrace, grouping its lines by run in
+// the order the runs started.
+func ReadTrace(r io.Reader) ([]TraceRun, error) {
+	var runs []TraceRun
+	index := make(map[uint64]int)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxFrameBytes)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var e TraceEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, newError(ErrInvalidInput, fmt.Sprintf("monty: trace line %d: %v", line, err))
+		}
+		i, ok := index[e.Run]
+		if !ok {
+			i = len(runs)
+			index[e.Run] = i
+			runs = append(runs, TraceRun{ID: e.Run})
+		}
+		runs[i].Events = append(runs[i].Events, e)
+	}
+	return runs, sc.Err()
+}
+
+// Program compiles the program a traced run executed.
+func (tr TraceRun) Program(opts ...Option) (*Monty, error) {
+	if len(tr.Events) == 0 || tr.Events[0].Type != "start" || tr.Events[0].Code == "" {
+		return nil, ErrNoSource
+	}
+	s := tr.Events[0]
+	return New(s.Code, s.Script, s.InputNames, s.Functions, opts...)
+}
+
+// TraceDivergence reports a replay whose run did something other than the
+// trace recorded. Index is the position of Want in TraceRun.Events.
+type TraceDivergence struct {
+	Index int
+	Want  TraceEvent
+	Got   TraceEvent
+}
+
+func (e *TraceDivergence) Error() string {
+	want, _ := json.Marshal(e.Want)
+	got, _ := json.Marshal(e.Got)
+	return fmt.Sprintf("monty: replay diverges from trace event %d: want %s, got %s", e.Index, want, got)
+}
+
+// Replay re-drives the traced run on m, starting it with the recorded inputs
+// and answering each event with the recorded resume, and returns its final
+// result. It stops with a *TraceDivergence at the first event that differs
+// from the trace, so replaying on a fixed program shows where behavior
+// changed. Options of m such as WithEnv should match the traced run's.
+func (tr TraceRun) Replay(m *Monty) (Object, error) {
+	p, err := tr.drive(m, sameEvent)
+	if err != nil {
+		return nil, err
+	}
+	if p.Kind != Complete {
+		closeProgress(p)
+		return nil, fmt.Errorf("monty: trace ends before run %d finished", tr.ID)
+	}
+	return completeResult(p)
+}
+
+// drive starts the traced run on m and answers its events from the trace
+// while they match the recorded ones, returning the progress after the last
+// recorded event. Call IDs of answers are translated to the calls they
+// matched, so a run whose IDs shifted is still answered correctly.
+func (tr TraceRun) drive(m *Monty, match func(want, got TraceEvent) bool) (Progress, error) {
+	if len(tr.Events) == 0 || tr.Events[0].Type != "start" {
+		return Progress{}, newError(ErrInvalidInput, "monty: trace run has no start event")
+	}
+	var inputs []json.RawMessage
+	if err := json.Unmarshal(tr.Events[0].Inputs, &inputs); err != nil && len(tr.Events[0].Inputs) > 0 {
+		return Progress{}, newError(ErrInvalidInput, fmt.Sprintf("monty: trace inputs: %v", err))
+	}
+	args := make([]any, len(inputs))
+	for i, in := range inputs {
+		args[i] = in
+	}
+	ids := make(map[uint32]uint32)
+	p, err := m.Start(args...)
+	for i := 1; i < len(tr.Events); i += 2 {
+		want := tr.Events[i]
+		got := TraceEvent{Run: tr.ID, Type: "error"}
+		if err != nil {
+			got.Error = err.Error()
+		} else {
+			got = progressEvent(p)
+			got.Run = tr.ID
+		}
+		if !match(want, got) {
+			if err == nil {
+				closeProgress(p)
+			}
+			return Progress{}, &TraceDivergence{Index: i, Want: want, Got: got}
+		}
+		if err != nil {
+			return Progress{}, err
+		}
+		if want.Kind == FunctionCall.String() || want.Kind == OsCall.String() || want.Kind == Timer.String() {
+			ids[want.CallID] = p.CallID
+		}
+		if p.Kind == Complete || i+1 >= len(tr.Events) {
+			break
+		}
+		p, err = replayResume(p, tr.Events[i+1], ids)
+	}
+	return p, err
+}
+
+func sameEvent(a, b TraceEvent) bool {
+	return sameJSON(a, b)
+}
+
+// sameJSON reports whether a and b encode to the same JSON, which ignores
+// the whitespace of embedded Objects.
+func sameJSON(a, b any) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(x, y)
+}
+
+// replayResume answers p as the trace event e records, translating recorded
+// call IDs through ids.
+func replayResume(p Progress, e TraceEvent, ids map[uint32]uint32) (Progress, error) {
+	id := func(old uint32) uint32 {
+		if id, ok := ids[old]; ok {
+			return id
+		}
+		return old
+	}
+	switch {
+	case e.Type == "resume_futures" && p.Kind == ResolveFutures:
+		results := make([]FutureResult, len(e.Results))
+		for i, r := range e.Results {
+			results[i] = FutureResult{CallID: id(r.CallID), Result: r.Result, Err: r.Error}
+		}
+		return p.FutureSnapshot.Resume(results)
+	case e.Type == "resume" && p.Snapshot != nil:
+		switch {
+		case e.Error != "":
+			return p.Snapshot.ResumeError(id(e.CallID), e.Error)
+		case e.Future:
+			next, _, err := p.Snapshot.ResumeFuture(id(e.CallID))
+			return next, err
+		}
+		return p.Snapshot.Resume(id(e.CallID), e.Result)
+	}
+	closeProgress(p)
+	return Progress{}, errors.New("monty: trace does not answer the replayed event")
+}
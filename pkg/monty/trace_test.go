@@ -0,0 +1,130 @@
+package monty
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTraceRecordAndReplay(t *testing.T) {
+	var ops []string
+	var buf bytes.Buffer
+	m, err := New("double(21)", "main.py", nil, []string{"double"},
+		WithSandbox(eventsBridge(&ops)), WithTrace(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Snapshot.Resume(p.CallID, 42); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatalf("ReadTrace failed: %v", err)
+	}
+	if len(runs) != 1 || len(runs[0].Events) != 4 {
+		t.Fatalf("expected one run of 4 events, got %+v", runs)
+	}
+	events := runs[0].Events
+	if events[0].Code != "double(21)" || string(events[0].Inputs) != "[7]" ||
+		events[1].Function != "double" || string(events[2].Result) != "42" || events[3].Kind != "complete" {
+		t.Fatalf("unexpected trace %+v", events)
+	}
+
+	replay, err := runs[0].Program(WithSandbox(eventsBridge(&ops)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replay.Close()
+	result, err := runs[0].Replay(replay)
+	if err != nil || string(result) != "42" {
+		t.Fatalf("replay returned %s, %v", result, err)
+	}
+
+	runs[0].Events[2].Result = Object("43")
+	_, err = runs[0].Replay(replay)
+	var div *TraceDivergence
+	if !errors.As(err, &div) || div.Index != 3 || string(div.Got.Result) != "43" {
+		t.Fatalf("expected divergence at the complete event, got %v", err)
+	}
+}
+
+func TestReadTraceRejectsBadLines(t *testing.T) {
+	_, err := ReadTrace(strings.NewReader("{\"run\":1,\"type\":\"start\"}\nnot json\n"))
+	if !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected a line 2 error, got %v", err)
+	}
+}
+
+func TestTraceRedactsCalls(t *testing.T) {
+	bridge := scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start": reply(sandboxResponse{Progress: &sandboxProgress{
+			Kind: FunctionCall, CallID: 1, FunctionName: "login",
+			Args: json.RawMessage(`["secret-arg"]`), Kwargs: json.RawMessage(`[["token","abc"]]`), Snapshot: 2,
+		}}),
+		"resume": reply(completeProgress(json.RawMessage("1"))),
+	})
+	rules := RedactionRules{Patterns: []*regexp.Regexp{regexp.MustCompile(`secret-\w+`)}, Fields: map[string][]string{"login": {"token"}}}
+	var buf bytes.Buffer
+	tracker := NewCallTracker()
+	m, err := New("login(x, token=y)", "main.py", nil, []string{"login"},
+		WithSandbox(bridge), WithTrace(&buf), WithRedactor(rules), WithCallTracker(tracker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer := Annotated{Value: map[string]any{"token": "xyz", "user": "secret-user"}, Meta: map[string]any{"token": "def", "source": "secret-db"}}
+	if _, err := p.Snapshot.Resume(p.CallID, answer); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, leaked := range []string{"secret-", "abc", "xyz", "def"} {
+		if strings.Contains(buf.String(), leaked) {
+			t.Errorf("trace contains %q:\n%s", leaked, buf.String())
+		}
+	}
+	meta := tracker.Calls(p.RunID)[0].Meta
+	if meta["token"] != DefaultRedaction || meta["source"] != DefaultRedaction {
+		t.Errorf("tracked meta = %v", meta)
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestTraceReportsWriteFailures(t *testing.T) {
+	var logs bytes.Buffer
+	var ops []string
+	m, err := New("double(21)", "main.py", nil, []string{"double"},
+		WithSandbox(eventsBridge(&ops)), WithTrace(failingWriter{}), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Snapshot.Resume(p.CallID, 42); err != nil {
+		t.Fatalf("a failing trace writer failed the run: %v", err)
+	}
+	if n := strings.Count(logs.String(), "disk full"); n != 1 {
+		t.Fatalf("expected the failure to be logged once, got:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,85 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ResultTransform post-processes the final result of a run, for example to
+// validate it against a schema, truncate it, or scrub personal data. It
+// returns the result to pass on, or an error that fails the run.
+type ResultTransform func(ctx context.Context, result Object) (Object, error)
+
+// Transform appends t to the transforms applied, in registration order, to
+// the result of each run before Run returns it. Register transforms before
+// running, as with handlers. A transform's error is returned by Run, wrapped
+// with the transform's position.
+func (r *Runner) Transform(t ResultTransform) {
+	r.transforms = append(r.transforms, t)
+}
+
+// transform passes result through the registered transforms.
+func (r *Runner) transform(ctx context.Context, result Object, err error) (Object, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range r.transforms {
+		if result, err = t(ctx, result); err != nil {
+			return nil, fmt.Errorf("monty: result transform %d: %w", i, err)
+		}
+	}
+	return result, nil
+}
+
+// RedactResult returns a ResultTransform that scrubs the result with rules:
+// string values matching its patterns are replaced, and so are the values of
+// dict keys listed under the "*" entry of its fields.
+func RedactResult(rules RedactionRules) ResultTransform {
+	return func(_ context.Context, result Object) (Object, error) {
+		return rules.redactObject(result, rules.Fields["*"]), nil
+	}
+}
+
+// TruncateResult returns a ResultTransform that cuts the strings of the
+// result longer than maxRunes down to maxRunes, ending them with "…".
+// Tagged values such as bytes and big ints are left whole.
+func TruncateResult(maxRunes int) ResultTransform {
+	return func(_ context.Context, result Object) (Object, error) {
+		value, err := objectToInterface(result)
+		if err != nil || value == nil {
+			return result, err
+		}
+		data, err := json.Marshal(truncateValue(value, maxRunes))
+		if err != nil {
+			return nil, err
+		}
+		return Object(data), nil
+	}
+}
+
+func truncateValue(value any, maxRunes int) any {
+	switch v := value.(type) {
+	case string:
+		if runes := []rune(v); len(runes) > maxRunes {
+			return string(runes[:max(maxRunes-1, 0)]) + "…"
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = truncateValue(v[i], maxRunes)
+		}
+		return v
+	case map[string]any:
+		for k, item := range v {
+			if _, tag := item.(string); tag && len(v) == 1 && strings.HasPrefix(k, "$") {
+				return v
+			}
+			v[k] = truncateValue(item, maxRunes)
+		}
+		return v
+	default:
+		return value
+	}
+}
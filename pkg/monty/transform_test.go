@@ -0,0 +1,40 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestRunnerTransform(t *testing.T) {
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(callBridge("double", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	r := NewRunner(m)
+	r.Register("double", func(ctx context.Context, call CallInfo) (any, error) {
+		return map[string]any{"email": "ann@example.com", "note": "contact ann@example.com today", "id": json.RawMessage(`{"$bigint":"123456789012345678901234567890"}`)}, nil
+	})
+	r.Transform(RedactResult(RedactionRules{Patterns: []*regexp.Regexp{regexp.MustCompile(`\S+@\S+`)}, Fields: map[string][]string{"*": {"email"}}}))
+	r.Transform(TruncateResult(12))
+	result, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := result.Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["email"] != "[REDACTED]" || got["note"] != "contact [RE…" || string(result.Get("id")) != `{"$bigint":"123456789012345678901234567890"}` {
+		t.Errorf("transformed result = %s", result)
+	}
+
+	invalid := errors.New("missing total")
+	r.Transform(func(ctx context.Context, result Object) (Object, error) { return nil, invalid })
+	if _, err := r.Run(context.Background()); !errors.Is(err, invalid) || err.Error() != "monty: result transform 2: missing total" {
+		t.Errorf("Run = %v, want the transform's error", err)
+	}
+}
@@ -0,0 +1,221 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// progressFormat is the version of the JSON encoding of Progress.
+const progressFormat = 1
+
+// progressJSON is the stable encoding of a Progress. Fields are only ever
+// added, so older decoders skip what they do not know.
+type progressJSON struct {
+	Format     int               `json:"format"`
+	Kind       string            `json:"kind"`
+	Result     Object            `json:"result,omitempty"`
+	Function   string            `json:"function,omitempty"`
+	OsFunction string            `json:"os_function,omitempty"`
+	Args       []Object          `json:"args,omitempty"`
+	Kwargs     []kvJSON          `json:"kwargs,omitempty"`
+	CallID     uint32            `json:"call_id,omitempty"`
+	MethodCall bool              `json:"method_call,omitempty"`
+	PendingIDs []uint32          `json:"pending_ids,omitempty"`
+	Duration   time.Duration     `json:"duration_ns,omitempty"`
+	Token      string            `json:"token,omitempty"`
+	Program    string            `json:"program,omitempty"`
+	RunID      string            `json:"run_id,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Context    map[string]Object `json:"context,omitempty"`
+	Exception  *exceptionJSON    `json:"exception,omitempty"`
+	Warnings   []warningJSON     `json:"warnings,omitempty"`
+}
+
+type kvJSON struct {
+	Key   Object `json:"key"`
+	Value Object `json:"value"`
+}
+
+type exceptionJSON struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type warningJSON struct {
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+// MarshalJSON encodes p in a stable format, for shipping progress events to
+// other workers over a queue. Live handles cannot be encoded: Detach p
+// first, which replaces its snapshot with a Token.
+func (p Progress) MarshalJSON() ([]byte, error) {
+	if p.Snapshot != nil || p.FutureSnapshot != nil {
+		return nil, newError(ErrInvalidInput, "monty: progress holds a live snapshot; Detach it first")
+	}
+	if p.ResultStream != nil {
+		return nil, newError(ErrInvalidInput, "monty: progress holds a result stream")
+	}
+	kind, ok := progressKindNames[p.Kind]
+	if !ok {
+		return nil, newError(ErrInvalidInput, fmt.Sprintf("monty: unknown progress kind %d", p.Kind))
+	}
+	w := progressJSON{
+		Format:     progressFormat,
+		Kind:       kind,
+		Result:     p.Result,
+		Function:   p.FunctionName,
+		OsFunction: p.OsFunction,
+		Args:       p.Args,
+		CallID:     p.CallID,
+		MethodCall: p.MethodCall,
+		PendingIDs: p.PendingIDs,
+		Duration:   p.Duration,
+		Token:      p.Token,
+		Program:    p.Program,
+		RunID:      p.RunID,
+		Labels:     p.Labels,
+		Context:    p.Context,
+	}
+	if e := p.Exception; e != nil {
+		w.Exception = &exceptionJSON{Type: e.Type, Message: e.Message}
+	}
+	for _, kv := range p.Kwargs {
+		w.Kwargs = append(w.Kwargs, kvJSON(kv))
+	}
+	for _, warning := range p.Warnings {
+		w.Warnings = append(w.Warnings, warningJSON(warning))
+	}
+	return json.Marshal(w)
+}
+
+// UnmarshalJSON decodes a progress event encoded by MarshalJSON. Attach it
+// to continue the run.
+func (p *Progress) UnmarshalJSON(data []byte) error {
+	var w progressJSON
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	if w.Format < 1 || w.Format > progressFormat {
+		return newError(ErrInvalidInput, fmt.Sprintf("monty: progress format %d is not supported", w.Format))
+	}
+	kind, ok := ProgressKind(-1), false
+	for k, name := range progressKindNames {
+		if name == w.Kind {
+			kind, ok = k, true
+		}
+	}
+	if !ok {
+		return newError(ErrInvalidInput, fmt.Sprintf("monty: unknown progress kind %q", w.Kind))
+	}
+	*p = Progress{
+		Kind:         kind,
+		Result:       w.Result,
+		FunctionName: w.Function,
+		OsFunction:   w.OsFunction,
+		Args:         w.Args,
+		CallID:       w.CallID,
+		MethodCall:   w.MethodCall,
+		PendingIDs:   w.PendingIDs,
+		Duration:     w.Duration,
+		Token:        w.Token,
+		Program:      w.Program,
+		RunID:        w.RunID,
+		Labels:       w.Labels,
+		Context:      w.Context,
+	}
+	if e := w.Exception; e != nil {
+		p.Exception = &Exception{Type: e.Type, Message: e.Message}
+	}
+	for _, kv := range w.Kwargs {
+		p.Kwargs = append(p.Kwargs, KV(kv))
+	}
+	for _, warning := range w.Warnings {
+		p.Warnings = append(p.Warnings, Warning(warning))
+	}
+	return nil
+}
+
+// Detach moves the paused state of p into store, replacing its snapshot with
+// Token, the key it is stored under, so that p can be encoded and acted on
+// by another worker. The snapshot is consumed. Detaching a Complete event,
+// or one already detached, does nothing.
+func (p *Progress) Detach(ctx context.Context, store Store) error {
+	var data []byte
+	var pending []uint32
+	var err error
+	switch {
+	case p.Snapshot != nil:
+		data, err = p.Snapshot.Dump()
+	case p.FutureSnapshot != nil:
+		data, pending, err = p.FutureSnapshot.DumpPending()
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token := "monty-progress-" + newRunID()
+	if err := store.Put(ctx, token, data); err != nil {
+		return err
+	}
+	closeProgress(*p)
+	if pending != nil {
+		p.PendingIDs = pending
+	}
+	p.Snapshot, p.FutureSnapshot, p.Token = nil, nil, token
+	return nil
+}
+
+// Attach restores the snapshot of a detached p from store, so the worker
+// holding p can resume it. The stored state is deleted, so a progress event
+// is acted on once. opts configure the restored run as for
+// SnapshotFromBytes; the run keeps its RunID and Labels.
+func (p *Progress) Attach(ctx context.Context, store Store, opts ...Option) error {
+	return p.attach(ctx, store, newConfig(opts).newRun)
+}
+
+// attach restores the snapshot of p as a run made by newRun.
+func (p *Progress) attach(ctx context.Context, store Store, newRun func() *run) error {
+	if p.Token == "" {
+		if p.Kind == Complete {
+			return nil
+		}
+		return newError(ErrInvalidInput, "monty: progress has no token")
+	}
+	data, err := store.Get(ctx, p.Token)
+	if err != nil {
+		return err
+	}
+	r := newRun()
+	if p.RunID != "" {
+		r.id = p.RunID
+	}
+	if len(p.Labels) > 0 {
+		r.cfg = r.cfg.with([]Option{WithLabels(p.Labels)})
+	}
+	if p.Kind == ResolveFutures {
+		fs, err := loadFutureSnapshot(data, r)
+		if err != nil {
+			return err
+		}
+		fs.pending = append([]uint32(nil), p.PendingIDs...)
+		p.FutureSnapshot = fs
+	} else {
+		s, err := loadSnapshot(data, r)
+		if err != nil {
+			return err
+		}
+		s.call = PendingCall{CallID: p.CallID, FunctionName: p.FunctionName, MethodCall: p.MethodCall, Args: p.Args, Kwargs: p.Kwargs}
+		p.Snapshot = s
+	}
+	if err := store.Delete(ctx, p.Token); err != nil {
+		closeProgress(*p)
+		p.Snapshot, p.FutureSnapshot = nil, nil
+		return err
+	}
+	p.Token = ""
+	return nil
+}
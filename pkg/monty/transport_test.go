@@ -0,0 +1,99 @@
+package monty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProgressDetachAttach(t *testing.T) {
+	sb := callBridge("double", 1)
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(sb), WithLabels(map[string]string{"tenant": "acme"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := json.Marshal(p); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Marshal of a live progress: err = %v", err)
+	}
+	snap := p.Snapshot
+	ctx := context.Background()
+	store := NewMemoryStore()
+	if err := p.Detach(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	if p.Snapshot != nil || p.Token == "" || !snap.IsClosed() {
+		t.Fatalf("Detach left %+v", p)
+	}
+	msg, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another worker decodes the message and resumes the run.
+	var got Progress
+	if err := json.Unmarshal(msg, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != FunctionCall || got.FunctionName != "double" || got.CallID != 1 || got.RunID != p.RunID || got.Labels["tenant"] != "acme" {
+		t.Errorf("decoded %+v", got)
+	}
+	if err := got.Attach(ctx, store, WithSandbox(sb)); err != nil {
+		t.Fatal(err)
+	}
+	done, err := got.Snapshot.Resume(got.CallID, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.Kind != Complete || string(done.Result) != "42" || done.RunID != p.RunID {
+		t.Errorf("Resume = %+v", done)
+	}
+
+	// The stored state is consumed by the first Attach.
+	var again Progress
+	json.Unmarshal(msg, &again)
+	if err := again.Attach(ctx, store, WithSandbox(sb)); !errors.Is(err, ErrNotStored) {
+		t.Errorf("second Attach: err = %v, want ErrNotStored", err)
+	}
+}
+
+func TestProgressJSONRoundTrip(t *testing.T) {
+	want := Progress{
+		Kind:         FunctionCall,
+		FunctionName: "fetch",
+		Args:         []Object{Object(`"a"`), Object(`{"$tuple":[1,2]}`)},
+		Kwargs:       []KV{{Key: Object(`"timeout"`), Value: Object(`1.5`)}},
+		CallID:       3,
+		MethodCall:   true,
+		Duration:     2 * time.Second,
+		Token:        "t",
+		RunID:        "r",
+		Labels:       map[string]string{"k": "v"},
+		Context:      map[string]Object{"request_id": Object(`"abc"`)},
+		Exception:    &Exception{Type: "ValueError", Message: "bad"},
+		Warnings:     []Warning{{Category: "UserWarning", Message: "careful"}},
+	}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Progress
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, want)
+	}
+	for _, bad := range []string{`{"format":2,"kind":"complete"}`, `{"format":1,"kind":"nope"}`} {
+		if err := json.Unmarshal([]byte(bad), &got); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Unmarshal(%s): err = %v", bad, err)
+		}
+	}
+}
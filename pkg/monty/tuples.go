@@ -0,0 +1,138 @@
+package monty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ObjectKind is the Python type of an Object, named as type(x).__name__
+// names builtins. Dataclasses, named tuples, exceptions, and reprs report
+// their category rather than their class.
+type ObjectKind string
+
+const (
+	NoneKind       ObjectKind = "NoneType"
+	BoolKind       ObjectKind = "bool"
+	IntKind        ObjectKind = "int"
+	FloatKind      ObjectKind = "float"
+	StrKind        ObjectKind = "str"
+	BytesKind      ObjectKind = "bytes"
+	ListKind       ObjectKind = "list"
+	TupleKind      ObjectKind = "tuple"
+	NamedTupleKind ObjectKind = "namedtuple"
+	DictKind       ObjectKind = "dict"
+	SetKind        ObjectKind = "set"
+	FrozenSetKind  ObjectKind = "frozenset"
+	PathKind       ObjectKind = "Path"
+	DataclassKind  ObjectKind = "dataclass"
+	ExceptionKind  ObjectKind = "exception"
+	ReprKind       ObjectKind = "repr"
+)
+
+// Kind returns the Python type of o, telling apart what Plain and Unmarshal
+// into []any merge, such as a tuple and a list. It returns "" for an empty
+// Object or JSON it does not recognize.
+func (o Object) Kind() ObjectKind {
+	v := bytes.TrimSpace(o)
+	if len(v) == 0 {
+		return ""
+	}
+	switch v[0] {
+	case 'n':
+		return NoneKind
+	case 't', 'f':
+		return BoolKind
+	case '"':
+		return StrKind
+	case '[':
+		return ListKind
+	case '{':
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		for _, c := range v {
+			if c == '.' || c == 'e' || c == 'E' {
+				return FloatKind
+			}
+		}
+		return IntKind
+	default:
+		return ""
+	}
+	tag, _ := taggedValue(v)
+	switch tag {
+	case "$tuple":
+		return TupleKind
+	case "$named_tuple":
+		return NamedTupleKind
+	case "$dict":
+		return DictKind
+	case "$set":
+		return SetKind
+	case "$frozenset":
+		return FrozenSetKind
+	case "$bytes":
+		return BytesKind
+	case "$bigint":
+		return IntKind
+	case "$float":
+		return FloatKind
+	case "$path":
+		return PathKind
+	case "$dataclass":
+		return DataclassKind
+	case "$exception":
+		return ExceptionKind
+	case "$repr":
+		return ReprKind
+	}
+	return ""
+}
+
+// Tuple is a Python tuple. Inputs and results encode slices as lists, so
+// scripts that check isinstance(x, tuple) need a Tuple to see one. It
+// crosses the bridge as {"$tuple": [...]}; decoding accepts tuples and named
+// tuples but not lists, and fills a Tuple with Objects.
+type Tuple []any
+
+// MarshalJSON encodes t as a $tuple, applying registered converters to its
+// elements.
+func (t Tuple) MarshalJSON() ([]byte, error) {
+	out := []byte(`{"$tuple":[`)
+	for i, item := range t {
+		data, err := marshalValue(item)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = append(out, data...)
+	}
+	return append(out, "]}"...), nil
+}
+
+// UnmarshalJSON decodes a tuple or named tuple into t.
+func (t *Tuple) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var items []json.RawMessage
+	switch tag, inner := taggedValue(data); tag {
+	case "$tuple":
+		if err := json.Unmarshal(inner, &items); err != nil {
+			return err
+		}
+	case "$named_tuple":
+		if err := json.Unmarshal(memberValue(inner, "values"), &items); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("monty: cannot decode %s as a tuple", Object(data).Kind())
+	}
+	out := make(Tuple, len(items))
+	for i, item := range items {
+		out[i] = append(Object{}, item...)
+	}
+	*t = out
+	return nil
+}
@@ -0,0 +1,54 @@
+package monty
+
+import "testing"
+
+func TestObjectKind(t *testing.T) {
+	for in, want := range map[string]ObjectKind{
+		`null`:                       NoneKind,
+		` true`:                      BoolKind,
+		`-12`:                        IntKind,
+		`{"$bigint": "1"}`:           IntKind,
+		`1.5e3`:                      FloatKind,
+		`{"$float": "nan"}`:          FloatKind,
+		`"s"`:                        StrKind,
+		`[1, 2]`:                     ListKind,
+		`{"$tuple": [1, 2]}`:         TupleKind,
+		`{"$named_tuple": {}}`:       NamedTupleKind,
+		`{"$dict": []}`:              DictKind,
+		`{"$set": []}`:               SetKind,
+		`{"$frozenset": []}`:         FrozenSetKind,
+		`{"$bytes": []}`:             BytesKind,
+		`{"$path": "/"}`:             PathKind,
+		`{"$dataclass": {}}`:         DataclassKind,
+		`{"$exception": "E"}`:        ExceptionKind,
+		`{"$repr": "<f>"}`:           ReprKind,
+		``:                           "",
+		`{"a": 1}`:                   "",
+		`{"$tuple": [], "$set": []}`: "",
+	} {
+		if got := Object(in).Kind(); got != want {
+			t.Errorf("Kind(%s) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTuple(t *testing.T) {
+	data, err := marshalValue(map[string]any{"pair": Tuple{1, "a", Tuple{}}, "list": []int{1}})
+	if err != nil || string(data) != `{"list":[1],"pair":{"$tuple":[1,"a",{"$tuple":[]}]}}` {
+		t.Fatalf("marshalValue = %s, %v", data, err)
+	}
+
+	var tup Tuple
+	if err := Object(`{"$tuple": [1, {"$tuple": ["x"]}]}`).Unmarshal(&tup); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(tup) != 2 || tup[0].(Object).String() != "1" || tup[1].(Object).Kind() != TupleKind {
+		t.Fatalf("Unmarshal = %v", tup)
+	}
+	if err := Object(`{"$named_tuple": {"field_names": ["a"], "values": [7]}}`).Unmarshal(&tup); err != nil || len(tup) != 1 {
+		t.Fatalf("named tuple Unmarshal = %v, %v", tup, err)
+	}
+	if err := Object(`[1, 2]`).Unmarshal(&tup); err == nil {
+		t.Fatal("a list should not decode as a tuple")
+	}
+}
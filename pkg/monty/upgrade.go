@@ -0,0 +1,98 @@
+package monty
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MigrationError reports a paused run that cannot move onto a new version of
+// its program. Index is the trace event where the new version departs from
+// the run's history, or -1 when the trace itself rules migration out.
+type MigrationError struct {
+	Run    uint64
+	Index  int
+	Reason string
+	Err    error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("monty: cannot migrate run %d: %s", e.Run, e.Reason)
+}
+
+// Unwrap classifies failed migrations as ErrIncompatibleSnapshot.
+func (e *MigrationError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrIncompatibleSnapshot}
+	}
+	return []error{ErrIncompatibleSnapshot, e.Err}
+}
+
+// Upgrade compiles code, a new version of the traced program, and moves the
+// run paused at the end of tr onto it. A snapshot's interpreter state is tied
+// to the bytecode it was compiled from, so the run is rebuilt instead: the
+// new program starts with the recorded inputs and its calls are answered
+// with the recorded results, matched to the old calls by order, kind,
+// function name, and arguments. Call IDs that shifted are translated, but an
+// answer is never given to a call made with different arguments, for which
+// it may be wrong. extFuncs lists the new version's external functions; nil keeps
+// the recorded ones.
+//
+// On success Upgrade returns the new program and the progress where the old
+// run was paused; answer it as the old snapshot would have been answered and
+// close the old one. If the new version makes different calls along the
+// recorded history, or the run already finished, Upgrade returns a
+// *MigrationError and the run must finish on its original program. The same
+// holds for a call that is made with different arguments.
+func (tr TraceRun) Upgrade(code string, extFuncs []string, opts ...Option) (*Monty, Progress, error) {
+	if len(tr.Events) == 0 || tr.Events[0].Type != "start" {
+		return nil, Progress{}, &MigrationError{Run: tr.ID, Index: -1, Reason: "trace has no start event"}
+	}
+	if last := tr.Events[len(tr.Events)-1]; last.Type == "error" || last.Kind == Complete.String() {
+		return nil, Progress{}, &MigrationError{Run: tr.ID, Index: -1, Reason: "run already finished"}
+	}
+	start := tr.Events[0]
+	if extFuncs == nil {
+		extFuncs = start.Functions
+	}
+	m, err := New(code, start.Script, start.InputNames, extFuncs, opts...)
+	if err != nil {
+		return nil, Progress{}, err
+	}
+	p, err := tr.drive(m, sameCall)
+	if err != nil {
+		m.Close()
+		var div *TraceDivergence
+		if errors.As(err, &div) {
+			reason := fmt.Sprintf("new version reaches %s where the run reached %s", describeEvent(div.Got), describeEvent(div.Want))
+			if describeEvent(div.Got) == describeEvent(div.Want) {
+				reason = fmt.Sprintf("new version reaches %s with different arguments", describeEvent(div.Got))
+			}
+			return nil, Progress{}, &MigrationError{Run: tr.ID, Index: div.Index, Reason: reason, Err: err}
+		}
+		return nil, Progress{}, &MigrationError{Run: tr.ID, Index: -1, Reason: err.Error(), Err: err}
+	}
+	return m, p, nil
+}
+
+// sameCall matches events by what was asked and with which arguments,
+// ignoring call IDs.
+func sameCall(want, got TraceEvent) bool {
+	if want.Type != got.Type || want.Kind != got.Kind {
+		return false
+	}
+	if want.Type == "error" {
+		return want.Error == got.Error
+	}
+	return want.Function == got.Function && want.MethodCall == got.MethodCall &&
+		sameJSON(want.Args, got.Args) && sameJSON(want.Kwargs, got.Kwargs)
+}
+
+func describeEvent(e TraceEvent) string {
+	switch {
+	case e.Type == "error":
+		return fmt.Sprintf("error %q", e.Error)
+	case e.Function != "":
+		return e.Kind + " " + e.Function
+	}
+	return e.Kind
+}
@@ -0,0 +1,81 @@
+package monty
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// callBridge scripts a run that calls name(21) with call ID id and completes
+// with the value it is resumed with.
+func callBridge(name string, id uint32) *Sandbox {
+	return callArgsBridge(name, id, "[21]")
+}
+
+// callArgsBridge is callBridge with the JSON array args as the arguments.
+func callArgsBridge(name string, id uint32, args string) *Sandbox {
+	return scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(callProgress(id, name, args, 2)),
+		"resume": func(req sandboxRequest) sandboxResponse {
+			if req.CallID != id {
+				return sandboxResponse{Err: "RuntimeError: unknown call"}
+			}
+			return echoResume(req)
+		},
+	})
+}
+
+func TestUpgradePausedRun(t *testing.T) {
+	var buf bytes.Buffer
+	m, err := New("double(21)", "main.py", nil, []string{"double"}, WithSandbox(callBridge("double", 1)), WithTrace(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	old, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Snapshot.Close()
+	runs, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new version's call has a different ID.
+	upgraded, p, err := runs[0].Upgrade("x = 1\ndouble(21)", nil, WithSandbox(callBridge("double", 5)))
+	if err != nil {
+		t.Fatalf("Upgrade failed: %v", err)
+	}
+	defer upgraded.Close()
+	if p.Kind != FunctionCall || p.FunctionName != "double" || p.CallID != 5 {
+		t.Fatalf("expected the run paused at double, got %+v", p)
+	}
+	if done, err := p.Snapshot.Resume(p.CallID, 42); err != nil || string(done.Result) != "42" {
+		t.Fatalf("upgraded run did not finish: %s, %v", done.Result, err)
+	}
+
+	_, _, err = runs[0].Upgrade("triple(21)", []string{"triple"}, WithSandbox(callBridge("triple", 1)))
+	var me *MigrationError
+	if !errors.As(err, &me) || me.Index != 1 || !errors.Is(err, ErrIncompatibleSnapshot) {
+		t.Fatalf("expected a MigrationError at event 1, got %v", err)
+	}
+
+	_, _, err = runs[0].Upgrade("double(22)", nil, WithSandbox(callArgsBridge("double", 1, "[22]")))
+	if !errors.As(err, &me) || me.Index != 1 || !strings.Contains(me.Reason, "different arguments") {
+		t.Fatalf("expected a MigrationError for changed arguments, got %v", err)
+	}
+}
+
+func TestUpgradeFinishedRun(t *testing.T) {
+	tr := TraceRun{ID: 1, Events: []TraceEvent{
+		{Run: 1, Type: "start", Inputs: json.RawMessage("[]")},
+		{Run: 1, Type: "progress", Kind: "complete", Result: Object("1")},
+	}}
+	if _, _, err := tr.Upgrade("1", nil); !errors.Is(err, ErrIncompatibleSnapshot) {
+		t.Fatalf("a finished run should not migrate, got %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package monty
+
+// WrapperVersion is the version of this Go package.
+const WrapperVersion = "0.1.0"
+
+// ABIVersion is the libmonty_ffi ABI this package is written against. Creating
+// a handle fails when the linked library reports a different version.
+const ABIVersion = 3
+
+// VersionInfo describes the wrapper and the library it is linked against.
+type VersionInfo struct {
+	// Wrapper is WrapperVersion.
+	Wrapper string
+	// Library is the libmonty_ffi version, empty when the library is unavailable.
+	Library string
+	// ABI is the ABI version the library reports, zero when it is unavailable.
+	ABI uint32
+}
+
+// Version reports the wrapper, library, and ABI versions in use.
+func Version() VersionInfo {
+	info := VersionInfo{Wrapper: WrapperVersion}
+	info.Library, info.ABI, _ = native.version()
+	return info
+}
@@ -0,0 +1,108 @@
+package monty
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReadyFunction is the external function a program calls to mark the end of
+// its initialization for Monty.Warm. List it among the program's external
+// functions.
+const ReadyFunction = "ready"
+
+// WarmSnapshot is a program paused after its initialization, from which
+// Monty.StartFrom begins new runs. It is immutable and safe for concurrent
+// use.
+type WarmSnapshot struct {
+	data    []byte
+	callID  uint32
+	program string
+}
+
+// Size returns the size of the serialized state in bytes.
+func (w *WarmSnapshot) Size() int {
+	return len(w.data)
+}
+
+// Warm runs m up to its call to ready and captures the state there, so that
+// serving many runs of the same script pays for its imports and setup once.
+// The program takes its inputs from ready rather than as input names, as a
+// tuple in the order given to StartFrom:
+//
+//	import json
+//	schema = json.loads(SCHEMA)
+//	(payload,) = ready()
+//	validate(schema, payload)
+//
+// Virtualized OS calls made before ready are answered as usual; any other
+// event, or finishing without calling ready, is an error wrapping
+// ErrInvalidInput.
+func (m *Monty) Warm(ctx context.Context) (*WarmSnapshot, error) {
+	if m == nil {
+		return nil, newError(ErrClosed, "monty: nil handle")
+	}
+	p, err := m.start(ctx, m.cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer closeProgress(p)
+	if p.Kind != FunctionCall || p.MethodCall || p.FunctionName != ReadyFunction {
+		what := "finished"
+		switch p.Kind {
+		case FunctionCall, OsCall:
+			what = "paused at " + callInfo(p).Name
+		case ResolveFutures:
+			what = "paused at futures"
+		case Timer:
+			what = "paused at sleep"
+		}
+		return nil, newError(ErrInvalidInput, fmt.Sprintf("monty: program %s before calling %s", what, ReadyFunction))
+	}
+	data, err := p.Snapshot.Dump()
+	if err != nil {
+		return nil, err
+	}
+	return &WarmSnapshot{data: data, callID: p.CallID, program: m.program}, nil
+}
+
+// StartFrom begins a run of m from w, passing inputs to the script as the
+// result of its ready call. Each run gets its own copy of the state, and the
+// options of m apply to it as to Start.
+func (m *Monty) StartFrom(w *WarmSnapshot, inputs ...any) (Progress, error) {
+	return m.StartFromContext(context.Background(), w, inputs...)
+}
+
+// StartFromContext is StartFrom with the context that WithContextVars reads.
+func (m *Monty) StartFromContext(ctx context.Context, w *WarmSnapshot, inputs ...any) (Progress, error) {
+	if m == nil || m.handle == nil {
+		return Progress{}, newError(ErrClosed, "monty: nil handle")
+	}
+	if w == nil || w.program != m.program {
+		return Progress{}, newError(ErrInvalidInput, "monty: warm snapshot is not of this program")
+	}
+	if err := m.cfg.quota.admit(); err != nil {
+		return Progress{}, err
+	}
+	r := m.cfg.newRun()
+	r.eng = m.eng
+	r.program = m.program
+	if err := r.bindContext(ctx); err != nil {
+		return Progress{}, err
+	}
+	if m.src != nil {
+		r.script = m.src.scriptName
+	}
+	info, data, err := undump(SnapshotHandle, w.data)
+	if err != nil {
+		return Progress{}, err
+	}
+	r.clock = info.Clock
+	handle, err := m.eng.loadSnapshot(data)
+	if err != nil {
+		return Progress{}, loadError(err)
+	}
+	if inputs == nil {
+		inputs = []any{}
+	}
+	return newSnapshot(handle, r).Resume(w.callID, Tuple(inputs))
+}
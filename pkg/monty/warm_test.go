@@ -0,0 +1,83 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// readyBridge pauses at call to first, then completes with the value its
+// call was answered with, counting starts and snapshot loads.
+func readyBridge(first string, starts, loads *int) *Sandbox {
+	return scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start": func(sandboxRequest) sandboxResponse {
+			*starts++
+			return callProgress(7, first, "[]", 2)
+		},
+		"dump_snapshot": reply(sandboxResponse{Data: []byte("warm")}),
+		"load_snapshot": func(sandboxRequest) sandboxResponse {
+			*loads++
+			return sandboxResponse{Handle: 3}
+		},
+		"resume": func(req sandboxRequest) sandboxResponse {
+			if req.CallID != 7 {
+				return sandboxResponse{Err: "RuntimeError: unknown call"}
+			}
+			return echoResume(req)
+		},
+	})
+}
+
+func TestWarmStart(t *testing.T) {
+	var starts, loads int
+	m, err := New("(x, y) = ready()\nx + y", "main.py", nil, []string{ReadyFunction}, WithSandbox(readyBridge(ReadyFunction, &starts, &loads)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	w, err := m.Warm(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, state, _ := undump(SnapshotHandle, w.data); w.Size() != len(w.data) || string(state) != "warm" {
+		t.Errorf("Size = %d", w.Size())
+	}
+	for i := 0; i < 2; i++ {
+		p, err := m.StartFrom(w, 1, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Kind != Complete || string(p.Result) != `{"$tuple":[1,"a"]}` {
+			t.Errorf("result = %v %s, want the inputs as a tuple", p.Kind, p.Result)
+		}
+	}
+	if starts != 1 || loads != 2 {
+		t.Errorf("starts = %d, loads = %d; want 1 and 2", starts, loads)
+	}
+}
+
+func TestWarmRequiresReady(t *testing.T) {
+	var starts, loads int
+	m, err := New("fetch()", "main.py", nil, []string{"fetch"}, WithSandbox(readyBridge("fetch", &starts, &loads)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.Warm(context.Background()); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Warm err = %v, want ErrInvalidInput", err)
+	}
+
+	other, err := New("ready()", "other.py", nil, []string{ReadyFunction}, WithSandbox(readyBridge(ReadyFunction, &starts, &loads)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	w, err := other.Warm(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.StartFrom(w); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("StartFrom with another program's snapshot: err = %v", err)
+	}
+}
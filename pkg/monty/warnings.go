@@ -0,0 +1,110 @@
+package monty
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// WarnFunction is the external function through which scripts issue
+// warnings under WithWarnings. List it among the program's external
+// functions.
+const WarnFunction = "warn"
+
+// Warning is a non-fatal issue reported by a script.
+type Warning struct {
+	// Category is the warning class, "UserWarning" unless the script names
+	// another, e.g. "DeprecationWarning".
+	Category string
+	Message  string
+}
+
+// WithWarnings lets scripts report non-fatal issues that platforms can show
+// their authors without failing the run. Scripts call warn as they would
+// warnings.warn:
+//
+//	warn("limit is deprecated, use max_items")
+//	warn("slow path", "RuntimeWarning")
+//	warn("old API", category=DeprecationWarning)
+//
+// The category may be given as a name or as the class itself. Each warning
+// is passed to handler, if it is not nil, as it is issued, and listed in
+// Progress.Warnings of the next progress event, or of the result under
+// WithExceptionResults. The calls are answered by the package and never
+// reach the host.
+func WithWarnings(handler func(Warning)) Option {
+	return func(c *config) {
+		c.warnings = true
+		c.onWarning = handler
+	}
+}
+
+var classRepr = regexp.MustCompile(`^<class '([A-Za-z_][A-Za-z0-9_.]*)'>$`)
+
+// warnCall answers warn, reporting false for other calls.
+func (r *run) warnCall(p Progress) (osReply, bool) {
+	if !r.cfg.warnings || p.Kind != FunctionCall || p.MethodCall || p.FunctionName != WarnFunction {
+		return osReply{}, false
+	}
+	var message, category Object
+	if len(p.Args) > 0 {
+		message = p.Args[0]
+	}
+	if len(p.Args) > 1 {
+		category = p.Args[1]
+	}
+	for _, kv := range p.Kwargs {
+		var key string
+		kv.Key.Unmarshal(&key)
+		switch key {
+		case "message":
+			message = kv.Value
+		case "category":
+			category = kv.Value
+		}
+	}
+	if len(message) == 0 {
+		return osReply{errMsg: "TypeError: warn() missing required argument 'message'"}, true
+	}
+	w := Warning{Category: "UserWarning"}
+	if exc, ok := message.Exception(); ok {
+		w.Category, w.Message = exc.Type, exc.Message
+	} else if message.Unmarshal(&w.Message) != nil {
+		w.Message = message.String()
+	}
+	if len(category) > 0 && category.Kind() != NoneKind {
+		name, ok := warningCategory(category)
+		if !ok {
+			return osReply{errMsg: fmt.Sprintf("TypeError: category must be a Warning subclass, not %s", category)}, true
+		}
+		w.Category = name
+	}
+	r.warnings = append(r.warnings, w)
+	if r.cfg.onWarning != nil {
+		r.cfg.onWarning(w)
+	}
+	return osReply{value: none{}}, true
+}
+
+// warningCategory reads a category given by name or as a class.
+func warningCategory(o Object) (string, bool) {
+	var name string
+	if o.Unmarshal(&name) == nil {
+		return name, name != ""
+	}
+	var repr struct {
+		Repr string `json:"$repr"`
+	}
+	if o.Unmarshal(&repr) == nil {
+		if m := classRepr.FindStringSubmatch(repr.Repr); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// takeWarnings returns the warnings issued since the last progress event.
+func (r *run) takeWarnings() []Warning {
+	w := r.warnings
+	r.warnings = nil
+	return w
+}
@@ -0,0 +1,66 @@
+package monty
+
+import "testing"
+
+func TestWithWarnings(t *testing.T) {
+	var seen []Warning
+	m, err := New("warn(21)", "main.py", nil, []string{WarnFunction}, WithSandbox(callBridge(WarnFunction, 1)),
+		WithWarnings(func(w Warning) { seen = append(seen, w) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	p, err := m.Start()
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	want := Warning{Category: "UserWarning", Message: "21"}
+	if p.Kind != Complete || len(p.Warnings) != 1 || p.Warnings[0] != want || len(seen) != 1 || seen[0] != want {
+		t.Fatalf("Start = %+v, handler saw %v", p, seen)
+	}
+
+	without, err := New("warn(21)", "main.py", nil, []string{WarnFunction}, WithSandbox(callBridge(WarnFunction, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer without.Close()
+	p, err = without.Start()
+	if err != nil || p.Kind != FunctionCall {
+		t.Fatalf("without WithWarnings warn should reach the host, got %+v, %v", p, err)
+	}
+	p.Snapshot.Close()
+}
+
+func TestWarnCall(t *testing.T) {
+	r := newConfig([]Option{WithWarnings(nil)}).newRun()
+	call := func(args []Object, kwargs ...KV) osReply {
+		t.Helper()
+		reply, ok := r.warnCall(Progress{Kind: FunctionCall, FunctionName: WarnFunction, Args: args, Kwargs: kwargs})
+		if !ok {
+			t.Fatal("warn was not answered")
+		}
+		return reply
+	}
+	call([]Object{Object(`"old"`), Object(`"DeprecationWarning"`)})
+	call(nil, KV{Object(`"message"`), Object(`"slow"`)}, KV{Object(`"category"`), Object(`{"$repr": "<class 'RuntimeWarning'>"}`)})
+	call([]Object{Object(`{"$exception": {"type": "FutureWarning", "message": "soon"}}`)}, KV{Object(`"stacklevel"`), Object(`2`)})
+	want := []Warning{{"DeprecationWarning", "old"}, {"RuntimeWarning", "slow"}, {"FutureWarning", "soon"}}
+	got := r.takeWarnings()
+	if len(got) != len(want) {
+		t.Fatalf("warnings = %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("warning %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if reply := call(nil); reply.errMsg == "" {
+		t.Fatal("warn without a message should raise")
+	}
+	if reply := call([]Object{Object(`"x"`), Object(`3`)}); reply.errMsg == "" {
+		t.Fatal("a numeric category should raise")
+	}
+	if len(r.takeWarnings()) != 0 {
+		t.Fatal("rejected calls should not record warnings")
+	}
+}
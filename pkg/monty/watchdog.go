@@ -0,0 +1,162 @@
+package monty
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithWatchdog bounds the wall time the interpreter may spend in a single
+// step, from Start or a resume to the next event. When a step overruns d,
+// the watchdog interrupts it and the step fails with a *WatchdogError.
+//
+// A sandboxed run is interrupted by killing the sandbox process. A bridged
+// sandbox cannot be interrupted: the step keeps running until the bridge
+// returns, its result is then discarded, and until then the program it
+// started from stays in use, so closing it fails with ErrConcurrentUse.
+// Native code running in process cannot be interrupted at all, so programs
+// and snapshots configured with WithWatchdog and without WithSandbox fail
+// with ErrInvalidInput. The watchdog is a backstop for scripts that never
+// reach an event, not a replacement for a run's context or the
+// interpreter's own limits. Zero disables it.
+func WithWatchdog(d time.Duration) Option {
+	return func(c *config) {
+		c.watchdog = d
+	}
+}
+
+// WatchdogError reports a step interrupted by WithWatchdog. Location is the
+// last event the script passed before it stalled.
+type WatchdogError struct {
+	Script   string
+	Op       string
+	Location string
+	Limit    time.Duration
+}
+
+func (e *WatchdogError) Error() string {
+	return fmt.Sprintf("monty: watchdog interrupted %s after %v in %s following %s", e.Script, e.Limit, e.Op, e.Location)
+}
+
+// Unwrap classifies watchdog interruptions as ErrTimeout.
+func (e *WatchdogError) Unwrap() error {
+	return ErrTimeout
+}
+
+// interrupter is implemented by engines that can abort a step in flight.
+type interrupter interface {
+	interrupt()
+}
+
+// checkWatchdog rejects WithWatchdog for engines that cannot interrupt a
+// step, whose stalled steps would keep using their handles forever.
+func (c *config) checkWatchdog() error {
+	if _, ok := c.eng.(interrupter); c.watchdog > 0 && !ok {
+		return newError(ErrInvalidInput, "monty: WithWatchdog needs an engine that can interrupt a step, such as a sandbox")
+	}
+	return nil
+}
+
+// watch runs one interpreter step in its queue slot under the watchdog,
+// charges it to the run's quota, and records where the script stopped, for
+// reporting the next overrun. pin, if set, guards the handle the step reads;
+// it and the queue slot stay in use until a step abandoned by the watchdog
+// returns.
+func (r *run) watch(op string, pin *useGuard, step func() (rawProgress, error)) (rawProgress, error) {
+	q := r.cfg.queue
+	if q != nil {
+		if err := q.Acquire(r.ctx, r.cfg.priority); err != nil {
+			return rawProgress{}, err
+		}
+		defer func() {
+			if q != nil {
+				q.Release()
+			}
+		}()
+	}
+	began := time.Now()
+	var raw rawProgress
+	var err error
+	var orphan <-chan struct{}
+	r.cfg.labeled(r.script, r.program, op, func() { raw, orphan, err = r.guard(op, step) })
+	if orphan != nil {
+		if pin != nil {
+			// The caller holds pin shared for the step; keep a share of it.
+			pin.acquire(MontyHandle, "watchdog", true)
+		}
+		// The abandoned step keeps its slot until it returns.
+		slot := q
+		q = nil
+		go func() {
+			<-orphan
+			if pin != nil {
+				pin.release(true)
+			}
+			if slot != nil {
+				slot.Release()
+			}
+		}()
+	}
+	used := Usage{CPU: time.Since(began)}
+	if err == nil && (raw.kind == FunctionCall || raw.kind == OsCall) {
+		used.Calls = 1
+	}
+	r.cfg.quota.charge(used)
+	if err == nil {
+		switch raw.kind {
+		case FunctionCall:
+			r.location = fmt.Sprintf("call %d to %s", raw.callID, raw.functionName)
+		case OsCall:
+			r.location = fmt.Sprintf("call %d to %s", raw.callID, raw.osFunction)
+		case Timer:
+			r.location = fmt.Sprintf("sleep call %d", raw.callID)
+		case ResolveFutures:
+			r.location = "await of pending futures"
+		}
+	}
+	return raw, err
+}
+
+// guard runs step under the watchdog. When the step overruns, it is
+// interrupted and abandoned, and the returned channel is closed once it
+// has returned.
+func (r *run) guard(op string, step func() (rawProgress, error)) (rawProgress, <-chan struct{}, error) {
+	limit := r.cfg.watchdog
+	if limit <= 0 {
+		raw, err := step()
+		return raw, nil, err
+	}
+	type outcome struct {
+		raw rawProgress
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		raw, err := step()
+		done <- outcome{raw, err}
+	}()
+	timer := time.NewTimer(limit)
+	defer timer.Stop()
+	select {
+	case o := <-done:
+		return o.raw, nil, o.err
+	case <-timer.C:
+	}
+	if in, ok := r.eng.(interrupter); ok {
+		in.interrupt()
+	}
+	orphan := make(chan struct{})
+	go func() {
+		o := <-done
+		o.raw.release(r.eng)
+		close(orphan)
+	}()
+	script := r.script
+	if script == "" {
+		script = "script"
+	}
+	location := r.location
+	if location == "" {
+		location = "start"
+	}
+	return rawProgress{}, orphan, &WatchdogError{Script: script, Op: op, Location: location, Limit: limit}
+}
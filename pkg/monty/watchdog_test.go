@@ -0,0 +1,116 @@
+package monty
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWatchdogInterruptsStalledStep(t *testing.T) {
+	// The bridge scripts a run that calls double(21) and then stalls until
+	// the test releases it.
+	stall := make(chan struct{})
+	sb := scriptedBridge(bridgeScript{
+		"compile": reply(sandboxResponse{Handle: 1}),
+		"start":   reply(callProgress(1, "double", "[21]", 2)),
+		"resume": func(req sandboxRequest) sandboxResponse {
+			<-stall
+			return echoResume(req)
+		},
+	})
+	m, err := New("while double(21): pass", "main.py", nil, []string{"double"},
+		WithSandbox(sb), WithWatchdog(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	defer close(stall)
+
+	p, err := m.Start()
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	_, err = p.Snapshot.Resume(p.CallID, 42)
+	var we *WatchdogError
+	if !errors.As(err, &we) || !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected a WatchdogError, got %v", err)
+	}
+	if we.Script != "main.py" || we.Op != "resume" || we.Location != "call 1 to double" {
+		t.Fatalf("unexpected report %+v", we)
+	}
+}
+
+func TestWatchdogPinsProgramUntilStepReturns(t *testing.T) {
+	stall, returned := make(chan struct{}), make(chan struct{})
+	sb := scriptedBridge(bridgeScript{
+		"start": func(sandboxRequest) sandboxResponse {
+			<-stall
+			defer close(returned)
+			return sandboxResponse{Handle: 1}
+		},
+		anyOp: reply(sandboxResponse{Handle: 1}),
+	})
+	m, err := New("while True: pass", "main.py", nil, nil, WithSandbox(sb), WithWatchdog(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Start(); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Start = %v, want a watchdog timeout", err)
+	}
+	if err := m.Close(); !errors.Is(err, ErrConcurrentUse) {
+		t.Fatalf("Close while the stalled step runs = %v, want ErrConcurrentUse", err)
+	}
+	close(stall)
+	<-returned
+	deadline := time.Now().Add(time.Second)
+	for m.Close() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("program still in use after the stalled step returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchdogHoldsQueueSlotUntilStepReturns(t *testing.T) {
+	stall, returned := make(chan struct{}), make(chan struct{})
+	sb := scriptedBridge(bridgeScript{
+		"start": func(sandboxRequest) sandboxResponse {
+			<-stall
+			defer close(returned)
+			return sandboxResponse{Handle: 1}
+		},
+		anyOp: reply(sandboxResponse{Handle: 1}),
+	})
+	q := NewQueue(1)
+	m, err := New("while True: pass", "main.py", nil, nil, WithSandbox(sb), WithWatchdog(20*time.Millisecond), WithQueue(q, PriorityNormal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Start(); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Start = %v, want a watchdog timeout", err)
+	}
+	if running := q.Stats().Running; running != 1 {
+		t.Fatalf("the stalled step should keep its slot, %d running", running)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := m.StartContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waiting for the slot past the deadline = %v", err)
+	}
+	close(stall)
+	<-returned
+	deadline := time.Now().Add(time.Second)
+	for q.Stats().Running != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("slot still held after the stalled step returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchdogNeedsInterruptibleEngine(t *testing.T) {
+	if _, err := New("1", "main.py", nil, nil, WithWatchdog(time.Second)); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("New in process with a watchdog = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,293 @@
+// Package montyflow packages program execution as durable-workflow
+// activities, in the style of Temporal. Start and Resume are deterministic
+// given their inputs and return a State that is plain, serializable workflow
+// data: the paused run as snapshot bytes plus the calls it is waiting on.
+// A workflow schedules one activity per Call, then feeds the Completions back
+// into Resume until the State is Done. Each Call is handed out once: an async
+// run resumed with only some of its completions lists just its new calls, and
+// takes the completions of the earlier ones in a later Resume.
+//
+//	state, err := montyflow.Start(ctx, input)
+//	for !state.Done {
+//		if state.WakeAfter > 0 {
+//			sleep(state.WakeAfter) // a durable workflow timer
+//		}
+//		completions := runActivities(state.Calls)
+//		state, err = montyflow.Resume(ctx, montyflow.ResumeInput{State: state, Completions: completions})
+//	}
+package montyflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+// StartInput compiles and starts a program.
+type StartInput struct {
+	Code              string            `json:"code"`
+	ScriptName        string            `json:"script_name"`
+	InputNames        []string          `json:"input_names,omitempty"`
+	ExternalFunctions []string          `json:"external_functions,omitempty"`
+	Inputs            []json.RawMessage `json:"inputs,omitempty"`
+	// Async answers every external call with a future, so a script that
+	// awaits several calls exposes all of them at once for parallel activities.
+	Async bool `json:"async,omitempty"`
+}
+
+// Call is an external or OS call the run is waiting on.
+type Call struct {
+	CallID   uint32                     `json:"call_id"`
+	Function string                     `json:"function"`
+	OS       bool                       `json:"os,omitempty"`
+	Args     []json.RawMessage          `json:"args,omitempty"`
+	Kwargs   map[string]json.RawMessage `json:"kwargs,omitempty"`
+}
+
+// Completion is the outcome of the activity that handled a Call.
+type Completion struct {
+	CallID uint32          `json:"call_id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// State is a run between activities.
+type State struct {
+	Done   bool            `json:"done,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// Snapshot is the paused run; Future marks a FutureSnapshot.
+	Snapshot []byte `json:"snapshot,omitempty"`
+	Future   bool   `json:"future,omitempty"`
+	// Calls are the calls to dispatch before resuming, each listed in only
+	// one State.
+	Calls []Call `json:"calls,omitempty"`
+	// WakeAfter is set when the script sleeps; resume with no completions
+	// once it has elapsed.
+	WakeAfter time.Duration `json:"wake_after,omitempty"`
+
+	// Async is carried over from StartInput.
+	Async bool `json:"async,omitempty"`
+	// Deferred holds async calls answered with a future that are not yet resolved.
+	Deferred []Call `json:"deferred,omitempty"`
+	// Dispatched lists the deferred calls already returned in Calls, so
+	// later states do not hand them out again.
+	Dispatched []uint32 `json:"dispatched,omitempty"`
+	// TimerCallID is the call a WakeAfter pause resumes.
+	TimerCallID uint32 `json:"timer_call_id,omitempty"`
+}
+
+// ResumeInput continues a paused run.
+type ResumeInput struct {
+	State       State        `json:"state"`
+	Completions []Completion `json:"completions,omitempty"`
+}
+
+// Start compiles the program and runs it until it completes or waits on calls.
+// opts configure the program, e.g. virtualized OS calls and limits. Start
+// gives up with ctx's error once ctx is done.
+func Start(ctx context.Context, in StartInput, opts ...monty.Option) (State, error) {
+	if err := ctx.Err(); err != nil {
+		return State{}, err
+	}
+	m, err := monty.New(in.Code, in.ScriptName, in.InputNames, in.ExternalFunctions, opts...)
+	if err != nil {
+		return State{}, err
+	}
+	defer m.Close()
+	inputs := make([]any, len(in.Inputs))
+	for i, v := range in.Inputs {
+		inputs[i] = v
+	}
+	progress, err := m.StartContext(ctx, inputs...)
+	if err != nil {
+		return State{}, err
+	}
+	return advance(ctx, progress, State{Async: in.Async})
+}
+
+// Resume applies completions to a paused run and runs it to the next wait.
+// opts must match the ones given to Start. Each completion must answer one of
+// the calls the state handed out, at most once; a state paused on a single
+// call takes exactly one. Resume gives up with ctx's error once ctx is done.
+func Resume(ctx context.Context, in ResumeInput, opts ...monty.Option) (State, error) {
+	st := in.State
+	if st.Done {
+		return State{}, errors.New("montyflow: run already completed")
+	}
+	if len(st.Snapshot) == 0 {
+		return State{}, errors.New("montyflow: state has no snapshot")
+	}
+	if err := ctx.Err(); err != nil {
+		return State{}, err
+	}
+	next := State{Async: st.Async}
+
+	if st.Future {
+		waiting := make(map[uint32]bool, len(st.Calls)+len(st.Dispatched))
+		for _, c := range st.Calls {
+			waiting[c.CallID] = true
+		}
+		for _, id := range st.Dispatched {
+			waiting[id] = true
+		}
+		resolved := make(map[uint32]bool, len(in.Completions))
+		results := make([]monty.FutureResult, len(in.Completions))
+		for i, c := range in.Completions {
+			if !waiting[c.CallID] || resolved[c.CallID] {
+				return State{}, fmt.Errorf("montyflow: completion for call %d, which the run is not waiting on", c.CallID)
+			}
+			resolved[c.CallID] = true
+			results[i] = monty.FutureResult{CallID: c.CallID, Err: c.Error, Result: resultValue(c.Result)}
+		}
+		for _, d := range st.Deferred {
+			if !resolved[d.CallID] {
+				next.Deferred = append(next.Deferred, d)
+			}
+		}
+		for _, id := range st.Dispatched {
+			if !resolved[id] {
+				next.Dispatched = append(next.Dispatched, id)
+			}
+		}
+		fut, err := monty.FutureSnapshotFromBytes(st.Snapshot, opts...)
+		if err != nil {
+			return State{}, err
+		}
+		progress, err := fut.Resume(results)
+		if err != nil {
+			return State{}, err
+		}
+		return advance(ctx, progress, next)
+	}
+
+	if st.WakeAfter <= 0 {
+		if len(in.Completions) != 1 {
+			return State{}, fmt.Errorf("montyflow: expected 1 completion, got %d", len(in.Completions))
+		}
+		if id := in.Completions[0].CallID; len(st.Calls) != 1 || st.Calls[0].CallID != id {
+			return State{}, fmt.Errorf("montyflow: completion for call %d, which the run is not waiting on", id)
+		}
+	}
+	snap, err := monty.SnapshotFromBytes(st.Snapshot, opts...)
+	if err != nil {
+		return State{}, err
+	}
+	next.Deferred, next.Dispatched = st.Deferred, st.Dispatched
+	var progress monty.Progress
+	switch {
+	case st.WakeAfter > 0:
+		progress, err = snap.Wake(st.TimerCallID)
+	case in.Completions[0].Error != "":
+		progress, err = snap.ResumeError(in.Completions[0].CallID, in.Completions[0].Error)
+	default:
+		progress, err = snap.Resume(in.Completions[0].CallID, resultValue(in.Completions[0].Result))
+	}
+	if err != nil {
+		return State{}, err
+	}
+	return advance(ctx, progress, next)
+}
+
+// advance runs past async calls and converts the next wait into a State.
+func advance(ctx context.Context, p monty.Progress, st State) (State, error) {
+	for {
+		if err := ctx.Err(); err != nil && p.Kind != monty.Complete {
+			closePaused(p)
+			return State{}, err
+		}
+		switch p.Kind {
+		case monty.Complete:
+			return State{Done: true, Result: json.RawMessage(p.Result)}, nil
+		case monty.FunctionCall, monty.OsCall:
+			call, err := toCall(p)
+			if err != nil {
+				p.Snapshot.Close()
+				return State{}, err
+			}
+			if st.Async && p.Kind == monty.FunctionCall {
+				st.Deferred = append(st.Deferred, call)
+				if p, _, err = p.Snapshot.ResumeFuture(p.CallID); err != nil {
+					return State{}, err
+				}
+				continue
+			}
+			data, err := dumpAndClose(p.Snapshot)
+			st.Snapshot, st.Calls = data, []Call{call}
+			return st, err
+		case monty.ResolveFutures:
+			pending := make(map[uint32]bool, len(p.PendingIDs))
+			for _, id := range p.PendingIDs {
+				pending[id] = true
+			}
+			dispatched := make(map[uint32]bool, len(st.Dispatched))
+			for _, id := range st.Dispatched {
+				dispatched[id] = true
+			}
+			for _, d := range st.Deferred {
+				if pending[d.CallID] && !dispatched[d.CallID] {
+					st.Calls = append(st.Calls, d)
+					st.Dispatched = append(st.Dispatched, d.CallID)
+				}
+			}
+			data, err := p.FutureSnapshot.Dump()
+			p.FutureSnapshot.Close()
+			st.Snapshot, st.Future = data, true
+			return st, err
+		case monty.Timer:
+			data, err := dumpAndClose(p.Snapshot)
+			st.Snapshot, st.WakeAfter, st.TimerCallID = data, p.Duration, p.CallID
+			return st, err
+		default:
+			return State{}, fmt.Errorf("montyflow: unexpected progress kind %v", p.Kind)
+		}
+	}
+}
+
+// closePaused frees the snapshot a run paused at.
+func closePaused(p monty.Progress) {
+	if p.Snapshot != nil {
+		p.Snapshot.Close()
+	}
+	if p.FutureSnapshot != nil {
+		p.FutureSnapshot.Close()
+	}
+}
+
+func dumpAndClose(s *monty.Snapshot) ([]byte, error) {
+	defer s.Close()
+	return s.Dump()
+}
+
+func toCall(p monty.Progress) (Call, error) {
+	call := Call{CallID: p.CallID, Function: p.FunctionName, OS: p.Kind == monty.OsCall}
+	if call.OS {
+		call.Function = p.OsFunction
+	}
+	for _, a := range p.Args {
+		call.Args = append(call.Args, json.RawMessage(a))
+	}
+	if len(p.Kwargs) > 0 {
+		call.Kwargs = make(map[string]json.RawMessage, len(p.Kwargs))
+		for _, kv := range p.Kwargs {
+			var key string
+			if err := kv.Key.Unmarshal(&key); err != nil {
+				return Call{}, fmt.Errorf("montyflow: non-string keyword argument name %s", kv.Key)
+			}
+			call.Kwargs[key] = json.RawMessage(kv.Value)
+		}
+	}
+	return call, nil
+}
+
+// resultValue maps an absent result to None.
+func resultValue(r json.RawMessage) any {
+	if len(r) == 0 {
+		return json.RawMessage("null")
+	}
+	return r
+}
@@ -0,0 +1,135 @@
+package montyflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+func TestResumeRejectsFinishedState(t *testing.T) {
+	ctx := context.Background()
+	if _, err := Resume(ctx, ResumeInput{State: State{Done: true}}); err == nil {
+		t.Fatalf("expected error resuming a completed run")
+	}
+	if _, err := Resume(ctx, ResumeInput{State: State{}}); err == nil {
+		t.Fatalf("expected error resuming a state without a snapshot")
+	}
+}
+
+func TestToCallNamesKwargs(t *testing.T) {
+	call, err := toCall(monty.Progress{
+		Kind:         monty.FunctionCall,
+		CallID:       4,
+		FunctionName: "fetch",
+		Args:         []monty.Object{monty.Object(`"a"`)},
+		Kwargs:       []monty.KV{{Key: monty.Object(`"timeout"`), Value: monty.Object(`5`)}},
+	})
+	if err != nil {
+		t.Fatalf("toCall failed: %v", err)
+	}
+	data, _ := json.Marshal(call)
+	if string(data) != `{"call_id":4,"function":"fetch","args":["a"],"kwargs":{"timeout":5}}` {
+		t.Fatalf("unexpected call encoding: %s", data)
+	}
+}
+
+// futuresBridge is a sandbox bridge whose programs call a() and b() and then
+// await both, completing once every future is resolved.
+func futuresBridge() *monty.Sandbox {
+	pending := map[uint32]bool{}
+	return monty.NewSandboxBridge(func(data []byte) ([]byte, error) {
+		var req struct {
+			Op      string          `json:"op"`
+			CallID  uint32          `json:"call_id"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		json.Unmarshal(data, &req)
+		switch req.Op {
+		case "compile", "load_snapshot", "load_future_snapshot":
+			return []byte(`{"handle":1}`), nil
+		case "start":
+			return []byte(`{"progress":{"kind":1,"call_id":1,"function_name":"a","args":[],"kwargs":[],"snapshot":2}}`), nil
+		case "resume":
+			if req.CallID == 1 && len(req.Payload) == 0 {
+				return []byte(`{"progress":{"kind":1,"call_id":2,"function_name":"b","args":[],"kwargs":[],"snapshot":3}}`), nil
+			}
+			if req.CallID == 2 && len(req.Payload) == 0 {
+				pending[1], pending[2] = true, true
+				return []byte(`{"progress":{"kind":3,"pending_call_ids":[1,2],"future_snapshot":4}}`), nil
+			}
+			return json.Marshal(map[string]any{"progress": map[string]any{"kind": 0, "result": req.Payload}})
+		case "resume_futures":
+			var results []struct {
+				CallID uint32 `json:"call_id"`
+			}
+			json.Unmarshal(req.Payload, &results)
+			for _, r := range results {
+				delete(pending, r.CallID)
+			}
+			if len(pending) == 0 {
+				return []byte(`{"progress":{"kind":0,"result":"done"}}`), nil
+			}
+			ids := []uint32{}
+			for id := range pending {
+				ids = append(ids, id)
+			}
+			return json.Marshal(map[string]any{"progress": map[string]any{"kind": 3, "pending_call_ids": ids, "future_snapshot": 5}})
+		case "dump_snapshot", "dump_future_snapshot":
+			return []byte(`{"data":"c3RhdGU="}`), nil
+		}
+		return []byte(`{}`), nil
+	})
+}
+
+func TestResumeHandsOutEachCallOnce(t *testing.T) {
+	ctx := context.Background()
+	sb := futuresBridge()
+	in := StartInput{Code: "a(); b()", ScriptName: "main.py", ExternalFunctions: []string{"a", "b"}, Async: true}
+	state, err := Start(ctx, in, monty.WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Calls) != 2 || !state.Future {
+		t.Fatalf("expected both calls, got %+v", state)
+	}
+
+	state, err = Resume(ctx, ResumeInput{State: state, Completions: []Completion{{CallID: 1, Result: json.RawMessage("1")}}}, monty.WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Done || len(state.Calls) != 0 {
+		t.Fatalf("call 2 was handed out again: %+v", state.Calls)
+	}
+	if _, err := Resume(ctx, ResumeInput{State: state, Completions: []Completion{{CallID: 1}}}, monty.WithSandbox(sb)); err == nil {
+		t.Fatal("expected a second completion for call 1 to be rejected")
+	}
+	state, err = Resume(ctx, ResumeInput{State: state, Completions: []Completion{{CallID: 2, Result: json.RawMessage("2")}}}, monty.WithSandbox(sb))
+	if err != nil || !state.Done {
+		t.Fatalf("expected the run to complete, got %+v, %v", state, err)
+	}
+}
+
+func TestResumeValidatesCompletions(t *testing.T) {
+	ctx := context.Background()
+	sb := futuresBridge()
+	in := StartInput{Code: "a()", ScriptName: "main.py", ExternalFunctions: []string{"a", "b"}}
+	state, err := Start(ctx, in, monty.WithSandbox(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resume(ctx, ResumeInput{State: state, Completions: []Completion{{CallID: 9}}}, monty.WithSandbox(sb)); err == nil {
+		t.Fatal("expected a completion for an unknown call to be rejected")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Resume(cancelled, ResumeInput{State: state, Completions: []Completion{{CallID: 1}}}, monty.WithSandbox(sb)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Resume with a cancelled context = %v", err)
+	}
+	if _, err := Start(cancelled, in, monty.WithSandbox(sb)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Start with a cancelled context = %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+// Package montymcp serves monty as a Model Context Protocol tool server. It
+// offers one tool, execute_python, whose scripts may call the host tools
+// registered with AddTool as ordinary Python functions; each such call is
+// dispatched to the tool's handler while the script is paused.
+package montymcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+// ProtocolVersion is the MCP revision this server implements.
+const ProtocolVersion = "2024-11-05"
+
+// ExecuteTool is the name of the code-execution tool.
+const ExecuteTool = "execute_python"
+
+// Tool is a host function scripts can call by name.
+type Tool struct {
+	Name        string
+	Description string
+	// Params names positional arguments in order; keyword arguments are
+	// passed through under their own names.
+	Params  []string
+	Handler func(ctx context.Context, args map[string]json.RawMessage) (any, error)
+}
+
+// Server answers MCP requests.
+type Server struct {
+	name string
+	opts []monty.Option
+
+	mu    sync.Mutex
+	tools map[string]Tool
+}
+
+// New returns a server that introduces itself as name and runs scripts with opts.
+func New(name string, opts ...monty.Option) *Server {
+	return &Server{name: name, opts: opts, tools: make(map[string]Tool)}
+}
+
+// AddTool makes t callable from scripts.
+func (s *Server) AddTool(t Tool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tools[t.Name] = t
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes.
+const (
+	codeParse          = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Serve reads newline-delimited JSON-RPC messages from r and writes responses
+// to w until r is exhausted, as in the MCP stdio transport.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	in := bufio.NewScanner(r)
+	in.Buffer(make([]byte, 64*1024), 64<<20)
+	enc := json.NewEncoder(w)
+	for in.Scan() {
+		line := in.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		if resp := s.Handle(ctx, line); resp != nil {
+			if err := enc.Encode(json.RawMessage(resp)); err != nil {
+				return err
+			}
+		}
+	}
+	return in.Err()
+}
+
+// Handle answers one JSON-RPC message, returning nil for notifications.
+func (s *Server) Handle(ctx context.Context, msg []byte) []byte {
+	var req rpcRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParse, Message: err.Error()}})
+	}
+	if len(req.ID) == 0 {
+		return nil
+	}
+	resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	switch req.Method {
+	case "initialize":
+		resp.Result = map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": s.name, "version": monty.WrapperVersion},
+		}
+	case "ping":
+		resp.Result = map[string]any{}
+	case "tools/list":
+		resp.Result = map[string]any{"tools": []any{s.executeToolSpec()}}
+	case "tools/call":
+		var params struct {
+			Name      string `json:"name"`
+			Arguments struct {
+				Code string `json:"code"`
+			} `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Name != ExecuteTool {
+			resp.Error = &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool %q", params.Name)}
+			break
+		}
+		resp.Result = s.execute(ctx, params.Arguments.Code)
+	default:
+		resp.Error = &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	return encode(resp)
+}
+
+func (s *Server) executeToolSpec() map[string]any {
+	var b strings.Builder
+	b.WriteString("Run a Python script in a sandbox and return the value of its final expression.")
+	tools := s.sortedTools()
+	if len(tools) > 0 {
+		b.WriteString(" The script may call these functions:")
+		for _, t := range tools {
+			fmt.Fprintf(&b, "\n- %s(%s): %s", t.Name, strings.Join(t.Params, ", "), t.Description)
+		}
+	}
+	return map[string]any{
+		"name":        ExecuteTool,
+		"description": b.String(),
+		"inputSchema": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"code": map[string]any{"type": "string", "description": "Python source"}},
+			"required":   []string{"code"},
+		},
+	}
+}
+
+// execute runs code and reports the result, or the failure, as tool content.
+func (s *Server) execute(ctx context.Context, code string) map[string]any {
+	tools := s.sortedTools()
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.Name
+	}
+	result, err := func() (monty.Object, error) {
+		m, err := monty.New(code, "tool.py", nil, names, s.opts...)
+		if err != nil {
+			return nil, err
+		}
+		defer m.Close()
+		r := monty.NewRunner(m)
+		for _, t := range tools {
+			r.Register(t.Name, toolHandler(t))
+		}
+		return r.Run(ctx)
+	}()
+	if err != nil {
+		return map[string]any{"content": []any{textContent(err.Error())}, "isError": true}
+	}
+	text := string(result)
+	if text == "" {
+		text = "null"
+	}
+	return map[string]any{"content": []any{textContent(text)}}
+}
+
+func toolHandler(t Tool) monty.Handler {
+	return func(ctx context.Context, call monty.CallInfo) (any, error) {
+		if len(call.Args) > len(t.Params) {
+			return nil, fmt.Errorf("%s() takes %d positional arguments but %d were given", t.Name, len(t.Params), len(call.Args))
+		}
+		args := make(map[string]json.RawMessage, len(call.Args)+len(call.Kwargs))
+		for i, a := range call.Args {
+			args[t.Params[i]] = json.RawMessage(a)
+		}
+		for _, kv := range call.Kwargs {
+			var key string
+			if err := kv.Key.Unmarshal(&key); err != nil {
+				return nil, errors.New("keyword argument names must be strings")
+			}
+			args[key] = json.RawMessage(kv.Value)
+		}
+		return t.Handler(ctx, args)
+	}
+}
+
+func (s *Server) sortedTools() []Tool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tools := make([]Tool, 0, len(s.tools))
+	for _, t := range s.tools {
+		tools = append(tools, t)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+func textContent(text string) map[string]any {
+	return map[string]any{"type": "text", "text": text}
+}
+
+func encode(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
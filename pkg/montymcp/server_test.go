@@ -0,0 +1,75 @@
+package montymcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+func TestServeListsExecuteTool(t *testing.T) {
+	srv := New("test")
+	srv.AddTool(Tool{Name: "search", Description: "Search the web.", Params: []string{"query"}})
+
+	in := strings.NewReader(strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"bogus"}`,
+	}, "\n"))
+	var out bytes.Buffer
+	if err := srv.Serve(context.Background(), in, &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 responses, got %d: %s", len(lines), out.String())
+	}
+	var list struct {
+		Result struct {
+			Tools []struct {
+				Name        string `json:"name"`
+				Description string `json:"description"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &list); err != nil {
+		t.Fatalf("decode tools/list: %v", err)
+	}
+	if len(list.Result.Tools) != 1 || list.Result.Tools[0].Name != ExecuteTool {
+		t.Fatalf("unexpected tools: %s", lines[1])
+	}
+	if !strings.Contains(list.Result.Tools[0].Description, "search(query)") {
+		t.Fatalf("expected search in description: %s", list.Result.Tools[0].Description)
+	}
+	if !strings.Contains(lines[2], `"code":-32601`) {
+		t.Fatalf("expected method not found, got %s", lines[2])
+	}
+}
+
+func TestToolHandlerMapsArguments(t *testing.T) {
+	var got map[string]json.RawMessage
+	h := toolHandler(Tool{Name: "search", Params: []string{"query"}, Handler: func(_ context.Context, args map[string]json.RawMessage) (any, error) {
+		got = args
+		return nil, nil
+	}})
+	call := montyCall(`"go"`, `"limit"`, `3`)
+	if _, err := h(context.Background(), call); err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	if string(got["query"]) != `"go"` || string(got["limit"]) != "3" {
+		t.Fatalf("unexpected args: %v", got)
+	}
+}
+
+func montyCall(arg, key, value string) monty.CallInfo {
+	return monty.CallInfo{
+		Name:   "search",
+		Args:   []monty.Object{monty.Object(arg)},
+		Kwargs: []monty.KV{{Key: monty.Object(key), Value: monty.Object(value)}},
+	}
+}
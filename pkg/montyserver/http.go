@@ -0,0 +1,183 @@
+package montyserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+// maxBodyBytes bounds request bodies accepted by the HTTP handler.
+const maxBodyBytes = 64 << 20
+
+// Handler returns a JSON API over s:
+//
+//	POST   /programs             CompileRequest → CompileResponse
+//	DELETE /programs/{id}
+//	POST   /runs                 StartRequest → []Event
+//	POST   /runs/{id}/resume     ResumeRequest (run_id from the path) → []Event
+//	GET    /runs/{id}/snapshot   snapshot bytes; X-Monty-Future: 1 marks a FutureSnapshot
+//	POST   /snapshots            snapshot bytes (?future=1) → RestoreResponse
+//
+// Errors are returned as {"error": "..."} with status 404 for unknown IDs and
+// 400 otherwise.
+func Handler(s *Server) http.Handler {
+	return &httpHandler{s: s}
+}
+
+type httpHandler struct {
+	s *Server
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "programs":
+		h.method(w, r, http.MethodPost, h.compile)
+	case len(parts) == 2 && parts[0] == "programs":
+		h.method(w, r, http.MethodDelete, func(w http.ResponseWriter, r *http.Request) {
+			resp, err := h.s.DeleteProgram(r.Context(), &DeleteProgramRequest{ProgramID: parts[1]})
+			reply(w, resp, err)
+		})
+	case len(parts) == 1 && parts[0] == "runs":
+		h.method(w, r, http.MethodPost, h.start)
+	case len(parts) == 3 && parts[0] == "runs" && parts[2] == "resume":
+		h.method(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+			h.resume(w, r, parts[1])
+		})
+	case len(parts) == 3 && parts[0] == "runs" && parts[2] == "snapshot":
+		h.method(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			h.snapshot(w, r, parts[1])
+		})
+	case len(parts) == 1 && parts[0] == "snapshots":
+		h.method(w, r, http.MethodPost, h.restore)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("montyserver: no such endpoint"))
+	}
+}
+
+func (h *httpHandler) method(w http.ResponseWriter, r *http.Request, method string, fn http.HandlerFunc) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("montyserver: method not allowed"))
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	fn(w, r)
+}
+
+func (h *httpHandler) compile(w http.ResponseWriter, r *http.Request) {
+	var req CompileRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	resp, err := h.s.Compile(r.Context(), &req)
+	reply(w, resp, err)
+}
+
+func (h *httpHandler) start(w http.ResponseWriter, r *http.Request) {
+	var req StartRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	stream := &collectStream{ctx: r.Context()}
+	if err := h.s.Start(&req, stream); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, stream.events)
+}
+
+func (h *httpHandler) resume(w http.ResponseWriter, r *http.Request, runID string) {
+	var req ResumeRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	req.RunID = runID
+	stream := &collectStream{ctx: r.Context()}
+	if err := h.s.Resume(&req, stream); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, stream.events)
+}
+
+func (h *httpHandler) snapshot(w http.ResponseWriter, r *http.Request, runID string) {
+	snap, err := h.s.Snapshot(r.Context(), &SnapshotRequest{RunID: runID})
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if snap.Future {
+		w.Header().Set("X-Monty-Future", "1")
+	}
+	w.Write(snap.Data)
+}
+
+func (h *httpHandler) restore(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	future := r.URL.Query().Get("future") == "1"
+	resp, err := h.s.Restore(r.Context(), &SnapshotData{Future: future, Data: data})
+	reply(w, resp, err)
+}
+
+// reply writes resp, or err when the call failed.
+func reply(w http.ResponseWriter, resp any, err error) {
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// collectStream gathers the events of one call for a single HTTP response.
+type collectStream struct {
+	ctx    context.Context
+	events []*Event
+}
+
+func (c *collectStream) Context() context.Context { return c.ctx }
+
+func (c *collectStream) Send(ev *Event) error {
+	c.events = append(c.events, ev)
+	return nil
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+func statusFor(err error) int {
+	var internal *monty.InternalError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &internal):
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
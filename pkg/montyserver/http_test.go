@@ -0,0 +1,37 @@
+package montyserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerRouting(t *testing.T) {
+	srv := New()
+	defer srv.Close()
+	h := Handler(srv)
+
+	cases := []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodGet, "/programs", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/runs", `{"program_id":"nope"}`, http.StatusNotFound},
+		{http.MethodPost, "/runs/nope/resume", `{"call_id":1}`, http.StatusNotFound},
+		{http.MethodGet, "/runs/nope/snapshot", "", http.StatusNotFound},
+		{http.MethodDelete, "/programs/nope", "", http.StatusNotFound},
+		{http.MethodPost, "/runs", `{`, http.StatusBadRequest},
+		{http.MethodGet, "/elsewhere", "", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if rec.Code != tc.status {
+			t.Errorf("%s %s: expected %d, got %d (%s)", tc.method, tc.path, tc.status, rec.Code, rec.Body)
+		}
+		if !strings.Contains(rec.Body.String(), `"error"`) {
+			t.Errorf("%s %s: expected error body, got %s", tc.method, tc.path, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: montyserver.proto
+
+package montyv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Monty_Compile_FullMethodName       = "/monty.v1.Monty/Compile"
+	Monty_DeleteProgram_FullMethodName = "/monty.v1.Monty/DeleteProgram"
+	Monty_Start_FullMethodName         = "/monty.v1.Monty/Start"
+	Monty_Resume_FullMethodName        = "/monty.v1.Monty/Resume"
+	Monty_Snapshot_FullMethodName      = "/monty.v1.Monty/Snapshot"
+	Monty_Restore_FullMethodName       = "/monty.v1.Monty/Restore"
+)
+
+// MontyClient is the client API for Monty service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Monty compiles programs and drives runs that pause for host calls. Values
+// crossing the API (inputs, args, results) are JSON documents in bytes fields.
+type MontyClient interface {
+	Compile(ctx context.Context, in *CompileRequest, opts ...grpc.CallOption) (*CompileResponse, error)
+	DeleteProgram(ctx context.Context, in *DeleteProgramRequest, opts ...grpc.CallOption) (*DeleteProgramResponse, error)
+	// Start streams a timer event for each sleep, then the pause or result.
+	Start(ctx context.Context, in *StartRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotData, error)
+	Restore(ctx context.Context, in *SnapshotData, opts ...grpc.CallOption) (*RestoreResponse, error)
+}
+
+type montyClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMontyClient(cc grpc.ClientConnInterface) MontyClient {
+	return &montyClient{cc}
+}
+
+func (c *montyClient) Compile(ctx context.Context, in *CompileRequest, opts ...grpc.CallOption) (*CompileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompileResponse)
+	err := c.cc.Invoke(ctx, Monty_Compile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *montyClient) DeleteProgram(ctx context.Context, in *DeleteProgramRequest, opts ...grpc.CallOption) (*DeleteProgramResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteProgramResponse)
+	err := c.cc.Invoke(ctx, Monty_DeleteProgram_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *montyClient) Start(ctx context.Context, in *StartRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Monty_ServiceDesc.Streams[0], Monty_Start_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StartRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Monty_StartClient = grpc.ServerStreamingClient[Event]
+
+func (c *montyClient) Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Monty_ServiceDesc.Streams[1], Monty_Resume_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ResumeRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Monty_ResumeClient = grpc.ServerStreamingClient[Event]
+
+func (c *montyClient) Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotData, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SnapshotData)
+	err := c.cc.Invoke(ctx, Monty_Snapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *montyClient) Restore(ctx context.Context, in *SnapshotData, opts ...grpc.CallOption) (*RestoreResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RestoreResponse)
+	err := c.cc.Invoke(ctx, Monty_Restore_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MontyServer is the server API for Monty service.
+// All implementations must embed UnimplementedMontyServer
+// for forward compatibility.
+//
+// Monty compiles programs and drives runs that pause for host calls. Values
+// crossing the API (inputs, args, results) are JSON documents in bytes fields.
+type MontyServer interface {
+	Compile(context.Context, *CompileRequest) (*CompileResponse, error)
+	DeleteProgram(context.Context, *DeleteProgramRequest) (*DeleteProgramResponse, error)
+	// Start streams a timer event for each sleep, then the pause or result.
+	Start(*StartRequest, grpc.ServerStreamingServer[Event]) error
+	Resume(*ResumeRequest, grpc.ServerStreamingServer[Event]) error
+	Snapshot(context.Context, *SnapshotRequest) (*SnapshotData, error)
+	Restore(context.Context, *SnapshotData) (*RestoreResponse, error)
+	mustEmbedUnimplementedMontyServer()
+}
+
+// UnimplementedMontyServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMontyServer struct{}
+
+func (UnimplementedMontyServer) Compile(context.Context, *CompileRequest) (*CompileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Compile not implemented")
+}
+func (UnimplementedMontyServer) DeleteProgram(context.Context, *DeleteProgramRequest) (*DeleteProgramResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteProgram not implemented")
+}
+func (UnimplementedMontyServer) Start(*StartRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method Start not implemented")
+}
+func (UnimplementedMontyServer) Resume(*ResumeRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method Resume not implemented")
+}
+func (UnimplementedMontyServer) Snapshot(context.Context, *SnapshotRequest) (*SnapshotData, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Snapshot not implemented")
+}
+func (UnimplementedMontyServer) Restore(context.Context, *SnapshotData) (*RestoreResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Restore not implemented")
+}
+func (UnimplementedMontyServer) mustEmbedUnimplementedMontyServer() {}
+func (UnimplementedMontyServer) testEmbeddedByValue()               {}
+
+// UnsafeMontyServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MontyServer will
+// result in compilation errors.
+type UnsafeMontyServer interface {
+	mustEmbedUnimplementedMontyServer()
+}
+
+func RegisterMontyServer(s grpc.ServiceRegistrar, srv MontyServer) {
+	// If the following call pancis, it indicates UnimplementedMontyServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Monty_ServiceDesc, srv)
+}
+
+func _Monty_Compile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MontyServer).Compile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Monty_Compile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MontyServer).Compile(ctx, req.(*CompileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Monty_DeleteProgram_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteProgramRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MontyServer).DeleteProgram(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Monty_DeleteProgram_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MontyServer).DeleteProgram(ctx, req.(*DeleteProgramRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Monty_Start_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StartRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MontyServer).Start(m, &grpc.GenericServerStream[StartRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Monty_StartServer = grpc.ServerStreamingServer[Event]
+
+func _Monty_Resume_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ResumeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MontyServer).Resume(m, &grpc.GenericServerStream[ResumeRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Monty_ResumeServer = grpc.ServerStreamingServer[Event]
+
+func _Monty_Snapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MontyServer).Snapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Monty_Snapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MontyServer).Snapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Monty_Restore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotData)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MontyServer).Restore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Monty_Restore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MontyServer).Restore(ctx, req.(*SnapshotData))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Monty_ServiceDesc is the grpc.ServiceDesc for Monty service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Monty_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "monty.v1.Monty",
+	HandlerType: (*MontyServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Compile",
+			Handler:    _Monty_Compile_Handler,
+		},
+		{
+			MethodName: "DeleteProgram",
+			Handler:    _Monty_DeleteProgram_Handler,
+		},
+		{
+			MethodName: "Snapshot",
+			Handler:    _Monty_Snapshot_Handler,
+		},
+		{
+			MethodName: "Restore",
+			Handler:    _Monty_Restore_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Start",
+			Handler:       _Monty_Start_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Resume",
+			Handler:       _Monty_Resume_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "montyserver.proto",
+}
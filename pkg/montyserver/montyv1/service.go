@@ -0,0 +1,152 @@
+// Package montyv1 serves a montyserver.Server over gRPC. Its message and
+// service types are generated from montyserver.proto, and NewService adapts
+// them onto the server:
+//
+//	g := grpc.NewServer()
+//	montyv1.RegisterMontyServer(g, montyv1.NewService(montyserver.New(opts...)))
+//	g.Serve(lis)
+//
+// The package is a module of its own, so programs that use monty without the
+// network service do not depend on gRPC.
+package montyv1
+
+//go:generate protoc -I.. --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative montyserver.proto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+	"github.com/ricochet1k/monty-go/pkg/montyserver"
+)
+
+// service adapts a montyserver.Server to MontyServer.
+type service struct {
+	UnimplementedMontyServer
+	s *montyserver.Server
+}
+
+// NewService returns a MontyServer backed by s. Errors carry status codes:
+// NotFound for unknown program and run IDs, Canceled or DeadlineExceeded when
+// the call's context ends, Internal for failures of the interpreter itself,
+// and InvalidArgument otherwise, as with montyserver.Handler.
+func NewService(s *montyserver.Server) MontyServer {
+	return &service{s: s}
+}
+
+func (v *service) Compile(ctx context.Context, req *CompileRequest) (*CompileResponse, error) {
+	resp, err := v.s.Compile(ctx, &montyserver.CompileRequest{
+		Code:              req.GetCode(),
+		ScriptName:        req.GetScriptName(),
+		InputNames:        req.GetInputNames(),
+		ExternalFunctions: req.GetExternalFunctions(),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &CompileResponse{ProgramId: resp.ProgramID}, nil
+}
+
+func (v *service) DeleteProgram(ctx context.Context, req *DeleteProgramRequest) (*DeleteProgramResponse, error) {
+	if _, err := v.s.DeleteProgram(ctx, &montyserver.DeleteProgramRequest{ProgramID: req.GetProgramId()}); err != nil {
+		return nil, statusError(err)
+	}
+	return &DeleteProgramResponse{}, nil
+}
+
+func (v *service) Start(req *StartRequest, stream grpc.ServerStreamingServer[Event]) error {
+	return statusError(v.s.Start(&montyserver.StartRequest{ProgramID: req.GetProgramId(), Inputs: rawValues(req.GetInputs())}, eventStream{stream}))
+}
+
+func (v *service) Resume(req *ResumeRequest, stream grpc.ServerStreamingServer[Event]) error {
+	in := &montyserver.ResumeRequest{
+		RunID:   req.GetRunId(),
+		CallID:  req.GetCallId(),
+		Result:  rawValue(req.GetResult()),
+		Error:   req.GetError(),
+		Pending: req.GetPending(),
+	}
+	for _, f := range req.GetFutures() {
+		in.Futures = append(in.Futures, montyserver.FutureResult{CallID: f.GetCallId(), Result: rawValue(f.GetResult()), Error: f.GetError()})
+	}
+	return statusError(v.s.Resume(in, eventStream{stream}))
+}
+
+func (v *service) Snapshot(ctx context.Context, req *SnapshotRequest) (*SnapshotData, error) {
+	data, err := v.s.Snapshot(ctx, &montyserver.SnapshotRequest{RunID: req.GetRunId()})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &SnapshotData{Future: data.Future, Data: data.Data}, nil
+}
+
+func (v *service) Restore(ctx context.Context, req *SnapshotData) (*RestoreResponse, error) {
+	resp, err := v.s.Restore(ctx, &montyserver.SnapshotData{Future: req.GetFuture(), Data: req.GetData()})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &RestoreResponse{RunId: resp.RunID}, nil
+}
+
+// eventStream converts the events of a Start or Resume call.
+type eventStream struct {
+	grpc.ServerStreamingServer[Event]
+}
+
+func (s eventStream) Send(ev *montyserver.Event) error {
+	out := &Event{
+		RunId:          ev.RunID,
+		Kind:           ev.Kind,
+		Result:         ev.Result,
+		FunctionName:   ev.FunctionName,
+		OsFunction:     ev.OsFunction,
+		CallId:         ev.CallID,
+		MethodCall:     ev.MethodCall,
+		PendingCallIds: ev.PendingCallIDs,
+		DurationMs:     ev.DurationMillis,
+	}
+	for _, arg := range ev.Args {
+		out.Args = append(out.Args, arg)
+	}
+	for _, kv := range ev.Kwargs {
+		out.Kwargs = append(out.Kwargs, &KV{Key: kv.Key, Value: kv.Value})
+	}
+	return s.ServerStreamingServer.Send(out)
+}
+
+// rawValue returns a JSON value from a bytes field, nil when it is empty.
+func rawValue(b []byte) json.RawMessage {
+	if len(b) == 0 {
+		return nil
+	}
+	return json.RawMessage(b)
+}
+
+func rawValues(list [][]byte) []json.RawMessage {
+	out := make([]json.RawMessage, len(list))
+	for i, b := range list {
+		out[i] = json.RawMessage(b)
+	}
+	return out
+}
+
+// statusError gives err a gRPC status code.
+func statusError(err error) error {
+	var internal *monty.InternalError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, montyserver.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.As(err, &internal):
+		return status.Error(codes.Internal, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
@@ -0,0 +1,119 @@
+package montyv1
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+	"github.com/ricochet1k/monty-go/pkg/montyserver"
+)
+
+// callBridge is a sandbox bridge whose programs call name once with call ID 1
+// and complete with the answer.
+func callBridge(name string) *monty.Sandbox {
+	return monty.NewSandboxBridge(func(data []byte) ([]byte, error) {
+		var req struct {
+			Op      string          `json:"op"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		json.Unmarshal(data, &req)
+		switch req.Op {
+		case "compile":
+			return []byte(`{"handle":1}`), nil
+		case "start":
+			return []byte(`{"progress":{"kind":1,"call_id":1,"function_name":"` + name + `","args":[5],"kwargs":[["k",true]],"snapshot":2}}`), nil
+		case "resume":
+			return json.Marshal(map[string]any{"progress": map[string]any{"kind": 0, "result": req.Payload}})
+		}
+		return []byte(`{}`), nil
+	})
+}
+
+func dial(t *testing.T, srv *montyserver.Server) MontyClient {
+	lis := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	RegisterMontyServer(g, NewService(srv))
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewMontyClient(conn)
+}
+
+// last returns the final event of a stream.
+func last(t *testing.T, stream grpc.ServerStreamingClient[Event], err error) *Event {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ev *Event
+	for {
+		next, err := stream.Recv()
+		if err == io.EOF {
+			return ev
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ev = next
+	}
+}
+
+func TestServiceStartResume(t *testing.T) {
+	srv := montyserver.New(monty.WithSandbox(callBridge("add_one")))
+	defer srv.Close()
+	client := dial(t, srv)
+	ctx := context.Background()
+
+	compiled, err := client.Compile(ctx, &CompileRequest{Code: "add_one(5, k=True)", ScriptName: "test.py", ExternalFunctions: []string{"add_one"}})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	stream, err := client.Start(ctx, &StartRequest{ProgramId: compiled.ProgramId})
+	call := last(t, stream, err)
+	if call.GetKind() != montyserver.KindFunctionCall || call.GetFunctionName() != "add_one" || call.GetRunId() == "" ||
+		len(call.GetArgs()) != 1 || string(call.GetArgs()[0]) != "5" || len(call.GetKwargs()) != 1 || string(call.GetKwargs()[0].GetKey()) != `"k"` {
+		t.Fatalf("unexpected event: %v", call)
+	}
+
+	stream, err = client.Resume(ctx, &ResumeRequest{RunId: call.GetRunId(), CallId: call.GetCallId(), Result: []byte("6")})
+	done := last(t, stream, err)
+	if done.GetKind() != montyserver.KindComplete || string(done.GetResult()) != "6" {
+		t.Fatalf("unexpected completion: %v", done)
+	}
+}
+
+func TestServiceStatusCodes(t *testing.T) {
+	srv := montyserver.New(monty.WithSandbox(callBridge("add_one")))
+	defer srv.Close()
+	client := dial(t, srv)
+	ctx := context.Background()
+
+	stream, err := client.Resume(ctx, &ResumeRequest{RunId: "nope"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Resume of an unknown run: expected NotFound, got %v", err)
+	}
+	if _, err := client.Snapshot(ctx, &SnapshotRequest{RunId: "nope"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("Snapshot of an unknown run: expected NotFound, got %v", err)
+	}
+	if _, err := client.DeleteProgram(ctx, &DeleteProgramRequest{ProgramId: "nope"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("DeleteProgram of an unknown program: expected NotFound, got %v", err)
+	}
+}
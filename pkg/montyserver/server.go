@@ -0,0 +1,364 @@
+// Package montyserver exposes compiled programs and paused runs as a network
+// service. Server implements the RPCs declared in montyserver.proto using Go
+// message types that mirror the proto messages field for field; package
+// montyv1 serves it over gRPC, and Handler over a JSON API.
+package montyserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+// ErrNotFound is returned for unknown program and run IDs.
+var ErrNotFound = errors.New("montyserver: not found")
+
+// CompileRequest compiles a program.
+type CompileRequest struct {
+	Code              string   `json:"code"`
+	ScriptName        string   `json:"script_name"`
+	InputNames        []string `json:"input_names,omitempty"`
+	ExternalFunctions []string `json:"external_functions,omitempty"`
+}
+
+// CompileResponse names the compiled program.
+type CompileResponse struct {
+	ProgramID string `json:"program_id"`
+}
+
+// DeleteProgramRequest names a program to release.
+type DeleteProgramRequest struct {
+	ProgramID string `json:"program_id"`
+}
+
+// DeleteProgramResponse is empty.
+type DeleteProgramResponse struct{}
+
+// StartRequest starts a run of a compiled program.
+type StartRequest struct {
+	ProgramID string            `json:"program_id"`
+	Inputs    []json.RawMessage `json:"inputs,omitempty"`
+}
+
+// ResumeRequest answers the call a paused run is waiting on. Exactly one of
+// Result, Error, Pending, or Futures applies, matching the pause kind.
+type ResumeRequest struct {
+	RunID  string          `json:"run_id"`
+	CallID uint32          `json:"call_id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	// Pending leaves the call unresolved so the script receives a future.
+	Pending bool `json:"pending,omitempty"`
+	// Futures resolves a run paused on ResolveFutures.
+	Futures []FutureResult `json:"futures,omitempty"`
+}
+
+// FutureResult resolves one pending call; leave both fields empty to keep waiting.
+type FutureResult struct {
+	CallID uint32          `json:"call_id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// SnapshotRequest names a paused run.
+type SnapshotRequest struct {
+	RunID string `json:"run_id"`
+}
+
+// SnapshotData is the serialized state of a paused run.
+type SnapshotData struct {
+	// Future is set when Data is a FutureSnapshot.
+	Future bool   `json:"future,omitempty"`
+	Data   []byte `json:"data"`
+}
+
+// RestoreResponse names the run created from SnapshotData.
+type RestoreResponse struct {
+	RunID string `json:"run_id"`
+}
+
+// KV is a keyword argument.
+type KV struct {
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Event reports one progress step of a run. Every event except a completion
+// or a timer leaves the run paused until Resume is called with its CallID.
+type Event struct {
+	RunID          string            `json:"run_id,omitempty"`
+	Kind           string            `json:"kind"`
+	Result         json.RawMessage   `json:"result,omitempty"`
+	FunctionName   string            `json:"function_name,omitempty"`
+	OsFunction     string            `json:"os_function,omitempty"`
+	Args           []json.RawMessage `json:"args,omitempty"`
+	Kwargs         []KV              `json:"kwargs,omitempty"`
+	CallID         uint32            `json:"call_id,omitempty"`
+	MethodCall     bool              `json:"method_call,omitempty"`
+	PendingCallIDs []uint32          `json:"pending_call_ids,omitempty"`
+	// DurationMillis is how long a timer event sleeps before the run continues.
+	DurationMillis int64 `json:"duration_ms,omitempty"`
+}
+
+// Event kinds.
+const (
+	KindComplete       = "complete"
+	KindFunctionCall   = "function_call"
+	KindOsCall         = "os_call"
+	KindResolveFutures = "resolve_futures"
+	KindTimer          = "timer"
+)
+
+// EventStream receives the events of a Start or Resume call.
+type EventStream interface {
+	Context() context.Context
+	Send(*Event) error
+}
+
+// Server holds compiled programs and paused runs in memory.
+type Server struct {
+	opts []monty.Option
+
+	mu       sync.Mutex
+	programs map[string]*monty.Monty
+	runs     map[string]*pausedRun
+}
+
+// pausedRun holds whichever snapshot a run is waiting on.
+type pausedRun struct {
+	snapshot *monty.Snapshot
+	future   *monty.FutureSnapshot
+}
+
+func (p *pausedRun) close() {
+	p.snapshot.Close()
+	p.future.Close()
+}
+
+// consumed reports whether resuming the run used up its snapshot.
+func (p *pausedRun) consumed() bool {
+	if p.future != nil {
+		return p.future.IsClosed()
+	}
+	return p.snapshot.IsClosed()
+}
+
+// New returns a Server that compiles and restores programs with opts.
+func New(opts ...monty.Option) *Server {
+	return &Server{
+		opts:     opts,
+		programs: make(map[string]*monty.Monty),
+		runs:     make(map[string]*pausedRun),
+	}
+}
+
+// Close releases every program and paused run.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, m := range s.programs {
+		m.Close()
+		delete(s.programs, id)
+	}
+	for id, r := range s.runs {
+		r.close()
+		delete(s.runs, id)
+	}
+}
+
+// Compile compiles a program and keeps it for Start.
+func (s *Server) Compile(ctx context.Context, req *CompileRequest) (*CompileResponse, error) {
+	m, err := monty.New(req.Code, req.ScriptName, req.InputNames, req.ExternalFunctions, s.opts...)
+	if err != nil {
+		return nil, err
+	}
+	id := newID()
+	s.mu.Lock()
+	s.programs[id] = m
+	s.mu.Unlock()
+	return &CompileResponse{ProgramID: id}, nil
+}
+
+// DeleteProgram releases a compiled program. Paused runs are unaffected.
+func (s *Server) DeleteProgram(ctx context.Context, req *DeleteProgramRequest) (*DeleteProgramResponse, error) {
+	s.mu.Lock()
+	m, ok := s.programs[req.ProgramID]
+	delete(s.programs, req.ProgramID)
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: program %q", ErrNotFound, req.ProgramID)
+	}
+	m.Close()
+	return &DeleteProgramResponse{}, nil
+}
+
+// Start runs a program until it completes or pauses, sending an event for
+// each timer it sleeps through and a final event for the pause or result.
+func (s *Server) Start(req *StartRequest, stream EventStream) error {
+	s.mu.Lock()
+	m, ok := s.programs[req.ProgramID]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: program %q", ErrNotFound, req.ProgramID)
+	}
+	inputs := make([]any, len(req.Inputs))
+	for i, in := range req.Inputs {
+		inputs[i] = in
+	}
+	progress, err := m.Start(inputs...)
+	if err != nil {
+		return err
+	}
+	return s.drive(newID(), progress, stream)
+}
+
+// Resume answers a paused run and streams events as Start does. While it
+// runs, the run is taken off the server so it is not resumed twice; an answer
+// the run rejects before continuing, such as a result that cannot be
+// encoded, leaves it paused to be resumed again.
+func (s *Server) Resume(req *ResumeRequest, stream EventStream) error {
+	s.mu.Lock()
+	paused, ok := s.runs[req.RunID]
+	delete(s.runs, req.RunID)
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: run %q", ErrNotFound, req.RunID)
+	}
+
+	var progress monty.Progress
+	var err error
+	switch {
+	case paused.future != nil:
+		results := make([]monty.FutureResult, len(req.Futures))
+		for i, f := range req.Futures {
+			results[i] = monty.FutureResult{CallID: f.CallID, Err: f.Error}
+			if len(f.Result) > 0 {
+				results[i].Result = f.Result
+			}
+		}
+		progress, err = paused.future.Resume(results)
+	case req.Error != "":
+		progress, err = paused.snapshot.ResumeError(req.CallID, req.Error)
+	case req.Pending:
+		progress, _, err = paused.snapshot.ResumeFuture(req.CallID)
+	default:
+		result := req.Result
+		if len(result) == 0 {
+			result = json.RawMessage("null")
+		}
+		progress, err = paused.snapshot.Resume(req.CallID, result)
+	}
+	if err != nil {
+		if !paused.consumed() {
+			s.mu.Lock()
+			s.runs[req.RunID] = paused
+			s.mu.Unlock()
+		}
+		return err
+	}
+	return s.drive(req.RunID, progress, stream)
+}
+
+// Snapshot serializes a paused run without resuming it.
+func (s *Server) Snapshot(ctx context.Context, req *SnapshotRequest) (*SnapshotData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paused, ok := s.runs[req.RunID]
+	if !ok {
+		return nil, fmt.Errorf("%w: run %q", ErrNotFound, req.RunID)
+	}
+	if paused.future != nil {
+		data, err := paused.future.Dump()
+		return &SnapshotData{Future: true, Data: data}, err
+	}
+	data, err := paused.snapshot.Dump()
+	return &SnapshotData{Data: data}, err
+}
+
+// Restore loads a serialized run so it can be resumed on this server.
+func (s *Server) Restore(ctx context.Context, req *SnapshotData) (*RestoreResponse, error) {
+	paused := &pausedRun{}
+	var err error
+	if req.Future {
+		paused.future, err = monty.FutureSnapshotFromBytes(req.Data, s.opts...)
+	} else {
+		paused.snapshot, err = monty.SnapshotFromBytes(req.Data, s.opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	id := newID()
+	s.mu.Lock()
+	s.runs[id] = paused
+	s.mu.Unlock()
+	return &RestoreResponse{RunID: id}, nil
+}
+
+// drive sends progress to stream, sleeping through timers, and parks the run
+// under runID when it pauses for the client.
+func (s *Server) drive(runID string, progress monty.Progress, stream EventStream) error {
+	ctx := stream.Context()
+	for progress.Kind == monty.Timer {
+		if err := stream.Send(event(runID, progress)); err != nil {
+			progress.Snapshot.Close()
+			return err
+		}
+		timer := time.NewTimer(progress.Duration)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			progress.Snapshot.Close()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		var err error
+		if progress, err = progress.Snapshot.Wake(progress.CallID); err != nil {
+			return err
+		}
+	}
+	if progress.Kind != monty.Complete {
+		s.mu.Lock()
+		s.runs[runID] = &pausedRun{snapshot: progress.Snapshot, future: progress.FutureSnapshot}
+		s.mu.Unlock()
+	}
+	return stream.Send(event(runID, progress))
+}
+
+func event(runID string, p monty.Progress) *Event {
+	ev := &Event{
+		RunID:          runID,
+		Kind:           p.Kind.String(),
+		Result:         json.RawMessage(p.Result),
+		FunctionName:   p.FunctionName,
+		OsFunction:     p.OsFunction,
+		CallID:         p.CallID,
+		MethodCall:     p.MethodCall,
+		PendingCallIDs: p.PendingIDs,
+		DurationMillis: p.Duration.Milliseconds(),
+	}
+	for _, arg := range p.Args {
+		ev.Args = append(ev.Args, json.RawMessage(arg))
+	}
+	for _, kv := range p.Kwargs {
+		ev.Kwargs = append(ev.Kwargs, KV{Key: json.RawMessage(kv.Key), Value: json.RawMessage(kv.Value)})
+	}
+	if p.Kind == monty.Complete {
+		ev.RunID = ""
+	}
+	return ev
+}
+
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
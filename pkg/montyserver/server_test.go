@@ -0,0 +1,125 @@
+package montyserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ricochet1k/monty-go/pkg/monty"
+)
+
+type recordStream struct {
+	events []*Event
+}
+
+func (s *recordStream) Context() context.Context { return context.Background() }
+
+func (s *recordStream) Send(ev *Event) error {
+	s.events = append(s.events, ev)
+	return nil
+}
+
+func TestServerUnknownIDs(t *testing.T) {
+	srv := New()
+	defer srv.Close()
+	if err := srv.Start(&StartRequest{ProgramID: "nope"}, &recordStream{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Start: expected ErrNotFound, got %v", err)
+	}
+	if err := srv.Resume(&ResumeRequest{RunID: "nope"}, &recordStream{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Resume: expected ErrNotFound, got %v", err)
+	}
+	if _, err := srv.Snapshot(context.Background(), &SnapshotRequest{RunID: "nope"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Snapshot: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestServerStartResume(t *testing.T) {
+	srv := New()
+	defer srv.Close()
+	ctx := context.Background()
+	compiled, err := srv.Compile(ctx, &CompileRequest{
+		Code:              "add_one(x)",
+		ScriptName:        "test.py",
+		InputNames:        []string{"x"},
+		ExternalFunctions: []string{"add_one"},
+	})
+	if errors.Is(err, monty.ErrUnavailable) {
+		t.Skip("native library unavailable")
+	}
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	stream := &recordStream{}
+	if err := srv.Start(&StartRequest{ProgramID: compiled.ProgramID, Inputs: []json.RawMessage{json.RawMessage("5")}}, stream); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	call := stream.events[len(stream.events)-1]
+	if call.Kind != KindFunctionCall || call.FunctionName != "add_one" || call.RunID == "" {
+		t.Fatalf("unexpected event: %+v", call)
+	}
+
+	snap, err := srv.Snapshot(ctx, &SnapshotRequest{RunID: call.RunID})
+	if err != nil || len(snap.Data) == 0 {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	stream = &recordStream{}
+	if err := srv.Resume(&ResumeRequest{RunID: call.RunID, CallID: call.CallID, Result: json.RawMessage("6")}, stream); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	done := stream.events[len(stream.events)-1]
+	if done.Kind != KindComplete || string(done.Result) != "6" {
+		t.Fatalf("unexpected completion: %+v", done)
+	}
+}
+
+// callBridge is a sandbox bridge whose programs call name once with call ID 1
+// and complete with the answer.
+func callBridge(name string) *monty.Sandbox {
+	return monty.NewSandboxBridge(func(data []byte) ([]byte, error) {
+		var req struct {
+			Op      string          `json:"op"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		json.Unmarshal(data, &req)
+		switch req.Op {
+		case "compile":
+			return []byte(`{"handle":1}`), nil
+		case "start":
+			return []byte(`{"progress":{"kind":1,"call_id":1,"function_name":"` + name + `","args":[5],"kwargs":[],"snapshot":2}}`), nil
+		case "resume":
+			return json.Marshal(map[string]any{"progress": map[string]any{"kind": 0, "result": req.Payload}})
+		}
+		return []byte(`{}`), nil
+	})
+}
+
+func TestServerResumeKeepsRunOnRejectedAnswer(t *testing.T) {
+	srv := New(monty.WithSandbox(callBridge("add_one")))
+	defer srv.Close()
+	compiled, err := srv.Compile(context.Background(), &CompileRequest{Code: "add_one(5)", ScriptName: "test.py", ExternalFunctions: []string{"add_one"}})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	stream := &recordStream{}
+	if err := srv.Start(&StartRequest{ProgramID: compiled.ProgramID}, stream); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	call := stream.events[len(stream.events)-1]
+
+	if err := srv.Resume(&ResumeRequest{RunID: call.RunID, CallID: call.CallID, Result: json.RawMessage("{bad")}, &recordStream{}); err == nil {
+		t.Fatal("expected the malformed result to be rejected")
+	}
+	stream = &recordStream{}
+	if err := srv.Resume(&ResumeRequest{RunID: call.RunID, CallID: call.CallID, Result: json.RawMessage("6")}, stream); err != nil {
+		t.Fatalf("Resume after a rejected answer failed: %v", err)
+	}
+	if done := stream.events[len(stream.events)-1]; done.Kind != KindComplete || string(done.Result) != "6" {
+		t.Fatalf("unexpected completion: %+v", done)
+	}
+	if err := srv.Resume(&ResumeRequest{RunID: call.RunID, CallID: call.CallID}, &recordStream{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("resuming a finished run: expected ErrNotFound, got %v", err)
+	}
+}